@@ -50,6 +50,11 @@ func (a *App) Run() error {
 		fmt.Printf("(err == http.ErrServerClosed): %v\n", (err == http.ErrServerClosed))
 		a.serviceProvider.Logger(context.Background()).Error("error shutting down the server", zap.Error(err))
 	}
+	a.serviceProvider.ChatService(context.Background()).Stop()
+	a.serviceProvider.WebhookService(context.Background()).Stop()
+	if err := a.serviceProvider.Broker(context.Background()).Close(); err != nil {
+		a.serviceProvider.Logger(context.Background()).Error("error closing the broker", zap.Error(err))
+	}
 	a.serviceProvider.Logger(context.Background()).Info("server shut down successfully")
 
 	return nil
@@ -78,7 +83,18 @@ func (a *App) initServiceProvider(ctx context.Context) error {
 }
 
 func (a *App) initHttpServer(ctx context.Context) error {
-	muxRouter := routes.InitRoutes(a.serviceProvider.ChatController(ctx))
+	if a.serviceProvider.AuthConfig().AllowUserHeader() {
+		a.serviceProvider.Logger(ctx).Warn("AUTH_ALLOW_USER_HEADER is on: requests without a token can act as any user through the X-User-ID header")
+	}
+
+	muxRouter := routes.InitRoutes(
+		a.serviceProvider.ChatController(ctx),
+		a.serviceProvider.AuthController(ctx),
+		a.serviceProvider.WebhookController(ctx),
+		a.serviceProvider.AuthService(ctx),
+		a.serviceProvider.AuthConfig(),
+		a.serviceProvider.AdminConfig(),
+	)
 
 	a.chatServer = &http.Server{
 		Addr:           a.serviceProvider.HttpConfig().Address(),
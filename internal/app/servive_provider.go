@@ -1,35 +1,81 @@
 package app
 
 import (
+	"chatsrv/internal/broker"
 	"chatsrv/internal/config"
 	"chatsrv/internal/config/env"
 	"chatsrv/internal/controller"
+	authctrl "chatsrv/internal/controller/auth"
 	chatctrl "chatsrv/internal/controller/chat"
+	webhookctrl "chatsrv/internal/controller/webhook"
+	"chatsrv/internal/presence"
 	"chatsrv/internal/repository"
 	chatrepository "chatsrv/internal/repository/chat"
+	idempotencyrepository "chatsrv/internal/repository/idempotency"
+	inviterepository "chatsrv/internal/repository/invite"
+	messagerepository "chatsrv/internal/repository/message"
+	userrepository "chatsrv/internal/repository/user"
+	webhookrepository "chatsrv/internal/repository/webhook"
 	"chatsrv/internal/service"
+	authsrv "chatsrv/internal/service/auth"
 	chatsrv "chatsrv/internal/service/chat"
+	webhooksrv "chatsrv/internal/service/webhook"
 	"context"
 	"database/sql"
 	"os"
 
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/jackc/pgx/v5/stdlib"
+	"github.com/redis/go-redis/v9"
 	"go.uber.org/zap"
 )
 
 type serviceProvider struct {
 	logger *zap.Logger
 
-	pgConfig config.PGConfig
-	httpCfg  config.HttpConfig
+	pgConfig    config.PGConfig
+	httpCfg     config.HttpConfig
+	adminCfg    config.AdminConfig
+	webhookCfg  config.WebhookConfig
+	incomingCfg config.IncomingWebhookConfig
+	chatCfg     config.ChatConfig
+	authCfg     config.AuthConfig
+	oidcCfg     config.OIDCConfig
+	limitCfg    config.RateLimitConfig
+	beatCfg     config.HeartbeatConfig
+	outCfg      config.OutboundConfig
+	brokerCfg   config.BrokerConfig
+	redisCfg    config.RedisConfig
+	presenceCfg config.PresenceConfig
 
-	db   *sql.DB
-	pool *pgxpool.Pool
+	db       *sql.DB
+	pool     *pgxpool.Pool
+	redis    *redis.Client
+	broker   broker.Broker
+	presence presence.Registry
 
-	chatImpl controller.ChatController
-	chatSrv  service.ChatService
-	chatRepo repository.ChatRepository
+	chatImpl   controller.ChatController
+	chatSrv    service.ChatService
+	chatRepo   repository.ChatRepository
+	memberRepo repository.MemberRepository
+	modRepo    repository.ModerationRepository
+	msgRepo    repository.MessageRepository
+	inviteRepo repository.InviteRepository
+	idemRepo   repository.IdempotencyRepository
+
+	authImpl    controller.AuthController
+	authSrv     service.AuthService
+	userRepo    repository.UserRepository
+	sessionRepo repository.SessionRepository
+	apiKeyRepo  repository.APIKeyRepository
+	oidcRepo    repository.OIDCRepository
+
+	webhookImpl controller.WebhookController
+	webhookSrv  service.WebhookService
+	webhookRepo repository.WebhookRepository
+
+	incomingSrv  service.IncomingWebhookService
+	incomingRepo repository.IncomingWebhookRepository
 }
 
 func newServiceProvider() *serviceProvider {
@@ -43,6 +89,96 @@ func (sp *serviceProvider) HttpConfig() config.HttpConfig {
 	return sp.httpCfg
 }
 
+func (sp *serviceProvider) AdminConfig() config.AdminConfig {
+	if sp.adminCfg == nil {
+		sp.adminCfg = env.NewAdminConfig()
+	}
+	return sp.adminCfg
+}
+
+func (sp *serviceProvider) ChatConfig() config.ChatConfig {
+	if sp.chatCfg == nil {
+		sp.chatCfg = env.NewChatConfig()
+	}
+	return sp.chatCfg
+}
+
+func (sp *serviceProvider) RateLimitConfig() config.RateLimitConfig {
+	if sp.limitCfg == nil {
+		sp.limitCfg = env.NewRateLimitConfig()
+	}
+	return sp.limitCfg
+}
+
+func (sp *serviceProvider) HeartbeatConfig() config.HeartbeatConfig {
+	if sp.beatCfg == nil {
+		sp.beatCfg = env.NewHeartbeatConfig()
+	}
+	return sp.beatCfg
+}
+
+func (sp *serviceProvider) OutboundConfig() config.OutboundConfig {
+	if sp.outCfg == nil {
+		sp.outCfg = env.NewOutboundConfig()
+	}
+	return sp.outCfg
+}
+
+func (sp *serviceProvider) BrokerConfig() config.BrokerConfig {
+	if sp.brokerCfg == nil {
+		sp.brokerCfg = env.NewBrokerConfig()
+	}
+	return sp.brokerCfg
+}
+
+func (sp *serviceProvider) PresenceConfig() config.PresenceConfig {
+	if sp.presenceCfg == nil {
+		sp.presenceCfg = env.NewPresenceConfig()
+	}
+	return sp.presenceCfg
+}
+
+func (sp *serviceProvider) RedisConfig() config.RedisConfig {
+	if sp.redisCfg == nil {
+		sp.redisCfg = env.NewRedisConfig()
+	}
+	return sp.redisCfg
+}
+
+func (sp *serviceProvider) AuthConfig() config.AuthConfig {
+	if sp.authCfg == nil {
+		sp.authCfg = env.NewAuthConfig()
+	}
+	return sp.authCfg
+}
+
+func (sp *serviceProvider) OIDCConfig() config.OIDCConfig {
+	if sp.oidcCfg == nil {
+		cfg, err := env.NewOIDCConfig()
+		if err != nil {
+			sp.Logger(context.Background()).Error("failed to get oidc config", zap.Error(err))
+			os.Exit(1)
+		}
+
+		sp.oidcCfg = cfg
+	}
+	return sp.oidcCfg
+}
+
+func (sp *serviceProvider) WebhookConfig() config.WebhookConfig {
+	if sp.webhookCfg == nil {
+		sp.webhookCfg = env.NewWebhookConfig()
+	}
+	return sp.webhookCfg
+}
+
+func (sp *serviceProvider) IncomingWebhookConfig() config.IncomingWebhookConfig {
+	if sp.incomingCfg == nil {
+		sp.incomingCfg = env.NewIncomingWebhookConfig()
+	}
+	return sp.incomingCfg
+}
+
 func (s *serviceProvider) PGConfig() config.PGConfig {
 	if s.pgConfig == nil {
 		cfg, err := env.NewPGConfig()
@@ -65,13 +201,154 @@ func (s *serviceProvider) ChatRepository(ctx context.Context) repository.ChatRep
 	return s.chatRepo
 }
 
+func (s *serviceProvider) MemberRepository(ctx context.Context) repository.MemberRepository {
+	if s.memberRepo == nil {
+		s.memberRepo = chatrepository.NewMemberRepository(s.DBClient(ctx))
+	}
+
+	return s.memberRepo
+}
+
+func (s *serviceProvider) ModerationRepository(ctx context.Context) repository.ModerationRepository {
+	if s.modRepo == nil {
+		s.modRepo = chatrepository.NewModerationRepository(s.DBClient(ctx))
+	}
+
+	return s.modRepo
+}
+
+func (s *serviceProvider) InviteRepository(ctx context.Context) repository.InviteRepository {
+	if s.inviteRepo == nil {
+		s.inviteRepo = inviterepository.NewInviteRepository(s.DBClient(ctx))
+	}
+
+	return s.inviteRepo
+}
+
+func (s *serviceProvider) IdempotencyRepository(ctx context.Context) repository.IdempotencyRepository {
+	if s.idemRepo == nil {
+		s.idemRepo = idempotencyrepository.NewIdempotencyRepository(s.DBClient(ctx))
+	}
+
+	return s.idemRepo
+}
+
+func (s *serviceProvider) UserRepository(ctx context.Context) repository.UserRepository {
+	if s.userRepo == nil {
+		s.userRepo = userrepository.NewUserRepository(s.DBClient(ctx))
+	}
+
+	return s.userRepo
+}
+
+func (s *serviceProvider) SessionRepository(ctx context.Context) repository.SessionRepository {
+	if s.sessionRepo == nil {
+		s.sessionRepo = userrepository.NewSessionRepository(s.DBClient(ctx))
+	}
+
+	return s.sessionRepo
+}
+
+func (s *serviceProvider) APIKeyRepository(ctx context.Context) repository.APIKeyRepository {
+	if s.apiKeyRepo == nil {
+		s.apiKeyRepo = userrepository.NewAPIKeyRepository(s.DBClient(ctx))
+	}
+
+	return s.apiKeyRepo
+}
+
+func (s *serviceProvider) OIDCRepository(ctx context.Context) repository.OIDCRepository {
+	if s.oidcRepo == nil {
+		s.oidcRepo = userrepository.NewOIDCRepository(s.DBClient(ctx))
+	}
+
+	return s.oidcRepo
+}
+
+func (s *serviceProvider) MessageRepository(ctx context.Context) repository.MessageRepository {
+	if s.msgRepo == nil {
+		s.msgRepo = messagerepository.NewMessageRepository(s.DBClient(ctx))
+	}
+
+	return s.msgRepo
+}
+
+func (s *serviceProvider) IncomingWebhookRepository(ctx context.Context) repository.IncomingWebhookRepository {
+	if s.incomingRepo == nil {
+		s.incomingRepo = webhookrepository.NewIncomingWebhookRepository(s.DBClient(ctx))
+	}
+
+	return s.incomingRepo
+}
+
+func (s *serviceProvider) WebhookRepository(ctx context.Context) repository.WebhookRepository {
+	if s.webhookRepo == nil {
+		s.webhookRepo = webhookrepository.NewWebhookRepository(s.DBClient(ctx))
+	}
+
+	return s.webhookRepo
+}
+
+func (sp *serviceProvider) WebhookService(ctx context.Context) service.WebhookService {
+	if sp.webhookSrv == nil {
+		sp.webhookSrv = webhooksrv.NewWebhookService(sp.WebhookRepository(ctx), sp.WebhookConfig(), sp.Logger(ctx))
+	}
+	return sp.webhookSrv
+}
+
+func (sp *serviceProvider) AuthService(ctx context.Context) service.AuthService {
+	if sp.authSrv == nil {
+		sp.authSrv = authsrv.NewAuthService(
+			sp.UserRepository(ctx),
+			sp.SessionRepository(ctx),
+			sp.APIKeyRepository(ctx),
+			sp.OIDCRepository(ctx),
+			sp.ChatService(ctx),
+			sp.AuthConfig(),
+			sp.OIDCConfig(),
+			sp.Logger(ctx),
+		)
+	}
+	return sp.authSrv
+}
+
 func (sp *serviceProvider) ChatService(ctx context.Context) service.ChatService {
 	if sp.chatSrv == nil {
-		sp.chatSrv = chatsrv.NewChatService(sp.ChatRepository(ctx), sp.Logger(ctx))
+		sp.chatSrv = chatsrv.NewChatService(
+			sp.ChatRepository(ctx),
+			sp.MemberRepository(ctx),
+			sp.ModerationRepository(ctx),
+			sp.MessageRepository(ctx),
+			sp.InviteRepository(ctx),
+			sp.IdempotencyRepository(ctx),
+			sp.UserRepository(ctx),
+			sp.WebhookService(ctx),
+			sp.Broker(ctx),
+			sp.Presence(ctx),
+			sp.ChatConfig(),
+			sp.RateLimitConfig(),
+			sp.HeartbeatConfig(),
+			sp.OutboundConfig(),
+			sp.PresenceConfig(),
+			sp.Logger(ctx),
+		)
 	}
 	return sp.chatSrv
 }
 
+func (sp *serviceProvider) IncomingWebhookService(ctx context.Context) service.IncomingWebhookService {
+	if sp.incomingSrv == nil {
+		sp.incomingSrv = webhooksrv.NewIncomingWebhookService(
+			sp.IncomingWebhookRepository(ctx),
+			sp.ChatRepository(ctx),
+			sp.ChatService(ctx),
+			sp.IncomingWebhookConfig(),
+			sp.Logger(ctx),
+		)
+	}
+	return sp.incomingSrv
+}
+
 func (sp *serviceProvider) ChatController(ctx context.Context) controller.ChatController {
 	if sp.chatImpl == nil {
 		sp.chatImpl = chatctrl.NewChatController(
@@ -82,6 +359,27 @@ func (sp *serviceProvider) ChatController(ctx context.Context) controller.ChatCo
 	return sp.chatImpl
 }
 
+func (sp *serviceProvider) AuthController(ctx context.Context) controller.AuthController {
+	if sp.authImpl == nil {
+		sp.authImpl = authctrl.NewAuthController(
+			authctrl.WithLogger(sp.Logger(ctx)),
+			authctrl.WithService(sp.AuthService(ctx)),
+		)
+	}
+	return sp.authImpl
+}
+
+func (sp *serviceProvider) WebhookController(ctx context.Context) controller.WebhookController {
+	if sp.webhookImpl == nil {
+		sp.webhookImpl = webhookctrl.NewWebhookController(
+			webhookctrl.WithLogger(sp.Logger(ctx)),
+			webhookctrl.WithService(sp.WebhookService(ctx)),
+			webhookctrl.WithIncomingService(sp.IncomingWebhookService(ctx)),
+		)
+	}
+	return sp.webhookImpl
+}
+
 func (sp *serviceProvider) Logger(ctx context.Context) *zap.Logger {
 	if sp.logger == nil {
 		logger := zap.New(getCore(getAtomicLevel()))
@@ -91,6 +389,12 @@ func (sp *serviceProvider) Logger(ctx context.Context) *zap.Logger {
 	return sp.logger
 }
 
+// PGPool returns the pool behind DBClient, for what needs pgx itself.
+func (s *serviceProvider) PGPool(ctx context.Context) *pgxpool.Pool {
+	s.DBClient(ctx)
+	return s.pool
+}
+
 func (s *serviceProvider) DBClient(ctx context.Context) *sql.DB {
 	if s.db == nil {
 		pool, err := pgxpool.New(ctx, s.PGConfig().DSN())
@@ -111,3 +415,49 @@ func (s *serviceProvider) DBClient(ctx context.Context) *sql.DB {
 
 	return s.db
 }
+
+func (s *serviceProvider) RedisClient(ctx context.Context) *redis.Client {
+	if s.redis == nil {
+		cfg := s.RedisConfig()
+		client := redis.NewClient(&redis.Options{
+			Addr:     cfg.Address(),
+			Password: cfg.Password(),
+			DB:       cfg.DB(),
+		})
+		if err := client.Ping(ctx).Err(); err != nil {
+			s.Logger(context.Background()).Error("failed to ping redis", zap.Error(err))
+			os.Exit(1)
+		}
+		s.redis = client
+	}
+
+	return s.redis
+}
+
+func (s *serviceProvider) Broker(ctx context.Context) broker.Broker {
+	if s.broker == nil {
+		switch s.BrokerConfig().Backend() {
+		case config.BrokerRedis:
+			s.broker = broker.NewRedis(s.RedisClient(ctx), s.BrokerConfig().Prefix())
+		case config.BrokerPostgres:
+			s.broker = broker.NewPostgres(s.PGPool(ctx), s.BrokerConfig().Prefix(), s.Logger(ctx))
+		default:
+			s.broker = broker.NewMemory()
+		}
+	}
+
+	return s.broker
+}
+
+func (s *serviceProvider) Presence(ctx context.Context) presence.Registry {
+	if s.presence == nil {
+		switch s.PresenceConfig().Backend() {
+		case config.PresenceRedis:
+			s.presence = presence.NewRedis(s.RedisClient(ctx), s.PresenceConfig().Prefix())
+		default:
+			s.presence = presence.NewMemory()
+		}
+	}
+
+	return s.presence
+}
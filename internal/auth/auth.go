@@ -0,0 +1,52 @@
+// Package auth carries the authenticated caller through request contexts.
+package auth
+
+import (
+	userdomain "chatsrv/internal/domain/user"
+	"context"
+	"net/http"
+	"strings"
+)
+
+// TokenProtocolPrefix marks the WebSocket subprotocol that carries a token,
+// for clients such as browsers that cannot set headers on the upgrade.
+const TokenProtocolPrefix = "bearer."
+
+type contextKey struct{}
+
+// NewContext returns ctx carrying principal.
+func NewContext(ctx context.Context, principal *userdomain.Principal) context.Context {
+	return context.WithValue(ctx, contextKey{}, principal)
+}
+
+// FromContext returns the principal stored by NewContext, or nil for
+// anonymous requests.
+func FromContext(ctx context.Context) *userdomain.Principal {
+	principal, _ := ctx.Value(contextKey{}).(*userdomain.Principal)
+	return principal
+}
+
+// BearerToken returns the token of an "Authorization: Bearer" header.
+func BearerToken(r *http.Request) (string, bool) {
+	tok, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	tok = strings.TrimSpace(tok)
+	return tok, ok && tok != ""
+}
+
+// WebSocketToken returns the token of a WebSocket upgrade, taken from the
+// Authorization header, the access_token query parameter or a
+// "bearer.<token>" subprotocol, in that order.
+func WebSocketToken(r *http.Request) (string, bool) {
+	if tok, ok := BearerToken(r); ok {
+		return tok, true
+	}
+	if tok := r.URL.Query().Get("access_token"); tok != "" {
+		return tok, true
+	}
+	for _, protocol := range strings.Split(r.Header.Get("Sec-WebSocket-Protocol"), ",") {
+		if tok, ok := strings.CutPrefix(strings.TrimSpace(protocol), TokenProtocolPrefix); ok && tok != "" {
+			return tok, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,33 @@
+package auth
+
+import (
+	"net/http/httptest"
+	"testing"
+)
+
+// TestWebSocketToken verifies tokens are found in the header, the query and
+// the subprotocol, in that order
+func TestWebSocketToken(t *testing.T) {
+	r := httptest.NewRequest("GET", "/ws?access_token=query", nil)
+	r.Header.Set("Sec-WebSocket-Protocol", "chat, bearer.protocol")
+	r.Header.Set("Authorization", "Bearer header")
+	if tok, _ := WebSocketToken(r); tok != "header" {
+		t.Errorf("Expected header token first, got %q", tok)
+	}
+
+	r.Header.Del("Authorization")
+	if tok, _ := WebSocketToken(r); tok != "query" {
+		t.Errorf("Expected query token second, got %q", tok)
+	}
+
+	r = httptest.NewRequest("GET", "/ws", nil)
+	r.Header.Set("Sec-WebSocket-Protocol", "chat, bearer.protocol")
+	if tok, _ := WebSocketToken(r); tok != "protocol" {
+		t.Errorf("Expected subprotocol token last, got %q", tok)
+	}
+
+	r.Header.Del("Sec-WebSocket-Protocol")
+	if _, ok := WebSocketToken(r); ok {
+		t.Errorf("Expected no token")
+	}
+}
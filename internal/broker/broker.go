@@ -0,0 +1,81 @@
+package broker
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrPayloadTooLarge is returned by Publish when a broker cannot carry a
+// payload that large. The publisher may send a smaller stand-in instead.
+var ErrPayloadTooLarge = errors.New("payload too large for the broker")
+
+// Handler receives a payload published to a room. It is called from the
+// broker's own goroutine, so it must not block.
+type Handler func(payload []byte)
+
+// Broker carries room events between the server nodes. Every node
+// subscribes to the rooms it has clients in and publishes the events of
+// any room; a payload reaches every subscriber of its room, on every node,
+// the publisher's own included.
+type Broker interface {
+	// Publish sends payload to the subscribers of room.
+	Publish(ctx context.Context, room string, payload []byte) error
+	// Subscribe calls handler with the payloads published to room until
+	// the returned function is called. Payloads published after Subscribe
+	// returns are not missed.
+	Subscribe(ctx context.Context, room string, handler Handler) (func(), error)
+	// Close stops delivering payloads.
+	Close() error
+}
+
+// handlers holds the handlers subscribed to each room. It is not safe for
+// concurrent use.
+type handlers struct {
+	next  int64
+	rooms map[string]map[int64]Handler
+}
+
+func newHandlers() handlers {
+	return handlers{rooms: make(map[string]map[int64]Handler)}
+}
+
+// add subscribes h to room and reports whether it is the first handler of
+// the room.
+func (hs *handlers) add(room string, h Handler) (int64, bool) {
+	hs.next++
+	subs, ok := hs.rooms[room]
+	if !ok {
+		subs = make(map[int64]Handler)
+		hs.rooms[room] = subs
+	}
+	subs[hs.next] = h
+	return hs.next, !ok
+}
+
+// remove unsubscribes the handler id from room and reports whether it was
+// the last handler of the room.
+func (hs *handlers) remove(room string, id int64) bool {
+	subs, ok := hs.rooms[room]
+	if !ok {
+		return false
+	}
+	if _, ok := subs[id]; !ok {
+		return false
+	}
+	delete(subs, id)
+	if len(subs) > 0 {
+		return false
+	}
+	delete(hs.rooms, room)
+	return true
+}
+
+// of returns the handlers of room.
+func (hs *handlers) of(room string) []Handler {
+	subs := hs.rooms[room]
+	list := make([]Handler, 0, len(subs))
+	for _, h := range subs {
+		list = append(list, h)
+	}
+	return list
+}
@@ -0,0 +1,91 @@
+package broker
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// receive returns a handler queueing payloads on a channel
+func receive() (Handler, chan string) {
+	got := make(chan string, 10)
+	return func(payload []byte) {
+		got <- string(payload)
+	}, got
+}
+
+func expectPayload(t *testing.T, got <-chan string, want string) {
+	t.Helper()
+	select {
+	case payload := <-got:
+		if payload != want {
+			t.Errorf("Expected %q, got %q", want, payload)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Never got %q", want)
+	}
+}
+
+func expectNothing(t *testing.T, got <-chan string) {
+	t.Helper()
+	select {
+	case payload := <-got:
+		t.Errorf("Expected nothing, got %q", payload)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// testBroker verifies b delivers the payloads of a room to all of its
+// subscribers, and only to them, until they unsubscribe
+func testBroker(t *testing.T, b Broker) {
+	ctx := context.Background()
+	h1, got1 := receive()
+	h2, got2 := receive()
+	other, gotOther := receive()
+
+	unsubscribe1, err := b.Subscribe(ctx, "c1", h1)
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	unsubscribe2, err := b.Subscribe(ctx, "c1", h2)
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	unsubscribeOther, err := b.Subscribe(ctx, "c2", other)
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	defer unsubscribeOther()
+
+	if err := b.Publish(ctx, "c1", []byte("hello")); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	expectPayload(t, got1, "hello")
+	expectPayload(t, got2, "hello")
+	expectNothing(t, gotOther)
+
+	unsubscribe1()
+	unsubscribe1()
+	if err := b.Publish(ctx, "c1", []byte("again")); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	expectPayload(t, got2, "again")
+	expectNothing(t, got1)
+
+	// Resubscribing after the room had no handlers left works again.
+	unsubscribe2()
+	unsubscribe1, err = b.Subscribe(ctx, "c1", h1)
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	defer unsubscribe1()
+	if err := b.Publish(ctx, "c1", []byte("back")); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	expectPayload(t, got1, "back")
+	expectNothing(t, got2)
+}
+
+func TestMemoryBroker(t *testing.T) {
+	testBroker(t, NewMemory())
+}
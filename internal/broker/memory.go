@@ -0,0 +1,50 @@
+package broker
+
+import (
+	"context"
+	"sync"
+)
+
+// Memory is a Broker within a single process. It is used when the server
+// runs as a single node, and by tests standing in for several nodes.
+type Memory struct {
+	mu       sync.RWMutex
+	handlers handlers
+}
+
+func NewMemory() *Memory {
+	return &Memory{handlers: newHandlers()}
+}
+
+func (m *Memory) Publish(_ context.Context, room string, payload []byte) error {
+	m.mu.RLock()
+	handlers := m.handlers.of(room)
+	m.mu.RUnlock()
+
+	for _, h := range handlers {
+		h(payload)
+	}
+	return nil
+}
+
+func (m *Memory) Subscribe(_ context.Context, room string, handler Handler) (func(), error) {
+	m.mu.Lock()
+	id, _ := m.handlers.add(room, handler)
+	m.mu.Unlock()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			m.mu.Lock()
+			m.handlers.remove(room, id)
+			m.mu.Unlock()
+		})
+	}, nil
+}
+
+func (m *Memory) Close() error {
+	m.mu.Lock()
+	m.handlers = newHandlers()
+	m.mu.Unlock()
+	return nil
+}
@@ -0,0 +1,363 @@
+package broker
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+const (
+	// maxNotifyPayload is the largest payload NOTIFY takes, header
+	// included.
+	maxNotifyPayload = 7999
+	// maxSeqDigits is the length of the largest sequence number in a
+	// header.
+	maxSeqDigits = 20
+
+	minReconnectDelay = 100 * time.Millisecond
+	maxReconnectDelay = 30 * time.Second
+)
+
+// postgresStats counts the notifications received, the reconnects of the
+// listener, the notifications found missing and the payloads refused as too
+// large. They are published under /debug/vars.
+var postgresStats = expvar.NewMap("broker_postgres")
+
+// Postgres is a Broker over LISTEN/NOTIFY, with a channel per room. A node
+// keeps one connection out of the pool listening to the channels of the
+// rooms it has handlers for, and listens to them again after it reconnects.
+//
+// Notifications sent while the listener is disconnected are lost. Every
+// payload sent to a room the node has handlers for carries the sequence
+// number of its publisher in its channel, so a receiver notices the gap
+// with the next notification of that publisher, logs it and counts it as
+// missed in broker_postgres. Payloads sent to other rooms carry 0 and are
+// not checked. A publisher that stops handling a room and comes back to it
+// numbers its notifications from 1 again.
+type Postgres struct {
+	pool   *pgxpool.Pool
+	prefix string
+	log    *zap.Logger
+
+	// id identifies this node in the notifications it sends.
+	id string
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	done   chan struct{}
+	// wake makes the listener catch up with the handlers.
+	wake chan struct{}
+
+	mu       sync.Mutex
+	handlers handlers
+	// ready holds, for the channels not yet listened to, a channel closed
+	// once they are.
+	ready map[string]chan struct{}
+	// last holds the sequence number last received from each publisher in
+	// each channel listened to.
+	last map[string]map[string]uint64
+	// seqs numbers the notifications this node sends to the channels it
+	// has handlers for.
+	seqs map[string]*sequence
+}
+
+// sequence numbers the notifications a node sends to a channel. Publishes
+// to the channel hold mu, so that the numbers go out in order and are only
+// used up by notifications actually sent.
+type sequence struct {
+	mu   sync.Mutex
+	last uint64
+}
+
+// NewPostgres returns a Broker notifying on the channels prefix+room with
+// the connections of pool, and starts its listener.
+func NewPostgres(pool *pgxpool.Pool, prefix string, log *zap.Logger) *Postgres {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := &Postgres{
+		pool:     pool,
+		prefix:   prefix,
+		log:      log,
+		id:       uuid.New().String(),
+		ctx:      ctx,
+		cancel:   cancel,
+		done:     make(chan struct{}),
+		wake:     make(chan struct{}, 1),
+		handlers: newHandlers(),
+		ready:    make(map[string]chan struct{}),
+		last:     make(map[string]map[string]uint64),
+		seqs:     make(map[string]*sequence),
+	}
+	go p.run()
+	return p
+}
+
+func (p *Postgres) Publish(ctx context.Context, room string, payload []byte) error {
+	if len(p.id)+1+maxSeqDigits+1+len(payload) > maxNotifyPayload {
+		postgresStats.Add("oversized", 1)
+		return fmt.Errorf("%w: %d bytes", ErrPayloadTooLarge, len(payload))
+	}
+
+	channel := p.prefix + room
+	p.mu.Lock()
+	seq := p.seqs[channel]
+	p.mu.Unlock()
+	if seq == nil {
+		return p.notify(ctx, channel, 0, payload)
+	}
+
+	seq.mu.Lock()
+	defer seq.mu.Unlock()
+	if err := p.notify(ctx, channel, seq.last+1, payload); err != nil {
+		return err
+	}
+	seq.last++
+	return nil
+}
+
+// notify sends payload to channel numbered seq.
+func (p *Postgres) notify(ctx context.Context, channel string, seq uint64, payload []byte) error {
+	header := p.id + " " + strconv.FormatUint(seq, 10) + "\n"
+	_, err := p.pool.Exec(ctx, `SELECT pg_notify($1, $2)`, channel, header+string(payload))
+	return err
+}
+
+func (p *Postgres) Subscribe(ctx context.Context, room string, handler Handler) (func(), error) {
+	channel := p.prefix + room
+
+	p.mu.Lock()
+	id, first := p.handlers.add(channel, handler)
+	if first {
+		p.ready[channel] = make(chan struct{})
+		p.seqs[channel] = &sequence{}
+	}
+	ready := p.ready[channel]
+	p.mu.Unlock()
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			p.mu.Lock()
+			last := p.handlers.remove(channel, id)
+			if last {
+				delete(p.ready, channel)
+				delete(p.seqs, channel)
+			}
+			p.mu.Unlock()
+			if last {
+				p.poke()
+			}
+		})
+	}
+
+	if ready != nil {
+		p.poke()
+		select {
+		case <-ready:
+		case <-ctx.Done():
+			unsubscribe()
+			return nil, ctx.Err()
+		}
+	}
+	return unsubscribe, nil
+}
+
+// Close stops the listener and returns its connection. The pool is left
+// open.
+func (p *Postgres) Close() error {
+	p.cancel()
+	<-p.done
+	return nil
+}
+
+func (p *Postgres) poke() {
+	select {
+	case p.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run keeps a listening connection until the broker is closed, reconnecting
+// with a growing delay whenever it is lost.
+func (p *Postgres) run() {
+	defer close(p.done)
+
+	delay := minReconnectDelay
+	for connected := false; ; {
+		if connected {
+			postgresStats.Add("reconnects", 1)
+		}
+		err := p.listen(func() {
+			connected = true
+			delay = minReconnectDelay
+		})
+		if p.ctx.Err() != nil {
+			return
+		}
+		p.log.Error("Postgres broker listener",
+			zap.Any("retry_in", delay),
+			zap.Error(err))
+
+		select {
+		case <-time.After(delay):
+		case <-p.ctx.Done():
+			return
+		}
+		delay = min(delay*2, maxReconnectDelay)
+	}
+}
+
+// listen takes a connection out of the pool and listens on it until it
+// fails or the broker is closed. connected is called once it is up.
+func (p *Postgres) listen(connected func()) error {
+	pooled, err := p.pool.Acquire(p.ctx)
+	if err != nil {
+		return err
+	}
+	conn := pooled.Hijack()
+	defer conn.Close(context.Background())
+	connected()
+
+	listening := make(map[string]struct{})
+	for {
+		if err := p.sync(conn, listening); err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithCancel(p.ctx)
+		go func() {
+			select {
+			case <-p.wake:
+				cancel()
+			case <-ctx.Done():
+			}
+		}()
+		notification, err := conn.WaitForNotification(ctx)
+		woken := ctx.Err() != nil
+		cancel()
+		switch {
+		case p.ctx.Err() != nil:
+			return p.ctx.Err()
+		case err == nil:
+			p.deliver(notification)
+		case !woken:
+			return err
+		}
+	}
+}
+
+// sync listens to the channels of the handlers and stops listening to the
+// others. listening holds the channels conn listens to.
+func (p *Postgres) sync(conn *pgx.Conn, listening map[string]struct{}) error {
+	p.mu.Lock()
+	var listen, unlisten []string
+	for channel := range p.handlers.rooms {
+		if _, ok := listening[channel]; !ok {
+			listen = append(listen, channel)
+		}
+	}
+	for channel := range listening {
+		if _, ok := p.handlers.rooms[channel]; !ok {
+			unlisten = append(unlisten, channel)
+		}
+	}
+	p.mu.Unlock()
+
+	for _, channel := range listen {
+		if _, err := conn.Exec(p.ctx, "LISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+			return err
+		}
+		listening[channel] = struct{}{}
+	}
+	for _, channel := range unlisten {
+		if _, err := conn.Exec(p.ctx, "UNLISTEN "+pgx.Identifier{channel}.Sanitize()); err != nil {
+			return err
+		}
+		delete(listening, channel)
+	}
+
+	p.mu.Lock()
+	for _, channel := range listen {
+		if ready, ok := p.ready[channel]; ok {
+			close(ready)
+			delete(p.ready, channel)
+		}
+	}
+	for _, channel := range unlisten {
+		delete(p.last, channel)
+	}
+	p.mu.Unlock()
+	return nil
+}
+
+// deliver hands a notification to the handlers of its channel, after
+// checking no notification of its publisher went missing before it.
+func (p *Postgres) deliver(notification *pgconn.Notification) {
+	postgresStats.Add("notifications", 1)
+	publisher, seq, payload, err := parseNotification(notification.Payload)
+	if err != nil {
+		p.log.Error("Postgres broker notification",
+			zap.Any("channel", notification.Channel),
+			zap.Error(err))
+		return
+	}
+
+	p.mu.Lock()
+	if seq > 0 {
+		p.checkSeq(notification.Channel, publisher, seq)
+	}
+	handlers := p.handlers.of(notification.Channel)
+	p.mu.Unlock()
+
+	for _, h := range handlers {
+		h(payload)
+	}
+}
+
+// checkSeq records seq as the last number received from publisher in
+// channel, counting the notifications missed since the previous one. A
+// number not above the previous one starts a new run of the publisher. It
+// is called with mu held.
+func (p *Postgres) checkSeq(channel string, publisher string, seq uint64) {
+	seen, ok := p.last[channel]
+	if !ok {
+		seen = make(map[string]uint64)
+		p.last[channel] = seen
+	}
+	if last, ok := seen[publisher]; ok && seq > last+1 {
+		postgresStats.Add("missed", int64(seq-last-1))
+		p.log.Warn("Postgres broker missed notifications",
+			zap.Any("channel", channel),
+			zap.Any("publisher", publisher),
+			zap.Any("missed", seq-last-1))
+	}
+	seen[publisher] = seq
+}
+
+// parseNotification splits a notification into its publisher, its sequence
+// number and its payload.
+func parseNotification(data string) (string, uint64, []byte, error) {
+	header, payload, ok := bytes.Cut([]byte(data), []byte("\n"))
+	if !ok {
+		return "", 0, nil, errors.New("notification without a header")
+	}
+	publisher, seq, ok := bytes.Cut(header, []byte(" "))
+	if !ok {
+		return "", 0, nil, fmt.Errorf("malformed notification header %q", header)
+	}
+	n, err := strconv.ParseUint(string(seq), 10, 64)
+	if err != nil {
+		return "", 0, nil, fmt.Errorf("malformed notification header %q", header)
+	}
+	return string(publisher), n, payload, nil
+}
@@ -0,0 +1,167 @@
+package broker
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+)
+
+// newIdlePostgres returns a Postgres broker without a listener, to feed
+// notifications by hand
+func newIdlePostgres() *Postgres {
+	return &Postgres{
+		prefix:   "test:",
+		log:      zap.NewNop(),
+		id:       "node1",
+		handlers: newHandlers(),
+		ready:    make(map[string]chan struct{}),
+		last:     make(map[string]map[string]uint64),
+		seqs:     make(map[string]*sequence),
+	}
+}
+
+// TestPostgresDetectsMissedNotifications verifies a gap in the sequence of
+// a publisher is counted, and payloads are still delivered
+func TestPostgresDetectsMissedNotifications(t *testing.T) {
+	p := newIdlePostgres()
+	h, got := receive()
+	p.handlers.add("test:c1", h)
+	missed := func() int64 {
+		if v, ok := postgresStats.Get("missed").(interface{ Value() int64 }); ok {
+			return v.Value()
+		}
+		return 0
+	}
+	before := missed()
+
+	// node2 starts over once, after five, and sends an unnumbered payload.
+	notifications := []string{"node2 1\none", "node2 2\ntwo", "node3 7\nfirst of node3", "node2 5\nfive",
+		"node2 1\nagain", "node2 0\nunnumbered", "node2 2\nagain two"}
+	for _, data := range notifications {
+		p.deliver(&pgconn.Notification{Channel: "test:c1", Payload: data})
+	}
+	for _, want := range []string{"one", "two", "first of node3", "five", "again", "unnumbered", "again two"} {
+		expectPayload(t, got, want)
+	}
+	if n := missed() - before; n != 2 {
+		t.Errorf("Expected 2 missed notifications, got %d", n)
+	}
+
+	p.deliver(&pgconn.Notification{Channel: "test:c1", Payload: "no header"})
+	expectNothing(t, got)
+}
+
+// TestPostgresRefusesLargePayloads verifies payloads NOTIFY cannot carry
+// are refused with ErrPayloadTooLarge
+func TestPostgresRefusesLargePayloads(t *testing.T) {
+	p := newIdlePostgres()
+	err := p.Publish(context.Background(), "c1", []byte(strings.Repeat("x", maxNotifyPayload)))
+	if !errors.Is(err, ErrPayloadTooLarge) {
+		t.Errorf("Expected ErrPayloadTooLarge, got %v", err)
+	}
+}
+
+// TestPostgresNumbersOnlySentNotifications verifies payloads refused as too
+// large or failing to be sent use up no sequence number, which would read
+// as a missed notification
+func TestPostgresNumbersOnlySentNotifications(t *testing.T) {
+	pool, err := pgxpool.New(context.Background(), "postgres://nobody@127.0.0.1:1/none?connect_timeout=1")
+	if err != nil {
+		t.Fatalf("pgxpool.New failed: %v", err)
+	}
+	defer pool.Close()
+	p := newIdlePostgres()
+	p.pool = pool
+	seq := &sequence{}
+	p.seqs["test:c1"] = seq
+
+	err = p.Publish(context.Background(), "c1", []byte(strings.Repeat("x", maxNotifyPayload)))
+	if !errors.Is(err, ErrPayloadTooLarge) {
+		t.Errorf("Expected ErrPayloadTooLarge, got %v", err)
+	}
+	if err := p.Publish(context.Background(), "c1", []byte("x")); err == nil {
+		t.Error("Expected the publish to fail without a database")
+	}
+	if seq.last != 0 {
+		t.Errorf("Expected no sequence number used, got %d", seq.last)
+	}
+}
+
+// TestPostgresForgetsSequencesOfRoomsLeft verifies a room is numbered only
+// while the node has handlers for it
+func TestPostgresForgetsSequencesOfRoomsLeft(t *testing.T) {
+	p := newIdlePostgres()
+	// Stand in for the listener, which would listen to the channel.
+	go func() {
+		for {
+			p.mu.Lock()
+			ready, ok := p.ready["test:c1"]
+			if ok {
+				close(ready)
+				delete(p.ready, "test:c1")
+			}
+			p.mu.Unlock()
+			if ok {
+				return
+			}
+			time.Sleep(time.Millisecond)
+		}
+	}()
+
+	h, _ := receive()
+	unsubscribe, err := p.Subscribe(context.Background(), "c1", h)
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	p.mu.Lock()
+	_, numbered := p.seqs["test:c1"]
+	p.mu.Unlock()
+	if !numbered {
+		t.Error("Expected the room to be numbered while subscribed")
+	}
+
+	unsubscribe()
+	p.mu.Lock()
+	_, numbered = p.seqs["test:c1"]
+	p.mu.Unlock()
+	if numbered {
+		t.Error("Expected the room to be forgotten once left")
+	}
+}
+
+func TestParseNotification(t *testing.T) {
+	publisher, seq, payload, err := parseNotification("node1 42\n{\"a\":\"b\nc\"}")
+	if err != nil || publisher != "node1" || seq != 42 || string(payload) != "{\"a\":\"b\nc\"}" {
+		t.Errorf("Unexpected parse %q %d %q %v", publisher, seq, payload, err)
+	}
+	for _, data := range []string{"", "node1\nx", "node1 x\ny"} {
+		if _, _, _, err := parseNotification(data); err == nil {
+			t.Errorf("Expected %q to be refused", data)
+		}
+	}
+}
+
+// TestPostgresBroker runs against the database of BROKER_TEST_PG_DSN, when
+// it is set
+func TestPostgresBroker(t *testing.T) {
+	dsn := os.Getenv("BROKER_TEST_PG_DSN")
+	if dsn == "" {
+		t.Skip("BROKER_TEST_PG_DSN is not set")
+	}
+	pool, err := pgxpool.New(context.Background(), dsn)
+	if err != nil {
+		t.Fatalf("pgxpool.New failed: %v", err)
+	}
+	defer pool.Close()
+
+	b := NewPostgres(pool, "test:", zap.NewNop())
+	defer b.Close()
+	testBroker(t, b)
+}
@@ -0,0 +1,116 @@
+package broker
+
+import (
+	"context"
+	"sync"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Redis is a Broker over Redis pub/sub, with a channel per room. A node
+// keeps a single subscriber connection, subscribed to the channels of the
+// rooms it has handlers for; the client subscribes it again after a
+// reconnect.
+type Redis struct {
+	client *redis.Client
+	prefix string
+	pubsub *redis.PubSub
+	start  sync.Once
+
+	mu       sync.Mutex
+	handlers handlers
+	// ready holds, for the channels being subscribed, a channel closed
+	// once Redis confirms the subscription.
+	ready map[string]chan struct{}
+}
+
+// NewRedis returns a Broker publishing on the channels prefix+room of
+// client.
+func NewRedis(client *redis.Client, prefix string) *Redis {
+	return &Redis{
+		client:   client,
+		prefix:   prefix,
+		pubsub:   client.Subscribe(context.Background()),
+		handlers: newHandlers(),
+		ready:    make(map[string]chan struct{}),
+	}
+}
+
+func (r *Redis) Publish(ctx context.Context, room string, payload []byte) error {
+	return r.client.Publish(ctx, r.prefix+room, payload).Err()
+}
+
+func (r *Redis) Subscribe(ctx context.Context, room string, handler Handler) (func(), error) {
+	channel := r.prefix + room
+
+	// Commands go out under the lock, so that a subscribe and an
+	// unsubscribe of the same channel reach Redis in order.
+	r.mu.Lock()
+	id, first := r.handlers.add(channel, handler)
+	if first {
+		if err := r.pubsub.Subscribe(ctx, channel); err != nil {
+			r.handlers.remove(channel, id)
+			r.mu.Unlock()
+			return nil, err
+		}
+		r.ready[channel] = make(chan struct{})
+	}
+	ready := r.ready[channel]
+	r.mu.Unlock()
+
+	r.start.Do(func() {
+		go r.run()
+	})
+
+	var once sync.Once
+	unsubscribe := func() {
+		once.Do(func() {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			if r.handlers.remove(channel, id) {
+				delete(r.ready, channel)
+				r.pubsub.Unsubscribe(context.Background(), channel)
+			}
+		})
+	}
+
+	if ready != nil {
+		select {
+		case <-ready:
+		case <-ctx.Done():
+			unsubscribe()
+			return nil, ctx.Err()
+		}
+	}
+	return unsubscribe, nil
+}
+
+func (r *Redis) run() {
+	for msg := range r.pubsub.ChannelWithSubscriptions() {
+		switch msg := msg.(type) {
+		case *redis.Subscription:
+			if msg.Kind != "subscribe" {
+				continue
+			}
+			r.mu.Lock()
+			if ready, ok := r.ready[msg.Channel]; ok {
+				close(ready)
+				delete(r.ready, msg.Channel)
+			}
+			r.mu.Unlock()
+		case *redis.Message:
+			r.mu.Lock()
+			handlers := r.handlers.of(msg.Channel)
+			r.mu.Unlock()
+
+			for _, h := range handlers {
+				h([]byte(msg.Payload))
+			}
+		}
+	}
+}
+
+// Close closes the subscriber connection. The client is left open.
+func (r *Redis) Close() error {
+	return r.pubsub.Close()
+}
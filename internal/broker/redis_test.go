@@ -0,0 +1,45 @@
+package broker
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestRedis(t *testing.T) *redis.Client {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestRedisBroker(t *testing.T) {
+	b := NewRedis(newTestRedis(t), "test:")
+	defer b.Close()
+	testBroker(t, b)
+}
+
+// TestRedisBrokerAcrossNodes verifies a payload published by one node
+// reaches the subscribers of another
+func TestRedisBrokerAcrossNodes(t *testing.T) {
+	client := newTestRedis(t)
+	node1 := NewRedis(client, "test:")
+	defer node1.Close()
+	node2 := NewRedis(client, "test:")
+	defer node2.Close()
+	ctx := context.Background()
+
+	h, got := receive()
+	unsubscribe, err := node2.Subscribe(ctx, "c1", h)
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	defer unsubscribe()
+
+	if err := node1.Publish(ctx, "c1", []byte("hello")); err != nil {
+		t.Fatalf("Publish failed: %v", err)
+	}
+	expectPayload(t, got, "hello")
+}
@@ -1,6 +1,10 @@
 package config
 
-import "os"
+import (
+	"os"
+	"strconv"
+	"time"
+)
 
 func GetEnvStringOrDefault(key string, defaultValue string) string {
 	val, ok := os.LookupEnv(key)
@@ -10,6 +14,42 @@ func GetEnvStringOrDefault(key string, defaultValue string) string {
 	return val
 }
 
+func GetEnvIntOrDefault(key string, defaultValue int) int {
+	val, ok := os.LookupEnv(key)
+	if !ok {
+		return defaultValue
+	}
+	n, err := strconv.Atoi(val)
+	if err != nil {
+		return defaultValue
+	}
+	return n
+}
+
+func GetEnvBoolOrDefault(key string, defaultValue bool) bool {
+	val, ok := os.LookupEnv(key)
+	if !ok {
+		return defaultValue
+	}
+	b, err := strconv.ParseBool(val)
+	if err != nil {
+		return defaultValue
+	}
+	return b
+}
+
+func GetEnvDurationOrDefault(key string, defaultValue time.Duration) time.Duration {
+	val, ok := os.LookupEnv(key)
+	if !ok {
+		return defaultValue
+	}
+	d, err := time.ParseDuration(val)
+	if err != nil {
+		return defaultValue
+	}
+	return d
+}
+
 type HttpConfig interface {
 	Address() string
 }
@@ -17,3 +57,166 @@ type HttpConfig interface {
 type PGConfig interface {
 	DSN() string
 }
+
+type AdminConfig interface {
+	Token() string
+}
+
+type AuthConfig interface {
+	// AccessTokenTTL is how long an access token stays valid before it has
+	// to be refreshed.
+	AccessTokenTTL() time.Duration
+	// SessionTTL is how long a session survives without being refreshed.
+	SessionTTL() time.Duration
+	BcryptCost() int
+	// AllowUserHeader keeps accepting the unauthenticated X-User-ID header
+	// from clients that do not send a token. It lets anyone act as any
+	// user, so it is meant for development only.
+	AllowUserHeader() bool
+}
+
+// OIDCConfig configures login through an OpenID Connect provider.
+type OIDCConfig interface {
+	// Enabled reports whether an issuer is configured.
+	Enabled() bool
+	IssuerURL() string
+	ClientID() string
+	ClientSecret() string
+	// RedirectURL is where the provider sends users back to, the public URL
+	// of /auth/oidc/callback.
+	RedirectURL() string
+	Scopes() []string
+	// UsernameClaim names the claim new users take their username from.
+	UsernameClaim() string
+	// RoleClaim names the claim, a string or a list of strings, whose values
+	// RoleMapping maps to roles.
+	RoleClaim() string
+	RoleMapping() map[string]string
+	// DefaultRole is given to users none of whose claim values is mapped.
+	// When it is empty such users cannot log in.
+	DefaultRole() string
+	// LoginTTL is how long a user may take to log in at the provider.
+	LoginTTL() time.Duration
+}
+
+type ChatConfig interface {
+	// UniqueNames reports whether room names must be unique, ignoring case.
+	UniqueNames() bool
+	// IdempotencyTTL is how long an Idempotency-Key is remembered.
+	IdempotencyTTL() time.Duration
+}
+
+type WebhookConfig interface {
+	MaxAttempts() int
+	BaseBackoff() time.Duration
+	MaxBackoff() time.Duration
+	PollInterval() time.Duration
+	Timeout() time.Duration
+}
+
+type IncomingWebhookConfig interface {
+	RatePerMinute() int
+	Burst() int
+}
+
+// RateLimit is a token bucket budget. A PerMinute of zero or less disables
+// the limit.
+type RateLimit struct {
+	PerMinute int
+	Burst     int
+}
+
+// RateLimitConfig budgets the actions clients send over WebSocket. Every
+// action is limited per connection and per authenticated user.
+type RateLimitConfig interface {
+	MessageLimit() RateLimit
+	UserMessageLimit() RateLimit
+	JoinLimit() RateLimit
+	UserJoinLimit() RateLimit
+	TypingLimit() RateLimit
+	UserTypingLimit() RateLimit
+	// MaxViolations is how many refused actions within ViolationWindow a
+	// connection is allowed before it is disconnected.
+	MaxViolations() int
+	ViolationWindow() time.Duration
+}
+
+// HeartbeatConfig configures the pings that detect dead WebSocket
+// connections.
+type HeartbeatConfig interface {
+	// Interval is how often connections are pinged. Zero disables pings.
+	Interval() time.Duration
+	// Timeout is how long a connection may stay silent, pongs included,
+	// before it is dropped.
+	Timeout() time.Duration
+}
+
+// SlowConsumerPolicy says what happens when an event is sent to a client
+// whose outbound queue is full.
+type SlowConsumerPolicy string
+
+const (
+	// SlowConsumerDropOldest makes room by dropping the oldest queued event.
+	SlowConsumerDropOldest SlowConsumerPolicy = "drop_oldest"
+	// SlowConsumerDropClient disconnects the client.
+	SlowConsumerDropClient SlowConsumerPolicy = "drop_client"
+	// SlowConsumerBlock waits for room up to BlockTimeout, then drops the
+	// event.
+	SlowConsumerBlock SlowConsumerPolicy = "block"
+)
+
+// OutboundConfig configures the queues that hold the events of each
+// WebSocket connection and event stream until they are written.
+type OutboundConfig interface {
+	QueueSize() int
+	// WriteTimeout bounds each write; a client that cannot take an event
+	// within it is disconnected. Zero means no deadline.
+	WriteTimeout() time.Duration
+	SlowConsumerPolicy() SlowConsumerPolicy
+	BlockTimeout() time.Duration
+}
+
+// BrokerBackend names what carries room events between the server nodes.
+type BrokerBackend string
+
+const (
+	// BrokerMemory keeps events within the process, for a single node.
+	BrokerMemory BrokerBackend = "memory"
+	// BrokerRedis uses Redis pub/sub.
+	BrokerRedis BrokerBackend = "redis"
+	// BrokerPostgres uses LISTEN/NOTIFY on the database.
+	BrokerPostgres BrokerBackend = "postgres"
+)
+
+// BrokerConfig configures how room events reach the other server nodes.
+type BrokerConfig interface {
+	Backend() BrokerBackend
+	// Prefix namespaces the channels of the broker, so that several
+	// deployments can share a server.
+	Prefix() string
+}
+
+// PresenceBackend names where the online users of the cluster are kept.
+type PresenceBackend string
+
+const (
+	// PresenceMemory keeps presence within the process, for a single node.
+	PresenceMemory PresenceBackend = "memory"
+	// PresenceRedis shares presence between the nodes through Redis.
+	PresenceRedis PresenceBackend = "redis"
+)
+
+// PresenceConfig configures the cluster-wide registry of online users.
+type PresenceConfig interface {
+	Backend() PresenceBackend
+	Prefix() string
+	// Lease is how long the users of a node stay online after its last
+	// heartbeat. The node renews it three times per lease.
+	Lease() time.Duration
+}
+
+type RedisConfig interface {
+	Address() string
+	Password() string
+	DB() int
+}
@@ -0,0 +1,19 @@
+package env
+
+import "chatsrv/internal/config"
+
+type adminCfg struct {
+	token string
+}
+
+// NewAdminConfig reads the static token guarding the admin API. An empty
+// token disables the admin API entirely.
+func NewAdminConfig() *adminCfg {
+	return &adminCfg{
+		token: config.GetEnvStringOrDefault("ADMIN_TOKEN", ""),
+	}
+}
+
+func (c *adminCfg) Token() string {
+	return c.token
+}
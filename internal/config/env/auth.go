@@ -0,0 +1,40 @@
+package env
+
+import (
+	"chatsrv/internal/config"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+type authCfg struct {
+	accessTokenTTL  time.Duration
+	sessionTTL      time.Duration
+	bcryptCost      int
+	allowUserHeader bool
+}
+
+func NewAuthConfig() *authCfg {
+	return &authCfg{
+		accessTokenTTL:  config.GetEnvDurationOrDefault("ACCESS_TOKEN_TTL", 15*time.Minute),
+		sessionTTL:      config.GetEnvDurationOrDefault("SESSION_TTL", 30*24*time.Hour),
+		bcryptCost:      config.GetEnvIntOrDefault("BCRYPT_COST", bcrypt.DefaultCost),
+		allowUserHeader: config.GetEnvBoolOrDefault("AUTH_ALLOW_USER_HEADER", false),
+	}
+}
+
+func (c *authCfg) AccessTokenTTL() time.Duration {
+	return c.accessTokenTTL
+}
+
+func (c *authCfg) SessionTTL() time.Duration {
+	return c.sessionTTL
+}
+
+func (c *authCfg) BcryptCost() int {
+	return c.bcryptCost
+}
+
+func (c *authCfg) AllowUserHeader() bool {
+	return c.allowUserHeader
+}
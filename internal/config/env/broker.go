@@ -0,0 +1,30 @@
+package env
+
+import "chatsrv/internal/config"
+
+type brokerCfg struct {
+	backend config.BrokerBackend
+	prefix  string
+}
+
+func NewBrokerConfig() *brokerCfg {
+	backend := config.BrokerBackend(config.GetEnvStringOrDefault("CHAT_BROKER", string(config.BrokerMemory)))
+	switch backend {
+	case config.BrokerMemory, config.BrokerRedis, config.BrokerPostgres:
+	default:
+		backend = config.BrokerMemory
+	}
+
+	return &brokerCfg{
+		backend: backend,
+		prefix:  config.GetEnvStringOrDefault("CHAT_BROKER_PREFIX", "ichat:"),
+	}
+}
+
+func (c *brokerCfg) Backend() config.BrokerBackend {
+	return c.backend
+}
+
+func (c *brokerCfg) Prefix() string {
+	return c.prefix
+}
@@ -0,0 +1,26 @@
+package env
+
+import (
+	"chatsrv/internal/config"
+	"time"
+)
+
+type chatCfg struct {
+	uniqueNames    bool
+	idempotencyTTL time.Duration
+}
+
+func NewChatConfig() *chatCfg {
+	return &chatCfg{
+		uniqueNames:    config.GetEnvBoolOrDefault("CHAT_UNIQUE_NAMES", false),
+		idempotencyTTL: config.GetEnvDurationOrDefault("IDEMPOTENCY_KEY_TTL", 24*time.Hour),
+	}
+}
+
+func (c *chatCfg) UniqueNames() bool {
+	return c.uniqueNames
+}
+
+func (c *chatCfg) IdempotencyTTL() time.Duration {
+	return c.idempotencyTTL
+}
@@ -0,0 +1,26 @@
+package env
+
+import (
+	"chatsrv/internal/config"
+	"time"
+)
+
+type heartbeatCfg struct {
+	interval time.Duration
+	timeout  time.Duration
+}
+
+func NewHeartbeatConfig() *heartbeatCfg {
+	return &heartbeatCfg{
+		interval: config.GetEnvDurationOrDefault("WS_HEARTBEAT_INTERVAL", 30*time.Second),
+		timeout:  config.GetEnvDurationOrDefault("WS_HEARTBEAT_TIMEOUT", 75*time.Second),
+	}
+}
+
+func (c *heartbeatCfg) Interval() time.Duration {
+	return c.interval
+}
+
+func (c *heartbeatCfg) Timeout() time.Duration {
+	return c.timeout
+}
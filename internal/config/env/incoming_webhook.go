@@ -0,0 +1,26 @@
+package env
+
+import "chatsrv/internal/config"
+
+type incomingWebhookCfg struct {
+	ratePerMinute int
+	burst         int
+}
+
+// NewIncomingWebhookConfig reads the default rate limit given to new
+// incoming-webhook tokens. Each token stores its own copy so it can be
+// tuned individually.
+func NewIncomingWebhookConfig() *incomingWebhookCfg {
+	return &incomingWebhookCfg{
+		ratePerMinute: config.GetEnvIntOrDefault("INCOMING_WEBHOOK_RATE_PER_MINUTE", 30),
+		burst:         config.GetEnvIntOrDefault("INCOMING_WEBHOOK_BURST", 5),
+	}
+}
+
+func (c *incomingWebhookCfg) RatePerMinute() int {
+	return c.ratePerMinute
+}
+
+func (c *incomingWebhookCfg) Burst() int {
+	return c.burst
+}
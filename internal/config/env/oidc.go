@@ -0,0 +1,129 @@
+package env
+
+import (
+	"chatsrv/internal/config"
+	userdomain "chatsrv/internal/domain/user"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type oidcCfg struct {
+	issuerURL     string
+	clientID      string
+	clientSecret  string
+	redirectURL   string
+	scopes        []string
+	usernameClaim string
+	roleClaim     string
+	roleMapping   map[string]string
+	defaultRole   string
+	loginTTL      time.Duration
+}
+
+const (
+	oidcIssuerURL     = "OIDC_ISSUER_URL"
+	oidcClientID      = "OIDC_CLIENT_ID"
+	oidcClientSecret  = "OIDC_CLIENT_SECRET"
+	oidcRedirectURL   = "OIDC_REDIRECT_URL"
+	oidcScopes        = "OIDC_SCOPES"
+	oidcUsernameClaim = "OIDC_USERNAME_CLAIM"
+	oidcRoleClaim     = "OIDC_ROLE_CLAIM"
+	oidcRoleMap       = "OIDC_ROLE_MAP"
+	oidcDefaultRole   = "OIDC_DEFAULT_ROLE"
+	oidcLoginTTL      = "OIDC_LOGIN_TTL"
+)
+
+// NewOIDCConfig reads the OpenID Connect settings. OIDC login is disabled
+// when OIDC_ISSUER_URL is empty; otherwise the client settings are required.
+// OIDC_ROLE_MAP is a comma-separated list of claim=role pairs, such as
+// "chat-admins=admin,staff=user".
+func NewOIDCConfig() (*oidcCfg, error) {
+	cfg := &oidcCfg{
+		issuerURL:     config.GetEnvStringOrDefault(oidcIssuerURL, ""),
+		clientID:      config.GetEnvStringOrDefault(oidcClientID, ""),
+		clientSecret:  config.GetEnvStringOrDefault(oidcClientSecret, ""),
+		redirectURL:   config.GetEnvStringOrDefault(oidcRedirectURL, ""),
+		scopes:        strings.Fields(config.GetEnvStringOrDefault(oidcScopes, "openid profile email")),
+		usernameClaim: config.GetEnvStringOrDefault(oidcUsernameClaim, "preferred_username"),
+		roleClaim:     config.GetEnvStringOrDefault(oidcRoleClaim, "groups"),
+		roleMapping:   make(map[string]string),
+		defaultRole:   config.GetEnvStringOrDefault(oidcDefaultRole, string(userdomain.RoleUser)),
+		loginTTL:      config.GetEnvDurationOrDefault(oidcLoginTTL, 10*time.Minute),
+	}
+	if cfg.issuerURL == "" {
+		return cfg, nil
+	}
+
+	if cfg.clientID == "" {
+		return nil, errors.New(oidcClientID + " is required with " + oidcIssuerURL)
+	}
+	if cfg.redirectURL == "" {
+		return nil, errors.New(oidcRedirectURL + " is required with " + oidcIssuerURL)
+	}
+	if cfg.defaultRole != "" && !userdomain.Role(cfg.defaultRole).Valid() {
+		return nil, fmt.Errorf("%s: unknown role %q", oidcDefaultRole, cfg.defaultRole)
+	}
+
+	for _, pair := range strings.Split(config.GetEnvStringOrDefault(oidcRoleMap, ""), ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		value, role, ok := strings.Cut(pair, "=")
+		if !ok || value == "" {
+			return nil, fmt.Errorf("%s: %q is not a claim=role pair", oidcRoleMap, pair)
+		}
+		if !userdomain.Role(role).Valid() {
+			return nil, fmt.Errorf("%s: unknown role %q", oidcRoleMap, role)
+		}
+		cfg.roleMapping[value] = role
+	}
+
+	return cfg, nil
+}
+
+func (c *oidcCfg) Enabled() bool {
+	return c.issuerURL != ""
+}
+
+func (c *oidcCfg) IssuerURL() string {
+	return c.issuerURL
+}
+
+func (c *oidcCfg) ClientID() string {
+	return c.clientID
+}
+
+func (c *oidcCfg) ClientSecret() string {
+	return c.clientSecret
+}
+
+func (c *oidcCfg) RedirectURL() string {
+	return c.redirectURL
+}
+
+func (c *oidcCfg) Scopes() []string {
+	return c.scopes
+}
+
+func (c *oidcCfg) UsernameClaim() string {
+	return c.usernameClaim
+}
+
+func (c *oidcCfg) RoleClaim() string {
+	return c.roleClaim
+}
+
+func (c *oidcCfg) RoleMapping() map[string]string {
+	return c.roleMapping
+}
+
+func (c *oidcCfg) DefaultRole() string {
+	return c.defaultRole
+}
+
+func (c *oidcCfg) LoginTTL() time.Duration {
+	return c.loginTTL
+}
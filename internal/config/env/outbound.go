@@ -0,0 +1,45 @@
+package env
+
+import (
+	"chatsrv/internal/config"
+	"time"
+)
+
+type outboundCfg struct {
+	queueSize    int
+	writeTimeout time.Duration
+	policy       config.SlowConsumerPolicy
+	blockTimeout time.Duration
+}
+
+func NewOutboundConfig() *outboundCfg {
+	policy := config.SlowConsumerPolicy(config.GetEnvStringOrDefault("WS_SLOW_CONSUMER_POLICY", string(config.SlowConsumerDropOldest)))
+	switch policy {
+	case config.SlowConsumerDropOldest, config.SlowConsumerDropClient, config.SlowConsumerBlock:
+	default:
+		policy = config.SlowConsumerDropOldest
+	}
+
+	return &outboundCfg{
+		queueSize:    config.GetEnvIntOrDefault("WS_QUEUE_SIZE", 256),
+		writeTimeout: config.GetEnvDurationOrDefault("WS_WRITE_TIMEOUT", 10*time.Second),
+		policy:       policy,
+		blockTimeout: config.GetEnvDurationOrDefault("WS_BLOCK_TIMEOUT", time.Second),
+	}
+}
+
+func (c *outboundCfg) QueueSize() int {
+	return c.queueSize
+}
+
+func (c *outboundCfg) WriteTimeout() time.Duration {
+	return c.writeTimeout
+}
+
+func (c *outboundCfg) SlowConsumerPolicy() config.SlowConsumerPolicy {
+	return c.policy
+}
+
+func (c *outboundCfg) BlockTimeout() time.Duration {
+	return c.blockTimeout
+}
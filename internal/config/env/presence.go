@@ -0,0 +1,45 @@
+package env
+
+import (
+	"time"
+
+	"chatsrv/internal/config"
+)
+
+type presenceCfg struct {
+	backend config.PresenceBackend
+	prefix  string
+	lease   time.Duration
+}
+
+func NewPresenceConfig() *presenceCfg {
+	backend := config.PresenceBackend(config.GetEnvStringOrDefault("CHAT_PRESENCE", string(config.PresenceMemory)))
+	switch backend {
+	case config.PresenceMemory, config.PresenceRedis:
+	default:
+		backend = config.PresenceMemory
+	}
+
+	lease := config.GetEnvDurationOrDefault("CHAT_PRESENCE_LEASE", 30*time.Second)
+	if lease <= 0 {
+		lease = 30 * time.Second
+	}
+
+	return &presenceCfg{
+		backend: backend,
+		prefix:  config.GetEnvStringOrDefault("CHAT_PRESENCE_PREFIX", "ichat:presence:"),
+		lease:   lease,
+	}
+}
+
+func (c *presenceCfg) Backend() config.PresenceBackend {
+	return c.backend
+}
+
+func (c *presenceCfg) Prefix() string {
+	return c.prefix
+}
+
+func (c *presenceCfg) Lease() time.Duration {
+	return c.lease
+}
@@ -0,0 +1,70 @@
+package env
+
+import (
+	"chatsrv/internal/config"
+	"time"
+)
+
+type rateLimitCfg struct {
+	message         config.RateLimit
+	userMessage     config.RateLimit
+	join            config.RateLimit
+	userJoin        config.RateLimit
+	typing          config.RateLimit
+	userTyping      config.RateLimit
+	maxViolations   int
+	violationWindow time.Duration
+}
+
+func NewRateLimitConfig() *rateLimitCfg {
+	return &rateLimitCfg{
+		message:         rateLimitFromEnv("WS_MESSAGE", 60, 10),
+		userMessage:     rateLimitFromEnv("WS_USER_MESSAGE", 120, 20),
+		join:            rateLimitFromEnv("WS_JOIN", 30, 10),
+		userJoin:        rateLimitFromEnv("WS_USER_JOIN", 60, 20),
+		typing:          rateLimitFromEnv("WS_TYPING", 60, 5),
+		userTyping:      rateLimitFromEnv("WS_USER_TYPING", 120, 10),
+		maxViolations:   config.GetEnvIntOrDefault("WS_MAX_VIOLATIONS", 10),
+		violationWindow: config.GetEnvDurationOrDefault("WS_VIOLATION_WINDOW", time.Minute),
+	}
+}
+
+// rateLimitFromEnv reads <prefix>_RATE_PER_MINUTE and <prefix>_BURST.
+func rateLimitFromEnv(prefix string, perMinute int, burst int) config.RateLimit {
+	return config.RateLimit{
+		PerMinute: config.GetEnvIntOrDefault(prefix+"_RATE_PER_MINUTE", perMinute),
+		Burst:     config.GetEnvIntOrDefault(prefix+"_BURST", burst),
+	}
+}
+
+func (c *rateLimitCfg) MessageLimit() config.RateLimit {
+	return c.message
+}
+
+func (c *rateLimitCfg) UserMessageLimit() config.RateLimit {
+	return c.userMessage
+}
+
+func (c *rateLimitCfg) JoinLimit() config.RateLimit {
+	return c.join
+}
+
+func (c *rateLimitCfg) UserJoinLimit() config.RateLimit {
+	return c.userJoin
+}
+
+func (c *rateLimitCfg) TypingLimit() config.RateLimit {
+	return c.typing
+}
+
+func (c *rateLimitCfg) UserTypingLimit() config.RateLimit {
+	return c.userTyping
+}
+
+func (c *rateLimitCfg) MaxViolations() int {
+	return c.maxViolations
+}
+
+func (c *rateLimitCfg) ViolationWindow() time.Duration {
+	return c.violationWindow
+}
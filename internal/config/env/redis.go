@@ -0,0 +1,42 @@
+package env
+
+import (
+	"chatsrv/internal/config"
+	"net"
+)
+
+type redisConfig struct {
+	address  string
+	password string
+	db       int
+}
+
+const (
+	rdhost     = "RD_HOST"
+	rdport     = "RD_PORT"
+	rdpassword = "RD_PASSWORD"
+	rddb       = "RD_DB"
+)
+
+func NewRedisConfig() *redisConfig {
+	host := config.GetEnvStringOrDefault(rdhost, "0.0.0.0")
+	port := config.GetEnvStringOrDefault(rdport, "6379")
+
+	return &redisConfig{
+		address:  net.JoinHostPort(host, port),
+		password: config.GetEnvStringOrDefault(rdpassword, ""),
+		db:       config.GetEnvIntOrDefault(rddb, 0),
+	}
+}
+
+func (cfg *redisConfig) Address() string {
+	return cfg.address
+}
+
+func (cfg *redisConfig) Password() string {
+	return cfg.password
+}
+
+func (cfg *redisConfig) DB() int {
+	return cfg.db
+}
@@ -0,0 +1,49 @@
+package env
+
+import (
+	"chatsrv/internal/config"
+	"time"
+)
+
+type webhookCfg struct {
+	maxAttempts  int
+	baseBackoff  time.Duration
+	maxBackoff   time.Duration
+	pollInterval time.Duration
+	timeout      time.Duration
+}
+
+func NewWebhookConfig() *webhookCfg {
+	pollInterval := config.GetEnvDurationOrDefault("WEBHOOK_POLL_INTERVAL", 2*time.Second)
+	if pollInterval <= 0 {
+		pollInterval = 2 * time.Second
+	}
+
+	return &webhookCfg{
+		maxAttempts:  config.GetEnvIntOrDefault("WEBHOOK_MAX_ATTEMPTS", 8),
+		baseBackoff:  config.GetEnvDurationOrDefault("WEBHOOK_BASE_BACKOFF", 5*time.Second),
+		maxBackoff:   config.GetEnvDurationOrDefault("WEBHOOK_MAX_BACKOFF", time.Hour),
+		pollInterval: pollInterval,
+		timeout:      config.GetEnvDurationOrDefault("WEBHOOK_TIMEOUT", 10*time.Second),
+	}
+}
+
+func (c *webhookCfg) MaxAttempts() int {
+	return c.maxAttempts
+}
+
+func (c *webhookCfg) BaseBackoff() time.Duration {
+	return c.baseBackoff
+}
+
+func (c *webhookCfg) MaxBackoff() time.Duration {
+	return c.maxBackoff
+}
+
+func (c *webhookCfg) PollInterval() time.Duration {
+	return c.pollInterval
+}
+
+func (c *webhookCfg) Timeout() time.Duration {
+	return c.timeout
+}
@@ -0,0 +1,62 @@
+package authctrl
+
+import (
+	userdomain "chatsrv/internal/domain/user"
+	"encoding/json"
+	"net/http"
+)
+
+// CreateServiceAccount implements controller.AuthController.
+func (c *implementation) CreateServiceAccount(w http.ResponseWriter, r *http.Request) {
+	var req userdomain.CreateServiceAccountRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "failed to decode request", http.StatusBadRequest)
+		return
+	}
+
+	user, err := c.srv.CreateServiceAccount(r.Context(), req)
+	if err != nil {
+		c.writeError(w, err, "failed to create service account")
+		return
+	}
+
+	c.writeJSON(w, http.StatusCreated, user)
+}
+
+// CreateAPIKey implements controller.AuthController.
+func (c *implementation) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	var req userdomain.CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "failed to decode request", http.StatusBadRequest)
+		return
+	}
+
+	key, err := c.srv.CreateAPIKey(r.Context(), r.PathValue("id"), req)
+	if err != nil {
+		c.writeError(w, err, "failed to create api key")
+		return
+	}
+
+	c.writeJSON(w, http.StatusCreated, key)
+}
+
+// GetAPIKeys implements controller.AuthController.
+func (c *implementation) GetAPIKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := c.srv.GetAPIKeys(r.Context(), r.PathValue("id"))
+	if err != nil {
+		c.writeError(w, err, "failed to get api keys")
+		return
+	}
+
+	c.writeJSON(w, http.StatusOK, keys)
+}
+
+// RevokeAPIKey implements controller.AuthController.
+func (c *implementation) RevokeAPIKey(w http.ResponseWriter, r *http.Request) {
+	if err := c.srv.RevokeAPIKey(r.Context(), r.PathValue("id")); err != nil {
+		c.writeError(w, err, "failed to revoke api key")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
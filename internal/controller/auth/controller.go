@@ -0,0 +1,185 @@
+package authctrl
+
+import (
+	"chatsrv/internal/auth"
+	"chatsrv/internal/controller"
+	chatdomain "chatsrv/internal/domain/chat"
+	userdomain "chatsrv/internal/domain/user"
+	"chatsrv/internal/service"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+var _ controller.AuthController = (*implementation)(nil)
+
+type Option func(*implementation)
+
+func WithLogger(log *zap.Logger) Option {
+	return func(i *implementation) {
+		i.log = log
+	}
+}
+
+func WithService(srv service.AuthService) Option {
+	return func(i *implementation) {
+		i.srv = srv
+	}
+}
+
+func NewAuthController(opts ...Option) controller.AuthController {
+	impl := &implementation{}
+
+	for _, opt := range opts {
+		opt(impl)
+	}
+
+	return impl
+}
+
+type implementation struct {
+	log *zap.Logger
+	srv service.AuthService
+}
+
+// Register implements controller.AuthController.
+func (c *implementation) Register(w http.ResponseWriter, r *http.Request) {
+	var req userdomain.RegisterRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "failed to decode request", http.StatusBadRequest)
+		return
+	}
+
+	tok, err := c.srv.Register(r.Context(), req, clientMeta(r))
+	if err != nil {
+		c.writeError(w, err, "failed to register")
+		return
+	}
+
+	c.writeJSON(w, http.StatusCreated, tok)
+}
+
+// Login implements controller.AuthController.
+func (c *implementation) Login(w http.ResponseWriter, r *http.Request) {
+	var req userdomain.LoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "failed to decode request", http.StatusBadRequest)
+		return
+	}
+
+	tok, err := c.srv.Login(r.Context(), req, clientMeta(r))
+	if err != nil {
+		c.writeError(w, err, "failed to log in")
+		return
+	}
+
+	c.writeJSON(w, http.StatusOK, tok)
+}
+
+// Refresh implements controller.AuthController.
+func (c *implementation) Refresh(w http.ResponseWriter, r *http.Request) {
+	var req userdomain.RefreshRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "failed to decode request", http.StatusBadRequest)
+		return
+	}
+
+	tok, err := c.srv.Refresh(r.Context(), req.RefreshToken, clientMeta(r))
+	if err != nil {
+		c.writeError(w, err, "failed to refresh token")
+		return
+	}
+
+	c.writeJSON(w, http.StatusOK, tok)
+}
+
+// Logout implements controller.AuthController.
+func (c *implementation) Logout(w http.ResponseWriter, r *http.Request) {
+	principal := auth.FromContext(r.Context())
+	if principal == nil {
+		c.writeError(w, userdomain.ErrUnauthenticated, "")
+		return
+	}
+
+	if err := c.srv.Logout(r.Context(), principal); err != nil {
+		c.writeError(w, err, "failed to log out")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Me implements controller.AuthController.
+func (c *implementation) Me(w http.ResponseWriter, r *http.Request) {
+	principal := auth.FromContext(r.Context())
+	if principal == nil {
+		c.writeError(w, userdomain.ErrUnauthenticated, "")
+		return
+	}
+
+	user, err := c.srv.GetUser(r.Context(), principal.UserID)
+	if err != nil {
+		c.writeError(w, err, "failed to get user")
+		return
+	}
+
+	c.writeJSON(w, http.StatusOK, user)
+}
+
+// clientMeta describes the client of r for the session it opens.
+func clientMeta(r *http.Request) userdomain.ClientMeta {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+
+	return userdomain.ClientMeta{
+		IP:        ip,
+		UserAgent: r.UserAgent(),
+	}
+}
+
+func (c *implementation) writeError(w http.ResponseWriter, err error, msg string) {
+	switch {
+	case errors.Is(err, userdomain.ErrUnauthenticated),
+		errors.Is(err, userdomain.ErrInvalidCredentials),
+		errors.Is(err, userdomain.ErrRefreshTokenReused),
+		errors.Is(err, userdomain.ErrOIDCLoginFailed):
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	case errors.Is(err, userdomain.ErrUserNotFound),
+		errors.Is(err, userdomain.ErrSessionNotFound),
+		errors.Is(err, userdomain.ErrOIDCDisabled),
+		errors.Is(err, userdomain.ErrAPIKeyNotFound),
+		errors.Is(err, chatdomain.ErrChatNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, userdomain.ErrRoleNotGranted):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, userdomain.ErrUsernameTaken):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, userdomain.ErrInvalidUsername),
+		errors.Is(err, userdomain.ErrInvalidPassword),
+		errors.Is(err, userdomain.ErrInvalidAPIKey),
+		errors.Is(err, userdomain.ErrNotServiceAccount),
+		errors.Is(err, userdomain.ErrInvalidLoginState):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		c.log.Error(msg, zap.Error(err))
+		http.Error(w, msg, http.StatusInternalServerError)
+	}
+}
+
+func (c *implementation) writeJSON(w http.ResponseWriter, status int, v any) {
+	resp, err := json.Marshal(v)
+	if err != nil {
+		c.log.Error("failed to marshal response", zap.Error(err))
+		http.Error(w, "failed to marshal response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(resp)
+}
@@ -0,0 +1,41 @@
+package authctrl
+
+import (
+	userdomain "chatsrv/internal/domain/user"
+	"fmt"
+	"net/http"
+)
+
+// OIDCLogin implements controller.AuthController. It sends the user to the
+// identity provider.
+func (c *implementation) OIDCLogin(w http.ResponseWriter, r *http.Request) {
+	url, err := c.srv.OIDCLoginURL(r.Context())
+	if err != nil {
+		c.writeError(w, err, "failed to start OIDC login")
+		return
+	}
+
+	http.Redirect(w, r, url, http.StatusFound)
+}
+
+// OIDCCallback implements controller.AuthController. The identity provider
+// redirects the user here with either a code or an error.
+func (c *implementation) OIDCCallback(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	if e := query.Get("error"); e != "" {
+		if desc := query.Get("error_description"); desc != "" {
+			e += ": " + desc
+		}
+		err := fmt.Errorf("%w: %s", userdomain.ErrOIDCLoginFailed, e)
+		c.writeError(w, err, "")
+		return
+	}
+
+	tok, err := c.srv.OIDCCallback(r.Context(), query.Get("code"), query.Get("state"), clientMeta(r))
+	if err != nil {
+		c.writeError(w, err, "failed to finish OIDC login")
+		return
+	}
+
+	c.writeJSON(w, http.StatusOK, tok)
+}
@@ -0,0 +1,40 @@
+package authctrl
+
+import (
+	"chatsrv/internal/auth"
+	userdomain "chatsrv/internal/domain/user"
+	"net/http"
+)
+
+// GetSessions implements controller.AuthController.
+func (c *implementation) GetSessions(w http.ResponseWriter, r *http.Request) {
+	principal := auth.FromContext(r.Context())
+	if principal == nil {
+		c.writeError(w, userdomain.ErrUnauthenticated, "")
+		return
+	}
+
+	sessions, err := c.srv.GetSessions(r.Context(), principal)
+	if err != nil {
+		c.writeError(w, err, "failed to get sessions")
+		return
+	}
+
+	c.writeJSON(w, http.StatusOK, sessions)
+}
+
+// RevokeSession implements controller.AuthController.
+func (c *implementation) RevokeSession(w http.ResponseWriter, r *http.Request) {
+	principal := auth.FromContext(r.Context())
+	if principal == nil {
+		c.writeError(w, userdomain.ErrUnauthenticated, "")
+		return
+	}
+
+	if err := c.srv.RevokeSession(r.Context(), principal, r.PathValue("id")); err != nil {
+		c.writeError(w, err, "failed to revoke session")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
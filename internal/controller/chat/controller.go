@@ -1,13 +1,18 @@
 package chatctrl
 
 import (
+	"chatsrv/internal/auth"
 	"chatsrv/internal/controller"
 	chatdomain "chatsrv/internal/domain/chat"
 	msgdomain "chatsrv/internal/domain/msg"
+	userdomain "chatsrv/internal/domain/user"
 	"chatsrv/internal/service"
+	"chatsrv/internal/wsutil"
 	"encoding/json"
-	"fmt"
+	"errors"
 	"net/http"
+	"strconv"
+	"strings"
 
 	"go.uber.org/zap"
 	"golang.org/x/net/websocket"
@@ -52,81 +57,189 @@ func (c *implementation) CreateChat(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, "failed to decode request", http.StatusBadRequest)
 		return
 	}
+	req.IdempotencyKey = r.Header.Get(HeaderIdempotencyKey)
 
-	chat, err := c.srv.CreateChat(r.Context(), req.Name)
+	chat, err := c.srv.CreateChat(r.Context(), callerID(r), req)
 	if err != nil {
-		c.log.Error("failed to create chat", zap.Error(err))
-		http.Error(w, "failed to create chat", http.StatusInternalServerError)
+		c.writeError(w, err, "failed to create chat")
 		return
 	}
 
-	resp, err := json.Marshal(chat)
+	w.Header().Set("Location", "/chats/"+chat.ID)
+	c.writeJSON(w, http.StatusCreated, chat)
+}
+
+// CreateDirectChat implements controller.ChatController.
+func (c *implementation) CreateDirectChat(w http.ResponseWriter, r *http.Request) {
+	userID := callerID(r)
+	if userID == "" {
+		c.writeError(w, userdomain.ErrUnauthenticated, "")
+		return
+	}
+
+	chat, created, err := c.srv.CreateDirectChat(r.Context(), userID, r.PathValue("userId"))
 	if err != nil {
-		c.log.Error("failed to marshal chat", zap.Error(err))
-		http.Error(w, "failed to marshal chat", http.StatusInternalServerError)
+		c.writeError(w, err, "failed to create direct chat")
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.Write(resp)
+	status := http.StatusOK
+	if created {
+		status = http.StatusCreated
+	}
+	c.writeJSON(w, status, chat)
 }
 
 // GetChats implements controller.ChatController.
 func (c *implementation) GetChats(w http.ResponseWriter, r *http.Request) {
-	chats, err := c.srv.GetChats(r.Context())
-	if err != nil {
-		c.log.Error("failed to get chats", zap.Error(err))
-		http.Error(w, "failed to get chats", http.StatusInternalServerError)
+	query := r.URL.Query()
+	req := chatdomain.ListChatsRequest{
+		UserID:   callerID(r),
+		Query:    strings.TrimSpace(query.Get("q")),
+		Sort:     chatdomain.Sort(query.Get("sort")),
+		Archived: chatdomain.ArchivedFilter(query.Get("archived")),
+	}
+	if req.Sort == "" {
+		req.Sort = chatdomain.SortActivity
+	}
+	if !req.Sort.Valid() {
+		http.Error(w, "sort must be activity, created or name", http.StatusBadRequest)
+		return
+	}
+	if req.Archived == "" {
+		req.Archived = chatdomain.ArchivedExclude
+	}
+	if !req.Archived.Valid() {
+		http.Error(w, "archived must be true, false or any", http.StatusBadRequest)
 		return
 	}
+	if v := query.Get("mine"); v != "" {
+		mine, err := strconv.ParseBool(v)
+		if err != nil {
+			http.Error(w, "mine must be a boolean", http.StatusBadRequest)
+			return
+		}
+		req.Mine = mine
+	}
+	if v := query.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 0 {
+			http.Error(w, "limit must be a non-negative integer", http.StatusBadRequest)
+			return
+		}
+		req.Limit = limit
+	}
+	if v := query.Get("cursor"); v != "" {
+		cursor, err := chatdomain.DecodeCursor(v, req.Sort)
+		if err != nil {
+			c.writeError(w, err, "failed to get chats")
+			return
+		}
+		req.Cursor = cursor
+	}
 
-	resp, err := json.Marshal(chats)
+	page, err := c.srv.ListChats(r.Context(), req)
 	if err != nil {
-		c.log.Error("failed to marshal chats", zap.Error(err))
-		http.Error(w, "failed to marshal chats", http.StatusInternalServerError)
+		c.writeError(w, err, "failed to get chats")
 		return
 	}
 
-	w.Header().Set("Content-Type", "application/json")
-	w.Write(resp)
+	c.writeJSON(w, http.StatusOK, page)
 }
 
-func (c *implementation) HandleWebSocket(ws *websocket.Conn) {
-	var client msgdomain.Message
+// MarkRead implements controller.ChatController.
+func (c *implementation) MarkRead(w http.ResponseWriter, r *http.Request) {
+	userID := callerID(r)
+	if userID == "" {
+		c.writeError(w, userdomain.ErrUnauthenticated, "")
+		return
+	}
 
-	defer func() {
-		if client.SenderID != "" {
-			c.srv.HandleDisconnect(ws, client.SenderID)
+	if err := c.srv.MarkRead(r.Context(), userID, r.PathValue("id")); err != nil {
+		c.writeError(w, err, "failed to mark chat read")
+		return
+	}
 
-			msg := msgdomain.Message{
-				Content:  fmt.Sprintf("%s left chat", client.SenderID),
-				SenderID: "SYSTEM",
-				ChatID:   client.ChatID,
-			}
-			c.srv.GetIncomeMessage(ws, msg)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// chatProtocol returns the version of the chat protocol ws negotiated, or
+// "" for clients that did not ask for one.
+func chatProtocol(ws *websocket.Conn) string {
+	for _, protocol := range ws.Config().Protocol {
+		if msgdomain.IsProtocol(protocol) {
+			return protocol
+		}
+	}
+	return ""
+}
+
+// HandleWebSocket implements controller.ChatController. The connection
+// belongs to the principal authenticated during the upgrade, and every
+// message it sends is attributed to that user whatever its sender and bot
+// fields say.
+func (c *implementation) HandleWebSocket(ws *websocket.Conn) {
+	principal := auth.FromContext(ws.Request().Context())
+	if principal == nil {
+		c.log.Error("WebSocket connection without principal")
+		ws.Close()
+		return
+	}
+	userID := principal.UserID
+
+	protocol := chatProtocol(ws)
+	if protocol != "" && !msgdomain.SupportsProtocol(protocol) {
+		c.log.Info("WebSocket client with unsupported protocol",
+			zap.String("protocol", protocol),
+			zap.String("user", userID))
+		reason := "unsupported protocol " + protocol + ", supported: " + strings.Join(msgdomain.SupportedProtocols, ", ")
+		if err := wsutil.Close(ws, wsutil.CloseProtocolError, reason); err != nil {
+			c.log.Error("error close websocket connection", zap.Error(err))
 		}
+		return
+	}
+
+	connectErr := c.srv.HandleConnect(ws, protocol)
+	defer func() {
+		// HandleDisconnect tells the rooms the client was in that it left.
+		c.srv.HandleDisconnect(ws, userID)
 		err := ws.Close()
 		if err != nil {
 			c.log.Error("error close websocket connection", zap.Error(err))
 		}
 	}()
 
-	c.log.Info("WebSocket client connected", zap.String("local", ws.LocalAddr().String()))
+	if connectErr != nil {
+		c.log.Error("failed to welcome websocket client", zap.Error(connectErr))
+		return
+	}
+
+	c.log.Info("WebSocket client connected",
+		zap.String("local", ws.LocalAddr().String()),
+		zap.String("user", userID),
+		zap.String("protocol", protocol))
 
-	var msg msgdomain.Message
 	for {
 		select {
 		case <-ws.Request().Context().Done():
 			c.log.Info("WebSocket client context done")
 			return
 		default:
+			var msg msgdomain.Message
 			err := websocket.JSON.Receive(ws, &msg)
 			if err != nil {
 				c.log.Info("WebSocket client disconnected", zap.Error(err))
 				return
 			}
-			client = msg
+			msg.SenderID = userID
+			msg.Username = ""
+			msg.Bot = principal.Bot
 			err = c.srv.GetIncomeMessage(ws, msg)
+			if errors.Is(err, msgdomain.ErrRateLimited) {
+				// The client has been sent an error frame already.
+				c.log.Debug("rate limited income message", zap.String("user", userID), zap.Error(err))
+				continue
+			}
 			if err != nil {
 				c.log.Error("error getting income message", zap.Error(err))
 				continue
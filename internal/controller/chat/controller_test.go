@@ -1,6 +1,9 @@
 package chatctrl
 
 import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"testing"
 
 	"go.uber.org/zap"
@@ -77,3 +80,24 @@ func BenchmarkControllerCreation(b *testing.B) {
 		_ = NewChatController(WithLogger(logger))
 	}
 }
+
+// TestAnonymousCallersUnauthenticated verifies handlers that need a user
+// answer anonymous callers with the same 401 as the other protected routes
+func TestAnonymousCallersUnauthenticated(t *testing.T) {
+	ctrl := NewChatController(WithLogger(zap.NewNop()))
+	handlers := map[string]http.HandlerFunc{
+		"CreateDirectChat": ctrl.CreateDirectChat,
+		"MarkRead":         ctrl.MarkRead,
+		"AcceptInvite":     ctrl.AcceptInvite,
+		"SendMessage":      ctrl.SendMessage,
+		"Events":           ctrl.Events,
+		"PollEvents":       ctrl.PollEvents,
+	}
+	for name, handler := range handlers {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest("POST", "/", nil))
+		if rec.Code != http.StatusUnauthorized || strings.TrimSpace(rec.Body.String()) != "unauthenticated" {
+			t.Errorf("%s: expected 401 unauthenticated, got %d %q", name, rec.Code, rec.Body.String())
+		}
+	}
+}
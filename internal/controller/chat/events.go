@@ -0,0 +1,172 @@
+package chatctrl
+
+import (
+	msgdomain "chatsrv/internal/domain/msg"
+	userdomain "chatsrv/internal/domain/user"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// sseKeepAlive is how often an idle event stream gets a comment, so that
+// proxies do not time it out.
+const sseKeepAlive = 25 * time.Second
+
+// sseWriteTimeout bounds each write to an event stream, so that a client
+// that stopped reading is dropped.
+const sseWriteTimeout = 10 * time.Second
+
+var errStreamClosed = errors.New("event stream closed")
+
+// SendMessage implements controller.ChatController.
+func (c *implementation) SendMessage(w http.ResponseWriter, r *http.Request) {
+	userID := callerID(r)
+	if userID == "" {
+		c.writeError(w, userdomain.ErrUnauthenticated, "")
+		return
+	}
+
+	var req msgdomain.SendMessageRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		c.log.Error("failed to decode request", zap.Error(err))
+		http.Error(w, "failed to decode request", http.StatusBadRequest)
+		return
+	}
+
+	msg, err := c.srv.SendMessage(r.Context(), userID, r.PathValue("id"), req)
+	if err != nil {
+		c.writeError(w, err, "failed to send message")
+		return
+	}
+
+	c.writeJSON(w, http.StatusCreated, msg)
+}
+
+// Events implements controller.ChatController. It streams the events of
+// the chats named by the chat_id parameters as Server-Sent Events, each
+// with the frame's action as its event type.
+func (c *implementation) Events(w http.ResponseWriter, r *http.Request) {
+	userID := callerID(r)
+	if userID == "" {
+		c.writeError(w, userdomain.ErrUnauthenticated, "")
+		return
+	}
+	rc := http.NewResponseController(w)
+
+	var (
+		mu     sync.Mutex
+		closed bool
+	)
+	write := func(event string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		if closed {
+			return errStreamClosed
+		}
+		if err := rc.SetWriteDeadline(time.Now().Add(sseWriteTimeout)); err != nil {
+			c.log.Debug("failed to set write deadline", zap.Error(err))
+		}
+		// The stream outlives the server's write timeout.
+		defer rc.SetWriteDeadline(time.Time{})
+		if _, err := fmt.Fprint(w, event); err != nil {
+			return err
+		}
+		return rc.Flush()
+	}
+	send := func(frame msgdomain.Frame) error {
+		event := fmt.Sprintf("event: %s\ndata: %s\n\n", frame.Action, frame.Data)
+		if frame.ID != "" {
+			event = "id: " + frame.ID + "\n" + event
+		}
+		return write(event)
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+
+	unsubscribe, dropped, err := c.srv.Subscribe(r.Context(), userID, r.URL.Query()["chat_id"], send)
+	if err != nil {
+		w.Header().Del("Cache-Control")
+		w.Header().Del("X-Accel-Buffering")
+		c.writeError(w, err, "failed to subscribe")
+		return
+	}
+	defer func() {
+		unsubscribe()
+		// Rooms may still hold send for a broadcast in flight.
+		mu.Lock()
+		closed = true
+		mu.Unlock()
+	}()
+
+	if err := write(": connected\n\n"); err != nil {
+		c.log.Error("failed to start event stream", zap.Error(err))
+		return
+	}
+
+	keepAlive := time.NewTicker(sseKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-dropped:
+			c.log.Debug("event stream dropped", zap.String("user", userID))
+			return
+		case <-keepAlive.C:
+			if err := write(": keep-alive\n\n"); err != nil {
+				c.log.Debug("event stream closed", zap.String("user", userID), zap.Error(err))
+				return
+			}
+		}
+	}
+}
+
+// PollEvents implements controller.ChatController. The first poll passes
+// the chats as chat_id parameters; later ones pass the poll_id returned.
+// timeout is in seconds.
+func (c *implementation) PollEvents(w http.ResponseWriter, r *http.Request) {
+	userID := callerID(r)
+	if userID == "" {
+		c.writeError(w, userdomain.ErrUnauthenticated, "")
+		return
+	}
+
+	query := r.URL.Query()
+	req := msgdomain.PollRequest{
+		PollID:  query.Get("poll_id"),
+		ChatIDs: query["chat_id"],
+	}
+	if v := query.Get("timeout"); v != "" {
+		seconds, err := strconv.Atoi(v)
+		if err != nil || seconds < 0 {
+			http.Error(w, "timeout must be a non-negative number of seconds", http.StatusBadRequest)
+			return
+		}
+		req.Timeout = time.Duration(seconds) * time.Second
+	}
+
+	// The poll may wait longer than the server's write timeout.
+	rc := http.NewResponseController(w)
+	if err := rc.SetWriteDeadline(time.Now().Add(msgdomain.MaxPollTimeout + 10*time.Second)); err != nil {
+		c.log.Debug("failed to extend write deadline", zap.Error(err))
+	}
+
+	resp, err := c.srv.Poll(r.Context(), userID, req)
+	if err != nil {
+		if r.Context().Err() != nil {
+			return
+		}
+		c.writeError(w, err, "failed to poll events")
+		return
+	}
+
+	c.writeJSON(w, http.StatusOK, resp)
+}
@@ -0,0 +1,80 @@
+package chatctrl
+
+import (
+	"bufio"
+	msgdomain "chatsrv/internal/domain/msg"
+	"chatsrv/internal/service"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// subscribeService is a service.ChatService that only streams: Subscribe
+// sends the events it holds and records the chats asked for
+type subscribeService struct {
+	service.ChatService
+	events  []msgdomain.Message
+	chatIDs chan []string
+}
+
+func (s *subscribeService) Subscribe(ctx context.Context, userID string, chatIDs []string, send func(msgdomain.Frame) error) (func(), <-chan struct{}, error) {
+	s.chatIDs <- chatIDs
+	go func() {
+		for _, msg := range s.events {
+			data, _ := json.Marshal(msg)
+			send(msgdomain.Frame{ID: msg.ID, Action: msg.Action, Data: data})
+		}
+	}()
+	return func() {}, make(chan struct{}), nil
+}
+
+// TestEventsStreamsFrames verifies room events are written as Server-Sent
+// Events named after their action
+func TestEventsStreamsFrames(t *testing.T) {
+	srv := &subscribeService{
+		events:  []msgdomain.Message{{ID: "m1", Action: string(msgdomain.ActionSendText), ChatID: "c1", Content: "hi"}},
+		chatIDs: make(chan []string, 1),
+	}
+	ctrl := NewChatController(WithLogger(zap.NewNop()), WithService(srv))
+	server := httptest.NewServer(http.HandlerFunc(ctrl.Events))
+	defer server.Close()
+
+	req, _ := http.NewRequest("GET", server.URL+"/events?chat_id=c1&chat_id=c2", nil)
+	req.Header.Set(HeaderUserID, "alice")
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	resp, err := http.DefaultClient.Do(req.WithContext(ctx))
+	if err != nil {
+		t.Fatalf("GET /events failed: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Errorf("Expected an event stream, got %q", ct)
+	}
+	if chatIDs := <-srv.chatIDs; len(chatIDs) != 2 {
+		t.Errorf("Expected both chats to be subscribed, got %v", chatIDs)
+	}
+
+	var event []string
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" && len(event) > 0 {
+			break
+		}
+		if line != "" && !strings.HasPrefix(line, ":") {
+			event = append(event, line)
+		}
+	}
+	if len(event) != 3 || event[0] != "id: m1" || event[1] != "event: send_text" ||
+		!strings.HasPrefix(event[2], "data: ") || !strings.Contains(event[2], `"content":"hi"`) {
+		t.Errorf("Unexpected event %q", event)
+	}
+}
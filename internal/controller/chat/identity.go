@@ -0,0 +1,25 @@
+package chatctrl
+
+import (
+	"chatsrv/internal/auth"
+	"net/http"
+	"strings"
+)
+
+// HeaderUserID identifies callers that do not send a bearer token. The
+// value is not verified, so it is only honoured while AUTH_ALLOW_USER_HEADER
+// is on.
+const HeaderUserID = "X-User-ID"
+
+// HeaderIdempotencyKey lets clients retry POST /chats without creating the
+// chat twice.
+const HeaderIdempotencyKey = "Idempotency-Key"
+
+// callerID returns the authenticated user, falling back to HeaderUserID.
+// It is empty for anonymous callers, who get userdomain.ErrUnauthenticated.
+func callerID(r *http.Request) string {
+	if principal := auth.FromContext(r.Context()); principal != nil {
+		return principal.UserID
+	}
+	return strings.TrimSpace(r.Header.Get(HeaderUserID))
+}
@@ -0,0 +1,76 @@
+package chatctrl
+
+import (
+	invitedomain "chatsrv/internal/domain/invite"
+	userdomain "chatsrv/internal/domain/user"
+	"encoding/json"
+	"net"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+// CreateInvite implements controller.ChatController.
+func (c *implementation) CreateInvite(w http.ResponseWriter, r *http.Request) {
+	var req invitedomain.CreateInviteRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		c.log.Error("failed to decode request", zap.Error(err))
+		http.Error(w, "failed to decode request", http.StatusBadRequest)
+		return
+	}
+
+	invite, err := c.srv.CreateInvite(r.Context(), callerID(r), r.PathValue("id"), req)
+	if err != nil {
+		c.writeError(w, err, "failed to create invite")
+		return
+	}
+
+	c.writeJSON(w, http.StatusCreated, invite)
+}
+
+// GetInvites implements controller.ChatController.
+func (c *implementation) GetInvites(w http.ResponseWriter, r *http.Request) {
+	invites, err := c.srv.GetInvites(r.Context(), callerID(r), r.PathValue("id"))
+	if err != nil {
+		c.writeError(w, err, "failed to get invites")
+		return
+	}
+
+	c.writeJSON(w, http.StatusOK, invites)
+}
+
+// RevokeInvite implements controller.ChatController.
+func (c *implementation) RevokeInvite(w http.ResponseWriter, r *http.Request) {
+	err := c.srv.RevokeInvite(r.Context(), callerID(r), r.PathValue("id"), r.PathValue("inviteId"))
+	if err != nil {
+		c.writeError(w, err, "failed to revoke invite")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AcceptInvite implements controller.ChatController.
+func (c *implementation) AcceptInvite(w http.ResponseWriter, r *http.Request) {
+	userID := callerID(r)
+	if userID == "" {
+		c.writeError(w, userdomain.ErrUnauthenticated, "")
+		return
+	}
+
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		ip = r.RemoteAddr
+	}
+
+	member, err := c.srv.AcceptInvite(r.Context(), userID, r.PathValue("token"), invitedomain.AcceptMeta{
+		IP:        ip,
+		UserAgent: r.UserAgent(),
+	})
+	if err != nil {
+		c.writeError(w, err, "failed to accept invite")
+		return
+	}
+
+	c.writeJSON(w, http.StatusOK, member)
+}
@@ -0,0 +1,68 @@
+package chatctrl
+
+import (
+	chatdomain "chatsrv/internal/domain/chat"
+	"encoding/json"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+// GetChat implements controller.ChatController.
+func (c *implementation) GetChat(w http.ResponseWriter, r *http.Request) {
+	chat, err := c.srv.GetChat(r.Context(), callerID(r), r.PathValue("id"))
+	if err != nil {
+		c.writeError(w, err, "failed to get chat")
+		return
+	}
+
+	c.writeJSON(w, http.StatusOK, chat)
+}
+
+// UpdateChat implements controller.ChatController.
+func (c *implementation) UpdateChat(w http.ResponseWriter, r *http.Request) {
+	var req chatdomain.UpdateChatRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		c.log.Error("failed to decode request", zap.Error(err))
+		http.Error(w, "failed to decode request", http.StatusBadRequest)
+		return
+	}
+
+	chat, err := c.srv.UpdateChat(r.Context(), callerID(r), r.PathValue("id"), req)
+	if err != nil {
+		c.writeError(w, err, "failed to update chat")
+		return
+	}
+
+	c.writeJSON(w, http.StatusOK, chat)
+}
+
+// ArchiveChat implements controller.ChatController.
+func (c *implementation) ArchiveChat(w http.ResponseWriter, r *http.Request) {
+	c.setArchived(w, r, true)
+}
+
+// UnarchiveChat implements controller.ChatController.
+func (c *implementation) UnarchiveChat(w http.ResponseWriter, r *http.Request) {
+	c.setArchived(w, r, false)
+}
+
+// DeleteChat implements controller.ChatController.
+func (c *implementation) DeleteChat(w http.ResponseWriter, r *http.Request) {
+	if err := c.srv.DeleteChat(r.Context(), callerID(r), r.PathValue("id")); err != nil {
+		c.writeError(w, err, "failed to delete chat")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *implementation) setArchived(w http.ResponseWriter, r *http.Request, archived bool) {
+	chat, err := c.srv.SetArchived(r.Context(), callerID(r), r.PathValue("id"), archived)
+	if err != nil {
+		c.writeError(w, err, "failed to archive chat")
+		return
+	}
+
+	c.writeJSON(w, http.StatusOK, chat)
+}
@@ -0,0 +1,157 @@
+package chatctrl
+
+import (
+	chatdomain "chatsrv/internal/domain/chat"
+	idempotencydomain "chatsrv/internal/domain/idempotency"
+	invitedomain "chatsrv/internal/domain/invite"
+	msgdomain "chatsrv/internal/domain/msg"
+	userdomain "chatsrv/internal/domain/user"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// GetMessages implements controller.ChatController.
+func (c *implementation) GetMessages(w http.ResponseWriter, r *http.Request) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	var before time.Time
+	if v := r.URL.Query().Get("before"); v != "" {
+		t, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			http.Error(w, "before must be an RFC 3339 timestamp", http.StatusBadRequest)
+			return
+		}
+		before = t
+	}
+
+	messages, err := c.srv.GetMessages(r.Context(), callerID(r), r.PathValue("id"), before, limit)
+	if err != nil {
+		c.writeError(w, err, "failed to get messages")
+		return
+	}
+
+	c.writeJSON(w, http.StatusOK, messages)
+}
+
+// GetMembers implements controller.ChatController.
+func (c *implementation) GetMembers(w http.ResponseWriter, r *http.Request) {
+	members, err := c.srv.GetMembers(r.Context(), callerID(r), r.PathValue("id"))
+	if err != nil {
+		c.writeError(w, err, "failed to get members")
+		return
+	}
+
+	c.writeJSON(w, http.StatusOK, members)
+}
+
+// AddMember implements controller.ChatController.
+func (c *implementation) AddMember(w http.ResponseWriter, r *http.Request) {
+	var req chatdomain.AddMemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		c.log.Error("failed to decode request", zap.Error(err))
+		http.Error(w, "failed to decode request", http.StatusBadRequest)
+		return
+	}
+
+	member, err := c.srv.AddMember(r.Context(), callerID(r), r.PathValue("id"), req)
+	if err != nil {
+		c.writeError(w, err, "failed to add member")
+		return
+	}
+
+	c.writeJSON(w, http.StatusCreated, member)
+}
+
+// RemoveMember implements controller.ChatController.
+func (c *implementation) RemoveMember(w http.ResponseWriter, r *http.Request) {
+	err := c.srv.RemoveMember(r.Context(), callerID(r), r.PathValue("id"), r.PathValue("userId"))
+	if err != nil {
+		c.writeError(w, err, "failed to remove member")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// UpdateMember implements controller.ChatController.
+func (c *implementation) UpdateMember(w http.ResponseWriter, r *http.Request) {
+	var req chatdomain.UpdateMemberRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		c.log.Error("failed to decode request", zap.Error(err))
+		http.Error(w, "failed to decode request", http.StatusBadRequest)
+		return
+	}
+
+	member, err := c.srv.UpdateMemberRole(r.Context(), callerID(r), r.PathValue("id"), r.PathValue("userId"), req)
+	if err != nil {
+		c.writeError(w, err, "failed to update member")
+		return
+	}
+
+	c.writeJSON(w, http.StatusOK, member)
+}
+
+// writeError maps domain errors to HTTP statuses. Anything unknown is
+// logged and reported as msg with a 500.
+func (c *implementation) writeError(w http.ResponseWriter, err error, msg string) {
+	var rateErr *msgdomain.RateLimitError
+	switch {
+	case errors.As(err, &rateErr):
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(rateErr.RetryAfter.Seconds()))))
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+	case errors.Is(err, userdomain.ErrUnauthenticated):
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+	case errors.Is(err, chatdomain.ErrChatNotFound),
+		errors.Is(err, chatdomain.ErrNotRestricted),
+		errors.Is(err, invitedomain.ErrInviteNotFound),
+		errors.Is(err, msgdomain.ErrPollNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, invitedomain.ErrInviteRevoked),
+		errors.Is(err, invitedomain.ErrInviteExpired),
+		errors.Is(err, invitedomain.ErrInviteExhausted):
+		http.Error(w, err.Error(), http.StatusGone)
+	case errors.Is(err, chatdomain.ErrChatArchived),
+		errors.Is(err, chatdomain.ErrChatNameTaken),
+		errors.Is(err, idempotencydomain.ErrInProgress):
+		http.Error(w, err.Error(), http.StatusConflict)
+	case errors.Is(err, chatdomain.ErrNotMember),
+		errors.Is(err, chatdomain.ErrForbidden),
+		errors.Is(err, chatdomain.ErrBanned),
+		errors.Is(err, chatdomain.ErrMuted):
+		http.Error(w, err.Error(), http.StatusForbidden)
+	case errors.Is(err, chatdomain.ErrInvalidChat),
+		errors.Is(err, chatdomain.ErrInvalidMember),
+		errors.Is(err, chatdomain.ErrInvalidDirectChat),
+		errors.Is(err, chatdomain.ErrInvalidModeration),
+		errors.Is(err, chatdomain.ErrInvalidCursor),
+		errors.Is(err, invitedomain.ErrInvalidInvite),
+		errors.Is(err, idempotencydomain.ErrInvalidKey),
+		errors.Is(err, msgdomain.ErrInvalidMessage),
+		errors.Is(err, msgdomain.ErrNoChats):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, idempotencydomain.ErrKeyReused):
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+	default:
+		c.log.Error(msg, zap.Error(err))
+		http.Error(w, msg, http.StatusInternalServerError)
+	}
+}
+
+func (c *implementation) writeJSON(w http.ResponseWriter, status int, v any) {
+	resp, err := json.Marshal(v)
+	if err != nil {
+		c.log.Error("failed to marshal response", zap.Error(err))
+		http.Error(w, "failed to marshal response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(resp)
+}
@@ -0,0 +1,86 @@
+package chatctrl
+
+import (
+	chatdomain "chatsrv/internal/domain/chat"
+	"encoding/json"
+	"net/http"
+
+	"go.uber.org/zap"
+)
+
+// Kick implements controller.ChatController.
+func (c *implementation) Kick(w http.ResponseWriter, r *http.Request) {
+	req, ok := c.decodeModeration(w, r)
+	if !ok {
+		return
+	}
+
+	if err := c.srv.Kick(r.Context(), callerID(r), r.PathValue("id"), req); err != nil {
+		c.writeError(w, err, "failed to kick member")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Ban implements controller.ChatController.
+func (c *implementation) Ban(w http.ResponseWriter, r *http.Request) {
+	req, ok := c.decodeModeration(w, r)
+	if !ok {
+		return
+	}
+
+	restriction, err := c.srv.Ban(r.Context(), callerID(r), r.PathValue("id"), req)
+	if err != nil {
+		c.writeError(w, err, "failed to ban member")
+		return
+	}
+
+	c.writeJSON(w, http.StatusCreated, restriction)
+}
+
+// Unban implements controller.ChatController.
+func (c *implementation) Unban(w http.ResponseWriter, r *http.Request) {
+	if err := c.srv.Unban(r.Context(), callerID(r), r.PathValue("id"), r.PathValue("userId")); err != nil {
+		c.writeError(w, err, "failed to unban member")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Mute implements controller.ChatController.
+func (c *implementation) Mute(w http.ResponseWriter, r *http.Request) {
+	req, ok := c.decodeModeration(w, r)
+	if !ok {
+		return
+	}
+
+	restriction, err := c.srv.Mute(r.Context(), callerID(r), r.PathValue("id"), req)
+	if err != nil {
+		c.writeError(w, err, "failed to mute member")
+		return
+	}
+
+	c.writeJSON(w, http.StatusCreated, restriction)
+}
+
+// Unmute implements controller.ChatController.
+func (c *implementation) Unmute(w http.ResponseWriter, r *http.Request) {
+	if err := c.srv.Unmute(r.Context(), callerID(r), r.PathValue("id"), r.PathValue("userId")); err != nil {
+		c.writeError(w, err, "failed to unmute member")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (c *implementation) decodeModeration(w http.ResponseWriter, r *http.Request) (chatdomain.ModerationRequest, bool) {
+	var req chatdomain.ModerationRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		c.log.Error("failed to decode request", zap.Error(err))
+		http.Error(w, "failed to decode request", http.StatusBadRequest)
+		return req, false
+	}
+	return req, true
+}
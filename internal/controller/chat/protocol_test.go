@@ -0,0 +1,75 @@
+package chatctrl
+
+import (
+	"bufio"
+	"chatsrv/internal/auth"
+	userdomain "chatsrv/internal/domain/user"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/net/websocket"
+)
+
+// TestHandleWebSocketRejectsUnknownProtocol verifies a client asking for a
+// protocol version the server does not speak is closed with a reason
+func TestHandleWebSocketRejectsUnknownProtocol(t *testing.T) {
+	ctrl := NewChatController(WithLogger(zap.NewNop()))
+	ws := websocket.Handler(ctrl.HandleWebSocket)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ws.ServeHTTP(w, r.WithContext(auth.NewContext(r.Context(), &userdomain.Principal{UserID: "alice"})))
+	}))
+	defer server.Close()
+
+	// The websocket client hides close frames, so speak the protocol by hand.
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+
+	req, _ := http.NewRequest("GET", server.URL+"/ws", nil)
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", "dGhlIHNhbXBsZSBub25jZQ==")
+	req.Header.Set("Sec-WebSocket-Version", "13")
+	req.Header.Set("Sec-WebSocket-Protocol", "ichat.v9")
+	req.Header.Set("Origin", server.URL)
+	if err := req.Write(conn); err != nil {
+		t.Fatalf("Write handshake failed: %v", err)
+	}
+
+	r := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(r, req)
+	if err != nil {
+		t.Fatalf("ReadResponse failed: %v", err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Sec-WebSocket-Protocol") != "ichat.v9" {
+		t.Fatalf("Expected the upgrade to succeed, got %d %v", resp.StatusCode, resp.Header)
+	}
+
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(r, header); err != nil {
+		t.Fatalf("Read frame failed: %v", err)
+	}
+	if header[0] != 0x88 {
+		t.Fatalf("Expected a close frame, got opcode %#x", header[0])
+	}
+	payload := make([]byte, header[1]&0x7f)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		t.Fatalf("Read close payload failed: %v", err)
+	}
+	if code := binary.BigEndian.Uint16(payload); code != 1002 {
+		t.Errorf("Expected status 1002, got %d", code)
+	}
+	if reason := string(payload[2:]); !strings.Contains(reason, "ichat.v9") || !strings.Contains(reason, "ichat.v1") {
+		t.Errorf("Expected the reason to name both versions, got %q", reason)
+	}
+}
@@ -10,4 +10,60 @@ type ChatController interface {
 	HandleWebSocket(ws *websocket.Conn)
 	GetChats(w http.ResponseWriter, r *http.Request)
 	CreateChat(w http.ResponseWriter, r *http.Request)
+	CreateDirectChat(w http.ResponseWriter, r *http.Request)
+	GetChat(w http.ResponseWriter, r *http.Request)
+	UpdateChat(w http.ResponseWriter, r *http.Request)
+	ArchiveChat(w http.ResponseWriter, r *http.Request)
+	UnarchiveChat(w http.ResponseWriter, r *http.Request)
+	DeleteChat(w http.ResponseWriter, r *http.Request)
+	GetMessages(w http.ResponseWriter, r *http.Request)
+	SendMessage(w http.ResponseWriter, r *http.Request)
+	Events(w http.ResponseWriter, r *http.Request)
+	PollEvents(w http.ResponseWriter, r *http.Request)
+	MarkRead(w http.ResponseWriter, r *http.Request)
+
+	GetMembers(w http.ResponseWriter, r *http.Request)
+	AddMember(w http.ResponseWriter, r *http.Request)
+	RemoveMember(w http.ResponseWriter, r *http.Request)
+	UpdateMember(w http.ResponseWriter, r *http.Request)
+
+	Kick(w http.ResponseWriter, r *http.Request)
+	Ban(w http.ResponseWriter, r *http.Request)
+	Unban(w http.ResponseWriter, r *http.Request)
+	Mute(w http.ResponseWriter, r *http.Request)
+	Unmute(w http.ResponseWriter, r *http.Request)
+
+	CreateInvite(w http.ResponseWriter, r *http.Request)
+	GetInvites(w http.ResponseWriter, r *http.Request)
+	RevokeInvite(w http.ResponseWriter, r *http.Request)
+	AcceptInvite(w http.ResponseWriter, r *http.Request)
+}
+
+type AuthController interface {
+	Register(w http.ResponseWriter, r *http.Request)
+	Login(w http.ResponseWriter, r *http.Request)
+	Refresh(w http.ResponseWriter, r *http.Request)
+	Logout(w http.ResponseWriter, r *http.Request)
+	Me(w http.ResponseWriter, r *http.Request)
+	GetSessions(w http.ResponseWriter, r *http.Request)
+	RevokeSession(w http.ResponseWriter, r *http.Request)
+	OIDCLogin(w http.ResponseWriter, r *http.Request)
+	OIDCCallback(w http.ResponseWriter, r *http.Request)
+
+	CreateServiceAccount(w http.ResponseWriter, r *http.Request)
+	CreateAPIKey(w http.ResponseWriter, r *http.Request)
+	GetAPIKeys(w http.ResponseWriter, r *http.Request)
+	RevokeAPIKey(w http.ResponseWriter, r *http.Request)
+}
+
+type WebhookController interface {
+	CreateWebhook(w http.ResponseWriter, r *http.Request)
+	GetWebhooks(w http.ResponseWriter, r *http.Request)
+	DeleteWebhook(w http.ResponseWriter, r *http.Request)
+	GetDeliveries(w http.ResponseWriter, r *http.Request)
+
+	CreateIncomingWebhook(w http.ResponseWriter, r *http.Request)
+	GetIncomingWebhooks(w http.ResponseWriter, r *http.Request)
+	DeleteIncomingWebhook(w http.ResponseWriter, r *http.Request)
+	PostIncoming(w http.ResponseWriter, r *http.Request)
 }
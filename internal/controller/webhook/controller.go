@@ -0,0 +1,133 @@
+package webhookctrl
+
+import (
+	"chatsrv/internal/controller"
+	webhookdomain "chatsrv/internal/domain/webhook"
+	"chatsrv/internal/service"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"go.uber.org/zap"
+)
+
+var _ controller.WebhookController = (*implementation)(nil)
+
+type Option func(*implementation)
+
+func WithLogger(log *zap.Logger) Option {
+	return func(i *implementation) {
+		i.log = log
+	}
+}
+
+func WithService(srv service.WebhookService) Option {
+	return func(i *implementation) {
+		i.srv = srv
+	}
+}
+
+func WithIncomingService(srv service.IncomingWebhookService) Option {
+	return func(i *implementation) {
+		i.incoming = srv
+	}
+}
+
+func NewWebhookController(opts ...Option) controller.WebhookController {
+	impl := &implementation{}
+
+	for _, opt := range opts {
+		opt(impl)
+	}
+
+	return impl
+}
+
+type implementation struct {
+	log      *zap.Logger
+	srv      service.WebhookService
+	incoming service.IncomingWebhookService
+}
+
+// CreateWebhook implements controller.WebhookController.
+func (c *implementation) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var req webhookdomain.CreateWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		c.log.Error("failed to decode request", zap.Error(err))
+		http.Error(w, "failed to decode request", http.StatusBadRequest)
+		return
+	}
+
+	webhook, err := c.srv.CreateWebhook(r.Context(), req)
+	if errors.Is(err, webhookdomain.ErrInvalidWebhook) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		c.log.Error("failed to create webhook", zap.Error(err))
+		http.Error(w, "failed to create webhook", http.StatusInternalServerError)
+		return
+	}
+
+	c.writeJSON(w, http.StatusCreated, webhook)
+}
+
+// GetWebhooks implements controller.WebhookController.
+func (c *implementation) GetWebhooks(w http.ResponseWriter, r *http.Request) {
+	webhooks, err := c.srv.GetWebhooks(r.Context())
+	if err != nil {
+		c.log.Error("failed to get webhooks", zap.Error(err))
+		http.Error(w, "failed to get webhooks", http.StatusInternalServerError)
+		return
+	}
+
+	c.writeJSON(w, http.StatusOK, webhooks)
+}
+
+// DeleteWebhook implements controller.WebhookController.
+func (c *implementation) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	err := c.srv.DeleteWebhook(r.Context(), r.PathValue("id"))
+	if errors.Is(err, webhookdomain.ErrWebhookNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		c.log.Error("failed to delete webhook", zap.Error(err))
+		http.Error(w, "failed to delete webhook", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetDeliveries implements controller.WebhookController.
+func (c *implementation) GetDeliveries(w http.ResponseWriter, r *http.Request) {
+	limit, _ := strconv.Atoi(r.URL.Query().Get("limit"))
+
+	deliveries, err := c.srv.GetDeliveries(r.Context(), r.PathValue("id"), limit)
+	if errors.Is(err, webhookdomain.ErrWebhookNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		c.log.Error("failed to get deliveries", zap.Error(err))
+		http.Error(w, "failed to get deliveries", http.StatusInternalServerError)
+		return
+	}
+
+	c.writeJSON(w, http.StatusOK, deliveries)
+}
+
+func (c *implementation) writeJSON(w http.ResponseWriter, status int, v any) {
+	resp, err := json.Marshal(v)
+	if err != nil {
+		c.log.Error("failed to marshal response", zap.Error(err))
+		http.Error(w, "failed to marshal response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(resp)
+}
@@ -0,0 +1,103 @@
+package webhookctrl
+
+import (
+	chatdomain "chatsrv/internal/domain/chat"
+	webhookdomain "chatsrv/internal/domain/webhook"
+	"encoding/json"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"go.uber.org/zap"
+)
+
+const maxIncomingBody = 64 << 10
+
+// CreateIncomingWebhook implements controller.WebhookController.
+func (c *implementation) CreateIncomingWebhook(w http.ResponseWriter, r *http.Request) {
+	var req webhookdomain.CreateIncomingWebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		c.log.Error("failed to decode request", zap.Error(err))
+		http.Error(w, "failed to decode request", http.StatusBadRequest)
+		return
+	}
+
+	hook, err := c.incoming.CreateIncomingWebhook(r.Context(), r.PathValue("id"), req)
+	switch {
+	case errors.Is(err, chatdomain.ErrChatNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, webhookdomain.ErrInvalidWebhook):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		c.log.Error("failed to create incoming webhook", zap.Error(err))
+		http.Error(w, "failed to create incoming webhook", http.StatusInternalServerError)
+		return
+	}
+
+	c.writeJSON(w, http.StatusCreated, hook)
+}
+
+// GetIncomingWebhooks implements controller.WebhookController.
+func (c *implementation) GetIncomingWebhooks(w http.ResponseWriter, r *http.Request) {
+	hooks, err := c.incoming.GetIncomingWebhooks(r.Context(), r.PathValue("id"))
+	if err != nil {
+		c.log.Error("failed to get incoming webhooks", zap.Error(err))
+		http.Error(w, "failed to get incoming webhooks", http.StatusInternalServerError)
+		return
+	}
+
+	c.writeJSON(w, http.StatusOK, hooks)
+}
+
+// DeleteIncomingWebhook implements controller.WebhookController.
+func (c *implementation) DeleteIncomingWebhook(w http.ResponseWriter, r *http.Request) {
+	err := c.incoming.DeleteIncomingWebhook(r.Context(), r.PathValue("id"))
+	if errors.Is(err, webhookdomain.ErrIncomingWebhookNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		c.log.Error("failed to delete incoming webhook", zap.Error(err))
+		http.Error(w, "failed to delete incoming webhook", http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// PostIncoming implements controller.WebhookController.
+func (c *implementation) PostIncoming(w http.ResponseWriter, r *http.Request) {
+	var req webhookdomain.IncomingMessage
+	if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxIncomingBody)).Decode(&req); err != nil {
+		http.Error(w, "failed to decode request", http.StatusBadRequest)
+		return
+	}
+
+	msg, err := c.incoming.Post(r.Context(), r.PathValue("token"), req)
+
+	var rateErr *webhookdomain.RateLimitError
+	switch {
+	case errors.As(err, &rateErr):
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(rateErr.RetryAfter.Seconds()))))
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+		return
+	case errors.Is(err, webhookdomain.ErrIncomingWebhookNotFound), errors.Is(err, chatdomain.ErrChatNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	case errors.Is(err, chatdomain.ErrChatArchived):
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	case errors.Is(err, webhookdomain.ErrInvalidIncomingMessage):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	case err != nil:
+		c.log.Error("failed to post incoming message", zap.Error(err))
+		http.Error(w, "failed to post message", http.StatusInternalServerError)
+		return
+	}
+
+	c.writeJSON(w, http.StatusCreated, msg)
+}
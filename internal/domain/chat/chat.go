@@ -1,10 +1,223 @@
 package chatdomain
 
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+type ChatType string
+
+const (
+	ChatTypeRoom   ChatType = "room"
+	ChatTypeDirect ChatType = "dm"
+)
+
+type Visibility string
+
+const (
+	VisibilityPublic  Visibility = "public"
+	VisibilityPrivate Visibility = "private"
+)
+
+type Role string
+
+const (
+	RoleOwner     Role = "owner"
+	RoleAdmin     Role = "admin"
+	RoleModerator Role = "moderator"
+	RoleMember    Role = "member"
+)
+
+// roleRanks orders roles from least to most privileged. Users that are not
+// members of a chat rank as RoleMember.
+var roleRanks = map[Role]int{
+	RoleMember:    1,
+	RoleModerator: 2,
+	RoleAdmin:     3,
+	RoleOwner:     4,
+}
+
+func (r Role) Valid() bool {
+	_, ok := roleRanks[r]
+	return ok
+}
+
+// AtLeast reports whether r is as privileged as other.
+func (r Role) AtLeast(other Role) bool {
+	return roleRanks[r] >= roleRanks[other]
+}
+
+// Outranks reports whether r is strictly more privileged than other.
+func (r Role) Outranks(other Role) bool {
+	return roleRanks[r] > roleRanks[other]
+}
+
 type Chat struct {
-	ID   string `json:"id"`
-	Name string `json:"name"`
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Type       ChatType   `json:"type"`
+	Visibility Visibility `json:"visibility"`
+
+	Topic       string     `json:"topic,omitempty"`
+	Description string     `json:"description,omitempty"`
+	ArchivedAt  *time.Time `json:"archived_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	// LastActivityAt is when the last message was posted, or the chat was
+	// created if it has none.
+	LastActivityAt time.Time `json:"last_activity_at"`
+
+	// NameKey is the case-folded name when room names must be unique.
+	NameKey string `json:"-"`
+
+	// Participants holds the two user IDs of a direct chat.
+	Participants []string `json:"participants,omitempty"`
+	// CounterpartID and CounterpartName describe the other participant of a
+	// direct chat from the caller's point of view.
+	CounterpartID   string `json:"counterpart_id,omitempty"`
+	CounterpartName string `json:"counterpart_name,omitempty"`
+}
+
+func (c *Chat) IsDirect() bool {
+	return c.Type == ChatTypeDirect
+}
+
+func (c *Chat) IsPrivate() bool {
+	return c.Visibility == VisibilityPrivate
+}
+
+const MaxNameLength = 100
+
+// ValidateName trims name and checks that it is 1 to MaxNameLength
+// characters of letters, digits, spaces and - _ . , ' # & ( ).
+func ValidateName(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" || utf8.RuneCountInString(name) > MaxNameLength {
+		return "", fmt.Errorf("%w: name must be 1-%d characters", ErrInvalidChat, MaxNameLength)
+	}
+	for _, r := range name {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == ' ' || strings.ContainsRune("-_.,'#&()", r) {
+			continue
+		}
+		return "", fmt.Errorf("%w: name must not contain %q", ErrInvalidChat, r)
+	}
+	return name, nil
+}
+
+// NameKey folds name for the unique-name check.
+func NameKey(name string) string {
+	return strings.ToLower(name)
+}
+
+// IsArchived reports whether the chat is read-only.
+func (c *Chat) IsArchived() bool {
+	return c.ArchivedAt != nil
+}
+
+// Counterpart returns the participant of a direct chat that is not userID.
+func (c *Chat) Counterpart(userID string) string {
+	for _, p := range c.Participants {
+		if p != userID {
+			return p
+		}
+	}
+	return userID
+}
+
+// DirectPair orders two user IDs so that (a, b) and (b, a) map to the same
+// direct chat.
+func DirectPair(userA, userB string) (string, string) {
+	if userA > userB {
+		return userB, userA
+	}
+	return userA, userB
+}
+
+type Member struct {
+	ChatID     string     `json:"chat_id"`
+	UserID     string     `json:"user_id"`
+	Role       Role       `json:"role"`
+	JoinedAt   time.Time  `json:"joined_at"`
+	LastReadAt *time.Time `json:"last_read_at,omitempty"`
+
+	// Online and Connections report whether the user is connected to any
+	// node, and through how many connections. They are not stored.
+	Online      bool `json:"online"`
+	Connections int  `json:"connections,omitempty"`
 }
 
 type CreateChatRequest struct {
-	Name string `json:"name"`
+	Name       string     `json:"name"`
+	Visibility Visibility `json:"visibility"`
+
+	// IdempotencyKey comes from the Idempotency-Key header. Retries with the
+	// same key return the chat created by the first request.
+	IdempotencyKey string `json:"-"`
+}
+
+// UpdateChatRequest is the body of PATCH /chats/{id}. Nil fields are left
+// unchanged.
+type UpdateChatRequest struct {
+	Name        *string `json:"name"`
+	Topic       *string `json:"topic"`
+	Description *string `json:"description"`
 }
+
+type AddMemberRequest struct {
+	UserID string `json:"user_id"`
+	Role   Role   `json:"role"`
+}
+
+type UpdateMemberRequest struct {
+	Role Role `json:"role"`
+}
+
+type RestrictionKind string
+
+const (
+	RestrictionBan  RestrictionKind = "ban"
+	RestrictionMute RestrictionKind = "mute"
+)
+
+// Restriction is a ban or mute of UserID in ChatID. A nil Until means it
+// lasts until it is lifted.
+type Restriction struct {
+	ChatID    string          `json:"chat_id"`
+	UserID    string          `json:"user_id"`
+	Kind      RestrictionKind `json:"kind"`
+	Reason    string          `json:"reason,omitempty"`
+	Until     *time.Time      `json:"until,omitempty"`
+	CreatedBy string          `json:"created_by"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+func (r *Restriction) Active(now time.Time) bool {
+	return r.Until == nil || r.Until.After(now)
+}
+
+// ModerationRequest is the body of the kick, ban and mute endpoints. Until
+// is ignored for kicks.
+type ModerationRequest struct {
+	UserID string     `json:"user_id"`
+	Reason string     `json:"reason"`
+	Until  *time.Time `json:"until"`
+}
+
+var (
+	ErrChatNotFound      = errors.New("chat not found")
+	ErrNotMember         = errors.New("user is not a member of this chat")
+	ErrForbidden         = errors.New("not allowed to manage this chat")
+	ErrInvalidChat       = errors.New("invalid chat")
+	ErrChatArchived      = errors.New("chat is archived")
+	ErrChatNameTaken     = errors.New("chat name is already taken")
+	ErrInvalidCursor     = errors.New("invalid cursor")
+	ErrInvalidMember     = errors.New("invalid member")
+	ErrInvalidDirectChat = errors.New("invalid direct chat")
+	ErrInvalidModeration = errors.New("invalid moderation action")
+	ErrNotRestricted     = errors.New("user is not restricted in this chat")
+	ErrBanned            = errors.New("user is banned from this chat")
+	ErrMuted             = errors.New("user is muted in this chat")
+)
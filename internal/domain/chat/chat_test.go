@@ -0,0 +1,68 @@
+package chatdomain
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestDirectPairIsUnordered verifies both orders map to the same pair
+func TestDirectPairIsUnordered(t *testing.T) {
+	a1, b1 := DirectPair("bob", "alice")
+	a2, b2 := DirectPair("alice", "bob")
+
+	if a1 != a2 || b1 != b2 {
+		t.Errorf("Expected same pair, got (%s, %s) and (%s, %s)", a1, b1, a2, b2)
+	}
+}
+
+// TestCounterpart verifies the other participant of a direct chat is found
+func TestCounterpart(t *testing.T) {
+	dm := &Chat{Type: ChatTypeDirect, Participants: []string{"alice", "bob"}}
+
+	if dm.Counterpart("alice") != "bob" {
+		t.Errorf("Expected counterpart bob, got %s", dm.Counterpart("alice"))
+	}
+	if dm.Counterpart("bob") != "alice" {
+		t.Errorf("Expected counterpart alice, got %s", dm.Counterpart("bob"))
+	}
+}
+
+// TestValidateName verifies names are trimmed and limited in length and
+// characters
+func TestValidateName(t *testing.T) {
+	name, err := ValidateName("  Release #42 (Q&A)  ")
+	if err != nil || name != "Release #42 (Q&A)" {
+		t.Errorf("Expected trimmed valid name, got %q, %v", name, err)
+	}
+
+	for _, bad := range []string{"", "   ", "<script>", "tab\there", strings.Repeat("a", MaxNameLength+1)} {
+		if _, err := ValidateName(bad); err == nil {
+			t.Errorf("Expected %q to be rejected", bad)
+		}
+	}
+}
+
+// TestCursorRoundTrip verifies cursors decode to what was encoded and are
+// rejected for another sort
+func TestCursorRoundTrip(t *testing.T) {
+	chat := &Chat{ID: "c1", Name: "General", CreatedAt: time.Now(), LastActivityAt: time.Now()}
+
+	for _, sort := range []Sort{SortActivity, SortCreated, SortName} {
+		cursor := CursorAfter(sort, chat)
+		decoded, err := DecodeCursor(cursor.Encode(), sort)
+		if err != nil {
+			t.Fatalf("DecodeCursor(%s) failed: %v", sort, err)
+		}
+		if *decoded != *cursor {
+			t.Errorf("Expected %+v, got %+v", cursor, decoded)
+		}
+	}
+
+	if _, err := DecodeCursor(CursorAfter(SortName, chat).Encode(), SortActivity); err != ErrInvalidCursor {
+		t.Errorf("Expected ErrInvalidCursor for another sort, got %v", err)
+	}
+	if _, err := DecodeCursor("not a cursor", SortName); err != ErrInvalidCursor {
+		t.Errorf("Expected ErrInvalidCursor for garbage, got %v", err)
+	}
+}
@@ -0,0 +1,119 @@
+package chatdomain
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+type Sort string
+
+const (
+	// SortActivity lists the most recently active chats first.
+	SortActivity Sort = "activity"
+	// SortCreated lists the newest chats first.
+	SortCreated Sort = "created"
+	// SortName lists chats alphabetically, ignoring case.
+	SortName Sort = "name"
+)
+
+func (s Sort) Valid() bool {
+	return s == SortActivity || s == SortCreated || s == SortName
+}
+
+type ArchivedFilter string
+
+const (
+	ArchivedExclude ArchivedFilter = "false"
+	ArchivedOnly    ArchivedFilter = "true"
+	ArchivedAny     ArchivedFilter = "any"
+)
+
+func (f ArchivedFilter) Valid() bool {
+	return f == ArchivedExclude || f == ArchivedOnly || f == ArchivedAny
+}
+
+// ListChatsRequest selects a page of the chats visible to UserID.
+type ListChatsRequest struct {
+	UserID   string
+	Query    string
+	Sort     Sort
+	Archived ArchivedFilter
+	// Mine limits the list to chats UserID is a member of.
+	Mine   bool
+	Limit  int
+	Cursor *Cursor
+}
+
+// ChatSummary is a chat as shown in the chat list.
+type ChatSummary struct {
+	Chat
+	MemberCount int             `json:"member_count"`
+	UnreadCount int             `json:"unread_count"`
+	LastMessage *MessagePreview `json:"last_message,omitempty"`
+}
+
+type MessagePreview struct {
+	ID        string    `json:"id"`
+	SenderID  string    `json:"sender"`
+	Content   string    `json:"content"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ChatPage struct {
+	Chats      []*ChatSummary `json:"chats"`
+	NextCursor string         `json:"next_cursor,omitempty"`
+}
+
+// Cursor is the position after the last chat of a page. Key is the sort
+// value of that chat and ID breaks ties.
+type Cursor struct {
+	Sort Sort   `json:"s"`
+	Key  string `json:"k"`
+	ID   string `json:"id"`
+}
+
+// CursorAfter returns the cursor that continues a listing sorted by sort
+// after chat.
+func CursorAfter(sort Sort, chat *Chat) *Cursor {
+	cursor := &Cursor{Sort: sort, ID: chat.ID}
+	switch sort {
+	case SortName:
+		cursor.Key = strings.ToLower(chat.Name)
+	case SortCreated:
+		cursor.Key = chat.CreatedAt.Format(time.RFC3339Nano)
+	default:
+		cursor.Key = chat.LastActivityAt.Format(time.RFC3339Nano)
+	}
+	return cursor
+}
+
+// Time returns Key as a timestamp for the time-based sorts.
+func (c *Cursor) Time() (time.Time, error) {
+	return time.Parse(time.RFC3339Nano, c.Key)
+}
+
+func (c *Cursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeCursor parses a cursor produced by Encode for a listing sorted by
+// sort.
+func DecodeCursor(s string, sort Sort) (*Cursor, error) {
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+	var cursor Cursor
+	if err := json.Unmarshal(b, &cursor); err != nil || cursor.Sort != sort || cursor.ID == "" {
+		return nil, ErrInvalidCursor
+	}
+	if sort != SortName {
+		if _, err := cursor.Time(); err != nil {
+			return nil, ErrInvalidCursor
+		}
+	}
+	return &cursor, nil
+}
@@ -0,0 +1,35 @@
+package idempotencydomain
+
+import (
+	"errors"
+	"time"
+)
+
+// Record remembers the resource created by a request carrying an
+// Idempotency-Key, so that a retry returns it instead of creating another.
+// ResourceID is empty while the first request is still in flight.
+type Record struct {
+	Scope       string    `json:"scope"`
+	UserID      string    `json:"user_id"`
+	Key         string    `json:"key"`
+	RequestHash string    `json:"-"`
+	ResourceID  string    `json:"resource_id,omitempty"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// Done reports whether the original request has completed.
+func (r *Record) Done() bool {
+	return r.ResourceID != ""
+}
+
+const MaxKeyLength = 255
+
+var (
+	ErrInvalidKey = errors.New("invalid idempotency key")
+	// ErrKeyReused is returned when a key is sent again with a different
+	// request body.
+	ErrKeyReused = errors.New("idempotency key was used with a different request")
+	// ErrInProgress is returned when a retry arrives before the original
+	// request has completed.
+	ErrInProgress = errors.New("a request with this idempotency key is in progress")
+)
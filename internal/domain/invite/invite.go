@@ -0,0 +1,75 @@
+package invitedomain
+
+import (
+	chatdomain "chatsrv/internal/domain/chat"
+	"errors"
+	"time"
+)
+
+// Invite is a shareable link into a chat. Token is only set on creation;
+// afterwards only its hash is stored.
+type Invite struct {
+	ID        string          `json:"id"`
+	ChatID    string          `json:"chat_id"`
+	Token     string          `json:"token,omitempty"`
+	TokenHash string          `json:"-"`
+	CreatedBy string          `json:"created_by"`
+	Role      chatdomain.Role `json:"role"`
+	MaxUses   int             `json:"max_uses"`
+	Uses      int             `json:"uses"`
+	ExpiresAt *time.Time      `json:"expires_at,omitempty"`
+	RevokedAt *time.Time      `json:"revoked_at,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// Check reports why the invite cannot be accepted at now, if it cannot.
+func (i *Invite) Check(now time.Time) error {
+	switch {
+	case i.RevokedAt != nil:
+		return ErrInviteRevoked
+	case i.ExpiresAt != nil && !now.Before(*i.ExpiresAt):
+		return ErrInviteExpired
+	case i.MaxUses > 0 && i.Uses >= i.MaxUses:
+		return ErrInviteExhausted
+	}
+	return nil
+}
+
+type CreateInviteRequest struct {
+	// MaxUses of zero means unlimited.
+	MaxUses int `json:"max_uses"`
+	// ExpiresIn is a Go duration such as "24h". Empty means never.
+	ExpiresIn string          `json:"expires_in"`
+	Role      chatdomain.Role `json:"role"`
+}
+
+type AcceptResult string
+
+const (
+	AcceptJoined        AcceptResult = "joined"
+	AcceptAlreadyMember AcceptResult = "already_member"
+)
+
+// Acceptance is the audit record written for every accepted invite.
+type Acceptance struct {
+	InviteID  string
+	ChatID    string
+	UserID    string
+	Result    AcceptResult
+	IP        string
+	UserAgent string
+}
+
+// AcceptMeta describes the request that accepted an invite.
+type AcceptMeta struct {
+	IP        string
+	UserAgent string
+}
+
+var (
+	ErrInviteNotFound  = errors.New("invite not found")
+	ErrInviteRevoked   = errors.New("invite has been revoked")
+	ErrInviteExpired   = errors.New("invite has expired")
+	ErrInviteExhausted = errors.New("invite has no uses left")
+	ErrInvalidInvite   = errors.New("invalid invite")
+)
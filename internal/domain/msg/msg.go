@@ -1,5 +1,11 @@
 package msgdomain
 
+import (
+	chatdomain "chatsrv/internal/domain/chat"
+	"errors"
+	"time"
+)
+
 type ActionType string
 
 const (
@@ -8,11 +14,118 @@ const (
 	ActionJoinChat   ActionType = "join_chat"
 	ActionLeaveChat  ActionType = "leave_chat"
 	ActionCreateChat ActionType = "create_chat"
+	// ActionTyping tells the room the sender is typing. It is relayed but
+	// not stored.
+	ActionTyping ActionType = "typing"
+
+	// Moderation actions. The target user is taken from TargetID.
+	ActionKick   ActionType = "kick"
+	ActionBan    ActionType = "ban"
+	ActionUnban  ActionType = "unban"
+	ActionMute   ActionType = "mute"
+	ActionUnmute ActionType = "unmute"
+
+	// ActionSystem marks frames generated by the server. They carry an Event
+	// and have no sender.
+	ActionSystem ActionType = "system"
+	// ActionError answers a frame the server refused. It carries an Error.
+	ActionError ActionType = "error"
+	// ActionPing asks the other side to answer with ActionPong, carrying
+	// back the ID of the ping. Browsers cannot see protocol-level pings, so
+	// they use these to check the connection.
+	ActionPing ActionType = "ping"
+	ActionPong ActionType = "pong"
+	// ActionWelcome opens a connection that negotiated a protocol. It
+	// carries a Welcome.
+	ActionWelcome ActionType = "welcome"
 )
 
 type Message struct {
-	Action   string `json:"action"`
-	Content  string `json:"content"`
-	SenderID string `json:"sender"`
-	ChatID   string `json:"chat_id"`
+	ID          string       `json:"id,omitempty"`
+	Action      string       `json:"action"`
+	Content     string       `json:"content"`
+	SenderID    string       `json:"sender"`
+	ChatID      string       `json:"chat_id"`
+	Username    string       `json:"username,omitempty"`
+	Attachments []Attachment `json:"attachments,omitempty"`
+	CreatedAt   *time.Time   `json:"created_at,omitempty"`
+	Event       *SystemEvent `json:"event,omitempty"`
+	Error       *Error       `json:"error,omitempty"`
+	Welcome     *Welcome     `json:"welcome,omitempty"`
+	// Bot marks messages sent by service accounts.
+	Bot bool `json:"bot,omitempty"`
+
+	// TargetID, Reason and Until are the arguments of moderation actions.
+	TargetID string     `json:"target_id,omitempty"`
+	Reason   string     `json:"reason,omitempty"`
+	Until    *time.Time `json:"until,omitempty"`
+}
+
+type Attachment struct {
+	Title string `json:"title,omitempty"`
+	Text  string `json:"text,omitempty"`
+	URL   string `json:"url,omitempty"`
 }
+
+type SystemEventType string
+
+const (
+	EventMemberLeft        SystemEventType = "member_left"
+	EventMemberKicked      SystemEventType = "member_kicked"
+	EventMemberRemoved     SystemEventType = "member_removed"
+	EventMemberBanned      SystemEventType = "member_banned"
+	EventMemberUnbanned    SystemEventType = "member_unbanned"
+	EventMemberMuted       SystemEventType = "member_muted"
+	EventMemberUnmuted     SystemEventType = "member_unmuted"
+	EventMemberRoleChanged SystemEventType = "member_role_changed"
+	EventChatUpdated       SystemEventType = "chat_updated"
+	EventChatDeleted       SystemEventType = "chat_deleted"
+)
+
+// SystemEvent describes something that happened in a chat. UserID is the
+// user it happened to and ActorID the one who caused it, if anyone did.
+// Chat events carry the chat as it is after the change.
+type SystemEvent struct {
+	Type    SystemEventType  `json:"type"`
+	UserID  string           `json:"user_id,omitempty"`
+	ActorID string           `json:"actor_id,omitempty"`
+	Role    string           `json:"role,omitempty"`
+	Reason  string           `json:"reason,omitempty"`
+	Until   *time.Time       `json:"until,omitempty"`
+	Chat    *chatdomain.Chat `json:"chat,omitempty"`
+}
+
+type ErrorCode string
+
+const (
+	ErrorCodeRateLimited ErrorCode = "rate_limited"
+)
+
+// Error tells a client why its frame was refused. RetryAfterMs hints when
+// the same action may be tried again.
+type Error struct {
+	Code         ErrorCode `json:"code"`
+	Message      string    `json:"message"`
+	Action       string    `json:"action,omitempty"`
+	RetryAfterMs int64     `json:"retry_after_ms,omitempty"`
+}
+
+// RateLimitError is returned when a connection or its user exceeds the
+// budget of an action.
+type RateLimitError struct {
+	Action     string
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return "rate limit exceeded for " + e.Action
+}
+
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrRateLimited
+}
+
+var (
+	ErrRateLimited     = errors.New("rate limit exceeded")
+	ErrMessageNotFound = errors.New("message not found")
+)
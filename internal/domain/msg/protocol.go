@@ -0,0 +1,81 @@
+package msgdomain
+
+import (
+	"strings"
+	"time"
+)
+
+// Versions of the WebSocket protocol, negotiated as the subprotocol of the
+// upgrade. Clients that offer none speak ProtocolV1 without the handshake.
+const (
+	ProtocolPrefix = "ichat."
+	ProtocolV1     = "ichat.v1"
+)
+
+// SupportedProtocols lists the versions the server speaks, newest first.
+var SupportedProtocols = []string{ProtocolV1}
+
+// IsProtocol reports whether protocol names a version of the chat protocol,
+// supported or not.
+func IsProtocol(protocol string) bool {
+	return strings.HasPrefix(protocol, ProtocolPrefix)
+}
+
+// SupportsProtocol reports whether the server speaks protocol.
+func SupportsProtocol(protocol string) bool {
+	for _, supported := range SupportedProtocols {
+		if protocol == supported {
+			return true
+		}
+	}
+	return false
+}
+
+// Feature names an optional part of the protocol the server has enabled.
+type Feature string
+
+const (
+	FeatureTyping       Feature = "typing"
+	FeatureModeration   Feature = "moderation"
+	FeatureSystemEvents Feature = "system_events"
+	FeatureRateLimits   Feature = "rate_limits"
+	FeatureHeartbeat    Feature = "heartbeat"
+	// FeatureUniqueNames is enabled when room names are unique.
+	FeatureUniqueNames Feature = "unique_names"
+)
+
+// Welcome is the first frame of a connection that negotiated a protocol.
+type Welcome struct {
+	Protocol     string           `json:"protocol"`
+	ServerTime   time.Time        `json:"server_time"`
+	ConnectionID string           `json:"connection_id"`
+	User         WelcomeUser      `json:"user"`
+	Limits       map[string]Limit `json:"limits"`
+	Features     []Feature        `json:"features"`
+	// Heartbeat is set when the server pings the connection.
+	Heartbeat *Heartbeat `json:"heartbeat,omitempty"`
+}
+
+// Heartbeat tells a client how often it is pinged and how long it may stay
+// silent before it is disconnected.
+type Heartbeat struct {
+	IntervalMs int64 `json:"interval_ms"`
+	TimeoutMs  int64 `json:"timeout_ms"`
+}
+
+// WelcomeUser is the user a connection is authenticated as.
+type WelcomeUser struct {
+	ID          string `json:"id"`
+	Username    string `json:"username,omitempty"`
+	DisplayName string `json:"display_name,omitempty"`
+	Bot         bool   `json:"bot,omitempty"`
+}
+
+// Limit is the budget of a kind of action for a connection and for its
+// user. A rate of zero means the action is not limited.
+type Limit struct {
+	PerMinute     int `json:"per_minute"`
+	Burst         int `json:"burst"`
+	UserPerMinute int `json:"user_per_minute"`
+	UserBurst     int `json:"user_burst"`
+}
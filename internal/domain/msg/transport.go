@@ -0,0 +1,71 @@
+package msgdomain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	MaxContentLength = 4000
+	MaxAttachments   = 10
+
+	// MaxPollTimeout caps how long a long-poll request waits for events.
+	MaxPollTimeout = 55 * time.Second
+	// DefaultPollTimeout is used when a poll does not ask for a timeout.
+	DefaultPollTimeout = 25 * time.Second
+)
+
+// Frame is an event encoded once for all the streams it is sent to. Data
+// is the JSON of the event; it is shared, so it must not be changed or
+// kept once the send it was passed to returns.
+type Frame struct {
+	ID     string
+	Action string
+	Data   []byte
+}
+
+// SendMessageRequest is the body of POST /chats/{id}/messages.
+type SendMessageRequest struct {
+	Content     string       `json:"content"`
+	Attachments []Attachment `json:"attachments,omitempty"`
+}
+
+// Validate checks that req carries something within the limits.
+func (req SendMessageRequest) Validate() error {
+	if strings.TrimSpace(req.Content) == "" && len(req.Attachments) == 0 {
+		return fmt.Errorf("%w: content or attachments are required", ErrInvalidMessage)
+	}
+	if utf8.RuneCountInString(req.Content) > MaxContentLength {
+		return fmt.Errorf("%w: content exceeds %d characters", ErrInvalidMessage, MaxContentLength)
+	}
+	if len(req.Attachments) > MaxAttachments {
+		return fmt.Errorf("%w: at most %d attachments are allowed", ErrInvalidMessage, MaxAttachments)
+	}
+	return nil
+}
+
+// PollRequest asks for the events of a long-poll mailbox. The first poll
+// has no PollID and names the chats to subscribe to; later polls pass the
+// PollID they were given.
+type PollRequest struct {
+	PollID  string
+	ChatIDs []string
+	Timeout time.Duration
+}
+
+// PollResponse carries the events queued since the previous poll. Dropped
+// counts events lost because the mailbox overflowed.
+type PollResponse struct {
+	PollID  string    `json:"poll_id"`
+	Events  []Message `json:"events"`
+	Dropped int       `json:"dropped,omitempty"`
+}
+
+var (
+	ErrInvalidMessage = errors.New("invalid message")
+	ErrPollNotFound   = errors.New("poll not found")
+	ErrNoChats        = errors.New("at least one chat_id is required")
+)
@@ -0,0 +1,77 @@
+package userdomain
+
+import (
+	"errors"
+	"slices"
+	"time"
+)
+
+// Permission is what an API key may do in the chats it is scoped to.
+type Permission string
+
+const (
+	// PermissionRead covers listing chats, reading history and members and
+	// joining over WebSocket.
+	PermissionRead Permission = "read"
+	// PermissionPost covers sending messages and opening direct chats.
+	PermissionPost Permission = "post"
+	// PermissionManage covers creating, editing and moderating chats.
+	PermissionManage Permission = "manage"
+)
+
+func (p Permission) Valid() bool {
+	return p == PermissionRead || p == PermissionPost || p == PermissionManage
+}
+
+// APIKeyPrefix starts every API key so it can be told apart from login
+// tokens.
+const APIKeyPrefix = "ick_"
+
+// APIKey authenticates a service account. An empty ChatID lets the key act
+// in every chat the account can reach.
+type APIKey struct {
+	ID          string       `json:"id"`
+	UserID      string       `json:"user_id"`
+	Name        string       `json:"name"`
+	ChatID      string       `json:"chat_id,omitempty"`
+	Permissions []Permission `json:"permissions"`
+	KeyHash     string       `json:"-"`
+	CreatedAt   time.Time    `json:"created_at"`
+	LastUsedAt  *time.Time   `json:"last_used_at,omitempty"`
+	RevokedAt   *time.Time   `json:"revoked_at,omitempty"`
+
+	// Key is only set in the response to creation.
+	Key string `json:"key,omitempty"`
+}
+
+type CreateServiceAccountRequest struct {
+	Username    string `json:"username"`
+	DisplayName string `json:"display_name"`
+}
+
+type CreateAPIKeyRequest struct {
+	Name        string       `json:"name"`
+	ChatID      string       `json:"chat_id"`
+	Permissions []Permission `json:"permissions"`
+}
+
+// Allows reports whether the principal may use perm in chatID. Logins are
+// unrestricted; API keys are limited to their chat and permissions. An
+// empty chatID stands for requests that are not about a single chat.
+func (p *Principal) Allows(chatID string, perm Permission) bool {
+	if p.APIKeyID == "" {
+		return true
+	}
+	if p.ChatID != "" && p.ChatID != chatID {
+		return false
+	}
+	return slices.Contains(p.Permissions, perm)
+}
+
+var (
+	ErrAPIKeyNotFound = errors.New("api key not found")
+	ErrInvalidAPIKey  = errors.New("invalid api key")
+	// ErrNotServiceAccount is returned when an API key is requested for a
+	// human user.
+	ErrNotServiceAccount = errors.New("user is not a service account")
+)
@@ -0,0 +1,38 @@
+package userdomain
+
+import (
+	"errors"
+	"time"
+)
+
+// Identity links a user to its account at an OpenID Connect provider.
+type Identity struct {
+	Issuer    string
+	Subject   string
+	UserID    string
+	CreatedAt time.Time
+}
+
+// LoginState is what the server remembers of an OpenID Connect login between
+// sending the user to the provider and the provider sending it back. It is
+// looked up by State and used once.
+type LoginState struct {
+	State     string
+	Nonce     string
+	Verifier  string
+	ExpiresAt time.Time
+}
+
+var (
+	ErrOIDCDisabled = errors.New("OIDC login is not configured")
+	// ErrInvalidLoginState is returned for callbacks whose state is unknown,
+	// used or expired.
+	ErrInvalidLoginState = errors.New("invalid or expired login state")
+	// ErrOIDCLoginFailed wraps failures of the code exchange and of the ID
+	// token checks.
+	ErrOIDCLoginFailed = errors.New("OIDC login failed")
+	// ErrRoleNotGranted is returned when the claims of a user map to no role
+	// and there is no default role.
+	ErrRoleNotGranted = errors.New("no role granted by the identity provider")
+	ErrIdentityTaken  = errors.New("identity is already linked to a user")
+)
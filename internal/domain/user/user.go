@@ -0,0 +1,205 @@
+package userdomain
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+type User struct {
+	ID          string `json:"id"`
+	Username    string `json:"username"`
+	DisplayName string `json:"display_name"`
+	// Bot marks service accounts. They have no password and authenticate
+	// with API keys only.
+	Bot  bool `json:"bot"`
+	Role Role `json:"role"`
+	// PasswordHash is the bcrypt hash of the password. Users provisioned
+	// from an identity provider have none and cannot log in with one.
+	PasswordHash string    `json:"-"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// Role is the server-wide role of a user, as opposed to its role in a chat.
+type Role string
+
+const (
+	RoleUser Role = "user"
+	// RoleAdmin may use the admin API with its own login.
+	RoleAdmin Role = "admin"
+)
+
+func (r Role) Valid() bool {
+	return r == RoleUser || r == RoleAdmin
+}
+
+// Outranks reports whether r grants more than other.
+func (r Role) Outranks(other Role) bool {
+	return r == RoleAdmin && other != RoleAdmin
+}
+
+// Session is a login on one device. Its access token is short-lived and
+// renewed with refresh tokens until the session expires or is revoked.
+// Only hashes of the tokens are stored.
+type Session struct {
+	ID              string     `json:"id"`
+	UserID          string     `json:"user_id"`
+	TokenHash       string     `json:"-"`
+	DeviceName      string     `json:"device_name,omitempty"`
+	IP              string     `json:"ip,omitempty"`
+	UserAgent       string     `json:"user_agent,omitempty"`
+	CreatedAt       time.Time  `json:"created_at"`
+	LastSeenAt      time.Time  `json:"last_seen_at"`
+	AccessExpiresAt time.Time  `json:"-"`
+	ExpiresAt       time.Time  `json:"expires_at"`
+	RevokedAt       *time.Time `json:"revoked_at,omitempty"`
+
+	// Current marks the session of the caller in session lists.
+	Current bool `json:"current,omitempty"`
+}
+
+// Active reports whether the session can still be refreshed.
+func (s *Session) Active(now time.Time) bool {
+	return s.RevokedAt == nil && s.ExpiresAt.After(now)
+}
+
+// RefreshToken renews the access token of a session. Each one can be used
+// once; presenting a used one again means it leaked, and the whole session
+// is revoked.
+type RefreshToken struct {
+	ID        string
+	SessionID string
+	TokenHash string
+	CreatedAt time.Time
+	UsedAt    *time.Time
+}
+
+// ClientMeta describes the client a session is opened or refreshed from.
+type ClientMeta struct {
+	IP        string
+	UserAgent string
+}
+
+// Principal is the authenticated caller of a request. Exactly one of
+// SessionID and APIKeyID is set.
+type Principal struct {
+	UserID    string
+	SessionID string
+
+	APIKeyID string
+	// Bot is set for service accounts.
+	Bot bool
+	// ChatID and Permissions are the scope of the API key.
+	ChatID      string
+	Permissions []Permission
+}
+
+type RegisterRequest struct {
+	Username    string `json:"username"`
+	Password    string `json:"password"`
+	DisplayName string `json:"display_name"`
+	// DeviceName labels the session in the sessions list.
+	DeviceName string `json:"device_name"`
+}
+
+type LoginRequest struct {
+	Username   string `json:"username"`
+	Password   string `json:"password"`
+	DeviceName string `json:"device_name"`
+}
+
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// AuthToken is returned by registration, login and refresh. The tokens are
+// shown only once.
+type AuthToken struct {
+	Token            string    `json:"token"`
+	ExpiresAt        time.Time `json:"expires_at"`
+	RefreshToken     string    `json:"refresh_token"`
+	RefreshExpiresAt time.Time `json:"refresh_expires_at"`
+	SessionID        string    `json:"session_id"`
+	User             *User     `json:"user,omitempty"`
+}
+
+const (
+	MinUsernameLength    = 3
+	MaxUsernameLength    = 32
+	MaxDisplayNameLength = 64
+	MaxDeviceNameLength  = 64
+	MinPasswordLength    = 8
+	// MaxPasswordLength is the most bcrypt looks at.
+	MaxPasswordLength = 72
+)
+
+// ValidateUsername trims username and checks that it is MinUsernameLength
+// to MaxUsernameLength ASCII letters, digits and . _ -, starting with a
+// letter or digit.
+func ValidateUsername(username string) (string, error) {
+	username = strings.TrimSpace(username)
+	if len(username) < MinUsernameLength || len(username) > MaxUsernameLength {
+		return "", fmt.Errorf("%w: username must be %d-%d characters", ErrInvalidUsername, MinUsernameLength, MaxUsernameLength)
+	}
+	for i, r := range username {
+		alnum := r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9'
+		if alnum || i > 0 && strings.ContainsRune("._-", r) {
+			continue
+		}
+		if i == 0 {
+			return "", fmt.Errorf("%w: username must start with a letter or digit", ErrInvalidUsername)
+		}
+		return "", fmt.Errorf("%w: username must not contain %q", ErrInvalidUsername, r)
+	}
+	return username, nil
+}
+
+// ValidateDisplayName trims name and defaults it to username.
+func ValidateDisplayName(name string, username string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return username, nil
+	}
+	if utf8.RuneCountInString(name) > MaxDisplayNameLength {
+		return "", fmt.Errorf("%w: display name exceeds %d characters", ErrInvalidUsername, MaxDisplayNameLength)
+	}
+	return name, nil
+}
+
+func ValidatePassword(password string) error {
+	if len(password) < MinPasswordLength || len(password) > MaxPasswordLength {
+		return fmt.Errorf("%w: password must be %d-%d bytes", ErrInvalidPassword, MinPasswordLength, MaxPasswordLength)
+	}
+	return nil
+}
+
+// DeviceName labels a session. An empty name falls back to the user agent,
+// and long ones are cut rather than rejected so that logins never fail over
+// a label.
+func DeviceName(name string, userAgent string) string {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		name = strings.TrimSpace(userAgent)
+	}
+	if utf8.RuneCountInString(name) > MaxDeviceNameLength {
+		name = string([]rune(name)[:MaxDeviceNameLength])
+	}
+	return name
+}
+
+var (
+	ErrUserNotFound       = errors.New("user not found")
+	ErrUsernameTaken      = errors.New("username is already taken")
+	ErrInvalidUsername    = errors.New("invalid username")
+	ErrInvalidPassword    = errors.New("invalid password")
+	ErrInvalidCredentials = errors.New("invalid username or password")
+	// ErrUnauthenticated is returned for missing, unknown, expired and
+	// revoked tokens alike.
+	ErrUnauthenticated = errors.New("unauthenticated")
+	ErrSessionNotFound = errors.New("session not found")
+	// ErrRefreshTokenReused is returned when a used refresh token comes back.
+	// The session it belongs to is revoked by then.
+	ErrRefreshTokenReused = errors.New("refresh token was already used")
+)
@@ -0,0 +1,35 @@
+package userdomain
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// TestValidateUsername verifies usernames are trimmed and limited in length
+// and characters
+func TestValidateUsername(t *testing.T) {
+	name, err := ValidateUsername("  Alice_01  ")
+	if err != nil || name != "Alice_01" {
+		t.Errorf("Expected trimmed valid username, got %q, %v", name, err)
+	}
+
+	for _, bad := range []string{"", "ab", "_alice", "alice smith", "ålice", strings.Repeat("a", MaxUsernameLength+1)} {
+		if _, err := ValidateUsername(bad); !errors.Is(err, ErrInvalidUsername) {
+			t.Errorf("Expected %q to be rejected, got %v", bad, err)
+		}
+	}
+}
+
+// TestValidatePassword verifies the password length bounds
+func TestValidatePassword(t *testing.T) {
+	if err := ValidatePassword("short"); !errors.Is(err, ErrInvalidPassword) {
+		t.Errorf("Expected short password to be rejected, got %v", err)
+	}
+	if err := ValidatePassword(strings.Repeat("x", MaxPasswordLength+1)); !errors.Is(err, ErrInvalidPassword) {
+		t.Errorf("Expected long password to be rejected, got %v", err)
+	}
+	if err := ValidatePassword("correct horse"); err != nil {
+		t.Errorf("Expected valid password, got %v", err)
+	}
+}
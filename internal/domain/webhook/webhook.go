@@ -0,0 +1,146 @@
+package webhookdomain
+
+import (
+	msgdomain "chatsrv/internal/domain/msg"
+	"encoding/json"
+	"errors"
+	"slices"
+	"time"
+)
+
+type EventType string
+
+const (
+	EventMessageCreated EventType = "message.created"
+	// EventMessageEdited and EventMessageDeleted can be subscribed to, but
+	// nothing emits them until messages can be edited and deleted.
+	EventMessageEdited  EventType = "message.edited"
+	EventMessageDeleted EventType = "message.deleted"
+	EventMemberJoined   EventType = "member.joined"
+	EventMemberLeft     EventType = "member.left"
+	EventChatCreated    EventType = "chat.created"
+	EventChatUpdated    EventType = "chat.updated"
+	EventChatDeleted    EventType = "chat.deleted"
+)
+
+var EventTypes = []EventType{
+	EventMessageCreated,
+	EventMessageEdited,
+	EventMessageDeleted,
+	EventMemberJoined,
+	EventMemberLeft,
+	EventChatCreated,
+	EventChatUpdated,
+	EventChatDeleted,
+}
+
+func (e EventType) Valid() bool {
+	return slices.Contains(EventTypes, e)
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliveryDelivered DeliveryStatus = "delivered"
+	DeliveryDead      DeliveryStatus = "dead"
+)
+
+// Webhook is an integrator-registered endpoint. An empty ChatID means the
+// webhook receives events from every chat.
+type Webhook struct {
+	ID        string      `json:"id"`
+	ChatID    string      `json:"chat_id,omitempty"`
+	URL       string      `json:"url"`
+	Secret    string      `json:"secret,omitempty"`
+	Events    []EventType `json:"events"`
+	CreatedAt time.Time   `json:"created_at"`
+}
+
+func (w *Webhook) Matches(event Event) bool {
+	if w.ChatID != "" && w.ChatID != event.ChatID {
+		return false
+	}
+	return slices.Contains(w.Events, event.Type)
+}
+
+type CreateWebhookRequest struct {
+	ChatID string      `json:"chat_id"`
+	URL    string      `json:"url"`
+	Secret string      `json:"secret"`
+	Events []EventType `json:"events"`
+}
+
+// Event is the JSON body POSTed to webhook receivers.
+type Event struct {
+	ID         string    `json:"id"`
+	Type       EventType `json:"type"`
+	ChatID     string    `json:"chat_id,omitempty"`
+	OccurredAt time.Time `json:"occurred_at"`
+	Data       any       `json:"data"`
+}
+
+// MemberEvent is the Data of member.joined and member.left events.
+type MemberEvent struct {
+	UserID string `json:"user_id"`
+}
+
+type Delivery struct {
+	ID            string          `json:"id"`
+	WebhookID     string          `json:"webhook_id"`
+	EventID       string          `json:"event_id"`
+	EventType     EventType       `json:"event_type"`
+	Payload       json.RawMessage `json:"payload"`
+	Status        DeliveryStatus  `json:"status"`
+	Attempts      int             `json:"attempts"`
+	ResponseCode  int             `json:"response_code"`
+	LastError     string          `json:"last_error,omitempty"`
+	NextAttemptAt time.Time       `json:"next_attempt_at"`
+	DeliveredAt   *time.Time      `json:"delivered_at,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+}
+
+var (
+	ErrWebhookNotFound = errors.New("webhook not found")
+	ErrInvalidWebhook  = errors.New("invalid webhook")
+)
+
+// IncomingWebhook lets an external system post into ChatID over plain HTTP.
+// Token is only set on creation; afterwards only its hash is stored.
+type IncomingWebhook struct {
+	ID            string    `json:"id"`
+	ChatID        string    `json:"chat_id"`
+	Name          string    `json:"name"`
+	Token         string    `json:"token,omitempty"`
+	TokenHash     string    `json:"-"`
+	RatePerMinute int       `json:"rate_per_minute"`
+	Burst         int       `json:"burst"`
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+type CreateIncomingWebhookRequest struct {
+	Name          string `json:"name"`
+	RatePerMinute int    `json:"rate_per_minute"`
+	Burst         int    `json:"burst"`
+}
+
+// IncomingMessage is the body accepted by POST /hooks/{token}.
+type IncomingMessage struct {
+	Text        string                 `json:"text"`
+	Username    string                 `json:"username"`
+	Attachments []msgdomain.Attachment `json:"attachments"`
+}
+
+// RateLimitError is returned when an incoming webhook exceeds its budget.
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return "rate limit exceeded"
+}
+
+var (
+	ErrIncomingWebhookNotFound = errors.New("incoming webhook not found")
+	ErrInvalidIncomingMessage  = errors.New("invalid incoming message")
+)
@@ -0,0 +1,122 @@
+package presence
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Memory is a Registry within a single process, for a single node.
+type Memory struct {
+	mu    sync.Mutex
+	nodes map[string]*memoryNode
+	now   func() time.Time
+}
+
+type memoryNode struct {
+	users     map[string]int
+	expiresAt time.Time
+}
+
+// NewMemory returns a Registry with no nodes.
+func NewMemory() *Memory {
+	return &Memory{
+		nodes: make(map[string]*memoryNode),
+		now:   time.Now,
+	}
+}
+
+func (m *Memory) Connect(_ context.Context, node string, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.node(node).users[userID]++
+	return nil
+}
+
+func (m *Memory) Disconnect(_ context.Context, node string, userID string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := m.node(node)
+	if n.users[userID]--; n.users[userID] <= 0 {
+		delete(n.users, userID)
+	}
+	return nil
+}
+
+func (m *Memory) Heartbeat(_ context.Context, node string, users map[string]int, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	n := &memoryNode{
+		users:     make(map[string]int, len(users)),
+		expiresAt: m.now().Add(ttl),
+	}
+	for userID, count := range users {
+		if count > 0 {
+			n.users[userID] = count
+		}
+	}
+	m.nodes[node] = n
+	return nil
+}
+
+func (m *Memory) Leave(_ context.Context, node string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.nodes, node)
+	return nil
+}
+
+func (m *Memory) Online(_ context.Context, userIDs []string) (map[string]int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	online := make(map[string]int)
+	for _, n := range m.live() {
+		for _, userID := range userIDs {
+			if count := n.users[userID]; count > 0 {
+				online[userID] += count
+			}
+		}
+	}
+	return online, nil
+}
+
+func (m *Memory) Nodes(_ context.Context) ([]Node, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	nodes := []Node{}
+	for id, n := range m.live() {
+		node := Node{ID: id, Users: len(n.users), ExpiresAt: n.expiresAt}
+		for _, count := range n.users {
+			node.Connections += count
+		}
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+	return nodes, nil
+}
+
+// node returns node, adding it without a lease if it is unknown.
+func (m *Memory) node(node string) *memoryNode {
+	n, ok := m.nodes[node]
+	if !ok {
+		n = &memoryNode{users: make(map[string]int)}
+		m.nodes[node] = n
+	}
+	return n
+}
+
+// live returns the nodes whose lease has not run out, forgetting the
+// others.
+func (m *Memory) live() map[string]*memoryNode {
+	now := m.now()
+	live := make(map[string]*memoryNode, len(m.nodes))
+	for id, n := range m.nodes {
+		if n.expiresAt.After(now) {
+			live[id] = n
+		} else if !n.expiresAt.IsZero() {
+			delete(m.nodes, id)
+		}
+	}
+	return live
+}
@@ -0,0 +1,35 @@
+package presence
+
+import (
+	"context"
+	"time"
+)
+
+// Registry is the cluster-wide record of who is online. Every node reports
+// how many connections each of its users has, under a lease it renews with
+// Heartbeat; a node whose lease runs out, because it crashed or lost the
+// backend, is forgotten along with its users.
+type Registry interface {
+	// Connect records a new connection of userID on node.
+	Connect(ctx context.Context, node string, userID string) error
+	// Disconnect records that a connection of userID on node closed.
+	Disconnect(ctx context.Context, node string, userID string) error
+	// Heartbeat replaces what node reported with users, the number of
+	// connections of each of its users, and renews its lease for ttl.
+	Heartbeat(ctx context.Context, node string, users map[string]int, ttl time.Duration) error
+	// Leave forgets node, when it shuts down.
+	Leave(ctx context.Context, node string) error
+	// Online returns how many connections each of userIDs has across the
+	// live nodes. Users with none are left out.
+	Online(ctx context.Context, userIDs []string) (map[string]int, error)
+	// Nodes returns the live nodes.
+	Nodes(ctx context.Context) ([]Node, error)
+}
+
+// Node is a server node holding a lease in the registry.
+type Node struct {
+	ID          string    `json:"id"`
+	Users       int       `json:"users"`
+	Connections int       `json:"connections"`
+	ExpiresAt   time.Time `json:"expires_at"`
+}
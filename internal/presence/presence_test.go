@@ -0,0 +1,82 @@
+package presence
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// testRegistry verifies reg counts connections across nodes and forgets a
+// node once its lease runs out. setNow moves the clock of reg.
+func testRegistry(t *testing.T, reg Registry, setNow func(time.Time)) {
+	ctx := context.Background()
+	start := time.Now()
+	setNow(start)
+
+	for _, node := range []string{"node1", "node2"} {
+		if err := reg.Heartbeat(ctx, node, nil, 30*time.Second); err != nil {
+			t.Fatalf("Heartbeat failed: %v", err)
+		}
+	}
+	for _, conn := range []struct{ node, user string }{
+		{"node1", "alice"}, {"node1", "alice"}, {"node2", "alice"}, {"node2", "bob"},
+	} {
+		if err := reg.Connect(ctx, conn.node, conn.user); err != nil {
+			t.Fatalf("Connect failed: %v", err)
+		}
+	}
+	if err := reg.Disconnect(ctx, "node2", "bob"); err != nil {
+		t.Fatalf("Disconnect failed: %v", err)
+	}
+
+	expectOnline := func(want map[string]int) {
+		t.Helper()
+		online, err := reg.Online(ctx, []string{"alice", "bob", "carol"})
+		if err != nil {
+			t.Fatalf("Online failed: %v", err)
+		}
+		if !reflect.DeepEqual(online, want) {
+			t.Errorf("Expected online %v, got %v", want, online)
+		}
+	}
+	expectNodes := func(want ...Node) {
+		t.Helper()
+		nodes, err := reg.Nodes(ctx)
+		if err != nil {
+			t.Fatalf("Nodes failed: %v", err)
+		}
+		if len(nodes) != len(want) {
+			t.Fatalf("Expected %d nodes, got %+v", len(want), nodes)
+		}
+		for i := range want {
+			if nodes[i].ID != want[i].ID || nodes[i].Users != want[i].Users || nodes[i].Connections != want[i].Connections {
+				t.Errorf("Expected node %+v, got %+v", want[i], nodes[i])
+			}
+		}
+	}
+	expectOnline(map[string]int{"alice": 3})
+	expectNodes(Node{ID: "node1", Users: 1, Connections: 2}, Node{ID: "node2", Users: 1, Connections: 1})
+
+	// node1 keeps renewing its lease, node2 crashed.
+	setNow(start.Add(20 * time.Second))
+	if err := reg.Heartbeat(ctx, "node1", map[string]int{"alice": 2, "carol": 1}, 30*time.Second); err != nil {
+		t.Fatalf("Heartbeat failed: %v", err)
+	}
+	setNow(start.Add(35 * time.Second))
+	expectOnline(map[string]int{"alice": 2, "carol": 1})
+	expectNodes(Node{ID: "node1", Users: 2, Connections: 3})
+
+	if err := reg.Leave(ctx, "node1"); err != nil {
+		t.Fatalf("Leave failed: %v", err)
+	}
+	expectOnline(map[string]int{})
+	expectNodes()
+}
+
+func TestMemoryRegistry(t *testing.T) {
+	reg := NewMemory()
+	testRegistry(t, reg, func(now time.Time) {
+		reg.now = func() time.Time { return now }
+	})
+}
@@ -0,0 +1,188 @@
+package presence
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// connectScript counts a connection of a user of a node. A count created
+// between heartbeats takes the lease of the node as its expiry, so that it
+// does not outlive a crashed node.
+var connectScript = redis.NewScript(`
+redis.call('HINCRBY', KEYS[1], ARGV[1], 1)
+if redis.call('PTTL', KEYS[1]) == -1 then
+	local expires = redis.call('ZSCORE', KEYS[2], ARGV[2])
+	if expires then
+		redis.call('PEXPIREAT', KEYS[1], expires)
+	end
+end
+return 1`)
+
+// disconnectScript uncounts a connection of a user of a node, forgetting
+// the user at zero.
+var disconnectScript = redis.NewScript(`
+local n = redis.call('HINCRBY', KEYS[1], ARGV[1], -1)
+if n <= 0 then
+	redis.call('HDEL', KEYS[1], ARGV[1])
+end
+return n`)
+
+// Redis is a Registry shared by the nodes through Redis. The leases are the
+// scores of a sorted set, in milliseconds since the epoch, so the clocks of
+// the nodes should roughly agree. Each node keeps the connections of its
+// users in a hash that expires with its lease.
+type Redis struct {
+	client *redis.Client
+	prefix string
+	now    func() time.Time
+}
+
+// NewRedis returns a Registry keeping its keys under prefix in client.
+func NewRedis(client *redis.Client, prefix string) *Redis {
+	return &Redis{
+		client: client,
+		prefix: prefix,
+		now:    time.Now,
+	}
+}
+
+func (r *Redis) Connect(ctx context.Context, node string, userID string) error {
+	return connectScript.Run(ctx, r.client, []string{r.nodeKey(node), r.nodesKey()}, userID, node).Err()
+}
+
+func (r *Redis) Disconnect(ctx context.Context, node string, userID string) error {
+	return disconnectScript.Run(ctx, r.client, []string{r.nodeKey(node)}, userID).Err()
+}
+
+func (r *Redis) Heartbeat(ctx context.Context, node string, users map[string]int, ttl time.Duration) error {
+	expiresAt := r.now().Add(ttl)
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		key := r.nodeKey(node)
+		pipe.Del(ctx, key)
+		fields := make([]any, 0, 2*len(users))
+		for userID, count := range users {
+			if count > 0 {
+				fields = append(fields, userID, count)
+			}
+		}
+		if len(fields) > 0 {
+			pipe.HSet(ctx, key, fields...)
+			pipe.PExpireAt(ctx, key, expiresAt)
+		}
+		pipe.ZAdd(ctx, r.nodesKey(), redis.Z{Score: float64(expiresAt.UnixMilli()), Member: node})
+		return nil
+	})
+	return err
+}
+
+func (r *Redis) Leave(ctx context.Context, node string) error {
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Del(ctx, r.nodeKey(node))
+		pipe.ZRem(ctx, r.nodesKey(), node)
+		return nil
+	})
+	return err
+}
+
+func (r *Redis) Online(ctx context.Context, userIDs []string) (map[string]int, error) {
+	online := make(map[string]int)
+	if len(userIDs) == 0 {
+		return online, nil
+	}
+	nodes, err := r.live(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cmds := make([]*redis.SliceCmd, len(nodes))
+	_, err = r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, node := range nodes {
+			cmds[i] = pipe.HMGet(ctx, r.nodeKey(node.Member.(string)), userIDs...)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, cmd := range cmds {
+		for i, value := range cmd.Val() {
+			if count := countOf(value); count > 0 {
+				online[userIDs[i]] += count
+			}
+		}
+	}
+	return online, nil
+}
+
+func (r *Redis) Nodes(ctx context.Context) ([]Node, error) {
+	live, err := r.live(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	cmds := make([]*redis.StringSliceCmd, len(live))
+	_, err = r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, node := range live {
+			cmds[i] = pipe.HVals(ctx, r.nodeKey(node.Member.(string)))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make([]Node, 0, len(live))
+	for i, z := range live {
+		node := Node{
+			ID:        z.Member.(string),
+			ExpiresAt: time.UnixMilli(int64(z.Score)),
+		}
+		for _, value := range cmds[i].Val() {
+			if count := countOf(value); count > 0 {
+				node.Users++
+				node.Connections += count
+			}
+		}
+		nodes = append(nodes, node)
+	}
+	sort.Slice(nodes, func(i, j int) bool { return nodes[i].ID < nodes[j].ID })
+	return nodes, nil
+}
+
+// live returns the nodes whose lease has not run out, with their expiry as
+// score, and forgets the others.
+func (r *Redis) live(ctx context.Context) ([]redis.Z, error) {
+	now := strconv.FormatInt(r.now().UnixMilli(), 10)
+	var live *redis.ZSliceCmd
+	_, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZRemRangeByScore(ctx, r.nodesKey(), "-inf", now)
+		live = pipe.ZRangeByScoreWithScores(ctx, r.nodesKey(), &redis.ZRangeBy{Min: "(" + now, Max: "+inf"})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return live.Val(), nil
+}
+
+func (r *Redis) nodesKey() string {
+	return r.prefix + "nodes"
+}
+
+func (r *Redis) nodeKey(node string) string {
+	return r.prefix + "node:" + node
+}
+
+// countOf reads a connection count out of a hash value.
+func countOf(value any) int {
+	s, ok := value.(string)
+	if !ok {
+		return 0
+	}
+	n, _ := strconv.Atoi(s)
+	return n
+}
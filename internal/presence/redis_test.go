@@ -0,0 +1,19 @@
+package presence
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestRedisRegistry(t *testing.T) {
+	client := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	defer client.Close()
+
+	reg := NewRedis(client, "test:")
+	testRegistry(t, reg, func(now time.Time) {
+		reg.now = func() time.Time { return now }
+	})
+}
@@ -0,0 +1,110 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+)
+
+// Bucket is a token bucket refilled continuously at rate tokens per second
+// up to burst tokens.
+type Bucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+func NewBucket(rate float64, burst int) *Bucket {
+	return &Bucket{
+		rate:   rate,
+		burst:  float64(burst),
+		tokens: float64(burst),
+		last:   time.Now(),
+	}
+}
+
+// Allow takes a token if one is available. Otherwise it reports how long
+// the caller has to wait for the next token.
+func (b *Bucket) Allow() (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.tokens += now.Sub(b.last).Seconds() * b.rate
+	if b.tokens > b.burst {
+		b.tokens = b.burst
+	}
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	if b.rate <= 0 {
+		return false, time.Duration(1<<63 - 1)
+	}
+
+	wait := time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+	return false, wait
+}
+
+// full reports whether the bucket has refilled completely by now, so that
+// a new one would be no different.
+func (b *Bucket) full(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.tokens+now.Sub(b.last).Seconds()*b.rate >= b.burst
+}
+
+// sweepInterval is how often a Limiter looks for buckets to drop.
+const sweepInterval = time.Minute
+
+// Limiter keeps one Bucket per key. Buckets that went idle long enough to
+// refill completely are dropped, as a new bucket would be no different.
+type Limiter struct {
+	mu        sync.Mutex
+	buckets   map[string]*Bucket
+	lastSweep time.Time
+}
+
+func NewLimiter() *Limiter {
+	return &Limiter{
+		buckets:   make(map[string]*Bucket),
+		lastSweep: time.Now(),
+	}
+}
+
+// Allow takes a token from the bucket for key, creating it with rate and
+// burst on first use.
+func (l *Limiter) Allow(key string, rate float64, burst int) (bool, time.Duration) {
+	l.mu.Lock()
+	if now := time.Now(); now.Sub(l.lastSweep) >= sweepInterval {
+		l.sweep(now)
+	}
+	b, ok := l.buckets[key]
+	if !ok {
+		b = NewBucket(rate, burst)
+		l.buckets[key] = b
+	}
+	l.mu.Unlock()
+
+	return b.Allow()
+}
+
+// sweep drops the buckets that are full at now. l.mu must be held.
+func (l *Limiter) sweep(now time.Time) {
+	for key, b := range l.buckets {
+		if b.full(now) {
+			delete(l.buckets, key)
+		}
+	}
+	l.lastSweep = now
+}
+
+// Forget drops the bucket for key.
+func (l *Limiter) Forget(key string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.buckets, key)
+}
@@ -0,0 +1,83 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+// TestBucketBurstThenDeny verifies the bucket allows burst requests then
+// reports a retry-after hint
+func TestBucketBurstThenDeny(t *testing.T) {
+	b := NewBucket(1, 3)
+
+	for i := 0; i < 3; i++ {
+		if ok, _ := b.Allow(); !ok {
+			t.Fatalf("Request %d should be allowed", i)
+		}
+	}
+
+	ok, wait := b.Allow()
+	if ok {
+		t.Fatal("Request past burst should be denied")
+	}
+	if wait <= 0 || wait > time.Second {
+		t.Errorf("Expected retry-after in (0, 1s], got %v", wait)
+	}
+}
+
+// TestBucketRefills verifies tokens come back over time
+func TestBucketRefills(t *testing.T) {
+	b := NewBucket(100, 1)
+
+	if ok, _ := b.Allow(); !ok {
+		t.Fatal("First request should be allowed")
+	}
+	time.Sleep(20 * time.Millisecond)
+	if ok, _ := b.Allow(); !ok {
+		t.Error("Request after refill should be allowed")
+	}
+}
+
+// TestLimiterKeysAreIndependent verifies one key cannot exhaust another
+func TestLimiterKeysAreIndependent(t *testing.T) {
+	l := NewLimiter()
+
+	if ok, _ := l.Allow("a", 0.001, 1); !ok {
+		t.Fatal("First request for a should be allowed")
+	}
+	if ok, _ := l.Allow("a", 0.001, 1); ok {
+		t.Error("Second request for a should be denied")
+	}
+	if ok, _ := l.Allow("b", 0.001, 1); !ok {
+		t.Error("First request for b should be allowed")
+	}
+
+	l.Forget("a")
+	if ok, _ := l.Allow("a", 0.001, 1); !ok {
+		t.Error("Request for a after Forget should be allowed")
+	}
+}
+
+// TestLimiterSweepsFullBuckets verifies buckets are only dropped once they
+// have refilled, so that dropping them never hands out extra tokens
+func TestLimiterSweepsFullBuckets(t *testing.T) {
+	l := NewLimiter()
+	l.Allow("fast", 1000, 1)
+	l.Allow("slow", 0.001, 1)
+
+	l.mu.Lock()
+	l.sweep(time.Now().Add(time.Second))
+	_, fast := l.buckets["fast"]
+	_, slow := l.buckets["slow"]
+	l.mu.Unlock()
+
+	if fast {
+		t.Error("Expected the refilled bucket to be dropped")
+	}
+	if !slow {
+		t.Fatal("Expected the drained bucket to be kept")
+	}
+	if ok, _ := l.Allow("slow", 0.001, 1); ok {
+		t.Error("Expected the drained bucket to still deny")
+	}
+}
@@ -5,8 +5,16 @@ import (
 	"chatsrv/internal/repository"
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
 )
 
+// uniqueViolation is the Postgres error code of a unique constraint failure.
+const uniqueViolation = "23505"
+
 var _ repository.ChatRepository = (*chatRepository)(nil)
 
 func NewChatRepository(db *sql.DB) *chatRepository {
@@ -19,50 +27,184 @@ type chatRepository struct {
 	db *sql.DB
 }
 
+const chatColumns = `uuid, name, type, visibility, dm_user_a, dm_user_b, topic, description, archived_at,
+	created_at, last_activity_at`
+
+// listChatColumns are chatColumns qualified for the chat list query.
+const listChatColumns = `c.uuid, c.name, c.type, c.visibility, c.dm_user_a, c.dm_user_b, c.topic, c.description,
+	c.archived_at, c.created_at, c.last_activity_at`
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
 // CreateChat implements repository.ChatRepository.
-func (c *chatRepository) CreateChat(ctx context.Context, chatID string, name string) error {
-	query := `
-	INSERT INTO 
-	chats(uuid, name) 
-	VALUES ($1, $2)
-	ON CONFLICT DO NOTHING`
-	_, err := c.db.ExecContext(ctx, query, chatID, name)
+func (c *chatRepository) CreateChat(ctx context.Context, chat *chatdomain.Chat, ownerID string) error {
+	tx, err := c.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
-	return nil
+	query := `
+	INSERT INTO
+	chats(uuid, name, visibility, topic, description, name_key)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	RETURNING created_at`
+	err = tx.QueryRowContext(ctx, query,
+		chat.ID, chat.Name, string(chat.Visibility), chat.Topic, chat.Description, nullString(chat.NameKey),
+	).Scan(&chat.CreatedAt)
+	if err != nil {
+		return nameConflict(err)
+	}
+
+	if ownerID != "" {
+		if err := addMember(ctx, tx, chat.ID, ownerID, chatdomain.RoleOwner); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetOrCreateDirectChat implements repository.ChatRepository.
+func (c *chatRepository) GetOrCreateDirectChat(ctx context.Context, chatID string, userA string, userB string) (*chatdomain.Chat, bool, error) {
+	userA, userB = chatdomain.DirectPair(userA, userB)
+
+	tx, err := c.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback()
+
+	query := `
+	INSERT INTO
+	chats(uuid, name, type, visibility, dm_user_a, dm_user_b)
+	VALUES ($1, '', 'dm', 'private', $2, $3)
+	ON CONFLICT (dm_user_a, dm_user_b) WHERE type = 'dm' DO NOTHING
+	RETURNING ` + chatColumns
+	chat, err := scanChat(tx.QueryRowContext(ctx, query, chatID, userA, userB))
+	if errors.Is(err, sql.ErrNoRows) {
+		query = `SELECT ` + chatColumns + ` FROM chats WHERE type = 'dm' AND dm_user_a = $1 AND dm_user_b = $2`
+		chat, err = scanChat(tx.QueryRowContext(ctx, query, userA, userB))
+		if err != nil {
+			return nil, false, err
+		}
+		return chat, false, tx.Commit()
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	for _, userID := range chat.Participants {
+		if err := addMember(ctx, tx, chat.ID, userID, chatdomain.RoleMember); err != nil {
+			return nil, false, err
+		}
+	}
+
+	return chat, true, tx.Commit()
 }
 
 // GetChat implements repository.ChatRepository.
 func (c *chatRepository) GetChat(ctx context.Context, chatID string) (*chatdomain.Chat, error) {
-	query := `SELECT uuid, name FROM chats WHERE uuid = $1`
+	query := `SELECT ` + chatColumns + ` FROM chats WHERE uuid = $1 AND deleted_at IS NULL`
 
-	var chat chatdomain.Chat
-	err := c.db.QueryRowContext(ctx, query, chatID).Scan(&chat.ID, &chat.Name)
+	chat, err := scanChat(c.db.QueryRowContext(ctx, query, chatID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, chatdomain.ErrChatNotFound
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	return &chat, nil
+	return chat, nil
 }
 
-// GetChats implements repository.ChatRepository.
-func (c *chatRepository) GetChats(ctx context.Context) ([]*chatdomain.Chat, error) {
-	query := "SELECT uuid, name FROM chats"
-	rows, err := c.db.QueryContext(ctx, query)
+// ListChats implements repository.ChatRepository.
+// Public chats are visible to everyone, private ones only to their members.
+// Pages are cut with keyset pagination on the sort key and chat ID.
+func (c *chatRepository) ListChats(ctx context.Context, req chatdomain.ListChatsRequest) ([]*chatdomain.ChatSummary, error) {
+	args := []any{req.UserID}
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	where := []string{
+		`c.deleted_at IS NULL`,
+		`(c.visibility = 'public' OR me.user_id IS NOT NULL)`,
+	}
+	if req.Mine {
+		where = append(where, `me.user_id IS NOT NULL`)
+	}
+	switch req.Archived {
+	case chatdomain.ArchivedOnly:
+		where = append(where, `c.archived_at IS NOT NULL`)
+	case chatdomain.ArchivedAny:
+	default:
+		where = append(where, `c.archived_at IS NULL`)
+	}
+	if req.Query != "" {
+		where = append(where, `c.name ILIKE `+arg("%"+likeEscaper.Replace(req.Query)+"%"))
+	}
+
+	var orderBy string
+	switch req.Sort {
+	case chatdomain.SortName:
+		orderBy = `lower(c.name), c.uuid`
+		if req.Cursor != nil {
+			where = append(where, `(lower(c.name), c.uuid) > (`+arg(req.Cursor.Key)+`, `+arg(req.Cursor.ID)+`)`)
+		}
+	case chatdomain.SortCreated:
+		orderBy = `c.created_at DESC, c.uuid DESC`
+		if req.Cursor != nil {
+			at, _ := req.Cursor.Time()
+			where = append(where, `(c.created_at, c.uuid) < (`+arg(at)+`, `+arg(req.Cursor.ID)+`)`)
+		}
+	default:
+		orderBy = `c.last_activity_at DESC, c.uuid DESC`
+		if req.Cursor != nil {
+			at, _ := req.Cursor.Time()
+			where = append(where, `(c.last_activity_at, c.uuid) < (`+arg(at)+`, `+arg(req.Cursor.ID)+`)`)
+		}
+	}
+
+	// Unread messages are those posted by others since the member last
+	// read the chat, or since it joined if it never did.
+	query := `
+	SELECT ` + listChatColumns + `,
+		(SELECT count(*) FROM chat_members cm WHERE cm.chat_id = c.uuid),
+		CASE WHEN me.user_id IS NULL THEN 0 ELSE (
+			SELECT count(*) FROM messages u
+			WHERE u.chat_id = c.uuid
+				AND u.created_at > COALESCE(me.last_read_at, me.joined_at)
+				AND u.sender_id <> $1
+		) END,
+		lm.uuid, lm.sender_id, lm.content, lm.created_at
+	FROM chats c
+	LEFT JOIN chat_members me ON me.chat_id = c.uuid AND me.user_id = $1
+	LEFT JOIN LATERAL (
+		SELECT uuid, sender_id, content, created_at
+		FROM messages
+		WHERE chat_id = c.uuid
+		ORDER BY created_at DESC
+		LIMIT 1
+	) lm ON true
+	WHERE ` + strings.Join(where, " AND ") + `
+	ORDER BY ` + orderBy + `
+	LIMIT ` + arg(req.Limit)
+
+	rows, err := c.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var chats []*chatdomain.Chat
+	var chats []*chatdomain.ChatSummary
 	for rows.Next() {
-		var chat chatdomain.Chat
-		if err := rows.Scan(&chat.ID, &chat.Name); err != nil {
+		chat, err := scanChatSummary(rows)
+		if err != nil {
 			return nil, err
 		}
-		chats = append(chats, &chat)
+		chats = append(chats, chat)
 	}
 	if err := rows.Err(); err != nil {
 		return nil, err
@@ -70,3 +212,149 @@ func (c *chatRepository) GetChats(ctx context.Context) ([]*chatdomain.Chat, erro
 
 	return chats, nil
 }
+
+// UpdateChat implements repository.ChatRepository.
+func (c *chatRepository) UpdateChat(ctx context.Context, chat *chatdomain.Chat) error {
+	query := `
+	UPDATE chats
+	SET name = $2, topic = $3, description = $4, name_key = $5
+	WHERE uuid = $1 AND deleted_at IS NULL`
+	res, err := c.db.ExecContext(ctx, query, chat.ID, chat.Name, chat.Topic, chat.Description, nullString(chat.NameKey))
+	if err != nil {
+		return nameConflict(err)
+	}
+
+	return chatAffected(res)
+}
+
+// SetArchived implements repository.ChatRepository.
+func (c *chatRepository) SetArchived(ctx context.Context, chatID string, archived bool) (*chatdomain.Chat, error) {
+	query := `
+	UPDATE chats
+	SET archived_at = CASE WHEN $2 THEN COALESCE(archived_at, now()) END
+	WHERE uuid = $1 AND deleted_at IS NULL
+	RETURNING ` + chatColumns
+
+	chat, err := scanChat(c.db.QueryRowContext(ctx, query, chatID, archived))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, chatdomain.ErrChatNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return chat, nil
+}
+
+// DeleteChat implements repository.ChatRepository.
+func (c *chatRepository) DeleteChat(ctx context.Context, chatID string) error {
+	res, err := c.db.ExecContext(ctx, `UPDATE chats SET deleted_at = now() WHERE uuid = $1 AND deleted_at IS NULL`, chatID)
+	if err != nil {
+		return err
+	}
+
+	return chatAffected(res)
+}
+
+func chatAffected(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return chatdomain.ErrChatNotFound
+	}
+	return nil
+}
+
+// nameConflict maps a violation of the unique name index to
+// chatdomain.ErrChatNameTaken.
+func nameConflict(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation && pgErr.ConstraintName == "chats_name_key_idx" {
+		return chatdomain.ErrChatNameTaken
+	}
+	return err
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+func addMember(ctx context.Context, db execer, chatID string, userID string, role chatdomain.Role) error {
+	query := `
+	INSERT INTO
+	chat_members(chat_id, user_id, role)
+	VALUES ($1, $2, $3)
+	ON CONFLICT DO NOTHING`
+	_, err := db.ExecContext(ctx, query, chatID, userID, string(role))
+	return err
+}
+
+func scanChat(row scanner) (*chatdomain.Chat, error) {
+	var chat chatdomain.Chat
+	if err := scanChatInto(row, &chat); err != nil {
+		return nil, err
+	}
+	return &chat, nil
+}
+
+func scanChatSummary(row scanner) (*chatdomain.ChatSummary, error) {
+	var (
+		summary     chatdomain.ChatSummary
+		lastID      sql.NullString
+		lastSender  sql.NullString
+		lastContent sql.NullString
+		lastAt      sql.NullTime
+	)
+	if err := scanChatInto(row, &summary.Chat, &summary.MemberCount, &summary.UnreadCount,
+		&lastID, &lastSender, &lastContent, &lastAt); err != nil {
+		return nil, err
+	}
+
+	if lastID.Valid {
+		summary.LastMessage = &chatdomain.MessagePreview{
+			ID:        lastID.String,
+			SenderID:  lastSender.String,
+			Content:   lastContent.String,
+			CreatedAt: lastAt.Time,
+		}
+	}
+
+	return &summary, nil
+}
+
+// scanChatInto scans chatColumns into chat followed by any extra columns.
+func scanChatInto(row scanner, chat *chatdomain.Chat, extra ...any) error {
+	var (
+		chatType   string
+		visibility string
+		dmUserA    sql.NullString
+		dmUserB    sql.NullString
+		archivedAt sql.NullTime
+	)
+	dest := append([]any{&chat.ID, &chat.Name, &chatType, &visibility, &dmUserA, &dmUserB,
+		&chat.Topic, &chat.Description, &archivedAt, &chat.CreatedAt, &chat.LastActivityAt}, extra...)
+	if err := row.Scan(dest...); err != nil {
+		return err
+	}
+
+	chat.Type = chatdomain.ChatType(chatType)
+	chat.Visibility = chatdomain.Visibility(visibility)
+	if chat.IsDirect() {
+		chat.Participants = []string{dmUserA.String, dmUserB.String}
+	}
+	if archivedAt.Valid {
+		chat.ArchivedAt = &archivedAt.Time
+	}
+
+	return nil
+}
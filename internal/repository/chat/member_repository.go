@@ -0,0 +1,146 @@
+package chatrepository
+
+import (
+	chatdomain "chatsrv/internal/domain/chat"
+	"chatsrv/internal/repository"
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var _ repository.MemberRepository = (*memberRepository)(nil)
+
+func NewMemberRepository(db *sql.DB) *memberRepository {
+	return &memberRepository{
+		db: db,
+	}
+}
+
+type memberRepository struct {
+	db *sql.DB
+}
+
+const memberColumns = `chat_id, user_id, role, joined_at, last_read_at`
+
+// AddMember implements repository.MemberRepository.
+func (m *memberRepository) AddMember(ctx context.Context, chatID string, userID string, role chatdomain.Role) error {
+	return addMember(ctx, m.db, chatID, userID, role)
+}
+
+// RemoveMember implements repository.MemberRepository.
+func (m *memberRepository) RemoveMember(ctx context.Context, chatID string, userID string) error {
+	res, err := m.db.ExecContext(ctx, `DELETE FROM chat_members WHERE chat_id = $1 AND user_id = $2`, chatID, userID)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return chatdomain.ErrNotMember
+	}
+
+	return nil
+}
+
+// UpdateMemberRole implements repository.MemberRepository.
+func (m *memberRepository) UpdateMemberRole(ctx context.Context, chatID string, userID string, role chatdomain.Role) error {
+	res, err := m.db.ExecContext(ctx, `UPDATE chat_members SET role = $3 WHERE chat_id = $1 AND user_id = $2`,
+		chatID, userID, string(role))
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return chatdomain.ErrNotMember
+	}
+
+	return nil
+}
+
+// MarkRead implements repository.MemberRepository.
+// The read marker only moves forward.
+func (m *memberRepository) MarkRead(ctx context.Context, chatID string, userID string, at time.Time) error {
+	query := `
+	UPDATE chat_members
+	SET last_read_at = GREATEST(COALESCE(last_read_at, $3), $3)
+	WHERE chat_id = $1 AND user_id = $2`
+	res, err := m.db.ExecContext(ctx, query, chatID, userID, at)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return chatdomain.ErrNotMember
+	}
+
+	return nil
+}
+
+// GetMember implements repository.MemberRepository.
+func (m *memberRepository) GetMember(ctx context.Context, chatID string, userID string) (*chatdomain.Member, error) {
+	query := `SELECT ` + memberColumns + ` FROM chat_members WHERE chat_id = $1 AND user_id = $2`
+
+	member, err := scanMember(m.db.QueryRowContext(ctx, query, chatID, userID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, chatdomain.ErrNotMember
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return member, nil
+}
+
+// GetMembers implements repository.MemberRepository.
+func (m *memberRepository) GetMembers(ctx context.Context, chatID string) ([]*chatdomain.Member, error) {
+	query := `SELECT ` + memberColumns + ` FROM chat_members WHERE chat_id = $1 ORDER BY joined_at`
+	rows, err := m.db.QueryContext(ctx, query, chatID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var members []*chatdomain.Member
+	for rows.Next() {
+		member, err := scanMember(rows)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return members, nil
+}
+
+func scanMember(row scanner) (*chatdomain.Member, error) {
+	var (
+		member     chatdomain.Member
+		role       string
+		lastReadAt sql.NullTime
+	)
+	if err := row.Scan(&member.ChatID, &member.UserID, &role, &member.JoinedAt, &lastReadAt); err != nil {
+		return nil, err
+	}
+
+	member.Role = chatdomain.Role(role)
+	if lastReadAt.Valid {
+		member.LastReadAt = &lastReadAt.Time
+	}
+
+	return &member, nil
+}
@@ -0,0 +1,91 @@
+package chatrepository
+
+import (
+	chatdomain "chatsrv/internal/domain/chat"
+	"chatsrv/internal/repository"
+	"context"
+	"database/sql"
+	"errors"
+)
+
+var _ repository.ModerationRepository = (*moderationRepository)(nil)
+
+func NewModerationRepository(db *sql.DB) *moderationRepository {
+	return &moderationRepository{
+		db: db,
+	}
+}
+
+type moderationRepository struct {
+	db *sql.DB
+}
+
+// SetRestriction implements repository.ModerationRepository.
+func (m *moderationRepository) SetRestriction(ctx context.Context, restriction *chatdomain.Restriction) error {
+	query := `
+	INSERT INTO
+	chat_restrictions(chat_id, user_id, kind, reason, until, created_by)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	ON CONFLICT (chat_id, user_id, kind) DO UPDATE
+	SET reason = EXCLUDED.reason, until = EXCLUDED.until,
+		created_by = EXCLUDED.created_by, created_at = CURRENT_TIMESTAMP
+	RETURNING created_at`
+	err := m.db.QueryRowContext(ctx, query,
+		restriction.ChatID, restriction.UserID, string(restriction.Kind),
+		restriction.Reason, restriction.Until, restriction.CreatedBy,
+	).Scan(&restriction.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// RemoveRestriction implements repository.ModerationRepository.
+func (m *moderationRepository) RemoveRestriction(ctx context.Context, chatID string, userID string, kind chatdomain.RestrictionKind) error {
+	res, err := m.db.ExecContext(ctx,
+		`DELETE FROM chat_restrictions WHERE chat_id = $1 AND user_id = $2 AND kind = $3`,
+		chatID, userID, string(kind))
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return chatdomain.ErrNotRestricted
+	}
+
+	return nil
+}
+
+// GetActiveRestriction implements repository.ModerationRepository.
+func (m *moderationRepository) GetActiveRestriction(ctx context.Context, chatID string, userID string, kind chatdomain.RestrictionKind) (*chatdomain.Restriction, error) {
+	query := `
+	SELECT chat_id, user_id, kind, reason, until, created_by, created_at
+	FROM chat_restrictions
+	WHERE chat_id = $1 AND user_id = $2 AND kind = $3 AND (until IS NULL OR until > now())`
+
+	var (
+		restriction chatdomain.Restriction
+		kindStr     string
+		until       sql.NullTime
+	)
+	err := m.db.QueryRowContext(ctx, query, chatID, userID, string(kind)).Scan(
+		&restriction.ChatID, &restriction.UserID, &kindStr, &restriction.Reason,
+		&until, &restriction.CreatedBy, &restriction.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, chatdomain.ErrNotRestricted
+	}
+	if err != nil {
+		return nil, err
+	}
+	restriction.Kind = chatdomain.RestrictionKind(kindStr)
+	if until.Valid {
+		restriction.Until = &until.Time
+	}
+
+	return &restriction, nil
+}
@@ -0,0 +1,78 @@
+package idempotencyrepository
+
+import (
+	idempotencydomain "chatsrv/internal/domain/idempotency"
+	"chatsrv/internal/repository"
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var _ repository.IdempotencyRepository = (*idempotencyRepository)(nil)
+
+func NewIdempotencyRepository(db *sql.DB) *idempotencyRepository {
+	return &idempotencyRepository{
+		db: db,
+	}
+}
+
+type idempotencyRepository struct {
+	db *sql.DB
+}
+
+// Reserve implements repository.IdempotencyRepository.
+// A record older than ttl is taken over as if it did not exist.
+func (r *idempotencyRepository) Reserve(ctx context.Context, record *idempotencydomain.Record, ttl time.Duration) (*idempotencydomain.Record, bool, error) {
+	query := `
+	INSERT INTO
+	idempotency_keys(scope, user_id, key, request_hash)
+	VALUES ($1, $2, $3, $4)
+	ON CONFLICT (scope, user_id, key) DO UPDATE
+	SET request_hash = EXCLUDED.request_hash, resource_id = NULL, created_at = now()
+	WHERE idempotency_keys.created_at < now() - $5 * interval '1 millisecond'
+	RETURNING created_at`
+	err := r.db.QueryRowContext(ctx, query,
+		record.Scope, record.UserID, record.Key, record.RequestHash, ttl.Milliseconds(),
+	).Scan(&record.CreatedAt)
+	if err == nil {
+		return record, true, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, false, err
+	}
+
+	query = `
+	SELECT scope, user_id, key, request_hash, resource_id, created_at
+	FROM idempotency_keys
+	WHERE scope = $1 AND user_id = $2 AND key = $3`
+	var (
+		existing   idempotencydomain.Record
+		resourceID sql.NullString
+	)
+	err = r.db.QueryRowContext(ctx, query, record.Scope, record.UserID, record.Key).Scan(
+		&existing.Scope, &existing.UserID, &existing.Key, &existing.RequestHash, &resourceID, &existing.CreatedAt)
+	if err != nil {
+		return nil, false, err
+	}
+	existing.ResourceID = resourceID.String
+
+	return &existing, false, nil
+}
+
+// Complete implements repository.IdempotencyRepository.
+func (r *idempotencyRepository) Complete(ctx context.Context, record *idempotencydomain.Record) error {
+	query := `
+	UPDATE idempotency_keys
+	SET resource_id = $4
+	WHERE scope = $1 AND user_id = $2 AND key = $3`
+	_, err := r.db.ExecContext(ctx, query, record.Scope, record.UserID, record.Key, record.ResourceID)
+	return err
+}
+
+// Release implements repository.IdempotencyRepository.
+func (r *idempotencyRepository) Release(ctx context.Context, record *idempotencydomain.Record) error {
+	query := `DELETE FROM idempotency_keys WHERE scope = $1 AND user_id = $2 AND key = $3 AND resource_id IS NULL`
+	_, err := r.db.ExecContext(ctx, query, record.Scope, record.UserID, record.Key)
+	return err
+}
@@ -2,11 +2,171 @@ package repository
 
 import (
 	chatdomain "chatsrv/internal/domain/chat"
+	idempotencydomain "chatsrv/internal/domain/idempotency"
+	invitedomain "chatsrv/internal/domain/invite"
+	msgdomain "chatsrv/internal/domain/msg"
+	userdomain "chatsrv/internal/domain/user"
+	webhookdomain "chatsrv/internal/domain/webhook"
 	"context"
+	"time"
 )
 
 type ChatRepository interface {
-	GetChats(ctx context.Context) ([]*chatdomain.Chat, error)
+	// ListChats returns up to req.Limit chats visible to req.UserID after
+	// req.Cursor.
+	ListChats(ctx context.Context, req chatdomain.ListChatsRequest) ([]*chatdomain.ChatSummary, error)
 	GetChat(ctx context.Context, chatID string) (*chatdomain.Chat, error)
-	CreateChat(ctx context.Context, chatID string, name string) error
+	// CreateChat stores chat and, when ownerID is set, makes that user its
+	// owner.
+	CreateChat(ctx context.Context, chat *chatdomain.Chat, ownerID string) error
+	// GetOrCreateDirectChat returns the direct chat of the unordered pair,
+	// creating it with chatID if it does not exist yet. The bool reports
+	// whether the chat was created.
+	GetOrCreateDirectChat(ctx context.Context, chatID string, userA string, userB string) (*chatdomain.Chat, bool, error)
+	// UpdateChat stores the name, topic and description of chat.
+	UpdateChat(ctx context.Context, chat *chatdomain.Chat) error
+	// SetArchived archives or unarchives chatID and returns the result.
+	SetArchived(ctx context.Context, chatID string, archived bool) (*chatdomain.Chat, error)
+	// DeleteChat soft-deletes chatID. Deleted chats are not found anymore.
+	DeleteChat(ctx context.Context, chatID string) error
+}
+
+type MemberRepository interface {
+	AddMember(ctx context.Context, chatID string, userID string, role chatdomain.Role) error
+	RemoveMember(ctx context.Context, chatID string, userID string) error
+	GetMember(ctx context.Context, chatID string, userID string) (*chatdomain.Member, error)
+	GetMembers(ctx context.Context, chatID string) ([]*chatdomain.Member, error)
+	UpdateMemberRole(ctx context.Context, chatID string, userID string, role chatdomain.Role) error
+	// MarkRead records that userID has read chatID up to at.
+	MarkRead(ctx context.Context, chatID string, userID string, at time.Time) error
+}
+
+type ModerationRepository interface {
+	// SetRestriction creates the restriction or replaces the one of the same
+	// kind already held by the user.
+	SetRestriction(ctx context.Context, restriction *chatdomain.Restriction) error
+	RemoveRestriction(ctx context.Context, chatID string, userID string, kind chatdomain.RestrictionKind) error
+	// GetActiveRestriction returns chatdomain.ErrNotRestricted when the user
+	// has no restriction of kind or it has run out.
+	GetActiveRestriction(ctx context.Context, chatID string, userID string, kind chatdomain.RestrictionKind) (*chatdomain.Restriction, error)
+}
+
+type InviteRepository interface {
+	CreateInvite(ctx context.Context, invite *invitedomain.Invite) error
+	GetInvites(ctx context.Context, chatID string) ([]*invitedomain.Invite, error)
+	GetInvite(ctx context.Context, inviteID string) (*invitedomain.Invite, error)
+	GetInviteByTokenHash(ctx context.Context, tokenHash string) (*invitedomain.Invite, error)
+	RevokeInvite(ctx context.Context, inviteID string) error
+	// ConsumeInvite takes one use of a usable invite and adds userID to its
+	// chat with its role, in one transaction. It reports false when the
+	// invite was revoked, expired or used up in the meantime.
+	ConsumeInvite(ctx context.Context, invite *invitedomain.Invite, userID string) (bool, error)
+	CreateAcceptance(ctx context.Context, acceptance *invitedomain.Acceptance) error
+}
+
+type IdempotencyRepository interface {
+	// Reserve stores record unless a live record with the same scope, user
+	// and key exists. It returns the stored record and whether it is the
+	// one just reserved.
+	Reserve(ctx context.Context, record *idempotencydomain.Record, ttl time.Duration) (*idempotencydomain.Record, bool, error)
+	// Complete stores the ID of the resource created for record.
+	Complete(ctx context.Context, record *idempotencydomain.Record) error
+	// Release drops a reservation whose request failed so it can be retried.
+	Release(ctx context.Context, record *idempotencydomain.Record) error
+}
+
+type UserRepository interface {
+	// CreateUser returns userdomain.ErrUsernameTaken when the username is in
+	// use, ignoring case.
+	CreateUser(ctx context.Context, user *userdomain.User) error
+	GetUser(ctx context.Context, userID string) (*userdomain.User, error)
+	// GetUserByUsername looks username up ignoring case.
+	GetUserByUsername(ctx context.Context, username string) (*userdomain.User, error)
+	// GetUsers returns the users of userIDs that exist, in no particular
+	// order.
+	GetUsers(ctx context.Context, userIDs []string) ([]*userdomain.User, error)
+	UpdateUserRole(ctx context.Context, userID string, role userdomain.Role) error
+}
+
+type OIDCRepository interface {
+	// CreateLoginState stores state and drops the expired ones.
+	CreateLoginState(ctx context.Context, state *userdomain.LoginState) error
+	// ConsumeLoginState deletes and returns the login state, expired or not,
+	// or returns userdomain.ErrInvalidLoginState when it is unknown.
+	ConsumeLoginState(ctx context.Context, state string) (*userdomain.LoginState, error)
+	// GetIdentity returns userdomain.ErrUserNotFound when no user is linked
+	// to the identity.
+	GetIdentity(ctx context.Context, issuer string, subject string) (*userdomain.Identity, error)
+	// CreateUserWithIdentity stores user linked to identity. It returns
+	// userdomain.ErrUsernameTaken or userdomain.ErrIdentityTaken when either
+	// is in use, and stores nothing then.
+	CreateUserWithIdentity(ctx context.Context, user *userdomain.User, identity *userdomain.Identity) error
+}
+
+type SessionRepository interface {
+	// CreateSession stores session together with its first refresh token.
+	CreateSession(ctx context.Context, session *userdomain.Session, refresh *userdomain.RefreshToken) error
+	// GetSession returns userdomain.ErrSessionNotFound when sessionID does
+	// not exist.
+	GetSession(ctx context.Context, sessionID string) (*userdomain.Session, error)
+	// GetSessionByTokenHash returns userdomain.ErrUnauthenticated when no
+	// session has the token.
+	GetSessionByTokenHash(ctx context.Context, tokenHash string) (*userdomain.Session, error)
+	// GetSessions returns the unrevoked, unexpired sessions of userID, most
+	// recently seen first.
+	GetSessions(ctx context.Context, userID string) ([]*userdomain.Session, error)
+	RevokeSession(ctx context.Context, sessionID string) error
+	// TouchSession records that sessionID was just used.
+	TouchSession(ctx context.Context, sessionID string) error
+	// RotateSession stores the new access token hash, expiry times and
+	// client of session along with refresh, its next refresh token. It
+	// returns userdomain.ErrUnauthenticated when the session was revoked in
+	// the meantime.
+	RotateSession(ctx context.Context, session *userdomain.Session, refresh *userdomain.RefreshToken) error
+
+	// GetRefreshToken returns userdomain.ErrUnauthenticated when no refresh
+	// token has the hash.
+	GetRefreshToken(ctx context.Context, tokenHash string) (*userdomain.RefreshToken, error)
+	// UseRefreshToken marks tokenID used. It reports false when it already
+	// was.
+	UseRefreshToken(ctx context.Context, tokenID string) (bool, error)
+}
+
+type APIKeyRepository interface {
+	CreateAPIKey(ctx context.Context, key *userdomain.APIKey) error
+	// GetAPIKeys returns the keys of userID, revoked ones included.
+	GetAPIKeys(ctx context.Context, userID string) ([]*userdomain.APIKey, error)
+	GetAPIKeyByHash(ctx context.Context, keyHash string) (*userdomain.APIKey, error)
+	RevokeAPIKey(ctx context.Context, keyID string) error
+	// TouchAPIKey records that keyID was just used.
+	TouchAPIKey(ctx context.Context, keyID string) error
+}
+
+type MessageRepository interface {
+	CreateMessage(ctx context.Context, msg *msgdomain.Message) error
+	GetMessage(ctx context.Context, messageID string) (*msgdomain.Message, error)
+	// GetMessages returns up to limit messages of chatID created before
+	// before, newest first.
+	GetMessages(ctx context.Context, chatID string, before time.Time, limit int) ([]*msgdomain.Message, error)
+}
+
+type WebhookRepository interface {
+	CreateWebhook(ctx context.Context, webhook *webhookdomain.Webhook) error
+	GetWebhooks(ctx context.Context) ([]*webhookdomain.Webhook, error)
+	GetWebhook(ctx context.Context, webhookID string) (*webhookdomain.Webhook, error)
+	GetWebhooksForEvent(ctx context.Context, chatID string, event webhookdomain.EventType) ([]*webhookdomain.Webhook, error)
+	DeleteWebhook(ctx context.Context, webhookID string) error
+
+	CreateDelivery(ctx context.Context, delivery *webhookdomain.Delivery) error
+	ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*webhookdomain.Delivery, error)
+	UpdateDelivery(ctx context.Context, delivery *webhookdomain.Delivery) error
+	GetDeliveries(ctx context.Context, webhookID string, limit int) ([]*webhookdomain.Delivery, error)
+	CreateDeadLetter(ctx context.Context, delivery *webhookdomain.Delivery) error
+}
+
+type IncomingWebhookRepository interface {
+	CreateIncomingWebhook(ctx context.Context, hook *webhookdomain.IncomingWebhook) error
+	GetIncomingWebhooks(ctx context.Context, chatID string) ([]*webhookdomain.IncomingWebhook, error)
+	GetIncomingWebhookByTokenHash(ctx context.Context, tokenHash string) (*webhookdomain.IncomingWebhook, error)
+	DeleteIncomingWebhook(ctx context.Context, hookID string) error
 }
@@ -0,0 +1,194 @@
+package inviterepository
+
+import (
+	chatdomain "chatsrv/internal/domain/chat"
+	invitedomain "chatsrv/internal/domain/invite"
+	"chatsrv/internal/repository"
+	"context"
+	"database/sql"
+	"errors"
+)
+
+var _ repository.InviteRepository = (*inviteRepository)(nil)
+
+func NewInviteRepository(db *sql.DB) *inviteRepository {
+	return &inviteRepository{
+		db: db,
+	}
+}
+
+type inviteRepository struct {
+	db *sql.DB
+}
+
+const inviteColumns = `uuid, chat_id, token_hash, created_by, role, max_uses, uses, expires_at, revoked_at, created_at`
+
+// CreateInvite implements repository.InviteRepository.
+func (r *inviteRepository) CreateInvite(ctx context.Context, invite *invitedomain.Invite) error {
+	query := `
+	INSERT INTO
+	chat_invites(uuid, chat_id, token_hash, created_by, role, max_uses, expires_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	RETURNING created_at`
+	err := r.db.QueryRowContext(ctx, query,
+		invite.ID, invite.ChatID, invite.TokenHash, invite.CreatedBy, string(invite.Role),
+		invite.MaxUses, invite.ExpiresAt,
+	).Scan(&invite.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetInvites implements repository.InviteRepository.
+func (r *inviteRepository) GetInvites(ctx context.Context, chatID string) ([]*invitedomain.Invite, error) {
+	query := `SELECT ` + inviteColumns + ` FROM chat_invites WHERE chat_id = $1 ORDER BY created_at DESC`
+	rows, err := r.db.QueryContext(ctx, query, chatID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var invites []*invitedomain.Invite
+	for rows.Next() {
+		invite, err := scanInvite(rows)
+		if err != nil {
+			return nil, err
+		}
+		invites = append(invites, invite)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return invites, nil
+}
+
+// GetInvite implements repository.InviteRepository.
+func (r *inviteRepository) GetInvite(ctx context.Context, inviteID string) (*invitedomain.Invite, error) {
+	query := `SELECT ` + inviteColumns + ` FROM chat_invites WHERE uuid = $1`
+	return r.getInvite(ctx, query, inviteID)
+}
+
+// GetInviteByTokenHash implements repository.InviteRepository.
+func (r *inviteRepository) GetInviteByTokenHash(ctx context.Context, tokenHash string) (*invitedomain.Invite, error) {
+	query := `SELECT ` + inviteColumns + ` FROM chat_invites WHERE token_hash = $1`
+	return r.getInvite(ctx, query, tokenHash)
+}
+
+// RevokeInvite implements repository.InviteRepository.
+func (r *inviteRepository) RevokeInvite(ctx context.Context, inviteID string) error {
+	query := `UPDATE chat_invites SET revoked_at = now() WHERE uuid = $1 AND revoked_at IS NULL`
+	res, err := r.db.ExecContext(ctx, query, inviteID)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return invitedomain.ErrInviteNotFound
+	}
+
+	return nil
+}
+
+// ConsumeInvite implements repository.InviteRepository.
+// The checks are repeated in the UPDATE so that two concurrent acceptances
+// cannot both take the last use.
+func (r *inviteRepository) ConsumeInvite(ctx context.Context, invite *invitedomain.Invite, userID string) (bool, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	query := `
+	UPDATE chat_invites SET uses = uses + 1
+	WHERE uuid = $1
+		AND revoked_at IS NULL
+		AND (expires_at IS NULL OR expires_at > now())
+		AND (max_uses = 0 OR uses < max_uses)`
+	res, err := tx.ExecContext(ctx, query, invite.ID)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if n != 1 {
+		return false, nil
+	}
+
+	query = `
+	INSERT INTO
+	chat_members(chat_id, user_id, role)
+	VALUES ($1, $2, $3)
+	ON CONFLICT DO NOTHING`
+	if _, err := tx.ExecContext(ctx, query, invite.ChatID, userID, string(invite.Role)); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
+// CreateAcceptance implements repository.InviteRepository.
+func (r *inviteRepository) CreateAcceptance(ctx context.Context, acceptance *invitedomain.Acceptance) error {
+	query := `
+	INSERT INTO
+	chat_invite_acceptances(invite_id, chat_id, user_id, result, ip, user_agent)
+	VALUES ($1, $2, $3, $4, $5, $6)`
+	_, err := r.db.ExecContext(ctx, query,
+		acceptance.InviteID, acceptance.ChatID, acceptance.UserID, string(acceptance.Result),
+		acceptance.IP, acceptance.UserAgent)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (r *inviteRepository) getInvite(ctx context.Context, query string, arg string) (*invitedomain.Invite, error) {
+	invite, err := scanInvite(r.db.QueryRowContext(ctx, query, arg))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, invitedomain.ErrInviteNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return invite, nil
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanInvite(row scanner) (*invitedomain.Invite, error) {
+	var (
+		invite    invitedomain.Invite
+		role      string
+		expiresAt sql.NullTime
+		revokedAt sql.NullTime
+	)
+	err := row.Scan(&invite.ID, &invite.ChatID, &invite.TokenHash, &invite.CreatedBy, &role,
+		&invite.MaxUses, &invite.Uses, &expiresAt, &revokedAt, &invite.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	invite.Role = chatdomain.Role(role)
+	if expiresAt.Valid {
+		invite.ExpiresAt = &expiresAt.Time
+	}
+	if revokedAt.Valid {
+		invite.RevokedAt = &revokedAt.Time
+	}
+
+	return &invite, nil
+}
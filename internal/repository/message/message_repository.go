@@ -0,0 +1,123 @@
+package messagerepository
+
+import (
+	msgdomain "chatsrv/internal/domain/msg"
+	"chatsrv/internal/repository"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+)
+
+var _ repository.MessageRepository = (*messageRepository)(nil)
+
+func NewMessageRepository(db *sql.DB) *messageRepository {
+	return &messageRepository{
+		db: db,
+	}
+}
+
+type messageRepository struct {
+	db *sql.DB
+}
+
+// CreateMessage implements repository.MessageRepository.
+func (m *messageRepository) CreateMessage(ctx context.Context, msg *msgdomain.Message) error {
+	attachments, err := json.Marshal(msg.Attachments)
+	if err != nil {
+		return err
+	}
+	if msg.Attachments == nil {
+		attachments = []byte("[]")
+	}
+
+	// The chat's last activity moves with every message so the chat list
+	// can sort by it without scanning messages.
+	query := `
+	WITH inserted AS (
+		INSERT INTO
+		messages(uuid, chat_id, sender_id, username, content, attachments, bot)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING chat_id, created_at
+	), touched AS (
+		UPDATE chats SET last_activity_at = inserted.created_at
+		FROM inserted
+		WHERE chats.uuid = inserted.chat_id
+	)
+	SELECT created_at FROM inserted`
+	var createdAt sql.NullTime
+	err = m.db.QueryRowContext(ctx, query,
+		msg.ID, msg.ChatID, msg.SenderID, msg.Username, msg.Content, attachments, msg.Bot,
+	).Scan(&createdAt)
+	if err != nil {
+		return err
+	}
+	msg.CreatedAt = &createdAt.Time
+
+	return nil
+}
+
+const messageColumns = `uuid, chat_id, sender_id, username, content, attachments, bot, created_at`
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func scanMessage(row scanner) (*msgdomain.Message, error) {
+	var (
+		msg         msgdomain.Message
+		attachments []byte
+		createdAt   time.Time
+	)
+	err := row.Scan(&msg.ID, &msg.ChatID, &msg.SenderID, &msg.Username, &msg.Content, &attachments, &msg.Bot, &createdAt)
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(attachments, &msg.Attachments); err != nil {
+		return nil, err
+	}
+	msg.Action = string(msgdomain.ActionSendText)
+	msg.CreatedAt = &createdAt
+	return &msg, nil
+}
+
+// GetMessage implements repository.MessageRepository.
+func (m *messageRepository) GetMessage(ctx context.Context, messageID string) (*msgdomain.Message, error) {
+	query := `SELECT ` + messageColumns + ` FROM messages WHERE uuid = $1`
+
+	msg, err := scanMessage(m.db.QueryRowContext(ctx, query, messageID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, msgdomain.ErrMessageNotFound
+	}
+	return msg, err
+}
+
+// GetMessages implements repository.MessageRepository.
+func (m *messageRepository) GetMessages(ctx context.Context, chatID string, before time.Time, limit int) ([]*msgdomain.Message, error) {
+	query := `
+	SELECT ` + messageColumns + `
+	FROM messages
+	WHERE chat_id = $1 AND created_at < $2
+	ORDER BY created_at DESC
+	LIMIT $3`
+	rows, err := m.db.QueryContext(ctx, query, chatID, before, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var messages []*msgdomain.Message
+	for rows.Next() {
+		msg, err := scanMessage(rows)
+		if err != nil {
+			return nil, err
+		}
+		messages = append(messages, msg)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return messages, nil
+}
@@ -0,0 +1,148 @@
+package userrepository
+
+import (
+	chatdomain "chatsrv/internal/domain/chat"
+	userdomain "chatsrv/internal/domain/user"
+	"chatsrv/internal/repository"
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var _ repository.APIKeyRepository = (*apiKeyRepository)(nil)
+
+const apiKeyColumns = `id, user_id, name, chat_id, permissions, key_hash, created_at, last_used_at, revoked_at`
+
+func NewAPIKeyRepository(db *sql.DB) *apiKeyRepository {
+	return &apiKeyRepository{
+		db: db,
+	}
+}
+
+type apiKeyRepository struct {
+	db *sql.DB
+}
+
+// CreateAPIKey implements repository.APIKeyRepository.
+func (r *apiKeyRepository) CreateAPIKey(ctx context.Context, key *userdomain.APIKey) error {
+	query := `
+	INSERT INTO
+	api_keys(id, user_id, name, chat_id, permissions, key_hash)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	RETURNING created_at`
+	err := r.db.QueryRowContext(ctx, query,
+		key.ID, key.UserID, key.Name, nullString(key.ChatID), joinPermissions(key.Permissions), key.KeyHash,
+	).Scan(&key.CreatedAt)
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == foreignKeyViolation && pgErr.ConstraintName == "api_keys_chat_id_fkey" {
+		return chatdomain.ErrChatNotFound
+	}
+	return err
+}
+
+// GetAPIKeys implements repository.APIKeyRepository.
+func (r *apiKeyRepository) GetAPIKeys(ctx context.Context, userID string) ([]*userdomain.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE user_id = $1 ORDER BY created_at`
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var keys []*userdomain.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return keys, nil
+}
+
+// GetAPIKeyByHash implements repository.APIKeyRepository.
+func (r *apiKeyRepository) GetAPIKeyByHash(ctx context.Context, keyHash string) (*userdomain.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE key_hash = $1`
+	return scanAPIKey(r.db.QueryRowContext(ctx, query, keyHash))
+}
+
+// RevokeAPIKey implements repository.APIKeyRepository.
+func (r *apiKeyRepository) RevokeAPIKey(ctx context.Context, keyID string) error {
+	query := `UPDATE api_keys SET revoked_at = COALESCE(revoked_at, now()) WHERE id = $1`
+	res, err := r.db.ExecContext(ctx, query, keyID)
+	if err != nil {
+		return err
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return userdomain.ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// TouchAPIKey implements repository.APIKeyRepository.
+// The timestamp is only written once a minute to spare busy keys a write
+// per request.
+func (r *apiKeyRepository) TouchAPIKey(ctx context.Context, keyID string) error {
+	query := `
+	UPDATE api_keys SET last_used_at = now()
+	WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < now() - interval '1 minute')`
+	_, err := r.db.ExecContext(ctx, query, keyID)
+	return err
+}
+
+func scanAPIKey(row scanner) (*userdomain.APIKey, error) {
+	var (
+		key         userdomain.APIKey
+		chatID      sql.NullString
+		permissions string
+		lastUsedAt  sql.NullTime
+		revokedAt   sql.NullTime
+	)
+	err := row.Scan(&key.ID, &key.UserID, &key.Name, &chatID, &permissions, &key.KeyHash,
+		&key.CreatedAt, &lastUsedAt, &revokedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, userdomain.ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key.ChatID = chatID.String
+	for _, p := range strings.Split(permissions, ",") {
+		if p != "" {
+			key.Permissions = append(key.Permissions, userdomain.Permission(p))
+		}
+	}
+	if lastUsedAt.Valid {
+		key.LastUsedAt = &lastUsedAt.Time
+	}
+	if revokedAt.Valid {
+		key.RevokedAt = &revokedAt.Time
+	}
+
+	return &key, nil
+}
+
+func joinPermissions(perms []userdomain.Permission) string {
+	parts := make([]string, len(perms))
+	for i, p := range perms {
+		parts[i] = string(p)
+	}
+	return strings.Join(parts, ",")
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
@@ -0,0 +1,105 @@
+package userrepository
+
+import (
+	userdomain "chatsrv/internal/domain/user"
+	"chatsrv/internal/repository"
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var _ repository.OIDCRepository = (*oidcRepository)(nil)
+
+func NewOIDCRepository(db *sql.DB) *oidcRepository {
+	return &oidcRepository{
+		db: db,
+	}
+}
+
+type oidcRepository struct {
+	db *sql.DB
+}
+
+// CreateLoginState implements repository.OIDCRepository.
+func (r *oidcRepository) CreateLoginState(ctx context.Context, state *userdomain.LoginState) error {
+	query := `
+	WITH expired AS (
+		DELETE FROM oidc_login_states WHERE expires_at < now()
+	)
+	INSERT INTO
+	oidc_login_states(state, nonce, verifier, expires_at)
+	VALUES ($1, $2, $3, $4)`
+	_, err := r.db.ExecContext(ctx, query, state.State, state.Nonce, state.Verifier, state.ExpiresAt)
+	return err
+}
+
+// ConsumeLoginState implements repository.OIDCRepository.
+func (r *oidcRepository) ConsumeLoginState(ctx context.Context, state string) (*userdomain.LoginState, error) {
+	query := `
+	DELETE FROM oidc_login_states
+	WHERE state = $1
+	RETURNING state, nonce, verifier, expires_at`
+
+	var login userdomain.LoginState
+	err := r.db.QueryRowContext(ctx, query, state).Scan(&login.State, &login.Nonce, &login.Verifier, &login.ExpiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, userdomain.ErrInvalidLoginState
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &login, nil
+}
+
+// GetIdentity implements repository.OIDCRepository.
+func (r *oidcRepository) GetIdentity(ctx context.Context, issuer string, subject string) (*userdomain.Identity, error) {
+	query := `
+	SELECT issuer, subject, user_id, created_at
+	FROM user_identities
+	WHERE issuer = $1 AND subject = $2`
+
+	var identity userdomain.Identity
+	err := r.db.QueryRowContext(ctx, query, issuer, subject).Scan(
+		&identity.Issuer, &identity.Subject, &identity.UserID, &identity.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, userdomain.ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &identity, nil
+}
+
+// CreateUserWithIdentity implements repository.OIDCRepository.
+func (r *oidcRepository) CreateUserWithIdentity(ctx context.Context, user *userdomain.User, identity *userdomain.Identity) error {
+	query := `
+	WITH u AS (
+		INSERT INTO
+		users(id, username, display_name, bot, role, password_hash)
+		VALUES ($1, $2, $3, $4, $5, $6)
+		RETURNING id, created_at
+	)
+	INSERT INTO user_identities(issuer, subject, user_id)
+	SELECT $7, $8, u.id FROM u
+	RETURNING created_at`
+	err := r.db.QueryRowContext(ctx, query,
+		user.ID, user.Username, user.DisplayName, user.Bot, user.Role, user.PasswordHash,
+		identity.Issuer, identity.Subject,
+	).Scan(&user.CreatedAt)
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation && pgErr.ConstraintName == "user_identities_pkey" {
+		return userdomain.ErrIdentityTaken
+	}
+	if err != nil {
+		return mapUserError(err)
+	}
+
+	identity.UserID = user.ID
+	identity.CreatedAt = user.CreatedAt
+	return nil
+}
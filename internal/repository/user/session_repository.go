@@ -0,0 +1,201 @@
+package userrepository
+
+import (
+	userdomain "chatsrv/internal/domain/user"
+	"chatsrv/internal/repository"
+	"context"
+	"database/sql"
+	"errors"
+)
+
+var _ repository.SessionRepository = (*sessionRepository)(nil)
+
+const sessionColumns = `id, user_id, token_hash, device_name, ip, user_agent,
+	created_at, last_seen_at, access_expires_at, expires_at, revoked_at`
+
+func NewSessionRepository(db *sql.DB) *sessionRepository {
+	return &sessionRepository{
+		db: db,
+	}
+}
+
+type sessionRepository struct {
+	db *sql.DB
+}
+
+// CreateSession implements repository.SessionRepository.
+func (r *sessionRepository) CreateSession(ctx context.Context, session *userdomain.Session, refresh *userdomain.RefreshToken) error {
+	query := `
+	WITH s AS (
+		INSERT INTO
+		sessions(id, user_id, token_hash, device_name, ip, user_agent, access_expires_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		RETURNING id
+	)
+	INSERT INTO refresh_tokens(id, session_id, token_hash)
+	SELECT $9, s.id, $10 FROM s
+	RETURNING created_at`
+	// Both rows are stamped with the same transaction time.
+	err := r.db.QueryRowContext(ctx, query,
+		session.ID, session.UserID, session.TokenHash, session.DeviceName, session.IP, session.UserAgent,
+		session.AccessExpiresAt, session.ExpiresAt,
+		refresh.ID, refresh.TokenHash,
+	).Scan(&session.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	session.LastSeenAt = session.CreatedAt
+	refresh.SessionID = session.ID
+	refresh.CreatedAt = session.CreatedAt
+	return nil
+}
+
+// GetSession implements repository.SessionRepository.
+func (r *sessionRepository) GetSession(ctx context.Context, sessionID string) (*userdomain.Session, error) {
+	query := `SELECT ` + sessionColumns + ` FROM sessions WHERE id = $1`
+
+	session, err := scanSession(r.db.QueryRowContext(ctx, query, sessionID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, userdomain.ErrSessionNotFound
+	}
+	return session, err
+}
+
+// GetSessionByTokenHash implements repository.SessionRepository.
+func (r *sessionRepository) GetSessionByTokenHash(ctx context.Context, tokenHash string) (*userdomain.Session, error) {
+	query := `SELECT ` + sessionColumns + ` FROM sessions WHERE token_hash = $1`
+
+	session, err := scanSession(r.db.QueryRowContext(ctx, query, tokenHash))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, userdomain.ErrUnauthenticated
+	}
+	return session, err
+}
+
+// GetSessions implements repository.SessionRepository.
+func (r *sessionRepository) GetSessions(ctx context.Context, userID string) ([]*userdomain.Session, error) {
+	query := `
+	SELECT ` + sessionColumns + `
+	FROM sessions
+	WHERE user_id = $1 AND revoked_at IS NULL AND expires_at > now()
+	ORDER BY last_seen_at DESC, id`
+	rows, err := r.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := make([]*userdomain.Session, 0)
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
+
+// RevokeSession implements repository.SessionRepository.
+func (r *sessionRepository) RevokeSession(ctx context.Context, sessionID string) error {
+	query := `UPDATE sessions SET revoked_at = now() WHERE id = $1 AND revoked_at IS NULL`
+	_, err := r.db.ExecContext(ctx, query, sessionID)
+	return err
+}
+
+// TouchSession implements repository.SessionRepository.
+// Like TouchAPIKey, it writes at most once a minute.
+func (r *sessionRepository) TouchSession(ctx context.Context, sessionID string) error {
+	query := `
+	UPDATE sessions SET last_seen_at = now()
+	WHERE id = $1 AND last_seen_at < now() - interval '1 minute'`
+	_, err := r.db.ExecContext(ctx, query, sessionID)
+	return err
+}
+
+// RotateSession implements repository.SessionRepository.
+func (r *sessionRepository) RotateSession(ctx context.Context, session *userdomain.Session, refresh *userdomain.RefreshToken) error {
+	query := `
+	WITH s AS (
+		UPDATE sessions
+		SET token_hash = $2, access_expires_at = $3, expires_at = $4, ip = $5, user_agent = $6, last_seen_at = now()
+		WHERE id = $1 AND revoked_at IS NULL
+		RETURNING id
+	)
+	INSERT INTO refresh_tokens(id, session_id, token_hash)
+	SELECT $7, s.id, $8 FROM s
+	RETURNING created_at`
+	err := r.db.QueryRowContext(ctx, query,
+		session.ID, session.TokenHash, session.AccessExpiresAt, session.ExpiresAt, session.IP, session.UserAgent,
+		refresh.ID, refresh.TokenHash,
+	).Scan(&refresh.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return userdomain.ErrUnauthenticated
+	}
+	if err != nil {
+		return err
+	}
+
+	session.LastSeenAt = refresh.CreatedAt
+	refresh.SessionID = session.ID
+	return nil
+}
+
+// GetRefreshToken implements repository.SessionRepository.
+func (r *sessionRepository) GetRefreshToken(ctx context.Context, tokenHash string) (*userdomain.RefreshToken, error) {
+	query := `SELECT id, session_id, token_hash, created_at, used_at FROM refresh_tokens WHERE token_hash = $1`
+
+	var (
+		refresh userdomain.RefreshToken
+		usedAt  sql.NullTime
+	)
+	err := r.db.QueryRowContext(ctx, query, tokenHash).Scan(
+		&refresh.ID, &refresh.SessionID, &refresh.TokenHash, &refresh.CreatedAt, &usedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, userdomain.ErrUnauthenticated
+	}
+	if err != nil {
+		return nil, err
+	}
+	if usedAt.Valid {
+		refresh.UsedAt = &usedAt.Time
+	}
+
+	return &refresh, nil
+}
+
+// UseRefreshToken implements repository.SessionRepository.
+func (r *sessionRepository) UseRefreshToken(ctx context.Context, tokenID string) (bool, error) {
+	query := `UPDATE refresh_tokens SET used_at = now() WHERE id = $1 AND used_at IS NULL`
+	res, err := r.db.ExecContext(ctx, query, tokenID)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n == 1, nil
+}
+
+func scanSession(row scanner) (*userdomain.Session, error) {
+	var (
+		session   userdomain.Session
+		revokedAt sql.NullTime
+	)
+	err := row.Scan(
+		&session.ID, &session.UserID, &session.TokenHash, &session.DeviceName, &session.IP, &session.UserAgent,
+		&session.CreatedAt, &session.LastSeenAt, &session.AccessExpiresAt, &session.ExpiresAt, &revokedAt)
+	if err != nil {
+		return nil, err
+	}
+	if revokedAt.Valid {
+		session.RevokedAt = &revokedAt.Time
+	}
+
+	return &session, nil
+}
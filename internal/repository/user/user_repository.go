@@ -0,0 +1,129 @@
+package userrepository
+
+import (
+	userdomain "chatsrv/internal/domain/user"
+	"chatsrv/internal/repository"
+	"context"
+	"database/sql"
+	"errors"
+
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var _ repository.UserRepository = (*userRepository)(nil)
+
+// Postgres error codes of constraint violations.
+const (
+	uniqueViolation     = "23505"
+	foreignKeyViolation = "23503"
+)
+
+const userColumns = `id, username, display_name, bot, role, password_hash, created_at`
+
+func NewUserRepository(db *sql.DB) *userRepository {
+	return &userRepository{
+		db: db,
+	}
+}
+
+type userRepository struct {
+	db *sql.DB
+}
+
+// CreateUser implements repository.UserRepository.
+func (r *userRepository) CreateUser(ctx context.Context, user *userdomain.User) error {
+	query := `
+	INSERT INTO
+	users(id, username, display_name, bot, role, password_hash)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	RETURNING created_at`
+	err := r.db.QueryRowContext(ctx, query,
+		user.ID, user.Username, user.DisplayName, user.Bot, user.Role, user.PasswordHash,
+	).Scan(&user.CreatedAt)
+	return mapUserError(err)
+}
+
+// UpdateUserRole implements repository.UserRepository.
+func (r *userRepository) UpdateUserRole(ctx context.Context, userID string, role userdomain.Role) error {
+	query := `UPDATE users SET role = $2 WHERE id = $1`
+	res, err := r.db.ExecContext(ctx, query, userID, role)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return userdomain.ErrUserNotFound
+	}
+
+	return nil
+}
+
+// GetUser implements repository.UserRepository.
+func (r *userRepository) GetUser(ctx context.Context, userID string) (*userdomain.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = $1`
+	return scanUser(r.db.QueryRowContext(ctx, query, userID))
+}
+
+// GetUserByUsername implements repository.UserRepository.
+func (r *userRepository) GetUserByUsername(ctx context.Context, username string) (*userdomain.User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE lower(username) = lower($1)`
+	return scanUser(r.db.QueryRowContext(ctx, query, username))
+}
+
+// GetUsers implements repository.UserRepository.
+func (r *userRepository) GetUsers(ctx context.Context, userIDs []string) ([]*userdomain.User, error) {
+	if len(userIDs) == 0 {
+		return nil, nil
+	}
+
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = ANY($1)`
+	rows, err := r.db.QueryContext(ctx, query, userIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var users []*userdomain.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, err
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return users, nil
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// mapUserError turns the constraint violations of inserting a user into
+// domain errors.
+func mapUserError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation && pgErr.ConstraintName == "users_username_idx" {
+		return userdomain.ErrUsernameTaken
+	}
+	return err
+}
+
+func scanUser(row scanner) (*userdomain.User, error) {
+	var user userdomain.User
+	err := row.Scan(&user.ID, &user.Username, &user.DisplayName, &user.Bot, &user.Role, &user.PasswordHash, &user.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, userdomain.ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
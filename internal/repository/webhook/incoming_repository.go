@@ -0,0 +1,106 @@
+package webhookrepository
+
+import (
+	webhookdomain "chatsrv/internal/domain/webhook"
+	"chatsrv/internal/repository"
+	"context"
+	"database/sql"
+	"errors"
+)
+
+var _ repository.IncomingWebhookRepository = (*incomingWebhookRepository)(nil)
+
+func NewIncomingWebhookRepository(db *sql.DB) *incomingWebhookRepository {
+	return &incomingWebhookRepository{
+		db: db,
+	}
+}
+
+type incomingWebhookRepository struct {
+	db *sql.DB
+}
+
+// CreateIncomingWebhook implements repository.IncomingWebhookRepository.
+func (r *incomingWebhookRepository) CreateIncomingWebhook(ctx context.Context, hook *webhookdomain.IncomingWebhook) error {
+	query := `
+	INSERT INTO
+	incoming_webhooks(uuid, chat_id, name, token_hash, rate_per_minute, burst)
+	VALUES ($1, $2, $3, $4, $5, $6)
+	RETURNING created_at`
+	err := r.db.QueryRowContext(ctx, query,
+		hook.ID, hook.ChatID, hook.Name, hook.TokenHash, hook.RatePerMinute, hook.Burst,
+	).Scan(&hook.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetIncomingWebhooks implements repository.IncomingWebhookRepository.
+func (r *incomingWebhookRepository) GetIncomingWebhooks(ctx context.Context, chatID string) ([]*webhookdomain.IncomingWebhook, error) {
+	query := `
+	SELECT uuid, chat_id, name, token_hash, rate_per_minute, burst, created_at
+	FROM incoming_webhooks
+	WHERE chat_id = $1
+	ORDER BY created_at`
+	rows, err := r.db.QueryContext(ctx, query, chatID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var hooks []*webhookdomain.IncomingWebhook
+	for rows.Next() {
+		var hook webhookdomain.IncomingWebhook
+		err := rows.Scan(&hook.ID, &hook.ChatID, &hook.Name, &hook.TokenHash,
+			&hook.RatePerMinute, &hook.Burst, &hook.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		hooks = append(hooks, &hook)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return hooks, nil
+}
+
+// GetIncomingWebhookByTokenHash implements repository.IncomingWebhookRepository.
+func (r *incomingWebhookRepository) GetIncomingWebhookByTokenHash(ctx context.Context, tokenHash string) (*webhookdomain.IncomingWebhook, error) {
+	query := `
+	SELECT uuid, chat_id, name, token_hash, rate_per_minute, burst, created_at
+	FROM incoming_webhooks
+	WHERE token_hash = $1`
+
+	var hook webhookdomain.IncomingWebhook
+	err := r.db.QueryRowContext(ctx, query, tokenHash).Scan(&hook.ID, &hook.ChatID, &hook.Name,
+		&hook.TokenHash, &hook.RatePerMinute, &hook.Burst, &hook.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, webhookdomain.ErrIncomingWebhookNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &hook, nil
+}
+
+// DeleteIncomingWebhook implements repository.IncomingWebhookRepository.
+func (r *incomingWebhookRepository) DeleteIncomingWebhook(ctx context.Context, hookID string) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM incoming_webhooks WHERE uuid = $1`, hookID)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return webhookdomain.ErrIncomingWebhookNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,266 @@
+package webhookrepository
+
+import (
+	webhookdomain "chatsrv/internal/domain/webhook"
+	"chatsrv/internal/repository"
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"github.com/jackc/pgx/v5/pgtype"
+)
+
+var _ repository.WebhookRepository = (*webhookRepository)(nil)
+
+func NewWebhookRepository(db *sql.DB) *webhookRepository {
+	return &webhookRepository{
+		db:   db,
+		tmap: pgtype.NewMap(),
+	}
+}
+
+type webhookRepository struct {
+	db   *sql.DB
+	tmap *pgtype.Map
+}
+
+const deliveryColumns = `uuid, webhook_id, event_id, event_type, payload, status, attempts,
+	response_code, last_error, next_attempt_at, delivered_at, created_at`
+
+// CreateWebhook implements repository.WebhookRepository.
+func (r *webhookRepository) CreateWebhook(ctx context.Context, webhook *webhookdomain.Webhook) error {
+	query := `
+	INSERT INTO
+	webhooks(uuid, chat_id, url, secret, events)
+	VALUES ($1, $2, $3, $4, $5)
+	RETURNING created_at`
+	err := r.db.QueryRowContext(ctx, query,
+		webhook.ID, nullString(webhook.ChatID), webhook.URL, webhook.Secret, eventStrings(webhook.Events),
+	).Scan(&webhook.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetWebhooks implements repository.WebhookRepository.
+func (r *webhookRepository) GetWebhooks(ctx context.Context) ([]*webhookdomain.Webhook, error) {
+	query := `SELECT uuid, chat_id, url, secret, events, created_at FROM webhooks ORDER BY created_at`
+	return r.queryWebhooks(ctx, query)
+}
+
+// GetWebhook implements repository.WebhookRepository.
+func (r *webhookRepository) GetWebhook(ctx context.Context, webhookID string) (*webhookdomain.Webhook, error) {
+	query := `SELECT uuid, chat_id, url, secret, events, created_at FROM webhooks WHERE uuid = $1`
+
+	webhook, err := r.scanWebhook(r.db.QueryRowContext(ctx, query, webhookID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, webhookdomain.ErrWebhookNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return webhook, nil
+}
+
+// GetWebhooksForEvent implements repository.WebhookRepository.
+func (r *webhookRepository) GetWebhooksForEvent(ctx context.Context, chatID string, event webhookdomain.EventType) ([]*webhookdomain.Webhook, error) {
+	query := `
+	SELECT uuid, chat_id, url, secret, events, created_at
+	FROM webhooks
+	WHERE (chat_id IS NULL OR chat_id = $1) AND $2 = ANY(events)`
+	return r.queryWebhooks(ctx, query, chatID, string(event))
+}
+
+// DeleteWebhook implements repository.WebhookRepository.
+func (r *webhookRepository) DeleteWebhook(ctx context.Context, webhookID string) error {
+	res, err := r.db.ExecContext(ctx, `DELETE FROM webhooks WHERE uuid = $1`, webhookID)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return webhookdomain.ErrWebhookNotFound
+	}
+
+	return nil
+}
+
+// CreateDelivery implements repository.WebhookRepository.
+func (r *webhookRepository) CreateDelivery(ctx context.Context, delivery *webhookdomain.Delivery) error {
+	query := `
+	INSERT INTO
+	webhook_deliveries(uuid, webhook_id, event_id, event_type, payload, status, next_attempt_at)
+	VALUES ($1, $2, $3, $4, $5, $6, $7)
+	RETURNING created_at`
+	err := r.db.QueryRowContext(ctx, query,
+		delivery.ID, delivery.WebhookID, delivery.EventID, string(delivery.EventType),
+		[]byte(delivery.Payload), string(delivery.Status), delivery.NextAttemptAt,
+	).Scan(&delivery.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ClaimDueDeliveries implements repository.WebhookRepository.
+// Claimed rows have their next attempt pushed out by lease so that a
+// concurrent worker does not pick them up while they are in flight.
+func (r *webhookRepository) ClaimDueDeliveries(ctx context.Context, limit int, lease time.Duration) ([]*webhookdomain.Delivery, error) {
+	query := `
+	UPDATE webhook_deliveries
+	SET next_attempt_at = now() + $2 * interval '1 millisecond'
+	WHERE id IN (
+		SELECT id FROM webhook_deliveries
+		WHERE status = 'pending' AND next_attempt_at <= now()
+		ORDER BY next_attempt_at
+		LIMIT $1
+		FOR UPDATE SKIP LOCKED
+	)
+	RETURNING ` + deliveryColumns
+	return r.queryDeliveries(ctx, query, limit, lease.Milliseconds())
+}
+
+// UpdateDelivery implements repository.WebhookRepository.
+func (r *webhookRepository) UpdateDelivery(ctx context.Context, delivery *webhookdomain.Delivery) error {
+	query := `
+	UPDATE webhook_deliveries
+	SET status = $2, attempts = $3, response_code = $4, last_error = $5,
+		next_attempt_at = $6, delivered_at = $7
+	WHERE uuid = $1`
+	_, err := r.db.ExecContext(ctx, query,
+		delivery.ID, string(delivery.Status), delivery.Attempts, delivery.ResponseCode,
+		delivery.LastError, delivery.NextAttemptAt, delivery.DeliveredAt,
+	)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetDeliveries implements repository.WebhookRepository.
+func (r *webhookRepository) GetDeliveries(ctx context.Context, webhookID string, limit int) ([]*webhookdomain.Delivery, error) {
+	query := `SELECT ` + deliveryColumns + `
+	FROM webhook_deliveries
+	WHERE webhook_id = $1
+	ORDER BY created_at DESC
+	LIMIT $2`
+	return r.queryDeliveries(ctx, query, webhookID, limit)
+}
+
+// CreateDeadLetter implements repository.WebhookRepository.
+func (r *webhookRepository) CreateDeadLetter(ctx context.Context, delivery *webhookdomain.Delivery) error {
+	query := `
+	INSERT INTO
+	webhook_dead_letters(delivery_id, webhook_id, payload, last_error)
+	VALUES ($1, $2, $3, $4)`
+	_, err := r.db.ExecContext(ctx, query,
+		delivery.ID, delivery.WebhookID, []byte(delivery.Payload), delivery.LastError)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+func (r *webhookRepository) scanWebhook(row scanner) (*webhookdomain.Webhook, error) {
+	var (
+		webhook webhookdomain.Webhook
+		chatID  sql.NullString
+		events  []string
+	)
+	err := row.Scan(&webhook.ID, &chatID, &webhook.URL, &webhook.Secret, r.tmap.SQLScanner(&events), &webhook.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	webhook.ChatID = chatID.String
+	for _, e := range events {
+		webhook.Events = append(webhook.Events, webhookdomain.EventType(e))
+	}
+
+	return &webhook, nil
+}
+
+func (r *webhookRepository) queryWebhooks(ctx context.Context, query string, args ...any) ([]*webhookdomain.Webhook, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var webhooks []*webhookdomain.Webhook
+	for rows.Next() {
+		webhook, err := r.scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+func (r *webhookRepository) queryDeliveries(ctx context.Context, query string, args ...any) ([]*webhookdomain.Delivery, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deliveries []*webhookdomain.Delivery
+	for rows.Next() {
+		var (
+			d           webhookdomain.Delivery
+			eventType   string
+			status      string
+			payload     []byte
+			deliveredAt sql.NullTime
+		)
+		err := rows.Scan(&d.ID, &d.WebhookID, &d.EventID, &eventType, &payload, &status, &d.Attempts,
+			&d.ResponseCode, &d.LastError, &d.NextAttemptAt, &deliveredAt, &d.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		d.EventType = webhookdomain.EventType(eventType)
+		d.Status = webhookdomain.DeliveryStatus(status)
+		d.Payload = payload
+		if deliveredAt.Valid {
+			d.DeliveredAt = &deliveredAt.Time
+		}
+		deliveries = append(deliveries, &d)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+func eventStrings(events []webhookdomain.EventType) []string {
+	out := make([]string, 0, len(events))
+	for _, e := range events {
+		out = append(out, string(e))
+	}
+	return out
+}
+
+func nullString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
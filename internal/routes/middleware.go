@@ -0,0 +1,111 @@
+package routes
+
+import (
+	"chatsrv/internal/auth"
+	"chatsrv/internal/config"
+	chatctrl "chatsrv/internal/controller/chat"
+	userdomain "chatsrv/internal/domain/user"
+	"chatsrv/internal/service"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// authenticate resolves the bearer token of the request, if any, and binds
+// the caller to the request context. Requests without a token pass through
+// anonymously; unless cfg allows it they lose the X-User-ID header too.
+func authenticate(srv service.AuthService, cfg config.AuthConfig, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tok, ok := auth.BearerToken(r)
+		if !ok {
+			if !cfg.AllowUserHeader() {
+				r.Header.Del(chatctrl.HeaderUserID)
+			}
+			next(w, r)
+			return
+		}
+
+		principal, err := srv.Authenticate(r.Context(), tok)
+		if errors.Is(err, userdomain.ErrUnauthenticated) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			http.Error(w, "failed to authenticate", http.StatusInternalServerError)
+			return
+		}
+
+		r.Header.Del(chatctrl.HeaderUserID)
+		next(w, r.WithContext(auth.NewContext(r.Context(), principal)))
+	}
+}
+
+// requireUser rejects WebSocket upgrades that carry no valid token with 401
+// and binds the caller to the request context for the handshake.
+func requireUser(srv service.AuthService, next http.Handler) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		tok, ok := auth.WebSocketToken(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		principal, err := srv.Authenticate(r.Context(), tok)
+		if errors.Is(err, userdomain.ErrUnauthenticated) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			http.Error(w, "failed to authenticate", http.StatusInternalServerError)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(auth.NewContext(r.Context(), principal)))
+	}
+}
+
+// requireAdmin guards admin endpoints. They accept the static bearer token
+// from ADMIN_TOKEN, unless it is empty, and the login tokens of users with
+// the admin role.
+func requireAdmin(cfg config.AdminConfig, srv service.AuthService, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		got, ok := auth.BearerToken(r)
+		if !ok {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		token := cfg.Token()
+		if token != "" && subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1 {
+			next(w, r)
+			return
+		}
+
+		principal, err := srv.Authenticate(r.Context(), got)
+		if errors.Is(err, userdomain.ErrUnauthenticated) {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		if err != nil {
+			http.Error(w, "failed to authenticate", http.StatusInternalServerError)
+			return
+		}
+		user, err := srv.GetUser(r.Context(), principal.UserID)
+		if err != nil {
+			http.Error(w, "failed to authenticate", http.StatusInternalServerError)
+			return
+		}
+		if user.Role != userdomain.RoleAdmin {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+
+		next(w, r.WithContext(auth.NewContext(r.Context(), principal)))
+	}
+}
+
+func methodNotAllowed(w http.ResponseWriter, allowed ...string) {
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+}
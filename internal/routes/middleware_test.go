@@ -0,0 +1,183 @@
+package routes
+
+import (
+	"chatsrv/internal/auth"
+	chatctrl "chatsrv/internal/controller/chat"
+	userdomain "chatsrv/internal/domain/user"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// stubAuthService accepts the token "valid" for user alice and "root" for
+// the admin root
+type stubAuthService struct{}
+
+func (stubAuthService) Register(context.Context, userdomain.RegisterRequest, userdomain.ClientMeta) (*userdomain.AuthToken, error) {
+	return nil, nil
+}
+func (stubAuthService) Login(context.Context, userdomain.LoginRequest, userdomain.ClientMeta) (*userdomain.AuthToken, error) {
+	return nil, nil
+}
+func (stubAuthService) Refresh(context.Context, string, userdomain.ClientMeta) (*userdomain.AuthToken, error) {
+	return nil, nil
+}
+func (stubAuthService) Logout(context.Context, *userdomain.Principal) error { return nil }
+func (stubAuthService) GetSessions(context.Context, *userdomain.Principal) ([]*userdomain.Session, error) {
+	return nil, nil
+}
+func (stubAuthService) RevokeSession(context.Context, *userdomain.Principal, string) error {
+	return nil
+}
+func (stubAuthService) Authenticate(_ context.Context, tok string) (*userdomain.Principal, error) {
+	switch tok {
+	case "valid":
+		return &userdomain.Principal{UserID: "alice", SessionID: "s1"}, nil
+	case "root":
+		return &userdomain.Principal{UserID: "root", SessionID: "s2"}, nil
+	}
+	return nil, userdomain.ErrUnauthenticated
+}
+func (stubAuthService) GetUser(_ context.Context, userID string) (*userdomain.User, error) {
+	role := userdomain.RoleUser
+	if userID == "root" {
+		role = userdomain.RoleAdmin
+	}
+	return &userdomain.User{ID: userID, Role: role}, nil
+}
+func (stubAuthService) OIDCLoginURL(context.Context) (string, error) { return "", nil }
+func (stubAuthService) OIDCCallback(context.Context, string, string, userdomain.ClientMeta) (*userdomain.AuthToken, error) {
+	return nil, nil
+}
+func (stubAuthService) CreateServiceAccount(context.Context, userdomain.CreateServiceAccountRequest) (*userdomain.User, error) {
+	return nil, nil
+}
+func (stubAuthService) CreateAPIKey(context.Context, string, userdomain.CreateAPIKeyRequest) (*userdomain.APIKey, error) {
+	return nil, nil
+}
+func (stubAuthService) GetAPIKeys(context.Context, string) ([]*userdomain.APIKey, error) {
+	return nil, nil
+}
+func (stubAuthService) RevokeAPIKey(context.Context, string) error { return nil }
+
+// TestRequireUser verifies upgrades without a valid token are rejected and
+// the caller is bound to the context otherwise
+func TestRequireUser(t *testing.T) {
+	var got *userdomain.Principal
+	handler := requireUser(stubAuthService{}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = auth.FromContext(r.Context())
+	}))
+
+	for _, target := range []string{"/ws", "/ws?access_token=forged"} {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest("GET", target, nil))
+		if rec.Code != http.StatusUnauthorized {
+			t.Errorf("Expected 401 for %s, got %d", target, rec.Code)
+		}
+	}
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest("GET", "/ws?access_token=valid", nil))
+	if rec.Code != http.StatusOK || got == nil || got.UserID != "alice" {
+		t.Errorf("Expected alice to be bound, got %d %+v", rec.Code, got)
+	}
+}
+
+// stubAuthConfig is a config.AuthConfig that only sets whether the
+// X-User-ID header is trusted
+type stubAuthConfig bool
+
+func (stubAuthConfig) AccessTokenTTL() time.Duration { return time.Minute }
+func (stubAuthConfig) SessionTTL() time.Duration     { return time.Hour }
+func (stubAuthConfig) BcryptCost() int               { return 4 }
+func (c stubAuthConfig) AllowUserHeader() bool       { return bool(c) }
+
+// TestAuthenticateUserHeader verifies X-User-ID is dropped from requests
+// without a token unless it is explicitly allowed, and from every request
+// with one
+func TestAuthenticateUserHeader(t *testing.T) {
+	var header string
+	next := func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Get(chatctrl.HeaderUserID)
+	}
+
+	for _, tc := range []struct {
+		allow bool
+		token string
+		want  string
+	}{
+		{allow: false, want: ""},
+		{allow: true, want: "mallory"},
+		{allow: true, token: "valid", want: ""},
+	} {
+		req := httptest.NewRequest("GET", "/chats", nil)
+		req.Header.Set(chatctrl.HeaderUserID, "mallory")
+		if tc.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+		}
+		authenticate(stubAuthService{}, stubAuthConfig(tc.allow), next)(httptest.NewRecorder(), req)
+		if header != tc.want {
+			t.Errorf("Expected header %q with allow=%v token=%q, got %q", tc.want, tc.allow, tc.token, header)
+		}
+	}
+}
+
+// TestRequireAdmin verifies the admin API takes the static token and the
+// logins of admins only
+func TestRequireAdmin(t *testing.T) {
+	handler := requireAdmin(stubAdminConfig("secret"), stubAuthService{}, func(w http.ResponseWriter, r *http.Request) {})
+
+	for token, want := range map[string]int{
+		"":       http.StatusUnauthorized,
+		"forged": http.StatusUnauthorized,
+		"secret": http.StatusOK,
+		"valid":  http.StatusForbidden,
+		"root":   http.StatusOK,
+	} {
+		req := httptest.NewRequest("GET", "/webhooks", nil)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		rec := httptest.NewRecorder()
+		handler(rec, req)
+		if rec.Code != want {
+			t.Errorf("Expected %d for token %q, got %d", want, token, rec.Code)
+		}
+	}
+
+	// Without ADMIN_TOKEN only admins get in.
+	handler = requireAdmin(stubAdminConfig(""), stubAuthService{}, func(w http.ResponseWriter, r *http.Request) {})
+	req := httptest.NewRequest("GET", "/webhooks", nil)
+	req.Header.Set("Authorization", "Bearer root")
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected admins to get in without ADMIN_TOKEN, got %d", rec.Code)
+	}
+}
+
+type stubAdminConfig string
+
+func (c stubAdminConfig) Token() string { return string(c) }
+
+// TestAcceptProtocol verifies chat protocol versions are preferred and
+// token subprotocols are only echoed when nothing else was offered
+func TestAcceptProtocol(t *testing.T) {
+	if got := acceptProtocol([]string{"bearer.x", "chat"}); len(got) != 1 || got[0] != "chat" {
+		t.Errorf("Expected chat, got %v", got)
+	}
+	if got := acceptProtocol([]string{"bearer.x"}); len(got) != 1 || got[0] != "bearer.x" {
+		t.Errorf("Expected the token protocol, got %v", got)
+	}
+	if got := acceptProtocol(nil); len(got) != 0 {
+		t.Errorf("Expected no protocol, got %v", got)
+	}
+	if got := acceptProtocol([]string{"bearer.x", "chat", "ichat.v9", "ichat.v1"}); len(got) != 1 || got[0] != "ichat.v1" {
+		t.Errorf("Expected ichat.v1, got %v", got)
+	}
+	if got := acceptProtocol([]string{"chat", "ichat.v9"}); len(got) != 1 || got[0] != "ichat.v9" {
+		t.Errorf("Expected the unsupported version to be accepted for closing, got %v", got)
+	}
+}
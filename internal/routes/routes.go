@@ -1,21 +1,46 @@
 package routes
 
 import (
+	"chatsrv/internal/auth"
+	"chatsrv/internal/config"
 	"chatsrv/internal/controller"
+	msgdomain "chatsrv/internal/domain/msg"
+	userdomain "chatsrv/internal/domain/user"
+	"chatsrv/internal/service"
+	"chatsrv/internal/wsutil"
+	"expvar"
 	"net/http"
 	"net/url"
+	"slices"
+	"strings"
 
 	"golang.org/x/net/websocket"
 )
 
-func InitRoutes(ctrl controller.ChatController) *http.ServeMux {
+func InitRoutes(
+	ctrl controller.ChatController,
+	authCtrl controller.AuthController,
+	webhookCtrl controller.WebhookController,
+	authSrv service.AuthService,
+	authCfg config.AuthConfig,
+	adminCfg config.AdminConfig,
+) *http.ServeMux {
 	mux := http.NewServeMux()
 
+	withUser := func(next http.HandlerFunc) http.HandlerFunc {
+		return authenticate(authSrv, authCfg, next)
+	}
+
 	wsServer := &websocket.Server{
 		Handler: func(ws *websocket.Conn) {
 			ctrl.HandleWebSocket(ws)
 		},
 		Handshake: func(config *websocket.Config, r *http.Request) error {
+			if auth.FromContext(r.Context()) == nil {
+				return userdomain.ErrUnauthenticated
+			}
+			config.Protocol = acceptProtocol(config.Protocol)
+
 			originStr := r.Header.Get("Origin")
 			if originStr != "" {
 				if origin, err := url.Parse(originStr); err == nil {
@@ -26,15 +51,364 @@ func InitRoutes(ctrl controller.ChatController) *http.ServeMux {
 		},
 	}
 
-	mux.Handle("/ws", wsServer)
-	mux.HandleFunc("/chats", func(w http.ResponseWriter, r *http.Request) {
+	mux.Handle("/ws", requireUser(authSrv, wsutil.TrackActivity(wsServer)))
+	mux.HandleFunc("/auth/register", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "POST":
+			authCtrl.Register(w, r)
+		default:
+			methodNotAllowed(w, "POST")
+		}
+	})
+	mux.HandleFunc("/auth/login", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "POST":
+			authCtrl.Login(w, r)
+		default:
+			methodNotAllowed(w, "POST")
+		}
+	})
+	mux.HandleFunc("/auth/refresh", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "POST":
+			authCtrl.Refresh(w, r)
+		default:
+			methodNotAllowed(w, "POST")
+		}
+	})
+	mux.HandleFunc("/auth/oidc/login", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			authCtrl.OIDCLogin(w, r)
+		default:
+			methodNotAllowed(w, "GET")
+		}
+	})
+	mux.HandleFunc("/auth/oidc/callback", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			authCtrl.OIDCCallback(w, r)
+		default:
+			methodNotAllowed(w, "GET")
+		}
+	})
+	mux.HandleFunc("/auth/logout", withUser(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "POST":
+			authCtrl.Logout(w, r)
+		default:
+			methodNotAllowed(w, "POST")
+		}
+	}))
+	mux.HandleFunc("/me", withUser(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			authCtrl.Me(w, r)
+		default:
+			methodNotAllowed(w, "GET")
+		}
+	}))
+	mux.HandleFunc("/me/sessions", withUser(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			authCtrl.GetSessions(w, r)
+		default:
+			methodNotAllowed(w, "GET")
+		}
+	}))
+	mux.HandleFunc("/me/sessions/{id}", withUser(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "DELETE":
+			authCtrl.RevokeSession(w, r)
+		default:
+			methodNotAllowed(w, "DELETE")
+		}
+	}))
+
+	mux.HandleFunc("/chats", withUser(func(w http.ResponseWriter, r *http.Request) {
 		switch r.Method {
 		case "GET":
 			ctrl.GetChats(w, r)
 		case "POST":
 			ctrl.CreateChat(w, r)
+		default:
+			methodNotAllowed(w, "GET", "POST")
+		}
+	}))
+	mux.HandleFunc("/chats/{id}", withUser(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			ctrl.GetChat(w, r)
+		case "PATCH":
+			ctrl.UpdateChat(w, r)
+		case "DELETE":
+			ctrl.DeleteChat(w, r)
+		default:
+			methodNotAllowed(w, "GET", "PATCH", "DELETE")
+		}
+	}))
+	mux.HandleFunc("/chats/{id}/read", withUser(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "POST":
+			ctrl.MarkRead(w, r)
+		default:
+			methodNotAllowed(w, "POST")
+		}
+	}))
+	mux.HandleFunc("/chats/{id}/archive", withUser(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "POST":
+			ctrl.ArchiveChat(w, r)
+		case "DELETE":
+			ctrl.UnarchiveChat(w, r)
+		default:
+			methodNotAllowed(w, "POST", "DELETE")
+		}
+	}))
+	mux.HandleFunc("/chats/{id}/messages", withUser(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			ctrl.GetMessages(w, r)
+		case "POST":
+			ctrl.SendMessage(w, r)
+		default:
+			methodNotAllowed(w, "GET", "POST")
+		}
+	}))
+	mux.HandleFunc("/events", withUser(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			ctrl.Events(w, r)
+		default:
+			methodNotAllowed(w, "GET")
+		}
+	}))
+	mux.HandleFunc("/events/poll", withUser(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			ctrl.PollEvents(w, r)
+		default:
+			methodNotAllowed(w, "GET")
+		}
+	}))
+	mux.HandleFunc("/chats/{id}/members", withUser(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			ctrl.GetMembers(w, r)
+		case "POST":
+			ctrl.AddMember(w, r)
+		default:
+			methodNotAllowed(w, "GET", "POST")
+		}
+	}))
+	mux.HandleFunc("/chats/{id}/members/{userId}", withUser(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "PATCH":
+			ctrl.UpdateMember(w, r)
+		case "DELETE":
+			ctrl.RemoveMember(w, r)
+		default:
+			methodNotAllowed(w, "PATCH", "DELETE")
+		}
+	}))
+	mux.HandleFunc("/chats/{id}/kicks", withUser(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "POST":
+			ctrl.Kick(w, r)
+		default:
+			methodNotAllowed(w, "POST")
+		}
+	}))
+	mux.HandleFunc("/chats/{id}/bans", withUser(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "POST":
+			ctrl.Ban(w, r)
+		default:
+			methodNotAllowed(w, "POST")
+		}
+	}))
+	mux.HandleFunc("/chats/{id}/bans/{userId}", withUser(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "DELETE":
+			ctrl.Unban(w, r)
+		default:
+			methodNotAllowed(w, "DELETE")
+		}
+	}))
+	mux.HandleFunc("/chats/{id}/mutes", withUser(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "POST":
+			ctrl.Mute(w, r)
+		default:
+			methodNotAllowed(w, "POST")
+		}
+	}))
+	mux.HandleFunc("/chats/{id}/mutes/{userId}", withUser(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "DELETE":
+			ctrl.Unmute(w, r)
+		default:
+			methodNotAllowed(w, "DELETE")
+		}
+	}))
+	mux.HandleFunc("/chats/{id}/invites", withUser(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			ctrl.GetInvites(w, r)
+		case "POST":
+			ctrl.CreateInvite(w, r)
+		default:
+			methodNotAllowed(w, "GET", "POST")
+		}
+	}))
+	mux.HandleFunc("/chats/{id}/invites/{inviteId}", withUser(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "DELETE":
+			ctrl.RevokeInvite(w, r)
+		default:
+			methodNotAllowed(w, "DELETE")
+		}
+	}))
+	mux.HandleFunc("/invites/{token}/accept", withUser(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "POST":
+			ctrl.AcceptInvite(w, r)
+		default:
+			methodNotAllowed(w, "POST")
+		}
+	}))
+	mux.HandleFunc("/dms/{userId}", withUser(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "POST":
+			ctrl.CreateDirectChat(w, r)
+		default:
+			methodNotAllowed(w, "POST")
+		}
+	}))
+
+	mux.HandleFunc("/webhooks", requireAdmin(adminCfg, authSrv, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			webhookCtrl.GetWebhooks(w, r)
+		case "POST":
+			webhookCtrl.CreateWebhook(w, r)
+		default:
+			methodNotAllowed(w, "GET", "POST")
+		}
+	}))
+	mux.HandleFunc("/webhooks/{id}", requireAdmin(adminCfg, authSrv, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "DELETE":
+			webhookCtrl.DeleteWebhook(w, r)
+		default:
+			methodNotAllowed(w, "DELETE")
+		}
+	}))
+	mux.HandleFunc("/webhooks/{id}/deliveries", requireAdmin(adminCfg, authSrv, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			webhookCtrl.GetDeliveries(w, r)
+		default:
+			methodNotAllowed(w, "GET")
+		}
+	}))
+
+	mux.HandleFunc("/service-accounts", requireAdmin(adminCfg, authSrv, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "POST":
+			authCtrl.CreateServiceAccount(w, r)
+		default:
+			methodNotAllowed(w, "POST")
+		}
+	}))
+	mux.HandleFunc("/service-accounts/{id}/api-keys", requireAdmin(adminCfg, authSrv, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			authCtrl.GetAPIKeys(w, r)
+		case "POST":
+			authCtrl.CreateAPIKey(w, r)
+		default:
+			methodNotAllowed(w, "GET", "POST")
+		}
+	}))
+	mux.HandleFunc("/api-keys/{id}", requireAdmin(adminCfg, authSrv, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "DELETE":
+			authCtrl.RevokeAPIKey(w, r)
+		default:
+			methodNotAllowed(w, "DELETE")
+		}
+	}))
+
+	mux.HandleFunc("/chats/{id}/incoming-webhooks", requireAdmin(adminCfg, authSrv, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			webhookCtrl.GetIncomingWebhooks(w, r)
+		case "POST":
+			webhookCtrl.CreateIncomingWebhook(w, r)
+		default:
+			methodNotAllowed(w, "GET", "POST")
+		}
+	}))
+	mux.HandleFunc("/incoming-webhooks/{id}", requireAdmin(adminCfg, authSrv, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "DELETE":
+			webhookCtrl.DeleteIncomingWebhook(w, r)
+		default:
+			methodNotAllowed(w, "DELETE")
+		}
+	}))
+	mux.HandleFunc("/hooks/{token}", func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "POST":
+			webhookCtrl.PostIncoming(w, r)
+		default:
+			methodNotAllowed(w, "POST")
 		}
 	})
+	// Counters such as the WebSocket rate limit rejections.
+	mux.HandleFunc("/debug/vars", requireAdmin(adminCfg, authSrv, func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case "GET":
+			expvar.Handler().ServeHTTP(w, r)
+		default:
+			methodNotAllowed(w, "GET")
+		}
+	}))
 
 	return mux
 }
+
+// acceptProtocol picks the subprotocol to answer the upgrade with. A
+// version of the chat protocol is preferred, the newest supported one if
+// any; an unsupported version is still accepted so that the connection can
+// be closed with a reason the client can read. Token subprotocols are only
+// echoed when the client offered nothing else, since browsers fail the
+// upgrade unless one of their offers is selected.
+func acceptProtocol(offered []string) []string {
+	for _, supported := range msgdomain.SupportedProtocols {
+		if slices.Contains(offered, supported) {
+			return []string{supported}
+		}
+	}
+
+	var token, other []string
+	for _, protocol := range offered {
+		switch {
+		case msgdomain.IsProtocol(protocol):
+			return []string{protocol}
+		case strings.HasPrefix(protocol, auth.TokenProtocolPrefix):
+			if token == nil {
+				token = []string{protocol}
+			}
+		default:
+			if other == nil {
+				other = []string{protocol}
+			}
+		}
+	}
+	if other != nil {
+		return other
+	}
+	return token
+}
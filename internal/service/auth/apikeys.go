@@ -0,0 +1,140 @@
+package authsrv
+
+import (
+	userdomain "chatsrv/internal/domain/user"
+	"chatsrv/internal/token"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+const maxAPIKeyNameLength = 64
+
+// CreateServiceAccount implements service.AuthService.
+func (s *authService) CreateServiceAccount(ctx context.Context, req userdomain.CreateServiceAccountRequest) (*userdomain.User, error) {
+	username, err := userdomain.ValidateUsername(req.Username)
+	if err != nil {
+		return nil, err
+	}
+	displayName, err := userdomain.ValidateDisplayName(req.DisplayName, username)
+	if err != nil {
+		return nil, err
+	}
+
+	// Service accounts have no password hash, so password logins always
+	// fail for them.
+	user := &userdomain.User{
+		ID:          uuid.New().String(),
+		Username:    username,
+		DisplayName: displayName,
+		Bot:         true,
+		Role:        userdomain.RoleUser,
+	}
+	err = s.userRepo.CreateUser(ctx, user)
+	if errors.Is(err, userdomain.ErrUsernameTaken) {
+		return nil, err
+	}
+	if err != nil {
+		s.log.Error("CreateServiceAccount",
+			zap.Any("username", username),
+			zap.Error(err))
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// CreateAPIKey implements service.AuthService.
+func (s *authService) CreateAPIKey(ctx context.Context, userID string, req userdomain.CreateAPIKeyRequest) (*userdomain.APIKey, error) {
+	name := strings.TrimSpace(req.Name)
+	if name == "" || utf8.RuneCountInString(name) > maxAPIKeyNameLength {
+		return nil, fmt.Errorf("%w: name must be 1-%d characters", userdomain.ErrInvalidAPIKey, maxAPIKeyNameLength)
+	}
+	if len(req.Permissions) == 0 {
+		return nil, fmt.Errorf("%w: at least one permission is required", userdomain.ErrInvalidAPIKey)
+	}
+	var perms []userdomain.Permission
+	for _, p := range req.Permissions {
+		if !p.Valid() {
+			return nil, fmt.Errorf("%w: unknown permission %q", userdomain.ErrInvalidAPIKey, p)
+		}
+		if !slices.Contains(perms, p) {
+			perms = append(perms, p)
+		}
+	}
+
+	user, err := s.userRepo.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !user.Bot {
+		return nil, userdomain.ErrNotServiceAccount
+	}
+
+	tok, err := token.New()
+	if err != nil {
+		return nil, err
+	}
+	tok = userdomain.APIKeyPrefix + tok
+
+	key := &userdomain.APIKey{
+		ID:          uuid.New().String(),
+		UserID:      userID,
+		Name:        name,
+		ChatID:      strings.TrimSpace(req.ChatID),
+		Permissions: perms,
+		KeyHash:     token.Hash(tok),
+	}
+	if err := s.apiKeyRepo.CreateAPIKey(ctx, key); err != nil {
+		return nil, err
+	}
+
+	key.Key = tok
+	return key, nil
+}
+
+// GetAPIKeys implements service.AuthService.
+func (s *authService) GetAPIKeys(ctx context.Context, userID string) ([]*userdomain.APIKey, error) {
+	if _, err := s.userRepo.GetUser(ctx, userID); err != nil {
+		return nil, err
+	}
+	return s.apiKeyRepo.GetAPIKeys(ctx, userID)
+}
+
+// RevokeAPIKey implements service.AuthService.
+func (s *authService) RevokeAPIKey(ctx context.Context, keyID string) error {
+	return s.apiKeyRepo.RevokeAPIKey(ctx, keyID)
+}
+
+func (s *authService) authenticateAPIKey(ctx context.Context, tok string) (*userdomain.Principal, error) {
+	key, err := s.apiKeyRepo.GetAPIKeyByHash(ctx, token.Hash(tok))
+	if errors.Is(err, userdomain.ErrAPIKeyNotFound) {
+		return nil, userdomain.ErrUnauthenticated
+	}
+	if err != nil {
+		return nil, err
+	}
+	if key.RevokedAt != nil {
+		return nil, userdomain.ErrUnauthenticated
+	}
+
+	if err := s.apiKeyRepo.TouchAPIKey(ctx, key.ID); err != nil {
+		s.log.Error("TouchAPIKey",
+			zap.Any("key", key.ID),
+			zap.Error(err))
+	}
+
+	return &userdomain.Principal{
+		UserID:      key.UserID,
+		APIKeyID:    key.ID,
+		Bot:         true,
+		ChatID:      key.ChatID,
+		Permissions: key.Permissions,
+	}, nil
+}
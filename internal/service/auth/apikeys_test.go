@@ -0,0 +1,81 @@
+package authsrv
+
+import (
+	userdomain "chatsrv/internal/domain/user"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// TestAPIKeyLifecycle verifies keys of service accounts authenticate with
+// their scope until revoked
+func TestAPIKeyLifecycle(t *testing.T) {
+	store := newMemoryStore()
+	srv := newTestService(store, testAuthConfig{})
+	ctx := context.Background()
+
+	bot, err := srv.CreateServiceAccount(ctx, userdomain.CreateServiceAccountRequest{Username: "ci-bot"})
+	if err != nil || !bot.Bot {
+		t.Fatalf("CreateServiceAccount failed: %+v, %v", bot, err)
+	}
+	if _, err := srv.Login(ctx, userdomain.LoginRequest{Username: "ci-bot", Password: ""}, userdomain.ClientMeta{}); !errors.Is(err, userdomain.ErrInvalidCredentials) {
+		t.Errorf("Expected service accounts to have no password login, got %v", err)
+	}
+
+	if _, err := srv.CreateAPIKey(ctx, bot.ID, userdomain.CreateAPIKeyRequest{Name: "ci", Permissions: []userdomain.Permission{"delete"}}); !errors.Is(err, userdomain.ErrInvalidAPIKey) {
+		t.Errorf("Expected ErrInvalidAPIKey for unknown permission, got %v", err)
+	}
+
+	key, err := srv.CreateAPIKey(ctx, bot.ID, userdomain.CreateAPIKeyRequest{
+		Name:        "ci",
+		ChatID:      "c1",
+		Permissions: []userdomain.Permission{userdomain.PermissionPost, userdomain.PermissionPost},
+	})
+	if err != nil {
+		t.Fatalf("CreateAPIKey failed: %v", err)
+	}
+	if !strings.HasPrefix(key.Key, userdomain.APIKeyPrefix) || len(key.Permissions) != 1 {
+		t.Errorf("Expected prefixed key with deduplicated permissions, got %+v", key)
+	}
+
+	principal, err := srv.Authenticate(ctx, key.Key)
+	if err != nil {
+		t.Fatalf("Authenticate failed: %v", err)
+	}
+	if principal.UserID != bot.ID || !principal.Bot || principal.APIKeyID != key.ID {
+		t.Errorf("Expected bot principal for the key, got %+v", principal)
+	}
+	if !principal.Allows("c1", userdomain.PermissionPost) || principal.Allows("c2", userdomain.PermissionPost) ||
+		principal.Allows("c1", userdomain.PermissionManage) {
+		t.Errorf("Expected key limited to posting in c1, got %+v", principal)
+	}
+
+	keys, _ := srv.GetAPIKeys(ctx, bot.ID)
+	if len(keys) != 1 || keys[0].LastUsedAt == nil || keys[0].Key != "" {
+		t.Errorf("Expected one used key without its secret, got %+v", keys)
+	}
+
+	if err := srv.RevokeAPIKey(ctx, key.ID); err != nil {
+		t.Fatalf("RevokeAPIKey failed: %v", err)
+	}
+	if _, err := srv.Authenticate(ctx, key.Key); !errors.Is(err, userdomain.ErrUnauthenticated) {
+		t.Errorf("Expected ErrUnauthenticated after revocation, got %v", err)
+	}
+}
+
+// TestAPIKeysOnlyForServiceAccounts verifies humans cannot get API keys
+func TestAPIKeysOnlyForServiceAccounts(t *testing.T) {
+	store := newMemoryStore()
+	srv := newTestService(store, testAuthConfig{})
+	ctx := context.Background()
+
+	human, err := srv.Register(ctx, userdomain.RegisterRequest{Username: "alice", Password: "correct horse"}, userdomain.ClientMeta{})
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	_, err = srv.CreateAPIKey(ctx, human.User.ID, userdomain.CreateAPIKeyRequest{Name: "mine", Permissions: []userdomain.Permission{userdomain.PermissionRead}})
+	if !errors.Is(err, userdomain.ErrNotServiceAccount) {
+		t.Errorf("Expected ErrNotServiceAccount, got %v", err)
+	}
+}
@@ -0,0 +1,362 @@
+package authsrv
+
+import (
+	userdomain "chatsrv/internal/domain/user"
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// memoryStore is an in-memory implementation of the user, session and API
+// key repositories. It also stands in for the connection registry and
+// records the sessions it was asked to close.
+type memoryStore struct {
+	mu            sync.Mutex
+	users         map[string]*userdomain.User
+	sessions      map[string]*userdomain.Session
+	refreshTokens map[string]*userdomain.RefreshToken
+	apiKeys       map[string]*userdomain.APIKey
+	loginStates   map[string]*userdomain.LoginState
+	identities    map[string]*userdomain.Identity
+	closed        []string
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		users:         make(map[string]*userdomain.User),
+		sessions:      make(map[string]*userdomain.Session),
+		refreshTokens: make(map[string]*userdomain.RefreshToken),
+		apiKeys:       make(map[string]*userdomain.APIKey),
+		loginStates:   make(map[string]*userdomain.LoginState),
+		identities:    make(map[string]*userdomain.Identity),
+	}
+}
+
+func (s *memoryStore) CreateUser(_ context.Context, user *userdomain.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, u := range s.users {
+		if strings.EqualFold(u.Username, user.Username) {
+			return userdomain.ErrUsernameTaken
+		}
+	}
+	user.CreatedAt = time.Now()
+	cp := *user
+	s.users[user.ID] = &cp
+	return nil
+}
+
+func (s *memoryStore) GetUser(_ context.Context, userID string) (*userdomain.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[userID]
+	if !ok {
+		return nil, userdomain.ErrUserNotFound
+	}
+	cp := *u
+	return &cp, nil
+}
+
+func (s *memoryStore) GetUserByUsername(_ context.Context, username string) (*userdomain.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, u := range s.users {
+		if strings.EqualFold(u.Username, username) {
+			cp := *u
+			return &cp, nil
+		}
+	}
+	return nil, userdomain.ErrUserNotFound
+}
+
+func (s *memoryStore) GetUsers(_ context.Context, userIDs []string) ([]*userdomain.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []*userdomain.User
+	for _, id := range userIDs {
+		if u, ok := s.users[id]; ok {
+			cp := *u
+			out = append(out, &cp)
+		}
+	}
+	return out, nil
+}
+
+func (s *memoryStore) UpdateUserRole(_ context.Context, userID string, role userdomain.Role) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[userID]
+	if !ok {
+		return userdomain.ErrUserNotFound
+	}
+	u.Role = role
+	return nil
+}
+
+func (s *memoryStore) CreateLoginState(_ context.Context, state *userdomain.LoginState) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cp := *state
+	s.loginStates[state.State] = &cp
+	return nil
+}
+
+func (s *memoryStore) ConsumeLoginState(_ context.Context, state string) (*userdomain.LoginState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	login, ok := s.loginStates[state]
+	if !ok {
+		return nil, userdomain.ErrInvalidLoginState
+	}
+	delete(s.loginStates, state)
+	return login, nil
+}
+
+func (s *memoryStore) GetIdentity(_ context.Context, issuer string, subject string) (*userdomain.Identity, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	identity, ok := s.identities[issuer+"\x00"+subject]
+	if !ok {
+		return nil, userdomain.ErrUserNotFound
+	}
+	cp := *identity
+	return &cp, nil
+}
+
+func (s *memoryStore) CreateUserWithIdentity(ctx context.Context, user *userdomain.User, identity *userdomain.Identity) error {
+	s.mu.Lock()
+	if _, ok := s.identities[identity.Issuer+"\x00"+identity.Subject]; ok {
+		s.mu.Unlock()
+		return userdomain.ErrIdentityTaken
+	}
+	s.mu.Unlock()
+
+	if err := s.CreateUser(ctx, user); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	identity.UserID = user.ID
+	identity.CreatedAt = user.CreatedAt
+	cp := *identity
+	s.identities[identity.Issuer+"\x00"+identity.Subject] = &cp
+	return nil
+}
+
+func (s *memoryStore) CreateSession(_ context.Context, session *userdomain.Session, refresh *userdomain.RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session.CreatedAt = time.Now()
+	session.LastSeenAt = session.CreatedAt
+	cp := *session
+	s.sessions[session.ID] = &cp
+	refresh.CreatedAt = session.CreatedAt
+	rcp := *refresh
+	s.refreshTokens[refresh.ID] = &rcp
+	return nil
+}
+
+func (s *memoryStore) GetSession(_ context.Context, sessionID string) (*userdomain.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	session, ok := s.sessions[sessionID]
+	if !ok {
+		return nil, userdomain.ErrSessionNotFound
+	}
+	cp := *session
+	return &cp, nil
+}
+
+func (s *memoryStore) GetSessionByTokenHash(_ context.Context, tokenHash string) (*userdomain.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, session := range s.sessions {
+		if session.TokenHash == tokenHash {
+			cp := *session
+			return &cp, nil
+		}
+	}
+	return nil, userdomain.ErrUnauthenticated
+}
+
+func (s *memoryStore) GetSessions(_ context.Context, userID string) ([]*userdomain.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []*userdomain.Session
+	for _, session := range s.sessions {
+		if session.UserID == userID && session.Active(time.Now()) {
+			cp := *session
+			out = append(out, &cp)
+		}
+	}
+	return out, nil
+}
+
+func (s *memoryStore) RevokeSession(_ context.Context, sessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if session, ok := s.sessions[sessionID]; ok && session.RevokedAt == nil {
+		now := time.Now()
+		session.RevokedAt = &now
+	}
+	return nil
+}
+
+func (s *memoryStore) TouchSession(_ context.Context, sessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if session, ok := s.sessions[sessionID]; ok {
+		session.LastSeenAt = time.Now()
+	}
+	return nil
+}
+
+func (s *memoryStore) RotateSession(_ context.Context, session *userdomain.Session, refresh *userdomain.RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, ok := s.sessions[session.ID]
+	if !ok || stored.RevokedAt != nil {
+		return userdomain.ErrUnauthenticated
+	}
+	session.LastSeenAt = time.Now()
+	cp := *session
+	s.sessions[session.ID] = &cp
+	refresh.CreatedAt = session.LastSeenAt
+	rcp := *refresh
+	s.refreshTokens[refresh.ID] = &rcp
+	return nil
+}
+
+func (s *memoryStore) GetRefreshToken(_ context.Context, tokenHash string) (*userdomain.RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, refresh := range s.refreshTokens {
+		if refresh.TokenHash == tokenHash {
+			cp := *refresh
+			return &cp, nil
+		}
+	}
+	return nil, userdomain.ErrUnauthenticated
+}
+
+func (s *memoryStore) UseRefreshToken(_ context.Context, tokenID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	refresh, ok := s.refreshTokens[tokenID]
+	if !ok || refresh.UsedAt != nil {
+		return false, nil
+	}
+	now := time.Now()
+	refresh.UsedAt = &now
+	return true, nil
+}
+
+func (s *memoryStore) CloseSession(sessionID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.closed = append(s.closed, sessionID)
+}
+
+func (s *memoryStore) CreateAPIKey(_ context.Context, key *userdomain.APIKey) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key.CreatedAt = time.Now()
+	cp := *key
+	s.apiKeys[key.ID] = &cp
+	return nil
+}
+
+func (s *memoryStore) GetAPIKeys(_ context.Context, userID string) ([]*userdomain.APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []*userdomain.APIKey
+	for _, key := range s.apiKeys {
+		if key.UserID == userID {
+			cp := *key
+			out = append(out, &cp)
+		}
+	}
+	return out, nil
+}
+
+func (s *memoryStore) GetAPIKeyByHash(_ context.Context, keyHash string) (*userdomain.APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, key := range s.apiKeys {
+		if key.KeyHash == keyHash {
+			cp := *key
+			return &cp, nil
+		}
+	}
+	return nil, userdomain.ErrAPIKeyNotFound
+}
+
+func (s *memoryStore) RevokeAPIKey(_ context.Context, keyID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key, ok := s.apiKeys[keyID]
+	if !ok {
+		return userdomain.ErrAPIKeyNotFound
+	}
+	if key.RevokedAt == nil {
+		now := time.Now()
+		key.RevokedAt = &now
+	}
+	return nil
+}
+
+func (s *memoryStore) TouchAPIKey(_ context.Context, keyID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if key, ok := s.apiKeys[keyID]; ok {
+		now := time.Now()
+		key.LastUsedAt = &now
+	}
+	return nil
+}
+
+// testAuthConfig is a config.AuthConfig with fixed values
+type testAuthConfig struct {
+	accessTokenTTL time.Duration
+	sessionTTL     time.Duration
+}
+
+func (c testAuthConfig) AccessTokenTTL() time.Duration {
+	if c.accessTokenTTL == 0 {
+		return time.Minute
+	}
+	return c.accessTokenTTL
+}
+
+func (c testAuthConfig) SessionTTL() time.Duration {
+	if c.sessionTTL == 0 {
+		return time.Hour
+	}
+	return c.sessionTTL
+}
+func (testAuthConfig) BcryptCost() int       { return bcrypt.MinCost }
+func (testAuthConfig) AllowUserHeader() bool { return false }
+
+// testOIDCConfig is a config.OIDCConfig for a client of issuer. The zero
+// value disables OIDC.
+type testOIDCConfig struct {
+	issuer      string
+	roleMapping map[string]string
+	defaultRole string
+}
+
+func (c testOIDCConfig) Enabled() bool                  { return c.issuer != "" }
+func (c testOIDCConfig) IssuerURL() string              { return c.issuer }
+func (testOIDCConfig) ClientID() string                 { return "chat" }
+func (testOIDCConfig) ClientSecret() string             { return "chat-secret" }
+func (testOIDCConfig) RedirectURL() string              { return "http://chat.test/auth/oidc/callback" }
+func (testOIDCConfig) Scopes() []string                 { return []string{"openid", "profile"} }
+func (testOIDCConfig) UsernameClaim() string            { return "preferred_username" }
+func (testOIDCConfig) RoleClaim() string                { return "groups" }
+func (c testOIDCConfig) RoleMapping() map[string]string { return c.roleMapping }
+func (c testOIDCConfig) DefaultRole() string            { return c.defaultRole }
+func (testOIDCConfig) LoginTTL() time.Duration          { return time.Minute }
@@ -0,0 +1,313 @@
+package authsrv
+
+import (
+	userdomain "chatsrv/internal/domain/user"
+	"chatsrv/internal/token"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"golang.org/x/oauth2"
+)
+
+const (
+	// oidcTimeout bounds every request to the provider.
+	oidcTimeout = 10 * time.Second
+	// provisionAttempts is how many usernames are tried for a new user
+	// before giving up.
+	provisionAttempts = 5
+)
+
+// oidcProvider is the discovered provider. The verifier caches the
+// provider's signing keys and refetches them when a token is signed with an
+// unknown one.
+type oidcProvider struct {
+	oauth    *oauth2.Config
+	verifier *oidc.IDTokenVerifier
+}
+
+// OIDCLoginURL implements service.AuthService.
+func (s *authService) OIDCLoginURL(ctx context.Context) (string, error) {
+	provider, err := s.oidcProvider()
+	if err != nil {
+		return "", err
+	}
+
+	state, err := token.New()
+	if err != nil {
+		return "", err
+	}
+	nonce, err := token.New()
+	if err != nil {
+		return "", err
+	}
+	login := &userdomain.LoginState{
+		State:     state,
+		Nonce:     nonce,
+		Verifier:  oauth2.GenerateVerifier(),
+		ExpiresAt: time.Now().Add(s.oidcCfg.LoginTTL()),
+	}
+	if err := s.oidcRepo.CreateLoginState(ctx, login); err != nil {
+		s.log.Error("CreateLoginState", zap.Error(err))
+		return "", err
+	}
+
+	return provider.oauth.AuthCodeURL(login.State,
+		oidc.Nonce(login.Nonce),
+		oauth2.S256ChallengeOption(login.Verifier),
+	), nil
+}
+
+// OIDCCallback implements service.AuthService.
+func (s *authService) OIDCCallback(ctx context.Context, code string, state string, meta userdomain.ClientMeta) (*userdomain.AuthToken, error) {
+	provider, err := s.oidcProvider()
+	if err != nil {
+		return nil, err
+	}
+
+	login, err := s.oidcRepo.ConsumeLoginState(ctx, state)
+	if err != nil {
+		return nil, err
+	}
+	if !login.ExpiresAt.After(time.Now()) {
+		return nil, userdomain.ErrInvalidLoginState
+	}
+
+	tok, err := provider.oauth.Exchange(oidc.ClientContext(ctx, s.httpClient), code, oauth2.VerifierOption(login.Verifier))
+	if err != nil {
+		return nil, fmt.Errorf("%w: exchange code: %v", userdomain.ErrOIDCLoginFailed, err)
+	}
+	rawIDToken, ok := tok.Extra("id_token").(string)
+	if !ok {
+		return nil, fmt.Errorf("%w: no id_token in token response", userdomain.ErrOIDCLoginFailed)
+	}
+	// Verify checks the signature, issuer, audience and expiry.
+	idToken, err := provider.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", userdomain.ErrOIDCLoginFailed, err)
+	}
+	if idToken.Nonce != login.Nonce {
+		return nil, fmt.Errorf("%w: nonce mismatch", userdomain.ErrOIDCLoginFailed)
+	}
+
+	var claims map[string]any
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, fmt.Errorf("%w: %v", userdomain.ErrOIDCLoginFailed, err)
+	}
+	role, err := s.mapRole(claims)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.oidcUser(ctx, idToken.Issuer, idToken.Subject, claims, role)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.startSession(ctx, user, "", meta)
+}
+
+// oidcProvider returns the provider, discovering it on first use. Failed
+// discoveries are retried on the next login.
+func (s *authService) oidcProvider() (*oidcProvider, error) {
+	if !s.oidcCfg.Enabled() {
+		return nil, userdomain.ErrOIDCDisabled
+	}
+
+	s.oidcMu.Lock()
+	defer s.oidcMu.Unlock()
+	if s.provider != nil {
+		return s.provider, nil
+	}
+
+	// The context outlives this call: the key set fetches keys with it.
+	ctx := oidc.ClientContext(context.Background(), s.httpClient)
+	provider, err := oidc.NewProvider(ctx, s.oidcCfg.IssuerURL())
+	if err != nil {
+		s.log.Error("oidc discovery",
+			zap.Any("issuer", s.oidcCfg.IssuerURL()),
+			zap.Error(err))
+		return nil, fmt.Errorf("%w: discovery: %v", userdomain.ErrOIDCLoginFailed, err)
+	}
+
+	s.provider = &oidcProvider{
+		oauth: &oauth2.Config{
+			ClientID:     s.oidcCfg.ClientID(),
+			ClientSecret: s.oidcCfg.ClientSecret(),
+			RedirectURL:  s.oidcCfg.RedirectURL(),
+			Endpoint:     provider.Endpoint(),
+			Scopes:       s.oidcCfg.Scopes(),
+		},
+		verifier: provider.Verifier(&oidc.Config{ClientID: s.oidcCfg.ClientID()}),
+	}
+	return s.provider, nil
+}
+
+// mapRole picks the highest role the values of the role claim map to,
+// falling back to the default role.
+func (s *authService) mapRole(claims map[string]any) (userdomain.Role, error) {
+	var role userdomain.Role
+	for _, value := range claimStrings(claims[s.oidcCfg.RoleClaim()]) {
+		mapped, ok := s.oidcCfg.RoleMapping()[value]
+		if ok && (role == "" || userdomain.Role(mapped).Outranks(role)) {
+			role = userdomain.Role(mapped)
+		}
+	}
+
+	if role == "" {
+		role = userdomain.Role(s.oidcCfg.DefaultRole())
+	}
+	if role == "" {
+		return "", userdomain.ErrRoleNotGranted
+	}
+	return role, nil
+}
+
+// oidcUser returns the user linked to the identity, provisioning it on its
+// first login. The role of the user follows the provider on every login.
+func (s *authService) oidcUser(ctx context.Context, issuer string, subject string, claims map[string]any, role userdomain.Role) (*userdomain.User, error) {
+	identity, err := s.oidcRepo.GetIdentity(ctx, issuer, subject)
+	if errors.Is(err, userdomain.ErrUserNotFound) {
+		user, err := s.provisionUser(ctx, issuer, subject, claims, role)
+		if !errors.Is(err, userdomain.ErrIdentityTaken) {
+			return user, err
+		}
+		// A concurrent first login won the race; use its user.
+		identity, err = s.oidcRepo.GetIdentity(ctx, issuer, subject)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := s.userRepo.GetUser(ctx, identity.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if user.Role != role {
+		if err := s.userRepo.UpdateUserRole(ctx, user.ID, role); err != nil {
+			s.log.Error("UpdateUserRole",
+				zap.Any("user", user.ID),
+				zap.Any("role", role),
+				zap.Error(err))
+			return nil, err
+		}
+		user.Role = role
+	}
+
+	return user, nil
+}
+
+// provisionUser creates the user of a first OIDC login. Its username comes
+// from the username claim, with a random suffix when it is taken.
+func (s *authService) provisionUser(ctx context.Context, issuer string, subject string, claims map[string]any, role userdomain.Role) (*userdomain.User, error) {
+	base := usernameFromClaim(claimString(claims, s.oidcCfg.UsernameClaim()))
+
+	for attempt := 0; attempt < provisionAttempts; attempt++ {
+		username := base
+		if attempt > 0 {
+			suffix, err := randomSuffix()
+			if err != nil {
+				return nil, err
+			}
+			username = truncateRunes(base, userdomain.MaxUsernameLength-len(suffix)-1) + "-" + suffix
+		}
+
+		displayName := truncateRunes(strings.TrimSpace(claimString(claims, "name")), userdomain.MaxDisplayNameLength)
+		if displayName == "" {
+			displayName = username
+		}
+
+		user := &userdomain.User{
+			ID:          uuid.New().String(),
+			Username:    username,
+			DisplayName: displayName,
+			Role:        role,
+		}
+		err := s.oidcRepo.CreateUserWithIdentity(ctx, user, &userdomain.Identity{Issuer: issuer, Subject: subject})
+		if errors.Is(err, userdomain.ErrUsernameTaken) {
+			continue
+		}
+		if errors.Is(err, userdomain.ErrIdentityTaken) {
+			return nil, err
+		}
+		if err != nil {
+			s.log.Error("CreateUserWithIdentity",
+				zap.Any("issuer", issuer),
+				zap.Any("subject", subject),
+				zap.Error(err))
+			return nil, err
+		}
+
+		return user, nil
+	}
+
+	return nil, fmt.Errorf("%w: no free username for %q", userdomain.ErrUsernameTaken, base)
+}
+
+// usernameFromClaim turns a claim such as a preferred username or an email
+// address into a valid username.
+func usernameFromClaim(value string) string {
+	value, _, _ = strings.Cut(value, "@")
+
+	var b strings.Builder
+	for _, r := range value {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case (r == '.' || r == '_' || r == '-') && b.Len() > 0:
+			b.WriteRune(r)
+		}
+	}
+
+	username := truncateRunes(b.String(), userdomain.MaxUsernameLength)
+	if _, err := userdomain.ValidateUsername(username); err != nil {
+		return "user"
+	}
+	return username
+}
+
+func randomSuffix() (string, error) {
+	buf := make([]byte, 2)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
+
+func truncateRunes(s string, n int) string {
+	if utf8.RuneCountInString(s) <= n {
+		return s
+	}
+	return string([]rune(s)[:n])
+}
+
+func claimString(claims map[string]any, name string) string {
+	s, _ := claims[name].(string)
+	return s
+}
+
+// claimStrings returns the values of a claim that is either a string or a
+// list of strings.
+func claimStrings(claim any) []string {
+	switch v := claim.(type) {
+	case string:
+		return []string{v}
+	case []any:
+		values := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
@@ -0,0 +1,283 @@
+package authsrv
+
+import (
+	userdomain "chatsrv/internal/domain/user"
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeIssuer is a stand-in OpenID Connect provider. It serves discovery,
+// its keys and a token endpoint that checks PKCE, and issues ID tokens for
+// the codes handed out by authorize.
+type fakeIssuer struct {
+	t   *testing.T
+	srv *httptest.Server
+	key *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]fakeGrant
+	// audience overrides the aud claim of the ID tokens when set.
+	audience string
+	// nonce overrides the nonce claim of the ID tokens when set.
+	nonce string
+}
+
+type fakeGrant struct {
+	challenge string
+	nonce     string
+	claims    map[string]any
+}
+
+func newFakeIssuer(t *testing.T) *fakeIssuer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("GenerateKey failed: %v", err)
+	}
+
+	iss := &fakeIssuer{t: t, key: key, codes: make(map[string]fakeGrant)}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", iss.discovery)
+	mux.HandleFunc("/jwks", iss.jwks)
+	mux.HandleFunc("/token", iss.token)
+	iss.srv = httptest.NewServer(mux)
+	t.Cleanup(iss.srv.Close)
+
+	return iss
+}
+
+func (iss *fakeIssuer) discovery(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]any{
+		"issuer":                                iss.srv.URL,
+		"authorization_endpoint":                iss.srv.URL + "/authorize",
+		"token_endpoint":                        iss.srv.URL + "/token",
+		"jwks_uri":                              iss.srv.URL + "/jwks",
+		"id_token_signing_alg_values_supported": []string{"RS256"},
+	})
+}
+
+func (iss *fakeIssuer) jwks(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(map[string]any{
+		"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "k1",
+			"alg": "RS256",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(iss.key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(iss.key.E)).Bytes()),
+		}},
+	})
+}
+
+func (iss *fakeIssuer) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if id, secret, _ := r.BasicAuth(); id != "chat" || secret != "chat-secret" {
+		http.Error(w, `{"error":"invalid_client"}`, http.StatusUnauthorized)
+		return
+	}
+
+	iss.mu.Lock()
+	grant, ok := iss.codes[r.PostForm.Get("code")]
+	delete(iss.codes, r.PostForm.Get("code"))
+	iss.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != grant.challenge {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"error":"invalid_grant"}`))
+		return
+	}
+
+	claims := map[string]any{
+		"iss":   iss.srv.URL,
+		"aud":   "chat",
+		"iat":   time.Now().Unix(),
+		"exp":   time.Now().Add(time.Minute).Unix(),
+		"nonce": grant.nonce,
+	}
+	if iss.audience != "" {
+		claims["aud"] = iss.audience
+	}
+	if iss.nonce != "" {
+		claims["nonce"] = iss.nonce
+	}
+	for k, v := range grant.claims {
+		claims[k] = v
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]any{
+		"access_token": "opaque",
+		"token_type":   "Bearer",
+		"expires_in":   60,
+		"id_token":     iss.sign(claims),
+	})
+}
+
+func (iss *fakeIssuer) sign(claims map[string]any) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "k1", "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+
+	digest := sha256.Sum256([]byte(signed))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, iss.key, crypto.SHA256, digest[:])
+	if err != nil {
+		iss.t.Fatalf("SignPKCS1v15 failed: %v", err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+// authorize plays the user logging in at the provider: it checks the
+// authorization request and returns the code and state the provider would
+// redirect back with.
+func (iss *fakeIssuer) authorize(loginURL string, claims map[string]any) (string, string) {
+	u, err := url.Parse(loginURL)
+	if err != nil {
+		iss.t.Fatalf("Parse login URL failed: %v", err)
+	}
+	q := u.Query()
+	if !strings.HasPrefix(loginURL, iss.srv.URL+"/authorize?") || q.Get("response_type") != "code" ||
+		q.Get("client_id") != "chat" || q.Get("code_challenge_method") != "S256" ||
+		q.Get("code_challenge") == "" || q.Get("nonce") == "" || q.Get("state") == "" {
+		iss.t.Fatalf("Unexpected authorization request %s", loginURL)
+	}
+
+	code := "code-" + q.Get("state")
+	iss.mu.Lock()
+	iss.codes[code] = fakeGrant{challenge: q.Get("code_challenge"), nonce: q.Get("nonce"), claims: claims}
+	iss.mu.Unlock()
+
+	return code, q.Get("state")
+}
+
+// login runs a whole OIDC login for claims
+func (iss *fakeIssuer) login(srv *authService, claims map[string]any) (*userdomain.AuthToken, error) {
+	loginURL, err := srv.OIDCLoginURL(context.Background())
+	if err != nil {
+		iss.t.Fatalf("OIDCLoginURL failed: %v", err)
+	}
+	code, state := iss.authorize(loginURL, claims)
+	return srv.OIDCCallback(context.Background(), code, state, userdomain.ClientMeta{IP: "10.0.0.1"})
+}
+
+// TestOIDCLoginProvisionsUser verifies the first login creates a user from
+// the claims, later logins reuse it, and its role follows the mapping
+func TestOIDCLoginProvisionsUser(t *testing.T) {
+	iss := newFakeIssuer(t)
+	store := newMemoryStore()
+	srv := newTestOIDCService(store, testAuthConfig{}, testOIDCConfig{
+		issuer:      iss.srv.URL,
+		roleMapping: map[string]string{"chat-admins": "admin", "staff": "user"},
+	})
+	ctx := context.Background()
+
+	if _, err := srv.Register(ctx, userdomain.RegisterRequest{Username: "alice", Password: "correct horse"}, userdomain.ClientMeta{}); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	first, err := iss.login(srv, map[string]any{
+		"sub":                "0001",
+		"preferred_username": "Alice",
+		"name":               "Alice Liddell",
+		"groups":             []string{"staff", "chat-admins"},
+	})
+	if err != nil {
+		t.Fatalf("OIDCCallback failed: %v", err)
+	}
+	user := first.User
+	if !strings.HasPrefix(user.Username, "Alice-") || user.DisplayName != "Alice Liddell" || user.Role != userdomain.RoleAdmin {
+		t.Errorf("Expected a suffixed username, the name claim and the admin role, got %+v", user)
+	}
+	if principal, err := srv.Authenticate(ctx, first.Token); err != nil || principal.UserID != user.ID {
+		t.Errorf("Expected the token to authenticate %s, got %+v, %v", user.ID, principal, err)
+	}
+
+	second, err := iss.login(srv, map[string]any{"sub": "0001", "preferred_username": "renamed", "groups": "staff"})
+	if err != nil {
+		t.Fatalf("OIDCCallback failed: %v", err)
+	}
+	if second.User.ID != user.ID || second.User.Username != user.Username || second.User.Role != userdomain.RoleUser {
+		t.Errorf("Expected the same user demoted to user, got %+v", second.User)
+	}
+	if second.SessionID == first.SessionID {
+		t.Errorf("Expected every login to open its own session")
+	}
+}
+
+// TestOIDCCallbackRejects verifies logins fail on replayed state, tokens not
+// meant for the client and users without a role
+func TestOIDCCallbackRejects(t *testing.T) {
+	iss := newFakeIssuer(t)
+	store := newMemoryStore()
+	srv := newTestOIDCService(store, testAuthConfig{}, testOIDCConfig{
+		issuer:      iss.srv.URL,
+		roleMapping: map[string]string{"chat-users": "user"},
+	})
+	ctx := context.Background()
+	claims := map[string]any{"sub": "0002", "preferred_username": "bob", "groups": []string{"chat-users"}}
+
+	loginURL, err := srv.OIDCLoginURL(ctx)
+	if err != nil {
+		t.Fatalf("OIDCLoginURL failed: %v", err)
+	}
+	code, state := iss.authorize(loginURL, claims)
+	if _, err := srv.OIDCCallback(ctx, code, state, userdomain.ClientMeta{}); err != nil {
+		t.Fatalf("OIDCCallback failed: %v", err)
+	}
+	if _, err := srv.OIDCCallback(ctx, code, state, userdomain.ClientMeta{}); !errors.Is(err, userdomain.ErrInvalidLoginState) {
+		t.Errorf("Expected ErrInvalidLoginState for a replayed state, got %v", err)
+	}
+
+	iss.audience = "someone-else"
+	if _, err := iss.login(srv, claims); !errors.Is(err, userdomain.ErrOIDCLoginFailed) {
+		t.Errorf("Expected ErrOIDCLoginFailed for a foreign audience, got %v", err)
+	}
+	iss.audience = ""
+
+	iss.nonce = "stolen"
+	if _, err := iss.login(srv, claims); !errors.Is(err, userdomain.ErrOIDCLoginFailed) {
+		t.Errorf("Expected ErrOIDCLoginFailed for a nonce mismatch, got %v", err)
+	}
+	iss.nonce = ""
+
+	if _, err := iss.login(srv, map[string]any{"sub": "0003", "groups": []string{"contractors"}}); !errors.Is(err, userdomain.ErrRoleNotGranted) {
+		t.Errorf("Expected ErrRoleNotGranted without a mapped group, got %v", err)
+	}
+
+	disabled := newTestService(newMemoryStore(), testAuthConfig{})
+	if _, err := disabled.OIDCLoginURL(ctx); !errors.Is(err, userdomain.ErrOIDCDisabled) {
+		t.Errorf("Expected ErrOIDCDisabled, got %v", err)
+	}
+}
+
+// TestUsernameFromClaim verifies claims are turned into valid usernames
+func TestUsernameFromClaim(t *testing.T) {
+	for claim, want := range map[string]string{
+		"alice":             "alice",
+		"bob.smith@corp.io": "bob.smith",
+		"_évé lyn":          "vlyn",
+		"é":                 "user",
+		"":                  "user",
+	} {
+		if got := usernameFromClaim(claim); got != want {
+			t.Errorf("usernameFromClaim(%q) = %q, want %q", claim, got, want)
+		}
+	}
+}
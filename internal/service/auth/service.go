@@ -0,0 +1,232 @@
+package authsrv
+
+import (
+	"chatsrv/internal/config"
+	userdomain "chatsrv/internal/domain/user"
+	"chatsrv/internal/repository"
+	"chatsrv/internal/service"
+	"chatsrv/internal/token"
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"golang.org/x/crypto/bcrypt"
+)
+
+var _ service.AuthService = (*authService)(nil)
+
+func NewAuthService(
+	userRepo repository.UserRepository,
+	sessionRepo repository.SessionRepository,
+	apiKeyRepo repository.APIKeyRepository,
+	oidcRepo repository.OIDCRepository,
+	conns service.SessionCloser,
+	cfg config.AuthConfig,
+	oidcCfg config.OIDCConfig,
+	log *zap.Logger,
+) service.AuthService {
+	dummyHash, _ := bcrypt.GenerateFromPassword([]byte("not a password"), cfg.BcryptCost())
+	return &authService{
+		userRepo:    userRepo,
+		sessionRepo: sessionRepo,
+		apiKeyRepo:  apiKeyRepo,
+		oidcRepo:    oidcRepo,
+		conns:       conns,
+		cfg:         cfg,
+		oidcCfg:     oidcCfg,
+		httpClient:  &http.Client{Timeout: oidcTimeout},
+		log:         log,
+		dummyHash:   dummyHash,
+	}
+}
+
+type authService struct {
+	userRepo    repository.UserRepository
+	sessionRepo repository.SessionRepository
+	apiKeyRepo  repository.APIKeyRepository
+	oidcRepo    repository.OIDCRepository
+	conns       service.SessionCloser
+	cfg         config.AuthConfig
+	oidcCfg     config.OIDCConfig
+	log         *zap.Logger
+
+	// httpClient talks to the OpenID Connect provider, which is discovered
+	// on first use and kept in provider.
+	httpClient *http.Client
+	oidcMu     sync.Mutex
+	provider   *oidcProvider
+
+	// dummyHash is compared against when the username is unknown, so that
+	// logins take as long whether or not the user exists.
+	dummyHash []byte
+}
+
+// Register implements service.AuthService.
+func (s *authService) Register(ctx context.Context, req userdomain.RegisterRequest, meta userdomain.ClientMeta) (*userdomain.AuthToken, error) {
+	username, err := userdomain.ValidateUsername(req.Username)
+	if err != nil {
+		return nil, err
+	}
+	displayName, err := userdomain.ValidateDisplayName(req.DisplayName, username)
+	if err != nil {
+		return nil, err
+	}
+	if err := userdomain.ValidatePassword(req.Password); err != nil {
+		return nil, err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), s.cfg.BcryptCost())
+	if err != nil {
+		return nil, err
+	}
+
+	user := &userdomain.User{
+		ID:           uuid.New().String(),
+		Username:     username,
+		DisplayName:  displayName,
+		Role:         userdomain.RoleUser,
+		PasswordHash: string(hash),
+	}
+	err = s.userRepo.CreateUser(ctx, user)
+	if errors.Is(err, userdomain.ErrUsernameTaken) {
+		return nil, err
+	}
+	if err != nil {
+		s.log.Error("Register",
+			zap.Any("username", username),
+			zap.Error(err))
+		return nil, err
+	}
+
+	return s.startSession(ctx, user, req.DeviceName, meta)
+}
+
+// Login implements service.AuthService.
+func (s *authService) Login(ctx context.Context, req userdomain.LoginRequest, meta userdomain.ClientMeta) (*userdomain.AuthToken, error) {
+	user, err := s.userRepo.GetUserByUsername(ctx, req.Username)
+	if errors.Is(err, userdomain.ErrUserNotFound) {
+		bcrypt.CompareHashAndPassword(s.dummyHash, []byte(req.Password))
+		return nil, userdomain.ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
+		return nil, userdomain.ErrInvalidCredentials
+	}
+
+	return s.startSession(ctx, user, req.DeviceName, meta)
+}
+
+// Logout implements service.AuthService.
+func (s *authService) Logout(ctx context.Context, principal *userdomain.Principal) error {
+	if principal.SessionID == "" {
+		return nil
+	}
+	return s.revokeSession(ctx, principal.SessionID)
+}
+
+// Authenticate implements service.AuthService.
+func (s *authService) Authenticate(ctx context.Context, tok string) (*userdomain.Principal, error) {
+	if tok == "" {
+		return nil, userdomain.ErrUnauthenticated
+	}
+	if strings.HasPrefix(tok, userdomain.APIKeyPrefix) {
+		return s.authenticateAPIKey(ctx, tok)
+	}
+
+	session, err := s.sessionRepo.GetSessionByTokenHash(ctx, token.Hash(tok))
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	if !session.Active(now) || !session.AccessExpiresAt.After(now) {
+		return nil, userdomain.ErrUnauthenticated
+	}
+
+	if err := s.sessionRepo.TouchSession(ctx, session.ID); err != nil {
+		s.log.Error("TouchSession",
+			zap.Any("session", session.ID),
+			zap.Error(err))
+	}
+
+	return &userdomain.Principal{UserID: session.UserID, SessionID: session.ID}, nil
+}
+
+// GetUser implements service.AuthService.
+func (s *authService) GetUser(ctx context.Context, userID string) (*userdomain.User, error) {
+	return s.userRepo.GetUser(ctx, userID)
+}
+
+func (s *authService) startSession(ctx context.Context, user *userdomain.User, deviceName string, meta userdomain.ClientMeta) (*userdomain.AuthToken, error) {
+	session := &userdomain.Session{
+		ID:         uuid.New().String(),
+		UserID:     user.ID,
+		DeviceName: userdomain.DeviceName(deviceName, meta.UserAgent),
+		IP:         meta.IP,
+		UserAgent:  meta.UserAgent,
+	}
+	tok, err := s.issueTokens(session)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.sessionRepo.CreateSession(ctx, session, tok.refresh); err != nil {
+		s.log.Error("CreateSession",
+			zap.Any("user", user.ID),
+			zap.Error(err))
+		return nil, err
+	}
+
+	tok.User = user
+	return &tok.AuthToken, nil
+}
+
+// issuedTokens is an AuthToken along with the refresh token row to store.
+type issuedTokens struct {
+	userdomain.AuthToken
+	refresh *userdomain.RefreshToken
+}
+
+// issueTokens generates a new access and refresh token for session, and
+// sets the token hash and expiry times of session to match.
+func (s *authService) issueTokens(session *userdomain.Session) (*issuedTokens, error) {
+	access, err := token.New()
+	if err != nil {
+		return nil, err
+	}
+	refresh, err := token.New()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	session.TokenHash = token.Hash(access)
+	session.AccessExpiresAt = now.Add(s.cfg.AccessTokenTTL())
+	session.ExpiresAt = now.Add(s.cfg.SessionTTL())
+	// The access token never outlives its session.
+	if session.AccessExpiresAt.After(session.ExpiresAt) {
+		session.AccessExpiresAt = session.ExpiresAt
+	}
+
+	return &issuedTokens{
+		AuthToken: userdomain.AuthToken{
+			Token:            access,
+			ExpiresAt:        session.AccessExpiresAt,
+			RefreshToken:     refresh,
+			RefreshExpiresAt: session.ExpiresAt,
+			SessionID:        session.ID,
+		},
+		refresh: &userdomain.RefreshToken{
+			ID:        uuid.New().String(),
+			SessionID: session.ID,
+			TokenHash: token.Hash(refresh),
+		},
+	}, nil
+}
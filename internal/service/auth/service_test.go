@@ -0,0 +1,84 @@
+package authsrv
+
+import (
+	userdomain "chatsrv/internal/domain/user"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func newTestService(store *memoryStore, cfg testAuthConfig) *authService {
+	return newTestOIDCService(store, cfg, testOIDCConfig{})
+}
+
+func newTestOIDCService(store *memoryStore, cfg testAuthConfig, oidcCfg testOIDCConfig) *authService {
+	return NewAuthService(store, store, store, store, store, cfg, oidcCfg, zap.NewNop()).(*authService)
+}
+
+// TestRegisterAndLogin verifies a registered user can log in and its tokens
+// authenticate until logout
+func TestRegisterAndLogin(t *testing.T) {
+	store := newMemoryStore()
+	srv := newTestService(store, testAuthConfig{})
+	ctx := context.Background()
+
+	registered, err := srv.Register(ctx, userdomain.RegisterRequest{Username: "Alice", Password: "correct horse"}, userdomain.ClientMeta{})
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if registered.User.DisplayName != "Alice" || registered.User.PasswordHash == "correct horse" {
+		t.Errorf("Expected display name defaulted and password hashed, got %+v", registered.User)
+	}
+
+	if _, err := srv.Register(ctx, userdomain.RegisterRequest{Username: "alice", Password: "another one"}, userdomain.ClientMeta{}); !errors.Is(err, userdomain.ErrUsernameTaken) {
+		t.Errorf("Expected ErrUsernameTaken ignoring case, got %v", err)
+	}
+
+	if _, err := srv.Login(ctx, userdomain.LoginRequest{Username: "alice", Password: "wrong password"}, userdomain.ClientMeta{}); !errors.Is(err, userdomain.ErrInvalidCredentials) {
+		t.Errorf("Expected ErrInvalidCredentials for wrong password, got %v", err)
+	}
+	if _, err := srv.Login(ctx, userdomain.LoginRequest{Username: "bob", Password: "correct horse"}, userdomain.ClientMeta{}); !errors.Is(err, userdomain.ErrInvalidCredentials) {
+		t.Errorf("Expected ErrInvalidCredentials for unknown user, got %v", err)
+	}
+
+	login, err := srv.Login(ctx, userdomain.LoginRequest{Username: "ALICE", Password: "correct horse"}, userdomain.ClientMeta{})
+	if err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+	principal, err := srv.Authenticate(ctx, login.Token)
+	if err != nil || principal.UserID != registered.User.ID {
+		t.Fatalf("Expected token to authenticate %s, got %+v, %v", registered.User.ID, principal, err)
+	}
+
+	if err := srv.Logout(ctx, principal); err != nil {
+		t.Fatalf("Logout failed: %v", err)
+	}
+	if _, err := srv.Authenticate(ctx, login.Token); !errors.Is(err, userdomain.ErrUnauthenticated) {
+		t.Errorf("Expected ErrUnauthenticated after logout, got %v", err)
+	}
+	if _, err := srv.Authenticate(ctx, registered.Token); err != nil {
+		t.Errorf("Expected other sessions to survive logout, got %v", err)
+	}
+}
+
+// TestAuthenticateRejectsExpiredTokens verifies sessions stop working once
+// they expire
+func TestAuthenticateRejectsExpiredTokens(t *testing.T) {
+	store := newMemoryStore()
+	srv := newTestService(store, testAuthConfig{sessionTTL: -time.Minute})
+	ctx := context.Background()
+
+	registered, err := srv.Register(ctx, userdomain.RegisterRequest{Username: "alice", Password: "correct horse"}, userdomain.ClientMeta{})
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if _, err := srv.Authenticate(ctx, registered.Token); !errors.Is(err, userdomain.ErrUnauthenticated) {
+		t.Errorf("Expected ErrUnauthenticated for expired token, got %v", err)
+	}
+	if _, err := srv.Authenticate(ctx, "unknown"); !errors.Is(err, userdomain.ErrUnauthenticated) {
+		t.Errorf("Expected ErrUnauthenticated for unknown token, got %v", err)
+	}
+}
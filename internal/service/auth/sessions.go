@@ -0,0 +1,101 @@
+package authsrv
+
+import (
+	userdomain "chatsrv/internal/domain/user"
+	"chatsrv/internal/token"
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// Refresh implements service.AuthService.
+func (s *authService) Refresh(ctx context.Context, refreshToken string, meta userdomain.ClientMeta) (*userdomain.AuthToken, error) {
+	if refreshToken == "" {
+		return nil, userdomain.ErrUnauthenticated
+	}
+
+	refresh, err := s.sessionRepo.GetRefreshToken(ctx, token.Hash(refreshToken))
+	if err != nil {
+		return nil, err
+	}
+	session, err := s.sessionRepo.GetSession(ctx, refresh.SessionID)
+	if err != nil {
+		return nil, err
+	}
+	if !session.Active(time.Now()) {
+		return nil, userdomain.ErrUnauthenticated
+	}
+
+	// Marking the token used is the single point that decides between two
+	// refreshes racing with the same token: the loser is treated as reuse.
+	fresh := refresh.UsedAt == nil
+	if fresh {
+		if fresh, err = s.sessionRepo.UseRefreshToken(ctx, refresh.ID); err != nil {
+			return nil, err
+		}
+	}
+	if !fresh {
+		s.log.Warn("Refresh token reused, revoking session",
+			zap.Any("session", session.ID),
+			zap.Any("user", session.UserID))
+		if err := s.revokeSession(ctx, session.ID); err != nil {
+			return nil, err
+		}
+		return nil, userdomain.ErrRefreshTokenReused
+	}
+
+	session.IP = meta.IP
+	session.UserAgent = meta.UserAgent
+	tok, err := s.issueTokens(session)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.sessionRepo.RotateSession(ctx, session, tok.refresh); err != nil {
+		return nil, err
+	}
+
+	return &tok.AuthToken, nil
+}
+
+// GetSessions implements service.AuthService.
+func (s *authService) GetSessions(ctx context.Context, principal *userdomain.Principal) ([]*userdomain.Session, error) {
+	sessions, err := s.sessionRepo.GetSessions(ctx, principal.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, session := range sessions {
+		session.Current = session.ID == principal.SessionID
+	}
+
+	return sessions, nil
+}
+
+// RevokeSession implements service.AuthService.
+func (s *authService) RevokeSession(ctx context.Context, principal *userdomain.Principal, sessionID string) error {
+	session, err := s.sessionRepo.GetSession(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	// Other users' sessions are reported missing rather than forbidden so
+	// that their IDs cannot be probed.
+	if session.UserID != principal.UserID {
+		return userdomain.ErrSessionNotFound
+	}
+
+	return s.revokeSession(ctx, sessionID)
+}
+
+// revokeSession revokes sessionID and closes the connections opened with it.
+func (s *authService) revokeSession(ctx context.Context, sessionID string) error {
+	if err := s.sessionRepo.RevokeSession(ctx, sessionID); err != nil {
+		s.log.Error("RevokeSession",
+			zap.Any("session", sessionID),
+			zap.Error(err))
+		return err
+	}
+
+	s.conns.CloseSession(sessionID)
+	return nil
+}
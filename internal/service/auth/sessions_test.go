@@ -0,0 +1,130 @@
+package authsrv
+
+import (
+	userdomain "chatsrv/internal/domain/user"
+	"context"
+	"errors"
+	"testing"
+)
+
+// TestRefreshRotatesTokens verifies a refresh token buys a new pair once,
+// and that replaying it revokes the session and closes its connections
+func TestRefreshRotatesTokens(t *testing.T) {
+	store := newMemoryStore()
+	srv := newTestService(store, testAuthConfig{})
+	ctx := context.Background()
+	meta := userdomain.ClientMeta{IP: "10.0.0.1", UserAgent: "test"}
+
+	login, err := srv.Register(ctx, userdomain.RegisterRequest{Username: "alice", Password: "correct horse", DeviceName: "laptop"}, meta)
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	rotated, err := srv.Refresh(ctx, login.RefreshToken, meta)
+	if err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	if rotated.Token == login.Token || rotated.RefreshToken == login.RefreshToken || rotated.SessionID != login.SessionID {
+		t.Errorf("Expected new tokens for the same session, got %+v", rotated)
+	}
+	if _, err := srv.Authenticate(ctx, login.Token); !errors.Is(err, userdomain.ErrUnauthenticated) {
+		t.Errorf("Expected the old access token to stop working, got %v", err)
+	}
+	if _, err := srv.Authenticate(ctx, rotated.Token); err != nil {
+		t.Errorf("Expected the new access token to work, got %v", err)
+	}
+
+	if _, err := srv.Refresh(ctx, login.RefreshToken, meta); !errors.Is(err, userdomain.ErrRefreshTokenReused) {
+		t.Fatalf("Expected ErrRefreshTokenReused, got %v", err)
+	}
+	if _, err := srv.Authenticate(ctx, rotated.Token); !errors.Is(err, userdomain.ErrUnauthenticated) {
+		t.Errorf("Expected reuse to revoke the session, got %v", err)
+	}
+	if _, err := srv.Refresh(ctx, rotated.RefreshToken, meta); !errors.Is(err, userdomain.ErrUnauthenticated) {
+		t.Errorf("Expected the latest refresh token to die with the session, got %v", err)
+	}
+	if len(store.closed) != 1 || store.closed[0] != login.SessionID {
+		t.Errorf("Expected the connections of %s to be closed, got %v", login.SessionID, store.closed)
+	}
+}
+
+// TestAuthenticateRejectsExpiredAccessTokens verifies access tokens expire
+// on their own while the session can still be refreshed
+func TestAuthenticateRejectsExpiredAccessTokens(t *testing.T) {
+	store := newMemoryStore()
+	srv := newTestService(store, testAuthConfig{accessTokenTTL: -1})
+	ctx := context.Background()
+
+	login, err := srv.Register(ctx, userdomain.RegisterRequest{Username: "alice", Password: "correct horse"}, userdomain.ClientMeta{})
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	if _, err := srv.Authenticate(ctx, login.Token); !errors.Is(err, userdomain.ErrUnauthenticated) {
+		t.Errorf("Expected ErrUnauthenticated for an expired access token, got %v", err)
+	}
+	if _, err := srv.Refresh(ctx, login.RefreshToken, userdomain.ClientMeta{}); err != nil {
+		t.Errorf("Expected the session to refresh, got %v", err)
+	}
+}
+
+// TestSessionsListAndRevoke verifies users see their own devices and can
+// only revoke their own sessions
+func TestSessionsListAndRevoke(t *testing.T) {
+	store := newMemoryStore()
+	srv := newTestService(store, testAuthConfig{})
+	ctx := context.Background()
+
+	laptop, err := srv.Register(ctx, userdomain.RegisterRequest{Username: "alice", Password: "correct horse", DeviceName: "laptop"},
+		userdomain.ClientMeta{IP: "10.0.0.1"})
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	phone, err := srv.Login(ctx, userdomain.LoginRequest{Username: "alice", Password: "correct horse"},
+		userdomain.ClientMeta{IP: "10.0.0.2", UserAgent: "ChatApp/1.0 (iPhone)"})
+	if err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+	mallory, err := srv.Register(ctx, userdomain.RegisterRequest{Username: "mallory", Password: "correct horse"}, userdomain.ClientMeta{})
+	if err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+
+	principal, err := srv.Authenticate(ctx, laptop.Token)
+	if err != nil {
+		t.Fatalf("Authenticate failed: %v", err)
+	}
+	sessions, err := srv.GetSessions(ctx, principal)
+	if err != nil {
+		t.Fatalf("GetSessions failed: %v", err)
+	}
+	if len(sessions) != 2 {
+		t.Fatalf("Expected 2 sessions, got %d", len(sessions))
+	}
+	for _, session := range sessions {
+		switch session.ID {
+		case laptop.SessionID:
+			if !session.Current || session.DeviceName != "laptop" || session.IP != "10.0.0.1" {
+				t.Errorf("Unexpected current session %+v", session)
+			}
+		case phone.SessionID:
+			if session.Current || session.DeviceName != "ChatApp/1.0 (iPhone)" {
+				t.Errorf("Expected the user agent as device name, got %+v", session)
+			}
+		default:
+			t.Errorf("Unexpected session %s", session.ID)
+		}
+	}
+
+	if err := srv.RevokeSession(ctx, principal, mallory.SessionID); !errors.Is(err, userdomain.ErrSessionNotFound) {
+		t.Errorf("Expected ErrSessionNotFound for another user's session, got %v", err)
+	}
+	if err := srv.RevokeSession(ctx, principal, phone.SessionID); err != nil {
+		t.Fatalf("RevokeSession failed: %v", err)
+	}
+	if _, err := srv.Authenticate(ctx, phone.Token); !errors.Is(err, userdomain.ErrUnauthenticated) {
+		t.Errorf("Expected the revoked session to stop working, got %v", err)
+	}
+	if len(store.closed) != 1 || store.closed[0] != phone.SessionID {
+		t.Errorf("Expected the connections of %s to be closed, got %v", phone.SessionID, store.closed)
+	}
+}
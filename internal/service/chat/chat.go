@@ -1,30 +1,238 @@
 package chatsrv
 
-import "sync"
+import (
+	chatdomain "chatsrv/internal/domain/chat"
+	msgdomain "chatsrv/internal/domain/msg"
+	"errors"
+	"sync/atomic"
 
+	"go.uber.org/zap"
+)
+
+// roomInboxSize is how many commands a room queues before callers wait.
+const roomInboxSize = 256
+
+var (
+	errRoomClosed    = errors.New("room closed")
+	errAlreadyJoined = errors.New("already in chat")
+)
+
+// chat is the live room of a chat. Its goroutine owns the clients and runs
+// the commands sent to its inbox one at a time, so joins, leaves and
+// broadcasts need no locks and rooms never wait for each other. The room
+// stops once its last client leaves or it is closed.
 type chat struct {
-	m       sync.RWMutex
-	chatID  string
-	clients map[string]*client
+	chatID string
+	meta   atomic.Pointer[chatdomain.Chat]
+	inbox  chan func()
+	// done is closed when the goroutine stops.
+	done chan struct{}
+	// release is called by the goroutine when the last client leaves, to
+	// forget the room before it stops.
+	release func(*chat)
+	// subscribe is called by the goroutine before it runs any command, to
+	// get the events other nodes publish to the room. It returns the
+	// function that ends the subscription once the room stops.
+	subscribe func(chatID string) func()
+	log       *zap.Logger
+
+	// Owned by the goroutine. clients holds the clients by client ID and
+	// users how many clients each user has in the room.
+	clients map[string]client
+	users   map[string]int
+	stopped bool
+}
+
+func newChat(meta *chatdomain.Chat, release func(*chat), subscribe func(string) func(), log *zap.Logger) *chat {
+	c := &chat{
+		chatID:    meta.ID,
+		inbox:     make(chan func(), roomInboxSize),
+		done:      make(chan struct{}),
+		release:   release,
+		subscribe: subscribe,
+		log:       log,
+		clients:   make(map[string]client),
+		users:     make(map[string]int),
+	}
+	c.meta.Store(meta)
+	go c.run()
+	return c
+}
 
-	isClosed bool
+func (c *chat) run() {
+	// Joins wait for the subscription, so a client misses nothing published
+	// after it joined.
+	unsubscribe := c.subscribe(c.chatID)
+	defer unsubscribe()
+	defer close(c.done)
+	for cmd := range c.inbox {
+		cmd()
+		if c.stopped {
+			return
+		}
+	}
+}
+
+// cast queues cmd without waiting for it. It reports false if the room has
+// stopped.
+func (c *chat) cast(cmd func()) bool {
+	select {
+	case <-c.done:
+		return false
+	default:
+	}
+	select {
+	case c.inbox <- cmd:
+		return true
+	case <-c.done:
+		return false
+	}
 }
 
-func newChat(chatID string) *chat {
-	return &chat{
-		chatID:  chatID,
-		clients: make(map[string]*client),
+// call runs cmd in the room and waits for it. It reports false if the room
+// stopped before running it.
+func (c *chat) call(cmd func()) bool {
+	finished := make(chan struct{})
+	if !c.cast(func() {
+		cmd()
+		close(finished)
+	}) {
+		return false
+	}
+	select {
+	case <-finished:
+		return true
+	case <-c.done:
+		// cmd may have been the last one the room ran.
+		select {
+		case <-finished:
+			return true
+		default:
+			return false
+		}
+	}
+}
+
+// info returns the stored chat this room belongs to.
+func (c *chat) info() *chatdomain.Chat {
+	return c.meta.Load()
+}
+
+func (c *chat) setInfo(meta *chatdomain.Chat) {
+	c.meta.Store(meta)
+}
+
+// addClient adds cl to the room and reports whether it is the first client
+// of its user there. It fails with errAlreadyJoined if cl is already in the
+// room, and with errRoomClosed if the room has stopped.
+func (c *chat) addClient(cl client) (bool, error) {
+	var (
+		firstOfUser bool
+		err         error
+	)
+	if !c.call(func() {
+		if _, ok := c.clients[cl.clientID()]; ok {
+			err = errAlreadyJoined
+			return
+		}
+		c.clients[cl.clientID()] = cl
+		c.users[cl.userID()]++
+		firstOfUser = c.users[cl.userID()] == 1
+	}) {
+		return false, errRoomClosed
+	}
+	return firstOfUser, err
+}
+
+// removeClient removes cl, and only cl, from the room. It reports whether
+// cl was in the room, and whether it was the last client of its user there.
+func (c *chat) removeClient(cl client) (removed bool, lastOfUser bool) {
+	c.call(func() {
+		if current, ok := c.clients[cl.clientID()]; ok && current == cl {
+			removed = true
+			lastOfUser = c.remove(current)
+		}
+	})
+	return removed, lastOfUser
+}
+
+// removeUser removes every client of userID from the room and returns
+// them.
+func (c *chat) removeUser(userID string) []client {
+	var removed []client
+	c.call(func() {
+		for _, cl := range c.clients {
+			if cl.userID() == userID {
+				removed = append(removed, cl)
+			}
+		}
+		for _, cl := range removed {
+			c.remove(cl)
+		}
+	})
+	return removed
+}
+
+// count returns how many clients the room has.
+func (c *chat) count() int {
+	n := 0
+	c.call(func() {
+		n = len(c.clients)
+	})
+	return n
+}
+
+// remove runs in the goroutine and reports whether cl was the last client
+// of its user. It stops the room once it is empty.
+func (c *chat) remove(cl client) bool {
+	delete(c.clients, cl.clientID())
+	lastOfUser := false
+	if c.users[cl.userID()]--; c.users[cl.userID()] <= 0 {
+		delete(c.users, cl.userID())
+		lastOfUser = true
+	}
+	if len(c.clients) == 0 {
+		c.release(c)
+		c.stopped = true
 	}
+	return lastOfUser
 }
 
-func (c *chat) addClient(client *client) {
-	c.m.Lock()
-	defer c.m.Unlock()
-	c.clients[client.id] = client
+// broadcast queues msg for every client of the room but origin, the
+// client it came from, if any. Typing notifications skip every client of
+// their sender. The frame is encoded at most once, however many clients
+// there are.
+func (c *chat) broadcast(msg msgdomain.Message, origin string) {
+	typing := msg.Action == string(msgdomain.ActionTyping)
+	c.cast(func() {
+		f := newFrame(frameOf(msg))
+		defer f.release()
+		for _, cl := range c.clients {
+			if cl.clientID() == origin || (typing && cl.userID() == msg.SenderID) {
+				continue
+			}
+			if err := cl.send(f); err != nil {
+				c.log.Error("broadcast",
+					zap.Any("msg", msg.ID),
+					zap.Any("client", cl.clientID()),
+					zap.Any("chat", c.chatID),
+					zap.Error(err))
+			}
+		}
+	})
 }
 
-func (c *chat) removeClient(id string) {
-	c.m.Lock()
-	defer c.m.Unlock()
-	delete(c.clients, id)
+// close stops the room and returns the clients it had. Later commands fail
+// as if the room had never existed.
+func (c *chat) close() []client {
+	var clients []client
+	c.call(func() {
+		for _, cl := range c.clients {
+			clients = append(clients, cl)
+		}
+		c.clients = nil
+		c.users = nil
+		c.stopped = true
+	})
+	return clients
 }
@@ -0,0 +1,133 @@
+package chatsrv
+
+import (
+	chatdomain "chatsrv/internal/domain/chat"
+	msgdomain "chatsrv/internal/domain/msg"
+	"errors"
+	"fmt"
+	"runtime"
+	"sync/atomic"
+	"testing"
+)
+
+// countingClient counts the events it receives
+type countingClient struct {
+	id   string
+	user string
+	n    *atomic.Int64
+}
+
+func (c *countingClient) clientID() string {
+	return c.id
+}
+
+func (c *countingClient) userID() string {
+	return c.user
+}
+
+func (c *countingClient) send(*frame) error {
+	c.n.Add(1)
+	return nil
+}
+
+// TestRoomStopsWhenEmpty verifies a room forgets itself once its last
+// client leaves, and a later join starts a new one
+func TestRoomStopsWhenEmpty(t *testing.T) {
+	srv := newTestService(newMemoryStore())
+	var delivered atomic.Int64
+	alice := &countingClient{id: "tab1", user: "alice", n: &delivered}
+
+	room := srv.openRoom(&chatdomain.Chat{ID: "c1"})
+	if first, err := room.addClient(alice); !first || err != nil {
+		t.Fatalf("addClient failed: %v, %v", first, err)
+	}
+	if _, err := room.addClient(alice); !errors.Is(err, errAlreadyJoined) {
+		t.Errorf("Expected errAlreadyJoined for the same client, got %v", err)
+	}
+	tab2 := &countingClient{id: "tab2", user: "alice", n: &delivered}
+	if first, err := room.addClient(tab2); first || err != nil {
+		t.Fatalf("Expected a second client of alice to join, got %v, %v", first, err)
+	}
+	if removed, last := room.removeClient(tab2); !removed || last {
+		t.Fatalf("Expected tab2 removed while alice keeps tab1, got %v, %v", removed, last)
+	}
+	if removed, last := room.removeClient(alice); !removed || !last {
+		t.Fatalf("Expected alice's last client removed, got %v, %v", removed, last)
+	}
+
+	if srv.room("c1") != nil {
+		t.Error("Expected the empty room to be forgotten")
+	}
+	if _, err := room.addClient(alice); !errors.Is(err, errRoomClosed) {
+		t.Errorf("Expected the stopped room to refuse clients, got %v", err)
+	}
+	if next := srv.openRoom(&chatdomain.Chat{ID: "c1"}); next == room {
+		t.Error("Expected a new room after the old one stopped")
+	}
+}
+
+// TestRoomBroadcastSkipsOrigin verifies a broadcast reaches every client of
+// the room, the other tabs of its sender included, but the one it came from
+func TestRoomBroadcastSkipsOrigin(t *testing.T) {
+	srv := newTestService(newMemoryStore())
+	var delivered atomic.Int64
+	room := srv.openRoom(&chatdomain.Chat{ID: "c1"})
+	for _, cl := range []*countingClient{
+		{id: "alice-1", user: "alice"},
+		{id: "alice-2", user: "alice"},
+		{id: "bob-1", user: "bob"},
+		{id: "carol-1", user: "carol"},
+	} {
+		cl.n = &delivered
+		room.addClient(cl)
+	}
+
+	room.broadcast(msgdomain.Message{ChatID: "c1", SenderID: "alice"}, "alice-1")
+	// Commands run in order, so the broadcast is done once count returns.
+	room.count()
+	if got := delivered.Load(); got != 3 {
+		t.Errorf("Expected 3 deliveries, got %d", got)
+	}
+
+	delivered.Store(0)
+	room.broadcast(msgdomain.Message{Action: string(msgdomain.ActionTyping), ChatID: "c1", SenderID: "alice"}, "")
+	room.count()
+	if got := delivered.Load(); got != 2 {
+		t.Errorf("Expected typing to skip every tab of its sender, got %d deliveries", got)
+	}
+}
+
+// BenchmarkBroadcast publishes to rooms of 10 clients from parallel
+// senders. Each room is served by its own goroutine, so throughput grows
+// with the number of rooms up to GOMAXPROCS.
+func BenchmarkBroadcast(b *testing.B) {
+	const clientsPerRoom = 10
+	for _, rooms := range []int{1, 4, 16, 64} {
+		b.Run(fmt.Sprintf("rooms=%d", rooms), func(b *testing.B) {
+			srv := newTestService(newMemoryStore())
+			var delivered atomic.Int64
+			chatIDs := make([]string, rooms)
+			for i := range chatIDs {
+				chatIDs[i] = fmt.Sprintf("c%d", i)
+				room := srv.openRoom(&chatdomain.Chat{ID: chatIDs[i]})
+				for j := 0; j < clientsPerRoom; j++ {
+					id := fmt.Sprintf("u%d", j)
+					room.addClient(&countingClient{id: id, user: id, n: &delivered})
+				}
+			}
+
+			var next atomic.Int64
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					chatID := chatIDs[next.Add(1)%int64(rooms)]
+					srv.publish(msgdomain.Message{Action: string(msgdomain.ActionSystem), ChatID: chatID}, "")
+				}
+			})
+			for want := int64(b.N) * clientsPerRoom; delivered.Load() < want; {
+				runtime.Gosched()
+			}
+			b.ReportMetric(float64(delivered.Load())/b.Elapsed().Seconds(), "deliveries/s")
+		})
+	}
+}
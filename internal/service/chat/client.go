@@ -1,30 +1,34 @@
 package chatsrv
 
-import (
-	msgdomain "chatsrv/internal/domain/msg"
+// client is a subscriber of a room. Rooms fan their events out to clients
+// without knowing whether a WebSocket, an event stream or a long-poll
+// mailbox carries them. A user may have many clients in the same room, one
+// per device or tab.
+type client interface {
+	// clientID identifies the client among those of its rooms.
+	clientID() string
+	// userID is the user the client receives events for.
+	userID() string
+	// send delivers f, retaining it if it is used after send returns.
+	send(f *frame) error
+}
 
-	"golang.org/x/net/websocket"
-)
+// funcClient delivers events through a function, such as one queueing
+// them for an event stream.
+type funcClient struct {
+	id      string
+	user    string
+	deliver func(*frame) error
+}
 
-func NewClient(id string, chatID string, conn *websocket.Conn) *client {
-	return &client{
-		id:     id,
-		chatID: chatID,
-		conn:   conn,
-	}
+func (c *funcClient) clientID() string {
+	return c.id
 }
 
-type client struct {
-	id     string
-	chatID string
-	conn   *websocket.Conn
+func (c *funcClient) userID() string {
+	return c.user
 }
 
-func (c *client) sendMessage(message msgdomain.Message) error {
-	msg := msgdomain.Message{
-		Content:  message.Content,
-		SenderID: message.SenderID,
-		ChatID:   message.ChatID,
-	}
-	return websocket.JSON.Send(c.conn, msg)
+func (c *funcClient) send(f *frame) error {
+	return c.deliver(f)
 }
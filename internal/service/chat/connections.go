@@ -0,0 +1,356 @@
+package chatsrv
+
+import (
+	"chatsrv/internal/auth"
+	msgdomain "chatsrv/internal/domain/msg"
+	userdomain "chatsrv/internal/domain/user"
+	"chatsrv/internal/wsutil"
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+	"golang.org/x/net/websocket"
+)
+
+// conn is the state the service keeps for a live WebSocket connection. It
+// is the client that the rooms it joined send their events to.
+type conn struct {
+	id string
+	ws *websocket.Conn
+	// w carries every write to ws, so that their deadlines do not clash.
+	w         *wsutil.Writer
+	user      string
+	sessionID string
+	// username is the account name of user, stamped on what it sends.
+	username string
+	// protocol is the negotiated version, empty for legacy clients.
+	protocol string
+	// activity tracks the reads of the connection, if the route does.
+	activity *wsutil.Activity
+	// done is closed when the connection is forgotten.
+	done chan struct{}
+	// out queues the frames sent to the connection.
+	out *outbox
+
+	mu sync.Mutex
+	// subs holds the chats the connection joined.
+	subs map[string]struct{}
+	// violations counts the actions refused since windowStart.
+	violations  int
+	windowStart time.Time
+}
+
+func (c *conn) userID() string {
+	return c.user
+}
+
+func (c *conn) clientID() string {
+	return c.id
+}
+
+func (c *conn) send(f *frame) error {
+	return c.out.push(f)
+}
+
+// subscribe records that the connection joined chatID. It reports false if
+// it already had.
+func (c *conn) subscribe(chatID string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.subs[chatID]; ok {
+		return false
+	}
+	c.subs[chatID] = struct{}{}
+	return true
+}
+
+// unsubscribe records that the connection left chatID. It reports false if
+// it was not in it.
+func (c *conn) unsubscribe(chatID string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.subs[chatID]; !ok {
+		return false
+	}
+	delete(c.subs, chatID)
+	return true
+}
+
+// subscribed reports whether the connection is in chatID.
+func (c *conn) subscribed(chatID string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.subs[chatID]
+	return ok
+}
+
+// subscriptions returns the chats the connection is in.
+func (c *conn) subscriptions() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	chatIDs := make([]string, 0, len(c.subs))
+	for chatID := range c.subs {
+		chatIDs = append(chatIDs, chatID)
+	}
+	return chatIDs
+}
+
+// violate records a refused action and returns how many were refused
+// within the current window.
+func (c *conn) violate(window time.Duration) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(c.windowStart) > window {
+		c.violations = 0
+		c.windowStart = now
+	}
+	c.violations++
+	return c.violations
+}
+
+// HandleConnect implements service.ChatService. Connections opened with a
+// login token are registered under its session so that revoking the session
+// can close them.
+func (s *chatService) HandleConnect(ws *websocket.Conn, protocol string) error {
+	c := &conn{
+		id:       uuid.New().String(),
+		ws:       ws,
+		w:        wsutil.NewWriter(ws),
+		protocol: protocol,
+		activity: wsutil.ActivityFromContext(ws.Request().Context()),
+		done:     make(chan struct{}),
+		subs:     make(map[string]struct{}),
+	}
+	principal := auth.FromContext(ws.Request().Context())
+	if principal != nil {
+		c.user = principal.UserID
+		c.sessionID = principal.SessionID
+	}
+	c.out = newOutbox(c.id, "websocket", c.user, s.outboundCfg,
+		func(f *frame) error {
+			return s.writeFrame(c.w, f)
+		},
+		func() {
+			if err := c.w.Close(wsutil.CloseTryAgainLater, "too slow to keep up"); err != nil {
+				s.log.Debug("slow consumer close",
+					zap.Any("conn", c.id),
+					zap.Error(err))
+			}
+		})
+	c.out.start()
+
+	account := s.account(ws.Request().Context(), c.user)
+	if account != nil {
+		c.username = account.Username
+	}
+
+	s.conns.add(c)
+	s.connected(c.user)
+
+	if s.heartbeatCfg.Interval() > 0 {
+		go s.heartbeat(c)
+	}
+
+	if protocol == "" {
+		return nil
+	}
+	return s.reply(ws, msgdomain.Message{
+		Action:  string(msgdomain.ActionWelcome),
+		Welcome: s.welcome(c, principal, account),
+	})
+}
+
+// writeFrame writes f through w within the write timeout.
+func (s *chatService) writeFrame(w *wsutil.Writer, f *frame) error {
+	data, err := f.bytes()
+	if err != nil {
+		return err
+	}
+	return w.SendText(data, s.outboundCfg.WriteTimeout())
+}
+
+// reply queues msg for ws behind the frames already queued for it.
+func (s *chatService) reply(ws *websocket.Conn, msg msgdomain.Message) error {
+	f := newFrame(msg)
+	defer f.release()
+	if out := s.outboxOf(ws); out != nil {
+		return out.push(f)
+	}
+	return s.writeFrame(wsutil.NewWriter(ws), f)
+}
+
+// welcome describes the server to the connection c.
+func (s *chatService) welcome(c *conn, principal *userdomain.Principal, account *userdomain.User) *msgdomain.Welcome {
+	welcome := &msgdomain.Welcome{
+		Protocol:     c.protocol,
+		ServerTime:   time.Now().UTC(),
+		ConnectionID: c.id,
+		User:         msgdomain.WelcomeUser{ID: c.user},
+		Limits:       make(map[string]msgdomain.Limit, len(limitKinds)),
+		Features: []msgdomain.Feature{
+			msgdomain.FeatureTyping,
+			msgdomain.FeatureModeration,
+			msgdomain.FeatureSystemEvents,
+			msgdomain.FeatureRateLimits,
+		},
+	}
+	if principal != nil {
+		welcome.User.Bot = principal.Bot
+	}
+	if s.cfg.UniqueNames() {
+		welcome.Features = append(welcome.Features, msgdomain.FeatureUniqueNames)
+	}
+	if s.heartbeatCfg.Interval() > 0 {
+		welcome.Features = append(welcome.Features, msgdomain.FeatureHeartbeat)
+		welcome.Heartbeat = &msgdomain.Heartbeat{
+			IntervalMs: s.heartbeatCfg.Interval().Milliseconds(),
+			TimeoutMs:  s.heartbeatCfg.Timeout().Milliseconds(),
+		}
+	}
+	for _, kind := range limitKinds {
+		connLimit, userLimit := s.limitsOf(kind)
+		welcome.Limits[string(kind)] = msgdomain.Limit{
+			PerMinute:     max(connLimit.PerMinute, 0),
+			Burst:         connLimit.Burst,
+			UserPerMinute: max(userLimit.PerMinute, 0),
+			UserBurst:     userLimit.Burst,
+		}
+	}
+
+	if account != nil {
+		welcome.User.Username = account.Username
+		welcome.User.DisplayName = account.DisplayName
+	}
+
+	return welcome
+}
+
+// account returns the account of userID, or nil if it has none or it
+// cannot be read.
+func (s *chatService) account(ctx context.Context, userID string) *userdomain.User {
+	if userID == "" {
+		return nil
+	}
+	users, err := s.userRepo.GetUsers(ctx, []string{userID})
+	if err != nil {
+		s.log.Error("GetUsers",
+			zap.Any("users", userID),
+			zap.Error(err))
+	}
+	if len(users) != 1 {
+		return nil
+	}
+	return users[0]
+}
+
+// sessionsRoom is the broker room the nodes tell each other about revoked
+// sessions in. Chat IDs are UUIDs, so it is no chat's.
+const sessionsRoom = "revoked-sessions"
+
+// sessionEvent is what a node publishes about a revoked session. Node is
+// the publisher, which has already closed its own connections.
+type sessionEvent struct {
+	Node      string `json:"node"`
+	SessionID string `json:"session_id"`
+}
+
+// CloseSession implements service.ChatService. The connections of the
+// session are closed on this node, and on the others through the broker.
+func (s *chatService) CloseSession(sessionID string) {
+	s.closeSession(sessionID)
+
+	payload, err := json.Marshal(sessionEvent{Node: s.node, SessionID: sessionID})
+	if err == nil {
+		err = s.broker.Publish(context.Background(), sessionsRoom, payload)
+	}
+	if err != nil {
+		s.log.Error("CloseSession",
+			zap.Any("session", sessionID),
+			zap.Error(err))
+	}
+}
+
+// closeSession closes the connections of sessionID on this node. Closing a
+// connection ends its read loop, which then disconnects it the usual way.
+func (s *chatService) closeSession(sessionID string) {
+	for _, c := range s.conns.ofSession(sessionID) {
+		if err := c.ws.Close(); err != nil {
+			s.log.Error("closeSession",
+				zap.Any("session", sessionID),
+				zap.Error(err))
+		}
+	}
+}
+
+// subscribeSessions closes the connections of the sessions other nodes
+// revoke until the returned function is called.
+func (s *chatService) subscribeSessions() func() {
+	ctx, cancel := context.WithTimeout(context.Background(), subscribeTimeout)
+	defer cancel()
+
+	unsubscribe, err := s.broker.Subscribe(ctx, sessionsRoom, func(payload []byte) {
+		var event sessionEvent
+		if err := json.Unmarshal(payload, &event); err != nil {
+			s.log.Error("subscribeSessions",
+				zap.Error(err))
+			return
+		}
+		if event.Node == s.node {
+			return
+		}
+		// Closing may wait for a write stuck on the peer, which must not
+		// hold up the broker.
+		go s.closeSession(event.SessionID)
+	})
+	if err != nil {
+		s.log.Error("subscribeSessions",
+			zap.Error(err))
+		return func() {}
+	}
+	return unsubscribe
+}
+
+// connState returns the state registered for ws by HandleConnect, if any.
+func (s *chatService) connState(ws *websocket.Conn) *conn {
+	return s.conns.of(ws)
+}
+
+// outboxOf returns the outbox of ws, or nil if ws is not registered.
+func (s *chatService) outboxOf(ws *websocket.Conn) *outbox {
+	if c := s.connState(ws); c != nil {
+		return c.out
+	}
+	return nil
+}
+
+// forgetConn drops ws from the registry along with its rate limits, and
+// those of its user once the user has no connections left. It returns the
+// connection, or nil if ws was not registered.
+func (s *chatService) forgetConn(ws *websocket.Conn) *conn {
+	c := s.conns.remove(ws)
+	if c == nil {
+		return nil
+	}
+	close(c.done)
+	c.out.close()
+
+	s.disconnected(c.user)
+	s.forgetLimits(c)
+	return c
+}
+
+// unsubscribe forgets chatID on the connections among clients, which were
+// taken out of its room, and returns clients.
+func (s *chatService) unsubscribe(chatID string, clients []client) []client {
+	for _, cl := range clients {
+		if c, ok := cl.(*conn); ok {
+			c.unsubscribe(chatID)
+		}
+	}
+	return clients
+}
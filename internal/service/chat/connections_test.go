@@ -0,0 +1,220 @@
+package chatsrv
+
+import (
+	"chatsrv/internal/auth"
+	msgdomain "chatsrv/internal/domain/msg"
+	userdomain "chatsrv/internal/domain/user"
+	"chatsrv/internal/wsutil"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// newWSTestServer serves srv over WebSocket the way the chat controller
+// and routes do, with every connection authenticated as alice.
+func newWSTestServer(t *testing.T, srv *chatService) string {
+	ws := websocket.Handler(func(ws *websocket.Conn) {
+		var protocol string
+		if len(ws.Config().Protocol) == 1 {
+			protocol = ws.Config().Protocol[0]
+		}
+		if err := srv.HandleConnect(ws, protocol); err != nil {
+			return
+		}
+		defer srv.HandleDisconnect(ws, "alice")
+		for {
+			var msg msgdomain.Message
+			if err := websocket.JSON.Receive(ws, &msg); err != nil {
+				return
+			}
+			msg.SenderID = "alice"
+			srv.GetIncomeMessage(ws, msg)
+		}
+	})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		principal := &userdomain.Principal{UserID: "alice", SessionID: "s1"}
+		wsutil.TrackActivity(ws).ServeHTTP(w, r.WithContext(auth.NewContext(r.Context(), principal)))
+	}))
+	t.Cleanup(server.Close)
+
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+func dialWSTestServer(t *testing.T, url string, protocol ...string) *websocket.Conn {
+	config, err := websocket.NewConfig(url, "http://localhost")
+	if err != nil {
+		t.Fatalf("NewConfig failed: %v", err)
+	}
+	config.Protocol = protocol
+	ws, err := websocket.DialConfig(config)
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	t.Cleanup(func() { ws.Close() })
+	ws.SetDeadline(time.Now().Add(5 * time.Second))
+	return ws
+}
+
+// TestHandleConnectWelcome verifies connections that negotiated a protocol
+// are greeted with the server's limits and features, and legacy ones are not
+func TestHandleConnectWelcome(t *testing.T) {
+	srv := newLimitTestService(t, testRateLimitConfig{})
+	store := srv.userRepo.(*memoryStore)
+	store.users["alice"] = &userdomain.User{ID: "alice", Username: "alice", DisplayName: "Alice"}
+	url := newWSTestServer(t, srv)
+
+	ws := dialWSTestServer(t, url, msgdomain.ProtocolV1)
+	var frame msgdomain.Message
+	if err := websocket.JSON.Receive(ws, &frame); err != nil {
+		t.Fatalf("Receive failed: %v", err)
+	}
+	welcome := frame.Welcome
+	if frame.Action != string(msgdomain.ActionWelcome) || welcome == nil {
+		t.Fatalf("Expected a welcome frame, got %+v", frame)
+	}
+	if welcome.Protocol != msgdomain.ProtocolV1 || welcome.ConnectionID == "" ||
+		time.Since(welcome.ServerTime) > time.Minute || welcome.User.ID != "alice" || welcome.User.DisplayName != "Alice" {
+		t.Errorf("Unexpected welcome %+v", welcome)
+	}
+	if _, ok := welcome.Limits["message"]; !ok || len(welcome.Features) == 0 {
+		t.Errorf("Expected limits and features, got %+v", welcome)
+	}
+
+	// A legacy client hears nothing until it is sent something.
+	legacy := dialWSTestServer(t, url)
+	if err := websocket.JSON.Send(legacy, msgdomain.Message{Action: string(msgdomain.ActionJoinChat), ChatID: "missing"}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	legacy.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if err := websocket.JSON.Receive(legacy, &frame); err == nil {
+		t.Errorf("Expected no welcome for a legacy client, got %+v", frame)
+	}
+}
+
+// TestUserTabsShareRooms verifies two connections of the same user can be
+// in a room together, each getting what the other sends, and that the room
+// hears the user left only once the last of them is gone
+func TestUserTabsShareRooms(t *testing.T) {
+	srv := newLimitTestService(t, testRateLimitConfig{})
+	url := newWSTestServer(t, srv)
+
+	stream := make(chan msgdomain.Message, 10)
+	unsubscribe, _, err := srv.Subscribe(context.Background(), "bob", []string{"c1"}, streamTo(stream))
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	defer unsubscribe()
+
+	tab1 := dialWSTestServer(t, url)
+	tab2 := dialWSTestServer(t, url)
+	for _, ws := range []*websocket.Conn{tab1, tab2} {
+		if err := websocket.JSON.Send(ws, msgdomain.Message{Action: string(msgdomain.ActionJoinChat), ChatID: "c1"}); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+	}
+	waitForClients(t, srv, "c1", 3)
+
+	conns := srv.conns.ofUser("alice")
+	if len(conns) != 2 {
+		t.Fatalf("Expected alice to have 2 connections, got %d", len(conns))
+	}
+	for _, c := range conns {
+		if srv.conns.get(c.id) != c {
+			t.Errorf("Expected connection %s to be found by its ID", c.id)
+		}
+	}
+
+	send := msgdomain.Message{Action: string(msgdomain.ActionSendText), ChatID: "c1", Content: "hello"}
+	if err := websocket.JSON.Send(tab1, send); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	var frame msgdomain.Message
+	if err := websocket.JSON.Receive(tab2, &frame); err != nil || frame.Content != "hello" {
+		t.Fatalf("Expected the other tab to get the message, got %+v, %v", frame, err)
+	}
+	if msg := <-stream; msg.Content != "hello" {
+		t.Fatalf("Expected bob to get the message, got %+v", msg)
+	}
+	tab1.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	if err := websocket.JSON.Receive(tab1, &frame); err == nil {
+		t.Errorf("Expected the sending tab not to get its message back, got %+v", frame)
+	}
+
+	tab1.Close()
+	waitForClients(t, srv, "c1", 2)
+	tab2.Close()
+	waitForClients(t, srv, "c1", 1)
+	for _, c := range conns {
+		if srv.conns.get(c.id) != nil {
+			t.Errorf("Expected connection %s to be forgotten", c.id)
+		}
+	}
+
+	left := 0
+	for {
+		select {
+		case msg := <-stream:
+			if msg.Event != nil && msg.Event.Type == msgdomain.EventMemberLeft && msg.Event.UserID == "alice" {
+				left++
+			}
+			continue
+		case <-time.After(100 * time.Millisecond):
+		}
+		break
+	}
+	if left != 1 {
+		t.Errorf("Expected alice to leave once, got %d member_left events", left)
+	}
+}
+
+// TestSenderNamedByAccount verifies clients cannot pick the name their
+// messages and typing events carry: both transports use the account's
+func TestSenderNamedByAccount(t *testing.T) {
+	srv := newLimitTestService(t, testRateLimitConfig{})
+	store := srv.userRepo.(*memoryStore)
+	store.users["alice"] = &userdomain.User{ID: "alice", Username: "alice"}
+	url := newWSTestServer(t, srv)
+
+	stream := make(chan msgdomain.Message, 10)
+	unsubscribe, _, err := srv.Subscribe(context.Background(), "bob", []string{"c1"}, streamTo(stream))
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	defer unsubscribe()
+
+	ws := dialWSTestServer(t, url)
+	if err := websocket.JSON.Send(ws, msgdomain.Message{Action: string(msgdomain.ActionJoinChat), ChatID: "c1"}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	waitForClients(t, srv, "c1", 2)
+
+	for _, action := range []msgdomain.ActionType{msgdomain.ActionTyping, msgdomain.ActionSendText} {
+		forged := msgdomain.Message{Action: string(action), ChatID: "c1", Content: "hi", Username: "root"}
+		if err := websocket.JSON.Send(ws, forged); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+	}
+	if _, err := srv.SendMessage(context.Background(), "alice", "c1", msgdomain.SendMessageRequest{Content: "over http"}); err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+
+	for got := 0; got < 3; {
+		select {
+		case msg := <-stream:
+			if msg.SenderID != "alice" {
+				continue
+			}
+			if msg.Username != "alice" {
+				t.Errorf("Expected %s from alice to be named alice, got %q", msg.Action, msg.Username)
+			}
+			got++
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Got %d of 3 events from alice", got)
+		}
+	}
+}
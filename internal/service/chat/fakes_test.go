@@ -0,0 +1,595 @@
+package chatsrv
+
+import (
+	"chatsrv/internal/config"
+	chatdomain "chatsrv/internal/domain/chat"
+	idempotencydomain "chatsrv/internal/domain/idempotency"
+	invitedomain "chatsrv/internal/domain/invite"
+	msgdomain "chatsrv/internal/domain/msg"
+	userdomain "chatsrv/internal/domain/user"
+	webhookdomain "chatsrv/internal/domain/webhook"
+	"context"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// memoryStore is an in-memory implementation of the chat, member,
+// moderation, message, invite, idempotency and user repositories
+type memoryStore struct {
+	mu          sync.Mutex
+	chats       map[string]*chatdomain.Chat
+	members     map[string]map[string]*chatdomain.Member
+	messages    []*msgdomain.Message
+	invites     map[string]*invitedomain.Invite
+	acceptances []*invitedomain.Acceptance
+	// restrictions is keyed by chat ID, user ID and kind
+	restrictions map[string]*chatdomain.Restriction
+	// idempotency is keyed by scope, user ID and key
+	idempotency map[string]*idempotencydomain.Record
+	users       map[string]*userdomain.User
+}
+
+func newMemoryStore() *memoryStore {
+	return &memoryStore{
+		chats:        make(map[string]*chatdomain.Chat),
+		members:      make(map[string]map[string]*chatdomain.Member),
+		invites:      make(map[string]*invitedomain.Invite),
+		restrictions: make(map[string]*chatdomain.Restriction),
+		idempotency:  make(map[string]*idempotencydomain.Record),
+		users:        make(map[string]*userdomain.User),
+	}
+}
+
+func (s *memoryStore) ListChats(_ context.Context, req chatdomain.ListChatsRequest) ([]*chatdomain.ChatSummary, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// less reports whether a sorts before b.
+	less := func(a, b *chatdomain.Chat) bool {
+		ka, kb := chatdomain.CursorAfter(req.Sort, a), chatdomain.CursorAfter(req.Sort, b)
+		if req.Sort == chatdomain.SortName {
+			return ka.Key < kb.Key || ka.Key == kb.Key && a.ID < b.ID
+		}
+		ta, _ := ka.Time()
+		tb, _ := kb.Time()
+		return ta.After(tb) || ta.Equal(tb) && a.ID > b.ID
+	}
+
+	var out []*chatdomain.ChatSummary
+	for _, c := range s.chats {
+		me, member := s.members[c.ID][req.UserID]
+		if c.IsPrivate() && !member || req.Mine && !member {
+			continue
+		}
+		if req.Archived == chatdomain.ArchivedExclude && c.IsArchived() ||
+			req.Archived == chatdomain.ArchivedOnly && !c.IsArchived() {
+			continue
+		}
+		if req.Query != "" && !strings.Contains(strings.ToLower(c.Name), strings.ToLower(req.Query)) {
+			continue
+		}
+		if req.Cursor != nil {
+			after := &chatdomain.Chat{ID: req.Cursor.ID, Name: req.Cursor.Key}
+			after.CreatedAt, _ = req.Cursor.Time()
+			after.LastActivityAt = after.CreatedAt
+			if !less(after, c) {
+				continue
+			}
+		}
+
+		summary := &chatdomain.ChatSummary{Chat: *c, MemberCount: len(s.members[c.ID])}
+		for _, m := range s.messages {
+			if m.ChatID != c.ID {
+				continue
+			}
+			summary.LastMessage = &chatdomain.MessagePreview{ID: m.ID, SenderID: m.SenderID, Content: m.Content, CreatedAt: *m.CreatedAt}
+			readAt := me.JoinedAt
+			if member && me.LastReadAt != nil {
+				readAt = *me.LastReadAt
+			}
+			if member && m.SenderID != req.UserID && m.CreatedAt.After(readAt) {
+				summary.UnreadCount++
+			}
+		}
+		out = append(out, summary)
+	}
+
+	sort.Slice(out, func(i, j int) bool { return less(&out[i].Chat, &out[j].Chat) })
+	if len(out) > req.Limit {
+		out = out[:req.Limit]
+	}
+	return out, nil
+}
+
+func (s *memoryStore) GetChat(_ context.Context, chatID string) (*chatdomain.Chat, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.chats[chatID]
+	if !ok {
+		return nil, chatdomain.ErrChatNotFound
+	}
+	cp := *c
+	return &cp, nil
+}
+
+func (s *memoryStore) CreateChat(ctx context.Context, chat *chatdomain.Chat, ownerID string) error {
+	s.mu.Lock()
+	for _, c := range s.chats {
+		if chat.NameKey != "" && c.NameKey == chat.NameKey {
+			s.mu.Unlock()
+			return chatdomain.ErrChatNameTaken
+		}
+	}
+	chat.CreatedAt = time.Now()
+	chat.LastActivityAt = chat.CreatedAt
+	cp := *chat
+	s.chats[chat.ID] = &cp
+	s.mu.Unlock()
+	if ownerID != "" {
+		return s.AddMember(ctx, chat.ID, ownerID, chatdomain.RoleOwner)
+	}
+	return nil
+}
+
+func (s *memoryStore) GetOrCreateDirectChat(ctx context.Context, chatID string, userA string, userB string) (*chatdomain.Chat, bool, error) {
+	userA, userB = chatdomain.DirectPair(userA, userB)
+	s.mu.Lock()
+	for _, c := range s.chats {
+		if c.IsDirect() && c.Participants[0] == userA && c.Participants[1] == userB {
+			cp := *c
+			s.mu.Unlock()
+			return &cp, false, nil
+		}
+	}
+	chat := &chatdomain.Chat{
+		ID:           chatID,
+		Type:         chatdomain.ChatTypeDirect,
+		Visibility:   chatdomain.VisibilityPrivate,
+		Participants: []string{userA, userB},
+	}
+	s.chats[chatID] = chat
+	s.mu.Unlock()
+
+	s.AddMember(ctx, chatID, userA, chatdomain.RoleMember)
+	s.AddMember(ctx, chatID, userB, chatdomain.RoleMember)
+	cp := *chat
+	return &cp, true, nil
+}
+
+func (s *memoryStore) UpdateChat(_ context.Context, chat *chatdomain.Chat) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.chats[chat.ID]
+	if !ok {
+		return chatdomain.ErrChatNotFound
+	}
+	c.Name, c.Topic, c.Description, c.NameKey = chat.Name, chat.Topic, chat.Description, chat.NameKey
+	return nil
+}
+
+func (s *memoryStore) SetArchived(_ context.Context, chatID string, archived bool) (*chatdomain.Chat, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	c, ok := s.chats[chatID]
+	if !ok {
+		return nil, chatdomain.ErrChatNotFound
+	}
+	c.ArchivedAt = nil
+	if archived {
+		now := time.Now()
+		c.ArchivedAt = &now
+	}
+	cp := *c
+	return &cp, nil
+}
+
+func (s *memoryStore) DeleteChat(_ context.Context, chatID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.chats[chatID]; !ok {
+		return chatdomain.ErrChatNotFound
+	}
+	delete(s.chats, chatID)
+	return nil
+}
+
+func (s *memoryStore) AddMember(_ context.Context, chatID string, userID string, role chatdomain.Role) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.members[chatID] == nil {
+		s.members[chatID] = make(map[string]*chatdomain.Member)
+	}
+	if _, ok := s.members[chatID][userID]; !ok {
+		s.members[chatID][userID] = &chatdomain.Member{ChatID: chatID, UserID: userID, Role: role, JoinedAt: time.Now()}
+	}
+	return nil
+}
+
+func (s *memoryStore) MarkRead(_ context.Context, chatID string, userID string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, ok := s.members[chatID][userID]
+	if !ok {
+		return chatdomain.ErrNotMember
+	}
+	if m.LastReadAt == nil || at.After(*m.LastReadAt) {
+		m.LastReadAt = &at
+	}
+	return nil
+}
+
+func (s *memoryStore) RemoveMember(_ context.Context, chatID string, userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.members[chatID][userID]; !ok {
+		return chatdomain.ErrNotMember
+	}
+	delete(s.members[chatID], userID)
+	return nil
+}
+
+func (s *memoryStore) GetMember(_ context.Context, chatID string, userID string) (*chatdomain.Member, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, ok := s.members[chatID][userID]
+	if !ok {
+		return nil, chatdomain.ErrNotMember
+	}
+	cp := *m
+	return &cp, nil
+}
+
+func (s *memoryStore) GetMembers(_ context.Context, chatID string) ([]*chatdomain.Member, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []*chatdomain.Member
+	for _, m := range s.members[chatID] {
+		cp := *m
+		out = append(out, &cp)
+	}
+	return out, nil
+}
+
+func (s *memoryStore) UpdateMemberRole(_ context.Context, chatID string, userID string, role chatdomain.Role) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	m, ok := s.members[chatID][userID]
+	if !ok {
+		return chatdomain.ErrNotMember
+	}
+	m.Role = role
+	return nil
+}
+
+func restrictionKey(chatID string, userID string, kind chatdomain.RestrictionKind) string {
+	return chatID + "/" + userID + "/" + string(kind)
+}
+
+func (s *memoryStore) SetRestriction(_ context.Context, r *chatdomain.Restriction) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r.CreatedAt = time.Now()
+	cp := *r
+	s.restrictions[restrictionKey(r.ChatID, r.UserID, r.Kind)] = &cp
+	return nil
+}
+
+func (s *memoryStore) RemoveRestriction(_ context.Context, chatID string, userID string, kind chatdomain.RestrictionKind) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := restrictionKey(chatID, userID, kind)
+	if _, ok := s.restrictions[key]; !ok {
+		return chatdomain.ErrNotRestricted
+	}
+	delete(s.restrictions, key)
+	return nil
+}
+
+func (s *memoryStore) GetActiveRestriction(_ context.Context, chatID string, userID string, kind chatdomain.RestrictionKind) (*chatdomain.Restriction, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	r, ok := s.restrictions[restrictionKey(chatID, userID, kind)]
+	if !ok || !r.Active(time.Now()) {
+		return nil, chatdomain.ErrNotRestricted
+	}
+	cp := *r
+	return &cp, nil
+}
+
+func (s *memoryStore) CreateMessage(_ context.Context, msg *msgdomain.Message) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	msg.CreatedAt = &now
+	cp := *msg
+	s.messages = append(s.messages, &cp)
+	if c, ok := s.chats[msg.ChatID]; ok {
+		c.LastActivityAt = now
+	}
+	return nil
+}
+
+func (s *memoryStore) GetMessage(_ context.Context, messageID string) (*msgdomain.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, m := range s.messages {
+		if m.ID == messageID {
+			cp := *m
+			return &cp, nil
+		}
+	}
+	return nil, msgdomain.ErrMessageNotFound
+}
+
+func (s *memoryStore) GetMessages(_ context.Context, chatID string, before time.Time, limit int) ([]*msgdomain.Message, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []*msgdomain.Message
+	for i := len(s.messages) - 1; i >= 0 && len(out) < limit; i-- {
+		m := s.messages[i]
+		if m.ChatID == chatID && m.CreatedAt.Before(before) {
+			cp := *m
+			out = append(out, &cp)
+		}
+	}
+	return out, nil
+}
+
+func (s *memoryStore) CreateInvite(_ context.Context, invite *invitedomain.Invite) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	invite.CreatedAt = time.Now()
+	cp := *invite
+	s.invites[invite.ID] = &cp
+	return nil
+}
+
+func (s *memoryStore) GetInvites(_ context.Context, chatID string) ([]*invitedomain.Invite, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []*invitedomain.Invite
+	for _, i := range s.invites {
+		if i.ChatID == chatID {
+			cp := *i
+			out = append(out, &cp)
+		}
+	}
+	return out, nil
+}
+
+func (s *memoryStore) GetInvite(_ context.Context, inviteID string) (*invitedomain.Invite, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i, ok := s.invites[inviteID]
+	if !ok {
+		return nil, invitedomain.ErrInviteNotFound
+	}
+	cp := *i
+	return &cp, nil
+}
+
+func (s *memoryStore) GetInviteByTokenHash(_ context.Context, tokenHash string) (*invitedomain.Invite, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, i := range s.invites {
+		if i.TokenHash == tokenHash {
+			cp := *i
+			return &cp, nil
+		}
+	}
+	return nil, invitedomain.ErrInviteNotFound
+}
+
+func (s *memoryStore) RevokeInvite(_ context.Context, inviteID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i, ok := s.invites[inviteID]
+	if !ok || i.RevokedAt != nil {
+		return invitedomain.ErrInviteNotFound
+	}
+	now := time.Now()
+	i.RevokedAt = &now
+	return nil
+}
+
+func (s *memoryStore) ConsumeInvite(_ context.Context, invite *invitedomain.Invite, userID string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i, ok := s.invites[invite.ID]
+	if !ok || i.Check(time.Now()) != nil {
+		return false, nil
+	}
+	i.Uses++
+	if s.members[i.ChatID] == nil {
+		s.members[i.ChatID] = make(map[string]*chatdomain.Member)
+	}
+	if _, ok := s.members[i.ChatID][userID]; !ok {
+		s.members[i.ChatID][userID] = &chatdomain.Member{ChatID: i.ChatID, UserID: userID, Role: i.Role, JoinedAt: time.Now()}
+	}
+	return true, nil
+}
+
+func (s *memoryStore) CreateAcceptance(_ context.Context, a *invitedomain.Acceptance) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	cp := *a
+	s.acceptances = append(s.acceptances, &cp)
+	return nil
+}
+
+func idempotencyKey(r *idempotencydomain.Record) string {
+	return r.Scope + "/" + r.UserID + "/" + r.Key
+}
+
+func (s *memoryStore) Reserve(_ context.Context, r *idempotencydomain.Record, _ time.Duration) (*idempotencydomain.Record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if existing, ok := s.idempotency[idempotencyKey(r)]; ok {
+		cp := *existing
+		return &cp, false, nil
+	}
+	r.CreatedAt = time.Now()
+	cp := *r
+	s.idempotency[idempotencyKey(r)] = &cp
+	return r, true, nil
+}
+
+func (s *memoryStore) Complete(_ context.Context, r *idempotencydomain.Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.idempotency[idempotencyKey(r)].ResourceID = r.ResourceID
+	return nil
+}
+
+func (s *memoryStore) Release(_ context.Context, r *idempotencydomain.Record) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.idempotency, idempotencyKey(r))
+	return nil
+}
+
+func (s *memoryStore) CreateUser(_ context.Context, user *userdomain.User) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, u := range s.users {
+		if strings.EqualFold(u.Username, user.Username) {
+			return userdomain.ErrUsernameTaken
+		}
+	}
+	user.CreatedAt = time.Now()
+	cp := *user
+	s.users[user.ID] = &cp
+	return nil
+}
+
+func (s *memoryStore) GetUser(_ context.Context, userID string) (*userdomain.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[userID]
+	if !ok {
+		return nil, userdomain.ErrUserNotFound
+	}
+	cp := *u
+	return &cp, nil
+}
+
+func (s *memoryStore) GetUserByUsername(_ context.Context, username string) (*userdomain.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, u := range s.users {
+		if strings.EqualFold(u.Username, username) {
+			cp := *u
+			return &cp, nil
+		}
+	}
+	return nil, userdomain.ErrUserNotFound
+}
+
+func (s *memoryStore) GetUsers(_ context.Context, userIDs []string) ([]*userdomain.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []*userdomain.User
+	for _, id := range userIDs {
+		if u, ok := s.users[id]; ok {
+			cp := *u
+			out = append(out, &cp)
+		}
+	}
+	return out, nil
+}
+
+func (s *memoryStore) UpdateUserRole(_ context.Context, userID string, role userdomain.Role) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[userID]
+	if !ok {
+		return userdomain.ErrUserNotFound
+	}
+	u.Role = role
+	return nil
+}
+
+// testChatConfig is a config.ChatConfig with fixed values
+type testChatConfig struct {
+	uniqueNames bool
+}
+
+func (c testChatConfig) UniqueNames() bool             { return c.uniqueNames }
+func (c testChatConfig) IdempotencyTTL() time.Duration { return time.Hour }
+
+// testRateLimitConfig is a config.RateLimitConfig whose message budget
+// and violation limit are set by the test. Other budgets are disabled.
+type testRateLimitConfig struct {
+	message       config.RateLimit
+	userMessage   config.RateLimit
+	maxViolations int
+}
+
+func (c testRateLimitConfig) MessageLimit() config.RateLimit     { return c.message }
+func (c testRateLimitConfig) UserMessageLimit() config.RateLimit { return c.userMessage }
+func (c testRateLimitConfig) JoinLimit() config.RateLimit        { return config.RateLimit{} }
+func (c testRateLimitConfig) UserJoinLimit() config.RateLimit    { return config.RateLimit{} }
+func (c testRateLimitConfig) TypingLimit() config.RateLimit      { return config.RateLimit{} }
+func (c testRateLimitConfig) UserTypingLimit() config.RateLimit  { return config.RateLimit{} }
+func (c testRateLimitConfig) MaxViolations() int                 { return c.maxViolations }
+func (c testRateLimitConfig) ViolationWindow() time.Duration     { return time.Minute }
+
+// testHeartbeatConfig is a config.HeartbeatConfig; the zero value disables
+// heartbeats
+type testHeartbeatConfig struct {
+	interval time.Duration
+	timeout  time.Duration
+}
+
+func (c testHeartbeatConfig) Interval() time.Duration { return c.interval }
+func (c testHeartbeatConfig) Timeout() time.Duration  { return c.timeout }
+
+// testOutboundConfig is a config.OutboundConfig; the zero value queues up
+// to 256 events per client and drops the oldest
+type testOutboundConfig struct {
+	queueSize    int
+	writeTimeout time.Duration
+	policy       config.SlowConsumerPolicy
+	blockTimeout time.Duration
+}
+
+func (c testOutboundConfig) QueueSize() int {
+	if c.queueSize == 0 {
+		return 256
+	}
+	return c.queueSize
+}
+
+func (c testOutboundConfig) WriteTimeout() time.Duration { return c.writeTimeout }
+
+func (c testOutboundConfig) SlowConsumerPolicy() config.SlowConsumerPolicy {
+	if c.policy == "" {
+		return config.SlowConsumerDropOldest
+	}
+	return c.policy
+}
+
+func (c testOutboundConfig) BlockTimeout() time.Duration { return c.blockTimeout }
+
+// testPresenceConfig is a config.PresenceConfig with a 30s lease
+type testPresenceConfig struct{}
+
+func (testPresenceConfig) Backend() config.PresenceBackend { return config.PresenceMemory }
+func (testPresenceConfig) Prefix() string                  { return "" }
+func (testPresenceConfig) Lease() time.Duration            { return 30 * time.Second }
+
+// nopWebhooks is a service.WebhookService that drops every event
+type nopWebhooks struct{}
+
+func (nopWebhooks) Dispatch(context.Context, webhookdomain.EventType, string, any) {}
+func (nopWebhooks) CreateWebhook(context.Context, webhookdomain.CreateWebhookRequest) (*webhookdomain.Webhook, error) {
+	return nil, nil
+}
+func (nopWebhooks) GetWebhooks(context.Context) ([]*webhookdomain.Webhook, error) { return nil, nil }
+func (nopWebhooks) DeleteWebhook(context.Context, string) error                   { return nil }
+func (nopWebhooks) GetDeliveries(context.Context, string, int) ([]*webhookdomain.Delivery, error) {
+	return nil, nil
+}
+func (nopWebhooks) Stop() {}
@@ -0,0 +1,91 @@
+package chatsrv
+
+import (
+	"bytes"
+	msgdomain "chatsrv/internal/domain/msg"
+	"encoding/json"
+	"sync"
+	"sync/atomic"
+)
+
+// maxPooledFrame is the largest buffer put back in framePool, so that a
+// rare huge frame does not stay in memory.
+const maxPooledFrame = 64 << 10
+
+var framePool = sync.Pool{
+	New: func() any {
+		return new(bytes.Buffer)
+	},
+}
+
+// frame is an event on its way to clients. It is shared by every client it
+// is sent to and must not be changed. Its JSON is encoded once, the first
+// time a connection needs it, into a pooled buffer that is returned once
+// the last holder releases the frame.
+type frame struct {
+	msg msgdomain.Message
+
+	once sync.Once
+	buf  *bytes.Buffer
+	data []byte
+	err  error
+
+	refs atomic.Int32
+}
+
+// newFrame returns a frame of msg held once by the caller.
+func newFrame(msg msgdomain.Message) *frame {
+	f := &frame{msg: msg}
+	f.refs.Store(1)
+	return f
+}
+
+// retain adds a holder to f.
+func (f *frame) retain() {
+	f.refs.Add(1)
+}
+
+// release drops a holder of f. The bytes of f must not be used after.
+func (f *frame) release() {
+	if f.refs.Add(-1) != 0 || f.buf == nil {
+		return
+	}
+	if f.buf.Cap() <= maxPooledFrame {
+		f.buf.Reset()
+		framePool.Put(f.buf)
+	}
+	f.buf, f.data = nil, nil
+}
+
+// bytes returns the JSON of the frame.
+func (f *frame) bytes() ([]byte, error) {
+	f.once.Do(func() {
+		buf := framePool.Get().(*bytes.Buffer)
+		if err := json.NewEncoder(buf).Encode(f.msg); err != nil {
+			f.err = err
+			framePool.Put(buf)
+			return
+		}
+		f.buf = buf
+		// Encode ends the value with a newline.
+		f.data = bytes.TrimSuffix(buf.Bytes(), []byte("\n"))
+	})
+	return f.data, f.err
+}
+
+// frameOf returns the fields of message that are sent to clients.
+func frameOf(message msgdomain.Message) msgdomain.Message {
+	return msgdomain.Message{
+		ID:          message.ID,
+		Action:      message.Action,
+		Content:     message.Content,
+		SenderID:    message.SenderID,
+		ChatID:      message.ChatID,
+		Username:    message.Username,
+		Attachments: message.Attachments,
+		CreatedAt:   message.CreatedAt,
+		Event:       message.Event,
+		Error:       message.Error,
+		Bot:         message.Bot,
+	}
+}
@@ -0,0 +1,95 @@
+package chatsrv
+
+import (
+	"bytes"
+	msgdomain "chatsrv/internal/domain/msg"
+	"encoding/json"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+)
+
+func sampleMessage() msgdomain.Message {
+	now := time.Now()
+	return msgdomain.Message{
+		ID:        "m1",
+		Action:    string(msgdomain.ActionSendText),
+		Content:   "The release is out, see <https://example.com/notes> & enjoy",
+		SenderID:  "alice",
+		ChatID:    "c1",
+		Username:  "alice",
+		CreatedAt: &now,
+	}
+}
+
+// TestFrameEncodesOnce verifies every holder of a frame gets the same
+// bytes, and they are what json.Marshal makes of the message
+func TestFrameEncodesOnce(t *testing.T) {
+	msg := frameOf(sampleMessage())
+	f := newFrame(msg)
+	defer f.release()
+
+	first, err := f.bytes()
+	if err != nil {
+		t.Fatalf("bytes failed: %v", err)
+	}
+	second, _ := f.bytes()
+	if &first[0] != &second[0] {
+		t.Error("Expected the frame to be encoded once")
+	}
+	want, _ := json.Marshal(msg)
+	if !bytes.Equal(first, want) {
+		t.Errorf("Expected %s, got %s", want, first)
+	}
+}
+
+// TestFrameReleaseReturnsBuffer verifies the buffer of a frame is kept
+// until its last holder releases it
+func TestFrameReleaseReturnsBuffer(t *testing.T) {
+	f := newFrame(frameOf(sampleMessage()))
+	f.retain()
+	if _, err := f.bytes(); err != nil {
+		t.Fatalf("bytes failed: %v", err)
+	}
+
+	f.release()
+	if f.buf == nil {
+		t.Fatal("Expected the buffer to outlive the first release")
+	}
+	f.release()
+	if f.buf != nil || f.data != nil {
+		t.Error("Expected the buffer to be returned after the last release")
+	}
+}
+
+// BenchmarkFanOutEncoding sends a message to every client of a room,
+// encoding it for each client as sendMessage used to, and once for the
+// whole room.
+func BenchmarkFanOutEncoding(b *testing.B) {
+	msg := sampleMessage()
+	for _, clients := range []int{100, 5000} {
+		b.Run(fmt.Sprintf("per_client/clients=%d", clients), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				for j := 0; j < clients; j++ {
+					data, _ := json.Marshal(frameOf(msg))
+					io.Discard.Write(data)
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("shared/clients=%d", clients), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				f := newFrame(frameOf(msg))
+				for j := 0; j < clients; j++ {
+					f.retain()
+					data, _ := f.bytes()
+					io.Discard.Write(data)
+					f.release()
+				}
+				f.release()
+			}
+		})
+	}
+}
@@ -0,0 +1,59 @@
+package chatsrv
+
+import (
+	"chatsrv/internal/wsutil"
+	"expvar"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// heartbeatStats counts the pings sent, those that failed, and the
+// connections evicted for staying silent. They are published under
+// /debug/vars.
+var heartbeatStats = expvar.NewMap("ws_heartbeat")
+
+// heartbeat pings c every interval until it is forgotten. A connection
+// that has not sent anything, pongs included, for the timeout is closed;
+// its read loop then fails and disconnects it from its rooms. No ping is
+// sent while a frame is being written to c.
+func (s *chatService) heartbeat(c *conn) {
+	interval, timeout := s.heartbeatCfg.Interval(), s.heartbeatCfg.Timeout()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+		}
+
+		if c.activity != nil && timeout > 0 && time.Since(c.activity.Last()) > timeout {
+			heartbeatStats.Add("evictions", 1)
+			s.log.Info("Evicting silent client",
+				zap.Any("user", c.user),
+				zap.Any("conn", c.id),
+				zap.Time("last_seen", c.activity.Last()))
+			if err := c.w.Close(wsutil.CloseGoingAway, "heartbeat timeout"); err != nil {
+				s.log.Debug("heartbeat close",
+					zap.Any("conn", c.id),
+					zap.Error(err))
+			}
+			return
+		}
+
+		sent, err := c.w.Ping(interval)
+		if sent {
+			heartbeatStats.Add("pings", 1)
+		}
+		if err != nil {
+			heartbeatStats.Add("ping_failures", 1)
+			s.log.Debug("heartbeat ping",
+				zap.Any("conn", c.id),
+				zap.Error(err))
+			c.ws.Close()
+			return
+		}
+	}
+}
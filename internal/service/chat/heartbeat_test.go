@@ -0,0 +1,70 @@
+package chatsrv
+
+import (
+	msgdomain "chatsrv/internal/domain/msg"
+	"context"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// TestHeartbeatEvictsSilentClient verifies a connection that stops
+// answering pings is closed and leaves its rooms
+func TestHeartbeatEvictsSilentClient(t *testing.T) {
+	srv := newLimitTestService(t, testRateLimitConfig{})
+	srv.heartbeatCfg = testHeartbeatConfig{interval: 50 * time.Millisecond, timeout: 500 * time.Millisecond}
+
+	stream := make(chan msgdomain.Message, 10)
+	unsubscribe, _, err := srv.Subscribe(context.Background(), "bob", []string{"c1"}, streamTo(stream))
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	defer unsubscribe()
+
+	// The client never reads, so it never answers the pings.
+	ws := dialWSTestServer(t, newWSTestServer(t, srv))
+	if err := websocket.JSON.Send(ws, msgdomain.Message{Action: string(msgdomain.ActionJoinChat), ChatID: "c1"}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	waitForClients(t, srv, "c1", 2)
+
+	for deadline := time.After(5 * time.Second); ; {
+		select {
+		case msg := <-stream:
+			if msg.Event != nil && msg.Event.Type == msgdomain.EventMemberLeft && msg.Event.UserID == "alice" {
+				waitForClients(t, srv, "c1", 1)
+				return
+			}
+		case <-deadline:
+			t.Fatal("Silent client was never evicted")
+		}
+	}
+}
+
+// TestHeartbeatKeepsAnsweringClient verifies a client that answers the
+// pings outlives the timeout
+func TestHeartbeatKeepsAnsweringClient(t *testing.T) {
+	srv := newLimitTestService(t, testRateLimitConfig{})
+	srv.heartbeatCfg = testHeartbeatConfig{interval: 50 * time.Millisecond, timeout: 500 * time.Millisecond}
+	ws := dialWSTestServer(t, newWSTestServer(t, srv))
+
+	// Reading answers the pings; nothing else arrives.
+	ws.SetReadDeadline(time.Now().Add(time.Second))
+	var frame msgdomain.Message
+	if err := websocket.JSON.Receive(ws, &frame); err == nil {
+		t.Fatalf("Expected no frame, got %+v", frame)
+	}
+	ws.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	ping := msgdomain.Message{ID: "p1", Action: string(msgdomain.ActionPing)}
+	if err := websocket.JSON.Send(ws, ping); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if err := websocket.JSON.Receive(ws, &frame); err != nil {
+		t.Fatalf("Expected a pong, got %v", err)
+	}
+	if frame.Action != string(msgdomain.ActionPong) || frame.ID != "p1" {
+		t.Errorf("Expected a pong for p1, got %+v", frame)
+	}
+}
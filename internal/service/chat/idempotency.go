@@ -0,0 +1,68 @@
+package chatsrv
+
+import (
+	idempotencydomain "chatsrv/internal/domain/idempotency"
+	"chatsrv/internal/token"
+	"context"
+	"fmt"
+
+	"go.uber.org/zap"
+)
+
+// idempotent runs create at most once per scope, user and key. A retry of
+// the same request returns the ID create produced the first time, with
+// replayed set. request identifies the request so that a key reused for a
+// different one is rejected.
+func (c *chatService) idempotent(ctx context.Context, scope string, userID string, key string, request string, create func() (string, error)) (string, bool, error) {
+	if len(key) > idempotencydomain.MaxKeyLength {
+		return "", false, fmt.Errorf("%w: key exceeds %d characters", idempotencydomain.ErrInvalidKey, idempotencydomain.MaxKeyLength)
+	}
+
+	record := &idempotencydomain.Record{
+		Scope:       scope,
+		UserID:      userID,
+		Key:         key,
+		RequestHash: token.Hash(request),
+	}
+	stored, reserved, err := c.idemRepo.Reserve(ctx, record, c.cfg.IdempotencyTTL())
+	if err != nil {
+		c.log.Error("Reserve idempotency key",
+			zap.Any("scope", scope),
+			zap.Any("user", userID),
+			zap.Error(err))
+		return "", false, err
+	}
+	if !reserved {
+		if stored.RequestHash != record.RequestHash {
+			return "", false, idempotencydomain.ErrKeyReused
+		}
+		if !stored.Done() {
+			return "", false, idempotencydomain.ErrInProgress
+		}
+		return stored.ResourceID, true, nil
+	}
+
+	id, err := create()
+	if err != nil {
+		// Let the client retry with the same key.
+		if relErr := c.idemRepo.Release(context.Background(), record); relErr != nil {
+			c.log.Error("Release idempotency key",
+				zap.Any("scope", scope),
+				zap.Any("user", userID),
+				zap.Error(relErr))
+		}
+		return "", false, err
+	}
+
+	record.ResourceID = id
+	if err := c.idemRepo.Complete(ctx, record); err != nil {
+		// The resource exists; a retry will be told the key is in progress
+		// until it expires rather than creating a duplicate.
+		c.log.Error("Complete idempotency key",
+			zap.Any("scope", scope),
+			zap.Any("user", userID),
+			zap.Error(err))
+	}
+
+	return id, false, nil
+}
@@ -0,0 +1,58 @@
+//go:build unix
+
+package chatsrv
+
+import (
+	chatdomain "chatsrv/internal/domain/chat"
+	"fmt"
+	"sync/atomic"
+	"syscall"
+	"testing"
+	"time"
+)
+
+// cpuTime returns the CPU time the process has used so far
+func cpuTime(tb testing.TB) time.Duration {
+	var usage syscall.Rusage
+	if err := syscall.Getrusage(syscall.RUSAGE_SELF, &usage); err != nil {
+		tb.Fatalf("Getrusage failed: %v", err)
+	}
+	return time.Duration(usage.Utime.Nano() + usage.Stime.Nano())
+}
+
+// openIdleRooms starts n rooms with a client each
+func openIdleRooms(srv *chatService, n int) {
+	var delivered atomic.Int64
+	for i := 0; i < n; i++ {
+		room := srv.openRoom(&chatdomain.Chat{ID: fmt.Sprintf("c%d", i)})
+		room.addClient(&countingClient{id: "alice", user: "alice", n: &delivered})
+	}
+}
+
+// TestIdleRoomsUseNoCPU verifies open rooms with nothing to do leave the
+// CPU alone
+func TestIdleRoomsUseNoCPU(t *testing.T) {
+	srv := newTestService(newMemoryStore())
+	openIdleRooms(srv, 100)
+
+	const idle = 200 * time.Millisecond
+	before := cpuTime(t)
+	time.Sleep(idle)
+	if used := cpuTime(t) - before; used > idle/4 {
+		t.Errorf("Expected idle rooms to use no CPU, used %v in %v", used, idle)
+	}
+}
+
+// BenchmarkIdleRooms reports the CPU time used per millisecond of wall
+// time while 100 rooms sit idle.
+func BenchmarkIdleRooms(b *testing.B) {
+	srv := newTestService(newMemoryStore())
+	openIdleRooms(srv, 100)
+
+	before := cpuTime(b)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		time.Sleep(time.Millisecond)
+	}
+	b.ReportMetric(float64(cpuTime(b)-before)/float64(b.N), "cpu-ns/op")
+}
@@ -0,0 +1,165 @@
+package chatsrv
+
+import (
+	chatdomain "chatsrv/internal/domain/chat"
+	invitedomain "chatsrv/internal/domain/invite"
+	userdomain "chatsrv/internal/domain/user"
+	"chatsrv/internal/token"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// CreateInvite implements service.ChatService.
+func (c *chatService) CreateInvite(ctx context.Context, userID string, chatID string, req invitedomain.CreateInviteRequest) (*invitedomain.Invite, error) {
+	chat, err := c.getChat(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	if chat.IsDirect() {
+		return nil, fmt.Errorf("%w: direct chats cannot have invites", invitedomain.ErrInvalidInvite)
+	}
+	actor, err := c.requireRole(ctx, chatID, userID, chatdomain.RoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.MaxUses < 0 {
+		return nil, fmt.Errorf("%w: max_uses must not be negative", invitedomain.ErrInvalidInvite)
+	}
+	role := req.Role
+	if role == "" {
+		role = chatdomain.RoleMember
+	}
+	if !role.Valid() || !actor.Role.Outranks(role) {
+		return nil, fmt.Errorf("%w: role %q cannot be granted by invite", invitedomain.ErrInvalidInvite, role)
+	}
+
+	invite := &invitedomain.Invite{
+		ID:        uuid.New().String(),
+		ChatID:    chatID,
+		CreatedBy: userID,
+		Role:      role,
+		MaxUses:   req.MaxUses,
+	}
+	if req.ExpiresIn != "" {
+		ttl, err := time.ParseDuration(req.ExpiresIn)
+		if err != nil || ttl <= 0 {
+			return nil, fmt.Errorf("%w: expires_in must be a positive duration", invitedomain.ErrInvalidInvite)
+		}
+		expiresAt := time.Now().Add(ttl)
+		invite.ExpiresAt = &expiresAt
+	}
+
+	tok, err := token.New()
+	if err != nil {
+		return nil, err
+	}
+	invite.TokenHash = token.Hash(tok)
+
+	if err := c.inviteRepo.CreateInvite(ctx, invite); err != nil {
+		c.log.Error("CreateInvite",
+			zap.Any("chat", chatID),
+			zap.Error(err))
+		return nil, err
+	}
+	invite.Token = tok
+
+	return invite, nil
+}
+
+// GetInvites implements service.ChatService.
+func (c *chatService) GetInvites(ctx context.Context, userID string, chatID string) ([]*invitedomain.Invite, error) {
+	if _, err := c.getChat(ctx, chatID); err != nil {
+		return nil, err
+	}
+	if _, err := c.requireRole(ctx, chatID, userID, chatdomain.RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	return c.inviteRepo.GetInvites(ctx, chatID)
+}
+
+// RevokeInvite implements service.ChatService.
+func (c *chatService) RevokeInvite(ctx context.Context, userID string, chatID string, inviteID string) error {
+	invite, err := c.inviteRepo.GetInvite(ctx, inviteID)
+	if err != nil {
+		return err
+	}
+	if invite.ChatID != chatID {
+		return invitedomain.ErrInviteNotFound
+	}
+	if _, err := c.requireRole(ctx, chatID, userID, chatdomain.RoleAdmin); err != nil {
+		return err
+	}
+
+	return c.inviteRepo.RevokeInvite(ctx, inviteID)
+}
+
+// AcceptInvite implements service.ChatService.
+func (c *chatService) AcceptInvite(ctx context.Context, userID string, tok string, meta invitedomain.AcceptMeta) (*chatdomain.Member, error) {
+	invite, err := c.inviteRepo.GetInviteByTokenHash(ctx, token.Hash(tok))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := c.getChat(ctx, invite.ChatID); err != nil {
+		return nil, err
+	}
+	if err := checkScope(ctx, invite.ChatID, userdomain.PermissionRead); err != nil {
+		return nil, err
+	}
+	if err := c.checkRestriction(ctx, invite.ChatID, userID, chatdomain.RestrictionBan); err != nil {
+		return nil, err
+	}
+
+	result := invitedomain.AcceptJoined
+	member, err := c.memberRepo.GetMember(ctx, invite.ChatID, userID)
+	switch {
+	case err == nil:
+		// Existing members keep their role and do not use up the invite.
+		result = invitedomain.AcceptAlreadyMember
+	case errors.Is(err, chatdomain.ErrNotMember):
+		if err := invite.Check(time.Now()); err != nil {
+			return nil, err
+		}
+		ok, err := c.inviteRepo.ConsumeInvite(ctx, invite, userID)
+		if err != nil {
+			c.log.Error("AcceptInvite consume",
+				zap.Any("invite", invite.ID),
+				zap.Any("user", userID),
+				zap.Error(err))
+			return nil, err
+		}
+		if !ok {
+			return nil, invitedomain.ErrInviteExhausted
+		}
+		member, err = c.memberRepo.GetMember(ctx, invite.ChatID, userID)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, err
+	}
+
+	err = c.inviteRepo.CreateAcceptance(ctx, &invitedomain.Acceptance{
+		InviteID:  invite.ID,
+		ChatID:    invite.ChatID,
+		UserID:    userID,
+		Result:    result,
+		IP:        meta.IP,
+		UserAgent: meta.UserAgent,
+	})
+	if err != nil {
+		c.log.Error("AcceptInvite audit",
+			zap.Any("invite", invite.ID),
+			zap.Any("user", userID),
+			zap.Error(err))
+		return nil, err
+	}
+
+	return member, nil
+}
@@ -0,0 +1,107 @@
+package chatsrv
+
+import (
+	chatdomain "chatsrv/internal/domain/chat"
+	invitedomain "chatsrv/internal/domain/invite"
+	"context"
+	"errors"
+	"testing"
+)
+
+// TestInviteUsageLimitAndAudit verifies invites grant their role, stop
+// working once used up and audit every acceptance
+func TestInviteUsageLimitAndAudit(t *testing.T) {
+	store := newMemoryStore()
+	srv := newTestService(store)
+	ctx := context.Background()
+
+	chat := &chatdomain.Chat{ID: "c1", Type: chatdomain.ChatTypeRoom, Visibility: chatdomain.VisibilityPrivate}
+	store.CreateChat(ctx, chat, "alice")
+
+	if _, err := srv.CreateInvite(ctx, "bob", "c1", invitedomain.CreateInviteRequest{}); !errors.Is(err, chatdomain.ErrForbidden) {
+		t.Errorf("Expected ErrForbidden for non-owner, got %v", err)
+	}
+	if _, err := srv.CreateInvite(ctx, "alice", "c1", invitedomain.CreateInviteRequest{Role: chatdomain.RoleOwner}); !errors.Is(err, invitedomain.ErrInvalidInvite) {
+		t.Errorf("Expected ErrInvalidInvite for owner role, got %v", err)
+	}
+
+	invite, err := srv.CreateInvite(ctx, "alice", "c1", invitedomain.CreateInviteRequest{MaxUses: 1, ExpiresIn: "1h"})
+	if err != nil {
+		t.Fatalf("CreateInvite failed: %v", err)
+	}
+	if invite.Token == "" || invite.TokenHash == invite.Token {
+		t.Fatal("Expected a token that is stored hashed")
+	}
+
+	member, err := srv.AcceptInvite(ctx, "bob", invite.Token, invitedomain.AcceptMeta{IP: "10.0.0.1"})
+	if err != nil {
+		t.Fatalf("AcceptInvite failed: %v", err)
+	}
+	if member.Role != chatdomain.RoleMember {
+		t.Errorf("Expected role member, got %s", member.Role)
+	}
+
+	// Re-accepting as an existing member does not use up the invite.
+	if _, err := srv.AcceptInvite(ctx, "bob", invite.Token, invitedomain.AcceptMeta{}); err != nil {
+		t.Errorf("Existing member should re-accept, got %v", err)
+	}
+	if _, err := srv.AcceptInvite(ctx, "carol", invite.Token, invitedomain.AcceptMeta{}); !errors.Is(err, invitedomain.ErrInviteExhausted) {
+		t.Errorf("Expected ErrInviteExhausted, got %v", err)
+	}
+
+	if len(store.acceptances) != 2 {
+		t.Fatalf("Expected 2 audited acceptances, got %d", len(store.acceptances))
+	}
+	if store.acceptances[0].Result != invitedomain.AcceptJoined || store.acceptances[0].IP != "10.0.0.1" {
+		t.Errorf("Unexpected audit record: %+v", store.acceptances[0])
+	}
+}
+
+// TestRevokedInviteIsRejected verifies revoked invites cannot be accepted
+func TestRevokedInviteIsRejected(t *testing.T) {
+	store := newMemoryStore()
+	srv := newTestService(store)
+	ctx := context.Background()
+
+	store.CreateChat(ctx, &chatdomain.Chat{ID: "c1", Visibility: chatdomain.VisibilityPrivate}, "alice")
+
+	invite, err := srv.CreateInvite(ctx, "alice", "c1", invitedomain.CreateInviteRequest{})
+	if err != nil {
+		t.Fatalf("CreateInvite failed: %v", err)
+	}
+	if err := srv.RevokeInvite(ctx, "alice", "c1", invite.ID); err != nil {
+		t.Fatalf("RevokeInvite failed: %v", err)
+	}
+
+	if _, err := srv.AcceptInvite(ctx, "bob", invite.Token, invitedomain.AcceptMeta{}); !errors.Is(err, invitedomain.ErrInviteRevoked) {
+		t.Errorf("Expected ErrInviteRevoked, got %v", err)
+	}
+}
+
+// TestBannedUserCannotAcceptInvite verifies a ban cannot be escaped through
+// an invite, and that the refused attempt does not use it up
+func TestBannedUserCannotAcceptInvite(t *testing.T) {
+	store := newMemoryStore()
+	srv := newTestService(store)
+	ctx := context.Background()
+
+	store.CreateChat(ctx, &chatdomain.Chat{ID: "c1", Visibility: chatdomain.VisibilityPrivate}, "alice")
+	if _, err := srv.Ban(ctx, "alice", "c1", chatdomain.ModerationRequest{UserID: "bob"}); err != nil {
+		t.Fatalf("Ban failed: %v", err)
+	}
+
+	invite, err := srv.CreateInvite(ctx, "alice", "c1", invitedomain.CreateInviteRequest{MaxUses: 1})
+	if err != nil {
+		t.Fatalf("CreateInvite failed: %v", err)
+	}
+
+	if _, err := srv.AcceptInvite(ctx, "bob", invite.Token, invitedomain.AcceptMeta{}); !errors.Is(err, chatdomain.ErrBanned) {
+		t.Errorf("Expected ErrBanned, got %v", err)
+	}
+	if _, err := store.GetMember(ctx, "c1", "bob"); !errors.Is(err, chatdomain.ErrNotMember) {
+		t.Errorf("Expected bob to stay out of the chat, got %v", err)
+	}
+	if _, err := srv.AcceptInvite(ctx, "carol", invite.Token, invitedomain.AcceptMeta{}); err != nil {
+		t.Errorf("Expected the invite to still be usable, got %v", err)
+	}
+}
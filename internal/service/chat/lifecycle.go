@@ -0,0 +1,182 @@
+package chatsrv
+
+import (
+	chatdomain "chatsrv/internal/domain/chat"
+	msgdomain "chatsrv/internal/domain/msg"
+	userdomain "chatsrv/internal/domain/user"
+	webhookdomain "chatsrv/internal/domain/webhook"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"go.uber.org/zap"
+)
+
+const (
+	maxChatTopicLength = 255
+	maxChatDescLength  = 4096
+)
+
+// GetChat implements service.ChatService.
+func (c *chatService) GetChat(ctx context.Context, userID string, chatID string) (*chatdomain.Chat, error) {
+	chat, err := c.getChat(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.authorize(ctx, chat, userID, userdomain.PermissionRead); err != nil {
+		return nil, err
+	}
+
+	// The live room shares its chat, so fill the counterpart on a copy.
+	cp := *chat
+	c.setCounterparts(ctx, userID, &cp)
+
+	return &cp, nil
+}
+
+// UpdateChat implements service.ChatService.
+func (c *chatService) UpdateChat(ctx context.Context, userID string, chatID string, req chatdomain.UpdateChatRequest) (*chatdomain.Chat, error) {
+	chat, err := c.manageableChat(ctx, userID, chatID, chatdomain.RoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+
+	updated := *chat
+	if req.Name != nil {
+		if updated.Name, err = chatdomain.ValidateName(*req.Name); err != nil {
+			return nil, err
+		}
+	}
+	updated.NameKey = ""
+	if c.cfg.UniqueNames() {
+		updated.NameKey = chatdomain.NameKey(updated.Name)
+	}
+	if req.Topic != nil {
+		updated.Topic = strings.TrimSpace(*req.Topic)
+		if utf8.RuneCountInString(updated.Topic) > maxChatTopicLength {
+			return nil, fmt.Errorf("%w: topic exceeds %d characters", chatdomain.ErrInvalidChat, maxChatTopicLength)
+		}
+	}
+	if req.Description != nil {
+		updated.Description = *req.Description
+		if utf8.RuneCountInString(updated.Description) > maxChatDescLength {
+			return nil, fmt.Errorf("%w: description exceeds %d characters", chatdomain.ErrInvalidChat, maxChatDescLength)
+		}
+	}
+
+	err = c.repo.UpdateChat(ctx, &updated)
+	if errors.Is(err, chatdomain.ErrChatNameTaken) {
+		return nil, err
+	}
+	if err != nil {
+		c.log.Error("UpdateChat",
+			zap.Any("chat", chatID),
+			zap.Error(err))
+		return nil, err
+	}
+
+	c.chatUpdated(ctx, userID, &updated)
+	return &updated, nil
+}
+
+// SetArchived implements service.ChatService.
+func (c *chatService) SetArchived(ctx context.Context, userID string, chatID string, archived bool) (*chatdomain.Chat, error) {
+	if _, err := c.manageableChat(ctx, userID, chatID, chatdomain.RoleAdmin); err != nil {
+		return nil, err
+	}
+
+	chat, err := c.repo.SetArchived(ctx, chatID, archived)
+	if err != nil {
+		c.log.Error("SetArchived",
+			zap.Any("chat", chatID),
+			zap.Any("archived", archived),
+			zap.Error(err))
+		return nil, err
+	}
+
+	c.chatUpdated(ctx, userID, chat)
+	return chat, nil
+}
+
+// DeleteChat implements service.ChatService.
+func (c *chatService) DeleteChat(ctx context.Context, userID string, chatID string) error {
+	chat, err := c.manageableChat(ctx, userID, chatID, chatdomain.RoleOwner)
+	if err != nil {
+		return err
+	}
+
+	if err := c.repo.DeleteChat(ctx, chatID); err != nil {
+		c.log.Error("DeleteChat",
+			zap.Any("chat", chatID),
+			zap.Error(err))
+		return err
+	}
+
+	deleted := systemMessage(chatID, msgdomain.SystemEvent{
+		Type:    msgdomain.EventChatDeleted,
+		ActorID: userID,
+		Chat:    chat,
+	})
+	c.closeRoom(chatID, deleted)
+	c.relay(deleted, "")
+
+	c.webhooks.Dispatch(ctx, webhookdomain.EventChatDeleted, chatID, chat)
+	return nil
+}
+
+// closeRoom stops the live room of chatID, if any, and sends msg to its
+// clients. The room is gone, so they are told directly instead of through a
+// broadcast, which would no longer find it.
+func (c *chatService) closeRoom(chatID string, msg msgdomain.Message) {
+	c.mutex.Lock()
+	live, ok := c.chats[chatID]
+	delete(c.chats, chatID)
+	c.mutex.Unlock()
+	if !ok {
+		return
+	}
+
+	f := newFrame(msg)
+	defer f.release()
+	for _, cl := range c.unsubscribe(chatID, live.close()) {
+		if err := cl.send(f); err != nil {
+			c.log.Error("DeleteChat notify",
+				zap.Any("client", cl.clientID()),
+				zap.Any("chat", chatID),
+				zap.Error(err))
+		}
+	}
+}
+
+// manageableChat returns chatID if it is a room userID holds at least min
+// in.
+func (c *chatService) manageableChat(ctx context.Context, userID string, chatID string, min chatdomain.Role) (*chatdomain.Chat, error) {
+	chat, err := c.getChat(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	if chat.IsDirect() {
+		return nil, fmt.Errorf("%w: direct chats cannot be changed", chatdomain.ErrInvalidChat)
+	}
+	if _, err := c.requireRole(ctx, chatID, userID, min); err != nil {
+		return nil, err
+	}
+	return chat, nil
+}
+
+// chatUpdated refreshes the live room of chat and tells its clients and
+// webhooks about the change.
+func (c *chatService) chatUpdated(ctx context.Context, userID string, chat *chatdomain.Chat) {
+	if live := c.room(chat.ID); live != nil {
+		live.setInfo(chat)
+	}
+
+	c.broadcastEvent(chat.ID, msgdomain.SystemEvent{
+		Type:    msgdomain.EventChatUpdated,
+		ActorID: userID,
+		Chat:    chat,
+	})
+	c.webhooks.Dispatch(ctx, webhookdomain.EventChatUpdated, chat.ID, chat)
+}
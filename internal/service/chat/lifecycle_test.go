@@ -0,0 +1,176 @@
+package chatsrv
+
+import (
+	chatdomain "chatsrv/internal/domain/chat"
+	idempotencydomain "chatsrv/internal/domain/idempotency"
+	msgdomain "chatsrv/internal/domain/msg"
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+)
+
+// TestUpdateChatRequiresAdmin verifies only admins and owners can edit a
+// room and that the live room sees the change
+func TestUpdateChatRequiresAdmin(t *testing.T) {
+	store := newMemoryStore()
+	srv := newTestService(store)
+	ctx := context.Background()
+
+	chat := &chatdomain.Chat{ID: "c1", Name: "general", Type: chatdomain.ChatTypeRoom, Visibility: chatdomain.VisibilityPublic}
+	if err := store.CreateChat(ctx, chat, "alice"); err != nil {
+		t.Fatalf("CreateChat failed: %v", err)
+	}
+	store.AddMember(ctx, "c1", "bob", chatdomain.RoleMember)
+	srv.openRoom(chat)
+
+	topic := "release planning"
+	if _, err := srv.UpdateChat(ctx, "bob", "c1", chatdomain.UpdateChatRequest{Topic: &topic}); !errors.Is(err, chatdomain.ErrForbidden) {
+		t.Errorf("Expected ErrForbidden for member, got %v", err)
+	}
+	blank := "  "
+	if _, err := srv.UpdateChat(ctx, "alice", "c1", chatdomain.UpdateChatRequest{Name: &blank}); !errors.Is(err, chatdomain.ErrInvalidChat) {
+		t.Errorf("Expected ErrInvalidChat for blank name, got %v", err)
+	}
+
+	updated, err := srv.UpdateChat(ctx, "alice", "c1", chatdomain.UpdateChatRequest{Topic: &topic})
+	if err != nil {
+		t.Fatalf("UpdateChat failed: %v", err)
+	}
+	if updated.Name != "general" || updated.Topic != topic {
+		t.Errorf("Expected name kept and topic set, got %+v", updated)
+	}
+	if got := srv.chats["c1"].info().Topic; got != topic {
+		t.Errorf("Expected live room topic %q, got %q", topic, got)
+	}
+}
+
+// TestArchivedChatIsReadOnly verifies archived rooms reject new messages
+// until they are unarchived
+func TestArchivedChatIsReadOnly(t *testing.T) {
+	store := newMemoryStore()
+	srv := newTestService(store)
+	ctx := context.Background()
+
+	chat := &chatdomain.Chat{ID: "c1", Type: chatdomain.ChatTypeRoom, Visibility: chatdomain.VisibilityPublic}
+	if err := store.CreateChat(ctx, chat, "alice"); err != nil {
+		t.Fatalf("CreateChat failed: %v", err)
+	}
+
+	archived, err := srv.SetArchived(ctx, "alice", "c1", true)
+	if err != nil || !archived.IsArchived() {
+		t.Fatalf("SetArchived failed: %+v %v", archived, err)
+	}
+	if _, err := srv.PostMessage(ctx, msgdomain.Message{ChatID: "c1", Content: "hi"}); !errors.Is(err, chatdomain.ErrChatArchived) {
+		t.Errorf("Expected ErrChatArchived, got %v", err)
+	}
+
+	if _, err := srv.SetArchived(ctx, "alice", "c1", false); err != nil {
+		t.Fatalf("Unarchive failed: %v", err)
+	}
+	if _, err := srv.PostMessage(ctx, msgdomain.Message{ChatID: "c1", Content: "hi"}); err != nil {
+		t.Errorf("Expected post to succeed after unarchive, got %v", err)
+	}
+}
+
+// TestDeleteChatTearsDownRoom verifies only the owner can delete a room and
+// the live room is closed
+func TestDeleteChatTearsDownRoom(t *testing.T) {
+	store := newMemoryStore()
+	srv := newTestService(store)
+	ctx := context.Background()
+
+	chat := &chatdomain.Chat{ID: "c1", Type: chatdomain.ChatTypeRoom, Visibility: chatdomain.VisibilityPublic}
+	if err := store.CreateChat(ctx, chat, "alice"); err != nil {
+		t.Fatalf("CreateChat failed: %v", err)
+	}
+	store.AddMember(ctx, "c1", "bob", chatdomain.RoleAdmin)
+	live := srv.openRoom(chat)
+
+	if err := srv.DeleteChat(ctx, "bob", "c1"); !errors.Is(err, chatdomain.ErrForbidden) {
+		t.Errorf("Expected ErrForbidden for admin, got %v", err)
+	}
+	if err := srv.DeleteChat(ctx, "alice", "c1"); err != nil {
+		t.Fatalf("DeleteChat failed: %v", err)
+	}
+
+	if _, ok := srv.chats["c1"]; ok {
+		t.Error("Expected live room to be removed")
+	}
+	if _, err := live.addClient(&countingClient{id: "carol", user: "carol", n: new(atomic.Int64)}); !errors.Is(err, errRoomClosed) {
+		t.Errorf("Expected closed room to refuse clients, got %v", err)
+	}
+	if _, err := srv.GetChat(ctx, "alice", "c1"); !errors.Is(err, chatdomain.ErrChatNotFound) {
+		t.Errorf("Expected ErrChatNotFound after delete, got %v", err)
+	}
+}
+
+// TestCreateChatReturnsChat verifies the created chat is returned and its
+// creator owns it
+func TestCreateChatReturnsChat(t *testing.T) {
+	store := newMemoryStore()
+	srv := newTestService(store)
+	ctx := context.Background()
+
+	chat, err := srv.CreateChat(ctx, "alice", chatdomain.CreateChatRequest{Name: " general "})
+	if err != nil {
+		t.Fatalf("CreateChat failed: %v", err)
+	}
+	if chat == nil || chat.ID == "" || chat.Name != "general" || chat.CreatedAt.IsZero() {
+		t.Fatalf("Expected created chat with ID and created_at, got %+v", chat)
+	}
+	if member, err := store.GetMember(ctx, chat.ID, "alice"); err != nil || member.Role != chatdomain.RoleOwner {
+		t.Errorf("Expected alice to own the chat, got %+v %v", member, err)
+	}
+	if _, err := srv.CreateChat(ctx, "alice", chatdomain.CreateChatRequest{Name: "a/b"}); !errors.Is(err, chatdomain.ErrInvalidChat) {
+		t.Errorf("Expected ErrInvalidChat for bad name, got %v", err)
+	}
+}
+
+// TestCreateChatUniqueNames verifies names clash ignoring case when unique
+// names are enabled
+func TestCreateChatUniqueNames(t *testing.T) {
+	store := newMemoryStore()
+	srv := newTestService(store)
+	srv.cfg = testChatConfig{uniqueNames: true}
+	ctx := context.Background()
+
+	if _, err := srv.CreateChat(ctx, "alice", chatdomain.CreateChatRequest{Name: "General"}); err != nil {
+		t.Fatalf("CreateChat failed: %v", err)
+	}
+	if _, err := srv.CreateChat(ctx, "bob", chatdomain.CreateChatRequest{Name: "general"}); !errors.Is(err, chatdomain.ErrChatNameTaken) {
+		t.Errorf("Expected ErrChatNameTaken, got %v", err)
+	}
+}
+
+// TestCreateChatIdempotencyKey verifies a retried request returns the first
+// chat and a reused key with another body is rejected
+func TestCreateChatIdempotencyKey(t *testing.T) {
+	store := newMemoryStore()
+	srv := newTestService(store)
+	ctx := context.Background()
+
+	req := chatdomain.CreateChatRequest{Name: "general", IdempotencyKey: "k1"}
+	first, err := srv.CreateChat(ctx, "alice", req)
+	if err != nil {
+		t.Fatalf("CreateChat failed: %v", err)
+	}
+	retry, err := srv.CreateChat(ctx, "alice", req)
+	if err != nil {
+		t.Fatalf("Retry failed: %v", err)
+	}
+	if retry.ID != first.ID {
+		t.Errorf("Expected retry to return chat %s, got %s", first.ID, retry.ID)
+	}
+	if len(store.chats) != 1 {
+		t.Errorf("Expected 1 chat, got %d", len(store.chats))
+	}
+
+	req.Name = "random"
+	if _, err := srv.CreateChat(ctx, "alice", req); !errors.Is(err, idempotencydomain.ErrKeyReused) {
+		t.Errorf("Expected ErrKeyReused, got %v", err)
+	}
+	if _, err := srv.CreateChat(ctx, "bob", req); err != nil {
+		t.Errorf("Keys should be scoped per user, got %v", err)
+	}
+}
@@ -0,0 +1,156 @@
+package chatsrv
+
+import (
+	"chatsrv/internal/config"
+	msgdomain "chatsrv/internal/domain/msg"
+	"chatsrv/internal/ratelimit"
+	"chatsrv/internal/wsutil"
+	"expvar"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/net/websocket"
+)
+
+// limitKind names a budget of WebSocket actions.
+type limitKind string
+
+const (
+	limitMessage limitKind = "message"
+	limitJoin    limitKind = "join"
+	limitTyping  limitKind = "typing"
+)
+
+var limitKinds = []limitKind{limitMessage, limitJoin, limitTyping}
+
+// limitStats counts refused actions as <kind>_denied_connection and
+// <kind>_denied_user, and the connections closed for abuse as disconnects.
+// They are published under /debug/vars.
+var limitStats = expvar.NewMap("ws_rate_limit")
+
+// limitKindOf returns the budget action is charged to.
+func limitKindOf(action string) (limitKind, bool) {
+	switch action {
+	case string(msgdomain.ActionSendText), string(msgdomain.ActionSendBinary):
+		return limitMessage, true
+	case string(msgdomain.ActionJoinChat):
+		return limitJoin, true
+	case string(msgdomain.ActionTyping):
+		return limitTyping, true
+	default:
+		return "", false
+	}
+}
+
+// limitsOf returns the per-connection and per-user budgets of kind.
+func (s *chatService) limitsOf(kind limitKind) (config.RateLimit, config.RateLimit) {
+	switch kind {
+	case limitJoin:
+		return s.limitCfg.JoinLimit(), s.limitCfg.UserJoinLimit()
+	case limitTyping:
+		return s.limitCfg.TypingLimit(), s.limitCfg.UserTypingLimit()
+	default:
+		return s.limitCfg.MessageLimit(), s.limitCfg.UserMessageLimit()
+	}
+}
+
+// checkLimits charges msg to the budgets of its connection and its sender.
+// A refused action is answered with an error frame, and a connection that
+// keeps being refused is closed.
+func (s *chatService) checkLimits(ws *websocket.Conn, msg msgdomain.Message) error {
+	kind, ok := limitKindOf(msg.Action)
+	if !ok {
+		return nil
+	}
+	connLimit, userLimit := s.limitsOf(kind)
+
+	c := s.connState(ws)
+	if c != nil {
+		if ok, wait := allow(s.connLimits, c.id+":"+string(kind), connLimit); !ok {
+			limitStats.Add(string(kind)+"_denied_connection", 1)
+			return s.refuse(ws, c, msg, wait)
+		}
+	}
+	if msg.SenderID != "" {
+		if ok, wait := allow(s.userLimits, msg.SenderID+":"+string(kind), userLimit); !ok {
+			limitStats.Add(string(kind)+"_denied_user", 1)
+			return s.refuse(ws, c, msg, wait)
+		}
+	}
+
+	return nil
+}
+
+// checkUserLimit charges userID's budget of kind for an action that did not
+// come over a WebSocket, such as a message posted over HTTP.
+func (s *chatService) checkUserLimit(userID string, kind limitKind, action string) error {
+	_, userLimit := s.limitsOf(kind)
+	if ok, wait := allow(s.userLimits, userID+":"+string(kind), userLimit); !ok {
+		limitStats.Add(string(kind)+"_denied_user", 1)
+		return &msgdomain.RateLimitError{Action: action, RetryAfter: wait}
+	}
+	return nil
+}
+
+// allow takes a token for key from limiter. Disabled limits allow everything.
+func allow(limiter *ratelimit.Limiter, key string, limit config.RateLimit) (bool, time.Duration) {
+	if limit.PerMinute <= 0 {
+		return true, 0
+	}
+	return limiter.Allow(key, float64(limit.PerMinute)/60, max(limit.Burst, 1))
+}
+
+// refuse tells the client msg was rate limited and closes the connection
+// once it has been refused more than MaxViolations times within the window.
+func (s *chatService) refuse(ws *websocket.Conn, c *conn, msg msgdomain.Message, wait time.Duration) error {
+	retryAfter := wait.Milliseconds()
+	if retryAfter < 1 {
+		retryAfter = 1
+	}
+	frame := msgdomain.Message{
+		Action: string(msgdomain.ActionError),
+		ChatID: msg.ChatID,
+		Error: &msgdomain.Error{
+			Code:         msgdomain.ErrorCodeRateLimited,
+			Message:      "too many " + msg.Action + " actions, slow down",
+			Action:       msg.Action,
+			RetryAfterMs: retryAfter,
+		},
+	}
+	// The frame skips the queue so that it goes out before a close.
+	f := newFrame(frame)
+	defer f.release()
+	w := wsutil.NewWriter(ws)
+	if c != nil {
+		w = c.w
+	}
+	if err := s.writeFrame(w, f); err != nil {
+		s.log.Debug("Rate limit error frame",
+			zap.Any("user", msg.SenderID),
+			zap.Error(err))
+	}
+
+	if c != nil && s.limitCfg.MaxViolations() > 0 &&
+		c.violate(s.limitCfg.ViolationWindow()) > s.limitCfg.MaxViolations() {
+		limitStats.Add("disconnects", 1)
+		s.log.Info("Disconnecting client over rate limit",
+			zap.Any("user", c.user),
+			zap.Any("conn", c.id))
+		if err := c.w.Close(wsutil.ClosePolicyViolation, "rate limit exceeded"); err != nil {
+			s.log.Debug("Rate limit close",
+				zap.Any("conn", c.id),
+				zap.Error(err))
+		}
+	}
+
+	return &msgdomain.RateLimitError{Action: msg.Action, RetryAfter: wait}
+}
+
+// forgetLimits drops the buckets of c. Those of its user are left to
+// expire once idle, since they also budget the other transports and a
+// reconnect must not refill them.
+func (s *chatService) forgetLimits(c *conn) {
+	for _, kind := range limitKinds {
+		s.connLimits.Forget(c.id + ":" + string(kind))
+	}
+}
@@ -0,0 +1,132 @@
+package chatsrv
+
+import (
+	"chatsrv/internal/broker"
+	"chatsrv/internal/config"
+	chatdomain "chatsrv/internal/domain/chat"
+	msgdomain "chatsrv/internal/domain/msg"
+	"chatsrv/internal/presence"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/net/websocket"
+)
+
+func newLimitTestService(t *testing.T, limits testRateLimitConfig) *chatService {
+	store := newMemoryStore()
+	chat := &chatdomain.Chat{ID: "c1", Type: chatdomain.ChatTypeRoom, Visibility: chatdomain.VisibilityPublic}
+	if err := store.CreateChat(context.Background(), chat, "alice"); err != nil {
+		t.Fatalf("CreateChat failed: %v", err)
+	}
+	return NewChatService(store, store, store, store, store, store, store, nopWebhooks{}, broker.NewMemory(), presence.NewMemory(), testChatConfig{}, limits, testHeartbeatConfig{}, testOutboundConfig{}, testPresenceConfig{}, zap.NewNop()).(*chatService)
+}
+
+// TestConnectionRateLimit verifies messages past the burst are answered
+// with an error frame and repeated abuse closes the connection
+func TestConnectionRateLimit(t *testing.T) {
+	srv := newLimitTestService(t, testRateLimitConfig{
+		message:       config.RateLimit{PerMinute: 1, Burst: 2},
+		maxViolations: 1,
+	})
+	ws := dialWSTestServer(t, newWSTestServer(t, srv))
+
+	send := msgdomain.Message{Action: string(msgdomain.ActionSendText), ChatID: "c1", Content: "hi"}
+	for i := 0; i < 3; i++ {
+		if err := websocket.JSON.Send(ws, send); err != nil {
+			t.Fatalf("Send failed: %v", err)
+		}
+	}
+
+	var frame msgdomain.Message
+	if err := websocket.JSON.Receive(ws, &frame); err != nil {
+		t.Fatalf("Receive failed: %v", err)
+	}
+	if frame.Action != string(msgdomain.ActionError) || frame.Error == nil ||
+		frame.Error.Code != msgdomain.ErrorCodeRateLimited || frame.Error.RetryAfterMs <= 0 {
+		t.Fatalf("Expected a rate_limited error frame with a retry-after hint, got %+v", frame)
+	}
+	if limitStats.Get("message_denied_connection") == nil {
+		t.Error("Expected the refusal to be counted")
+	}
+
+	// The second refusal is over the limit of one violation.
+	if err := websocket.JSON.Send(ws, send); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	if err := websocket.JSON.Receive(ws, &frame); err != nil || frame.Action != string(msgdomain.ActionError) {
+		t.Fatalf("Expected a second error frame, got %+v, %v", frame, err)
+	}
+	if err := websocket.JSON.Receive(ws, &frame); err == nil {
+		t.Errorf("Expected the connection to be closed, got %+v", frame)
+	}
+}
+
+// TestUserRateLimitSpansConnections verifies the per-user budget is shared
+// by all connections of the user
+func TestUserRateLimitSpansConnections(t *testing.T) {
+	srv := newLimitTestService(t, testRateLimitConfig{
+		userMessage: config.RateLimit{PerMinute: 1, Burst: 1},
+	})
+	url := newWSTestServer(t, srv)
+	first := dialWSTestServer(t, url)
+	second := dialWSTestServer(t, url)
+
+	send := msgdomain.Message{Action: string(msgdomain.ActionSendText), ChatID: "c1", Content: "hi"}
+	if err := websocket.JSON.Send(first, send); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	// Wait for the first message to be stored so it is charged first.
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(10 * time.Millisecond) {
+		msgs, err := srv.GetMessages(context.Background(), "alice", "c1", time.Time{}, 0)
+		if err == nil && len(msgs) == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("First message was not stored: %v", err)
+		}
+	}
+	if err := websocket.JSON.Send(second, send); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	var frame msgdomain.Message
+	if err := websocket.JSON.Receive(second, &frame); err != nil {
+		t.Fatalf("Receive failed: %v", err)
+	}
+	if frame.Error == nil || frame.Error.Code != msgdomain.ErrorCodeRateLimited {
+		t.Errorf("Expected the second connection to be rate limited, got %+v", frame)
+	}
+}
+
+// TestUserRateLimitSurvivesReconnect verifies closing the last connection
+// does not refill the per-user budget, which HTTP posts share
+func TestUserRateLimitSurvivesReconnect(t *testing.T) {
+	srv := newLimitTestService(t, testRateLimitConfig{
+		userMessage: config.RateLimit{PerMinute: 1, Burst: 1},
+	})
+	url := newWSTestServer(t, srv)
+
+	req := msgdomain.SendMessageRequest{Content: "hi"}
+	if _, err := srv.SendMessage(context.Background(), "alice", "c1", req); err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+
+	ws := dialWSTestServer(t, url)
+	if err := websocket.JSON.Send(ws, msgdomain.Message{Action: string(msgdomain.ActionJoinChat), ChatID: "c1"}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	waitForClients(t, srv, "c1", 1)
+	ws.Close()
+	for deadline := time.Now().Add(5 * time.Second); srv.room("c1") != nil; time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("The connection was never dropped")
+		}
+	}
+
+	if _, err := srv.SendMessage(context.Background(), "alice", "c1", req); !errors.Is(err, msgdomain.ErrRateLimited) {
+		t.Errorf("Expected the budget to stay spent after a reconnect, got %v", err)
+	}
+}
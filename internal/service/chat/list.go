@@ -0,0 +1,92 @@
+package chatsrv
+
+import (
+	chatdomain "chatsrv/internal/domain/chat"
+	userdomain "chatsrv/internal/domain/user"
+	"context"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	defaultChatListLimit = 50
+	maxChatListLimit     = 200
+
+	// maxPreviewLength is the number of characters of the last message
+	// shown in the chat list.
+	maxPreviewLength = 100
+)
+
+// ListChats implements service.ChatService.
+func (c *chatService) ListChats(ctx context.Context, req chatdomain.ListChatsRequest) (*chatdomain.ChatPage, error) {
+	if err := checkScope(ctx, "", userdomain.PermissionRead); err != nil {
+		return nil, err
+	}
+	if req.Sort == "" {
+		req.Sort = chatdomain.SortActivity
+	}
+	if req.Archived == "" {
+		req.Archived = chatdomain.ArchivedExclude
+	}
+	if req.Limit <= 0 {
+		req.Limit = defaultChatListLimit
+	}
+	if req.Limit > maxChatListLimit {
+		req.Limit = maxChatListLimit
+	}
+	limit := req.Limit
+
+	// One extra row tells whether there is a next page.
+	req.Limit++
+	chats, err := c.repo.ListChats(ctx, req)
+	if err != nil {
+		c.log.Error("ListChats",
+			zap.Any("user", req.UserID),
+			zap.Error(err))
+		return nil, err
+	}
+
+	page := &chatdomain.ChatPage{Chats: chats}
+	if len(chats) > limit {
+		page.Chats = chats[:limit]
+		page.NextCursor = chatdomain.CursorAfter(req.Sort, &page.Chats[limit-1].Chat).Encode()
+	}
+	if page.Chats == nil {
+		page.Chats = []*chatdomain.ChatSummary{}
+	}
+
+	infos := make([]*chatdomain.Chat, len(page.Chats))
+	for i, chat := range page.Chats {
+		infos[i] = &chat.Chat
+		if chat.LastMessage != nil {
+			chat.LastMessage.Content = truncate(chat.LastMessage.Content, maxPreviewLength)
+		}
+	}
+
+	c.setCounterparts(ctx, req.UserID, infos...)
+
+	return page, nil
+}
+
+// MarkRead implements service.ChatService.
+func (c *chatService) MarkRead(ctx context.Context, userID string, chatID string) error {
+	chat, err := c.getChat(ctx, chatID)
+	if err != nil {
+		return err
+	}
+	if err := c.authorize(ctx, chat, userID, userdomain.PermissionRead); err != nil {
+		return err
+	}
+
+	return c.memberRepo.MarkRead(ctx, chatID, userID, time.Now())
+}
+
+// truncate shortens s to at most n characters.
+func truncate(s string, n int) string {
+	runes := []rune(s)
+	if len(runes) <= n {
+		return s
+	}
+	return string(runes[:n])
+}
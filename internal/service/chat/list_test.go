@@ -0,0 +1,96 @@
+package chatsrv
+
+import (
+	chatdomain "chatsrv/internal/domain/chat"
+	msgdomain "chatsrv/internal/domain/msg"
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TestListChatsPaginates verifies chats are listed by activity across pages
+// without gaps or repeats
+func TestListChatsPaginates(t *testing.T) {
+	store := newMemoryStore()
+	srv := newTestService(store)
+	ctx := context.Background()
+
+	for i := 0; i < 5; i++ {
+		chat := &chatdomain.Chat{ID: fmt.Sprintf("c%d", i), Name: fmt.Sprintf("room %d", i), Type: chatdomain.ChatTypeRoom, Visibility: chatdomain.VisibilityPublic}
+		if err := store.CreateChat(ctx, chat, "alice"); err != nil {
+			t.Fatalf("CreateChat failed: %v", err)
+		}
+	}
+	// c1 becomes the most recently active chat.
+	store.CreateMessage(ctx, &msgdomain.Message{ID: "m1", ChatID: "c1", SenderID: "alice", Content: "hi"})
+
+	var got []string
+	req := chatdomain.ListChatsRequest{UserID: "alice", Limit: 2}
+	for page := 0; ; page++ {
+		if page > 5 {
+			t.Fatalf("Pagination did not terminate")
+		}
+		res, err := srv.ListChats(ctx, req)
+		if err != nil {
+			t.Fatalf("ListChats failed: %v", err)
+		}
+		for _, chat := range res.Chats {
+			got = append(got, chat.ID)
+		}
+		if res.NextCursor == "" {
+			break
+		}
+		if req.Cursor, err = chatdomain.DecodeCursor(res.NextCursor, chatdomain.SortActivity); err != nil {
+			t.Fatalf("DecodeCursor failed: %v", err)
+		}
+	}
+
+	if want := "c1,c4,c3,c2,c0"; strings.Join(got, ",") != want {
+		t.Errorf("Expected order %s, got %s", want, strings.Join(got, ","))
+	}
+}
+
+// TestListChatsUnreadAndPreview verifies the last message preview and that
+// unread counts reset once the chat is marked read
+func TestListChatsUnreadAndPreview(t *testing.T) {
+	store := newMemoryStore()
+	srv := newTestService(store)
+	ctx := context.Background()
+
+	chat := &chatdomain.Chat{ID: "c1", Name: "general", Type: chatdomain.ChatTypeRoom, Visibility: chatdomain.VisibilityPublic}
+	if err := store.CreateChat(ctx, chat, "alice"); err != nil {
+		t.Fatalf("CreateChat failed: %v", err)
+	}
+	store.AddMember(ctx, "c1", "bob", chatdomain.RoleMember)
+	store.CreateMessage(ctx, &msgdomain.Message{ID: "m1", ChatID: "c1", SenderID: "alice", Content: "hello"})
+	store.CreateMessage(ctx, &msgdomain.Message{ID: "m2", ChatID: "c1", SenderID: "alice", Content: strings.Repeat("x", 300)})
+
+	page, err := srv.ListChats(ctx, chatdomain.ListChatsRequest{UserID: "bob"})
+	if err != nil || len(page.Chats) != 1 {
+		t.Fatalf("Expected one chat, got %v, %v", page, err)
+	}
+	summary := page.Chats[0]
+	if summary.UnreadCount != 2 || summary.MemberCount != 2 {
+		t.Errorf("Expected 2 unread and 2 members, got %d and %d", summary.UnreadCount, summary.MemberCount)
+	}
+	if summary.LastMessage == nil || summary.LastMessage.ID != "m2" || len(summary.LastMessage.Content) != maxPreviewLength {
+		t.Errorf("Expected truncated preview of m2, got %+v", summary.LastMessage)
+	}
+
+	if err := srv.MarkRead(ctx, "bob", "c1"); err != nil {
+		t.Fatalf("MarkRead failed: %v", err)
+	}
+	page, _ = srv.ListChats(ctx, chatdomain.ListChatsRequest{UserID: "bob"})
+	if page.Chats[0].UnreadCount != 0 {
+		t.Errorf("Expected no unread after MarkRead, got %d", page.Chats[0].UnreadCount)
+	}
+
+	page, _ = srv.ListChats(ctx, chatdomain.ListChatsRequest{UserID: "carol", Mine: true})
+	if len(page.Chats) != 0 {
+		t.Errorf("Expected no chats for a non-member with mine, got %d", len(page.Chats))
+	}
+	if err := srv.MarkRead(ctx, "carol", "c1"); err == nil {
+		t.Errorf("Expected non-member MarkRead to fail")
+	}
+}
@@ -0,0 +1,250 @@
+package chatsrv
+
+import (
+	chatdomain "chatsrv/internal/domain/chat"
+	msgdomain "chatsrv/internal/domain/msg"
+	userdomain "chatsrv/internal/domain/user"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	defaultHistoryLimit = 50
+	maxHistoryLimit     = 200
+)
+
+// getChat returns the chat from the live rooms if it is active, falling
+// back to the repository.
+func (c *chatService) getChat(ctx context.Context, chatID string) (*chatdomain.Chat, error) {
+	if chat := c.room(chatID); chat != nil {
+		return chat.info(), nil
+	}
+	return c.repo.GetChat(ctx, chatID)
+}
+
+// authorize checks that userID may use perm, read or post, in chat. Public
+// chats are open to everyone, private ones only to their members. Banned
+// users are shut out of both.
+func (c *chatService) authorize(ctx context.Context, chat *chatdomain.Chat, userID string, perm userdomain.Permission) error {
+	if err := checkScope(ctx, chat.ID, perm); err != nil {
+		return err
+	}
+	if userID != "" {
+		if err := c.checkRestriction(ctx, chat.ID, userID, chatdomain.RestrictionBan); err != nil {
+			return err
+		}
+	}
+	if !chat.IsPrivate() {
+		return nil
+	}
+	if userID == "" {
+		return chatdomain.ErrNotMember
+	}
+	_, err := c.memberRepo.GetMember(ctx, chat.ID, userID)
+	return err
+}
+
+// roleOf returns the role of userID in chatID. Users that are not members
+// rank as plain members.
+func (c *chatService) roleOf(ctx context.Context, chatID string, userID string) (chatdomain.Role, error) {
+	member, err := c.memberRepo.GetMember(ctx, chatID, userID)
+	if errors.Is(err, chatdomain.ErrNotMember) {
+		return chatdomain.RoleMember, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return member.Role, nil
+}
+
+// requireRole checks that userID is a member of chatID with at least role
+// min and returns that membership.
+func (c *chatService) requireRole(ctx context.Context, chatID string, userID string, min chatdomain.Role) (*chatdomain.Member, error) {
+	if err := checkScope(ctx, chatID, userdomain.PermissionManage); err != nil {
+		return nil, err
+	}
+	member, err := c.memberRepo.GetMember(ctx, chatID, userID)
+	if errors.Is(err, chatdomain.ErrNotMember) {
+		return nil, chatdomain.ErrForbidden
+	}
+	if err != nil {
+		return nil, err
+	}
+	if !member.Role.AtLeast(min) {
+		return nil, chatdomain.ErrForbidden
+	}
+	return member, nil
+}
+
+// GetMembers implements service.ChatService.
+func (c *chatService) GetMembers(ctx context.Context, userID string, chatID string) ([]*chatdomain.Member, error) {
+	chat, err := c.getChat(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.authorize(ctx, chat, userID, userdomain.PermissionRead); err != nil {
+		return nil, err
+	}
+
+	members, err := c.memberRepo.GetMembers(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	return c.withPresence(ctx, members), nil
+}
+
+// AddMember implements service.ChatService.
+func (c *chatService) AddMember(ctx context.Context, userID string, chatID string, req chatdomain.AddMemberRequest) (*chatdomain.Member, error) {
+	chat, err := c.getChat(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	if chat.IsDirect() {
+		return nil, fmt.Errorf("%w: direct chats have a fixed pair of members", chatdomain.ErrInvalidMember)
+	}
+	if req.UserID == "" {
+		return nil, fmt.Errorf("%w: user_id is required", chatdomain.ErrInvalidMember)
+	}
+	role := req.Role
+	if role == "" {
+		role = chatdomain.RoleMember
+	}
+	if !role.Valid() {
+		return nil, fmt.Errorf("%w: unknown role %q", chatdomain.ErrInvalidMember, role)
+	}
+
+	actor, err := c.requireRole(ctx, chatID, userID, chatdomain.RoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+	if !actor.Role.Outranks(role) {
+		return nil, chatdomain.ErrForbidden
+	}
+
+	if err := c.memberRepo.AddMember(ctx, chatID, req.UserID, role); err != nil {
+		c.log.Error("AddMember",
+			zap.Any("chat", chatID),
+			zap.Any("member", req.UserID),
+			zap.Error(err))
+		return nil, err
+	}
+
+	return c.memberRepo.GetMember(ctx, chatID, req.UserID)
+}
+
+// RemoveMember implements service.ChatService.
+// Members may remove themselves; admins and owners may remove anyone they
+// outrank. The owner cannot be removed.
+func (c *chatService) RemoveMember(ctx context.Context, userID string, chatID string, memberID string) error {
+	chat, err := c.getChat(ctx, chatID)
+	if err != nil {
+		return err
+	}
+	if chat.IsDirect() {
+		return fmt.Errorf("%w: direct chats have a fixed pair of members", chatdomain.ErrInvalidMember)
+	}
+
+	member, err := c.memberRepo.GetMember(ctx, chatID, memberID)
+	if err != nil {
+		return err
+	}
+	if member.Role == chatdomain.RoleOwner {
+		return chatdomain.ErrForbidden
+	}
+	if userID != memberID {
+		actor, err := c.requireRole(ctx, chatID, userID, chatdomain.RoleAdmin)
+		if err != nil {
+			return err
+		}
+		if !actor.Role.Outranks(member.Role) {
+			return chatdomain.ErrForbidden
+		}
+	}
+
+	if err := c.memberRepo.RemoveMember(ctx, chatID, memberID); err != nil {
+		return err
+	}
+
+	// A removed member must not keep receiving the chat's messages, on any
+	// node.
+	c.expel(chatID, msgdomain.SystemEvent{
+		Type:    msgdomain.EventMemberRemoved,
+		UserID:  memberID,
+		ActorID: userID,
+	})
+
+	return nil
+}
+
+// UpdateMemberRole implements service.ChatService.
+// Admins and owners may change the role of members they outrank, to a role
+// below their own.
+func (c *chatService) UpdateMemberRole(ctx context.Context, userID string, chatID string, memberID string, req chatdomain.UpdateMemberRequest) (*chatdomain.Member, error) {
+	chat, err := c.getChat(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	if chat.IsDirect() {
+		return nil, fmt.Errorf("%w: direct chats have no roles", chatdomain.ErrInvalidMember)
+	}
+	if !req.Role.Valid() {
+		return nil, fmt.Errorf("%w: unknown role %q", chatdomain.ErrInvalidMember, req.Role)
+	}
+
+	actor, err := c.requireRole(ctx, chatID, userID, chatdomain.RoleAdmin)
+	if err != nil {
+		return nil, err
+	}
+	member, err := c.memberRepo.GetMember(ctx, chatID, memberID)
+	if err != nil {
+		return nil, err
+	}
+	if !actor.Role.Outranks(member.Role) || !actor.Role.Outranks(req.Role) {
+		return nil, chatdomain.ErrForbidden
+	}
+
+	if err := c.memberRepo.UpdateMemberRole(ctx, chatID, memberID, req.Role); err != nil {
+		c.log.Error("UpdateMemberRole",
+			zap.Any("chat", chatID),
+			zap.Any("member", memberID),
+			zap.Error(err))
+		return nil, err
+	}
+	member.Role = req.Role
+
+	c.broadcastEvent(chatID, msgdomain.SystemEvent{
+		Type:    msgdomain.EventMemberRoleChanged,
+		UserID:  memberID,
+		ActorID: userID,
+		Role:    string(req.Role),
+	})
+
+	return member, nil
+}
+
+// GetMessages implements service.ChatService.
+func (c *chatService) GetMessages(ctx context.Context, userID string, chatID string, before time.Time, limit int) ([]*msgdomain.Message, error) {
+	chat, err := c.getChat(ctx, chatID)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.authorize(ctx, chat, userID, userdomain.PermissionRead); err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 {
+		limit = defaultHistoryLimit
+	}
+	if limit > maxHistoryLimit {
+		limit = maxHistoryLimit
+	}
+	if before.IsZero() {
+		before = time.Now()
+	}
+
+	return c.msgRepo.GetMessages(ctx, chatID, before, limit)
+}
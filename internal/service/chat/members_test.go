@@ -0,0 +1,95 @@
+package chatsrv
+
+import (
+	"chatsrv/internal/broker"
+	chatdomain "chatsrv/internal/domain/chat"
+	userdomain "chatsrv/internal/domain/user"
+	"chatsrv/internal/presence"
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func newTestService(store *memoryStore) *chatService {
+	return NewChatService(store, store, store, store, store, store, store, nopWebhooks{}, broker.NewMemory(), presence.NewMemory(), testChatConfig{}, testRateLimitConfig{}, testHeartbeatConfig{}, testOutboundConfig{}, testPresenceConfig{}, zap.NewNop()).(*chatService)
+}
+
+// TestPrivateChatMembership verifies that only members can read a private
+// chat and only owners can manage its members
+func TestPrivateChatMembership(t *testing.T) {
+	store := newMemoryStore()
+	srv := newTestService(store)
+	ctx := context.Background()
+
+	chat := &chatdomain.Chat{ID: "c1", Type: chatdomain.ChatTypeRoom, Visibility: chatdomain.VisibilityPrivate}
+	if err := store.CreateChat(ctx, chat, "alice"); err != nil {
+		t.Fatalf("CreateChat failed: %v", err)
+	}
+
+	if _, err := srv.GetMessages(ctx, "bob", "c1", time.Time{}, 0); !errors.Is(err, chatdomain.ErrNotMember) {
+		t.Errorf("Expected ErrNotMember for outsider, got %v", err)
+	}
+	if _, err := srv.AddMember(ctx, "bob", "c1", chatdomain.AddMemberRequest{UserID: "bob"}); !errors.Is(err, chatdomain.ErrForbidden) {
+		t.Errorf("Expected ErrForbidden for non-owner, got %v", err)
+	}
+
+	member, err := srv.AddMember(ctx, "alice", "c1", chatdomain.AddMemberRequest{UserID: "bob"})
+	if err != nil {
+		t.Fatalf("AddMember failed: %v", err)
+	}
+	if member.Role != chatdomain.RoleMember {
+		t.Errorf("Expected default role member, got %s", member.Role)
+	}
+	if _, err := srv.GetMessages(ctx, "bob", "c1", time.Time{}, 0); err != nil {
+		t.Errorf("Member should read history, got %v", err)
+	}
+
+	page, _ := srv.ListChats(ctx, chatdomain.ListChatsRequest{UserID: "carol"})
+	if len(page.Chats) != 0 {
+		t.Errorf("Private chat should be hidden from outsiders, got %d chats", len(page.Chats))
+	}
+
+	if err := srv.RemoveMember(ctx, "bob", "c1", "alice"); !errors.Is(err, chatdomain.ErrForbidden) {
+		t.Errorf("Expected ErrForbidden removing the owner, got %v", err)
+	}
+	if err := srv.RemoveMember(ctx, "bob", "c1", "bob"); err != nil {
+		t.Errorf("Member should be able to leave, got %v", err)
+	}
+	if _, err := srv.GetMembers(ctx, "bob", "c1"); !errors.Is(err, chatdomain.ErrNotMember) {
+		t.Errorf("Expected ErrNotMember after leaving, got %v", err)
+	}
+}
+
+// TestDirectChatIsPrivateToPair verifies direct chats are reused and hidden
+// from third parties
+func TestDirectChatIsPrivateToPair(t *testing.T) {
+	store := newMemoryStore()
+	srv := newTestService(store)
+	ctx := context.Background()
+	store.CreateUser(ctx, &userdomain.User{ID: "alice", Username: "alice", DisplayName: "Alice Liddell"})
+
+	dm, created, err := srv.CreateDirectChat(ctx, "alice", "bob")
+	if err != nil || !created {
+		t.Fatalf("CreateDirectChat failed: created=%v err=%v", created, err)
+	}
+	again, created, err := srv.CreateDirectChat(ctx, "bob", "alice")
+	if err != nil || created || again.ID != dm.ID {
+		t.Fatalf("Expected existing chat %s, got %+v created=%v err=%v", dm.ID, again, created, err)
+	}
+	if again.CounterpartID != "alice" || again.CounterpartName != "Alice Liddell" {
+		t.Errorf("Expected counterpart alice named Alice Liddell, got %s %q", again.CounterpartID, again.CounterpartName)
+	}
+	if dm.CounterpartName != "bob" {
+		t.Errorf("Expected a counterpart without an account to be named by ID, got %q", dm.CounterpartName)
+	}
+
+	if _, err := srv.GetMembers(ctx, "mallory", dm.ID); !errors.Is(err, chatdomain.ErrNotMember) {
+		t.Errorf("Expected ErrNotMember for third party, got %v", err)
+	}
+	if _, _, err := srv.CreateDirectChat(ctx, "alice", "alice"); !errors.Is(err, chatdomain.ErrInvalidDirectChat) {
+		t.Errorf("Expected ErrInvalidDirectChat for self DM, got %v", err)
+	}
+}
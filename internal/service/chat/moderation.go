@@ -0,0 +1,261 @@
+package chatsrv
+
+import (
+	chatdomain "chatsrv/internal/domain/chat"
+	msgdomain "chatsrv/internal/domain/msg"
+	webhookdomain "chatsrv/internal/domain/webhook"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+	"golang.org/x/net/websocket"
+)
+
+// checkRestriction returns chatdomain.ErrBanned or chatdomain.ErrMuted when
+// userID has an active restriction of kind in chatID.
+func (c *chatService) checkRestriction(ctx context.Context, chatID string, userID string, kind chatdomain.RestrictionKind) error {
+	_, err := c.modRepo.GetActiveRestriction(ctx, chatID, userID, kind)
+	if errors.Is(err, chatdomain.ErrNotRestricted) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if kind == chatdomain.RestrictionMute {
+		return chatdomain.ErrMuted
+	}
+	return chatdomain.ErrBanned
+}
+
+// requireModerator checks that userID may moderate targetID in chatID:
+// moderators and above may act on anyone they outrank.
+func (c *chatService) requireModerator(ctx context.Context, chatID string, userID string, targetID string) error {
+	if targetID == "" {
+		return fmt.Errorf("%w: user_id is required", chatdomain.ErrInvalidModeration)
+	}
+	if targetID == userID {
+		return fmt.Errorf("%w: cannot moderate yourself", chatdomain.ErrInvalidModeration)
+	}
+	if _, err := c.getChat(ctx, chatID); err != nil {
+		return err
+	}
+
+	actor, err := c.requireRole(ctx, chatID, userID, chatdomain.RoleModerator)
+	if err != nil {
+		return err
+	}
+	target, err := c.roleOf(ctx, chatID, targetID)
+	if err != nil {
+		return err
+	}
+	if !actor.Role.Outranks(target) {
+		return chatdomain.ErrForbidden
+	}
+	return nil
+}
+
+// Kick implements service.ChatService. The member is disconnected from
+// the chat on every node, wherever their clients are.
+func (c *chatService) Kick(ctx context.Context, userID string, chatID string, req chatdomain.ModerationRequest) error {
+	if err := c.requireModerator(ctx, chatID, userID, req.UserID); err != nil {
+		return err
+	}
+	_, err := c.memberRepo.GetMember(ctx, chatID, req.UserID)
+	if errors.Is(err, chatdomain.ErrNotMember) {
+		return fmt.Errorf("%w: user %s is not a member of the chat", chatdomain.ErrInvalidModeration, req.UserID)
+	}
+	if err != nil {
+		return err
+	}
+
+	c.expel(chatID, msgdomain.SystemEvent{
+		Type:    msgdomain.EventMemberKicked,
+		UserID:  req.UserID,
+		ActorID: userID,
+		Reason:  req.Reason,
+	})
+
+	return nil
+}
+
+// Ban implements service.ChatService.
+func (c *chatService) Ban(ctx context.Context, userID string, chatID string, req chatdomain.ModerationRequest) (*chatdomain.Restriction, error) {
+	restriction, err := c.restrict(ctx, userID, chatID, chatdomain.RestrictionBan, req)
+	if err != nil {
+		return nil, err
+	}
+
+	c.expel(chatID, msgdomain.SystemEvent{
+		Type:    msgdomain.EventMemberBanned,
+		UserID:  req.UserID,
+		ActorID: userID,
+		Reason:  req.Reason,
+		Until:   req.Until,
+	})
+
+	return restriction, nil
+}
+
+// Unban implements service.ChatService.
+func (c *chatService) Unban(ctx context.Context, userID string, chatID string, targetID string) error {
+	return c.lift(ctx, userID, chatID, targetID, chatdomain.RestrictionBan, msgdomain.EventMemberUnbanned)
+}
+
+// Mute implements service.ChatService.
+func (c *chatService) Mute(ctx context.Context, userID string, chatID string, req chatdomain.ModerationRequest) (*chatdomain.Restriction, error) {
+	restriction, err := c.restrict(ctx, userID, chatID, chatdomain.RestrictionMute, req)
+	if err != nil {
+		return nil, err
+	}
+
+	c.broadcastEvent(chatID, msgdomain.SystemEvent{
+		Type:    msgdomain.EventMemberMuted,
+		UserID:  req.UserID,
+		ActorID: userID,
+		Reason:  req.Reason,
+		Until:   req.Until,
+	})
+
+	return restriction, nil
+}
+
+// Unmute implements service.ChatService.
+func (c *chatService) Unmute(ctx context.Context, userID string, chatID string, targetID string) error {
+	return c.lift(ctx, userID, chatID, targetID, chatdomain.RestrictionMute, msgdomain.EventMemberUnmuted)
+}
+
+func (c *chatService) restrict(ctx context.Context, userID string, chatID string, kind chatdomain.RestrictionKind, req chatdomain.ModerationRequest) (*chatdomain.Restriction, error) {
+	if req.Until != nil && !req.Until.After(time.Now()) {
+		return nil, fmt.Errorf("%w: until must be in the future", chatdomain.ErrInvalidModeration)
+	}
+	if err := c.requireModerator(ctx, chatID, userID, req.UserID); err != nil {
+		return nil, err
+	}
+
+	restriction := &chatdomain.Restriction{
+		ChatID:    chatID,
+		UserID:    req.UserID,
+		Kind:      kind,
+		Reason:    req.Reason,
+		Until:     req.Until,
+		CreatedBy: userID,
+	}
+	if err := c.modRepo.SetRestriction(ctx, restriction); err != nil {
+		c.log.Error("SetRestriction",
+			zap.Any("chat", chatID),
+			zap.Any("user", req.UserID),
+			zap.Any("kind", kind),
+			zap.Error(err))
+		return nil, err
+	}
+
+	return restriction, nil
+}
+
+func (c *chatService) lift(ctx context.Context, userID string, chatID string, targetID string, kind chatdomain.RestrictionKind, eventType msgdomain.SystemEventType) error {
+	if err := c.requireModerator(ctx, chatID, userID, targetID); err != nil {
+		return err
+	}
+	if err := c.modRepo.RemoveRestriction(ctx, chatID, targetID, kind); err != nil {
+		return err
+	}
+
+	c.broadcastEvent(chatID, msgdomain.SystemEvent{
+		Type:    eventType,
+		UserID:  targetID,
+		ActorID: userID,
+	})
+
+	return nil
+}
+
+// handleModeration runs a moderation action sent over the socket.
+func (c *chatService) handleModeration(ws *websocket.Conn, msg msgdomain.Message) error {
+	ctx := ws.Request().Context()
+	req := chatdomain.ModerationRequest{
+		UserID: msg.TargetID,
+		Reason: msg.Reason,
+		Until:  msg.Until,
+	}
+
+	var err error
+	switch msgdomain.ActionType(msg.Action) {
+	case msgdomain.ActionKick:
+		err = c.Kick(ctx, msg.SenderID, msg.ChatID, req)
+	case msgdomain.ActionBan:
+		_, err = c.Ban(ctx, msg.SenderID, msg.ChatID, req)
+	case msgdomain.ActionUnban:
+		err = c.Unban(ctx, msg.SenderID, msg.ChatID, msg.TargetID)
+	case msgdomain.ActionMute:
+		_, err = c.Mute(ctx, msg.SenderID, msg.ChatID, req)
+	case msgdomain.ActionUnmute:
+		err = c.Unmute(ctx, msg.SenderID, msg.ChatID, msg.TargetID)
+	}
+	return err
+}
+
+// expel removes the user event is about from the live room of chatID on
+// every node, then tells the rest of the room. Their clients get event as
+// they are removed.
+func (c *chatService) expel(chatID string, event msgdomain.SystemEvent) {
+	msg := systemMessage(chatID, event)
+	c.evict(msg)
+	c.publish(msg, "")
+}
+
+// expels reports whether a system event of eventType takes its user out of
+// the live room.
+func expels(eventType msgdomain.SystemEventType) bool {
+	switch eventType {
+	case msgdomain.EventMemberKicked, msgdomain.EventMemberBanned, msgdomain.EventMemberRemoved:
+		return true
+	}
+	return false
+}
+
+// evict removes every client of the user msg's event is about from the
+// live room on this node and sends them msg. It reports whether the user
+// had any here.
+func (c *chatService) evict(msg msgdomain.Message) bool {
+	chatID, userID := msg.ChatID, msg.Event.UserID
+	live := c.room(chatID)
+	if live == nil {
+		return false
+	}
+	clients := c.unsubscribe(chatID, live.removeUser(userID))
+	if len(clients) == 0 {
+		return false
+	}
+
+	f := newFrame(frameOf(msg))
+	defer f.release()
+	for _, client := range clients {
+		if err := client.send(f); err != nil {
+			c.log.Error("evict",
+				zap.Any("client", client.clientID()),
+				zap.Any("user", userID),
+				zap.Any("chat", chatID),
+				zap.Error(err))
+		}
+	}
+	c.webhooks.Dispatch(context.Background(), webhookdomain.EventMemberLeft, chatID,
+		webhookdomain.MemberEvent{UserID: userID})
+	return true
+}
+
+// broadcastEvent sends event to every client connected to chatID.
+func (c *chatService) broadcastEvent(chatID string, event msgdomain.SystemEvent) {
+	c.publish(systemMessage(chatID, event), "")
+}
+
+func systemMessage(chatID string, event msgdomain.SystemEvent) msgdomain.Message {
+	now := time.Now()
+	return msgdomain.Message{
+		Action:    string(msgdomain.ActionSystem),
+		ChatID:    chatID,
+		CreatedAt: &now,
+		Event:     &event,
+	}
+}
@@ -0,0 +1,112 @@
+package chatsrv
+
+import (
+	chatdomain "chatsrv/internal/domain/chat"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestModerationRequiresRank verifies moderators act only on users they
+// outrank and roles can only be granted below the granter's own
+func TestModerationRequiresRank(t *testing.T) {
+	store := newMemoryStore()
+	srv := newTestService(store)
+	ctx := context.Background()
+
+	chat := &chatdomain.Chat{ID: "c1", Type: chatdomain.ChatTypeRoom, Visibility: chatdomain.VisibilityPublic}
+	if err := store.CreateChat(ctx, chat, "alice"); err != nil {
+		t.Fatalf("CreateChat failed: %v", err)
+	}
+	if _, err := srv.AddMember(ctx, "alice", "c1", chatdomain.AddMemberRequest{UserID: "bob", Role: chatdomain.RoleAdmin}); err != nil {
+		t.Fatalf("Owner should add an admin, got %v", err)
+	}
+	if _, err := srv.AddMember(ctx, "bob", "c1", chatdomain.AddMemberRequest{UserID: "carol", Role: chatdomain.RoleAdmin}); !errors.Is(err, chatdomain.ErrForbidden) {
+		t.Errorf("Expected ErrForbidden for admin granting admin, got %v", err)
+	}
+	if _, err := srv.AddMember(ctx, "bob", "c1", chatdomain.AddMemberRequest{UserID: "carol", Role: chatdomain.RoleModerator}); err != nil {
+		t.Fatalf("Admin should add a moderator, got %v", err)
+	}
+	store.AddMember(ctx, "c1", "dave", chatdomain.RoleMember)
+
+	if _, err := srv.Ban(ctx, "carol", "c1", chatdomain.ModerationRequest{UserID: "bob"}); !errors.Is(err, chatdomain.ErrForbidden) {
+		t.Errorf("Expected ErrForbidden for moderator banning admin, got %v", err)
+	}
+	if _, err := srv.Mute(ctx, "dave", "c1", chatdomain.ModerationRequest{UserID: "eve"}); !errors.Is(err, chatdomain.ErrForbidden) {
+		t.Errorf("Expected ErrForbidden for member muting, got %v", err)
+	}
+	if _, err := srv.Ban(ctx, "carol", "c1", chatdomain.ModerationRequest{UserID: "carol"}); !errors.Is(err, chatdomain.ErrInvalidModeration) {
+		t.Errorf("Expected ErrInvalidModeration for self-ban, got %v", err)
+	}
+	if _, err := srv.Mute(ctx, "carol", "c1", chatdomain.ModerationRequest{UserID: "dave"}); err != nil {
+		t.Errorf("Moderator should mute a member, got %v", err)
+	}
+	if err := srv.Kick(ctx, "carol", "c1", chatdomain.ModerationRequest{UserID: "dave"}); err != nil {
+		t.Errorf("Moderator should kick a member connected nowhere, got %v", err)
+	}
+	if err := srv.Kick(ctx, "carol", "c1", chatdomain.ModerationRequest{UserID: "eve"}); !errors.Is(err, chatdomain.ErrInvalidModeration) {
+		t.Errorf("Expected ErrInvalidModeration kicking a non-member, got %v", err)
+	}
+
+	if _, err := srv.UpdateMemberRole(ctx, "bob", "c1", "alice", chatdomain.UpdateMemberRequest{Role: chatdomain.RoleMember}); !errors.Is(err, chatdomain.ErrForbidden) {
+		t.Errorf("Expected ErrForbidden for admin demoting owner, got %v", err)
+	}
+	member, err := srv.UpdateMemberRole(ctx, "bob", "c1", "dave", chatdomain.UpdateMemberRequest{Role: chatdomain.RoleModerator})
+	if err != nil {
+		t.Fatalf("UpdateMemberRole failed: %v", err)
+	}
+	if member.Role != chatdomain.RoleModerator {
+		t.Errorf("Expected role moderator, got %s", member.Role)
+	}
+}
+
+// TestBanAndMuteAreEnforced verifies bans shut users out until they are
+// lifted or run out, and mutes block posting
+func TestBanAndMuteAreEnforced(t *testing.T) {
+	store := newMemoryStore()
+	srv := newTestService(store)
+	ctx := context.Background()
+
+	chat := &chatdomain.Chat{ID: "c1", Type: chatdomain.ChatTypeRoom, Visibility: chatdomain.VisibilityPublic}
+	if err := store.CreateChat(ctx, chat, "alice"); err != nil {
+		t.Fatalf("CreateChat failed: %v", err)
+	}
+
+	past := time.Now().Add(-time.Minute)
+	if _, err := srv.Ban(ctx, "alice", "c1", chatdomain.ModerationRequest{UserID: "bob", Until: &past}); !errors.Is(err, chatdomain.ErrInvalidModeration) {
+		t.Errorf("Expected ErrInvalidModeration for a ban in the past, got %v", err)
+	}
+
+	until := time.Now().Add(time.Hour)
+	if _, err := srv.Ban(ctx, "alice", "c1", chatdomain.ModerationRequest{UserID: "bob", Until: &until, Reason: "spam"}); err != nil {
+		t.Fatalf("Ban failed: %v", err)
+	}
+	if _, err := srv.GetMessages(ctx, "bob", "c1", time.Time{}, 0); !errors.Is(err, chatdomain.ErrBanned) {
+		t.Errorf("Expected ErrBanned, got %v", err)
+	}
+	if err := srv.Unban(ctx, "alice", "c1", "bob"); err != nil {
+		t.Fatalf("Unban failed: %v", err)
+	}
+	if _, err := srv.GetMessages(ctx, "bob", "c1", time.Time{}, 0); err != nil {
+		t.Errorf("Unbanned user should read history, got %v", err)
+	}
+	if err := srv.Unban(ctx, "alice", "c1", "bob"); !errors.Is(err, chatdomain.ErrNotRestricted) {
+		t.Errorf("Expected ErrNotRestricted for a second unban, got %v", err)
+	}
+
+	store.SetRestriction(ctx, &chatdomain.Restriction{ChatID: "c1", UserID: "carol", Kind: chatdomain.RestrictionBan, Until: &past})
+	if _, err := srv.GetMessages(ctx, "carol", "c1", time.Time{}, 0); err != nil {
+		t.Errorf("Expired ban should not be enforced, got %v", err)
+	}
+
+	if _, err := srv.Mute(ctx, "alice", "c1", chatdomain.ModerationRequest{UserID: "dave"}); err != nil {
+		t.Fatalf("Mute failed: %v", err)
+	}
+	if err := srv.checkRestriction(ctx, "c1", "dave", chatdomain.RestrictionMute); !errors.Is(err, chatdomain.ErrMuted) {
+		t.Errorf("Expected ErrMuted, got %v", err)
+	}
+	if _, err := srv.GetMessages(ctx, "dave", "c1", time.Time{}, 0); err != nil {
+		t.Errorf("Muted user should still read history, got %v", err)
+	}
+}
@@ -0,0 +1,209 @@
+package chatsrv
+
+import (
+	"chatsrv/internal/config"
+	"errors"
+	"expvar"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	errOutboxClosed = errors.New("client disconnected")
+	errSlowConsumer = errors.New("client is too slow")
+)
+
+// outboxStats counts the events dropped for slow clients, the clients
+// disconnected for it and the writes that failed. They are published under
+// /debug/vars next to ws_outbound_queues, which lists every live queue.
+var outboxStats = expvar.NewMap("ws_outbound")
+
+// liveOutboxes holds the running outboxes for ws_outbound_queues.
+var liveOutboxes sync.Map
+
+func init() {
+	expvar.Publish("ws_outbound_queues", expvar.Func(outboxSnapshot))
+}
+
+// outboxInfo is the state of an outbox published in ws_outbound_queues.
+type outboxInfo struct {
+	ID      string `json:"id"`
+	Kind    string `json:"kind"`
+	UserID  string `json:"user_id"`
+	Depth   int    `json:"depth"`
+	Dropped int64  `json:"dropped"`
+}
+
+func outboxSnapshot() any {
+	infos := []outboxInfo{}
+	liveOutboxes.Range(func(key, _ any) bool {
+		o := key.(*outbox)
+		infos = append(infos, outboxInfo{
+			ID:      o.id,
+			Kind:    o.kind,
+			UserID:  o.user,
+			Depth:   len(o.queue),
+			Dropped: o.dropped.Load(),
+		})
+		return true
+	})
+	return infos
+}
+
+// outbox is the outbound queue of a client. Rooms push events into it
+// without waiting for the network; its own goroutine writes them out, so a
+// slow client only holds up itself.
+type outbox struct {
+	id   string
+	kind string
+	user string
+
+	queue        chan *frame
+	policy       config.SlowConsumerPolicy
+	blockTimeout time.Duration
+
+	// write sends an event to the client. drop disconnects the client; it
+	// runs at most once, in its own goroutine, when the client is too slow
+	// or a write fails.
+	write func(*frame) error
+	drop  func()
+
+	done      chan struct{}
+	closeOnce sync.Once
+	dropOnce  sync.Once
+	dropped   atomic.Int64
+}
+
+func newOutbox(id, kind, user string, cfg config.OutboundConfig, write func(*frame) error, drop func()) *outbox {
+	return &outbox{
+		id:           id,
+		kind:         kind,
+		user:         user,
+		queue:        make(chan *frame, max(cfg.QueueSize(), 1)),
+		policy:       cfg.SlowConsumerPolicy(),
+		blockTimeout: cfg.BlockTimeout(),
+		write:        write,
+		drop:         drop,
+		done:         make(chan struct{}),
+	}
+}
+
+// start runs the writer until the outbox is closed.
+func (o *outbox) start() {
+	liveOutboxes.Store(o, struct{}{})
+	go o.run()
+}
+
+func (o *outbox) run() {
+	defer liveOutboxes.Delete(o)
+	defer o.discard()
+	for {
+		select {
+		case <-o.done:
+			return
+		case f := <-o.queue:
+			err := o.write(f)
+			f.release()
+			if err != nil {
+				outboxStats.Add("write_failures", 1)
+				o.disconnect()
+				return
+			}
+		}
+	}
+}
+
+// discard releases the frames left in the queue.
+func (o *outbox) discard() {
+	for {
+		select {
+		case f := <-o.queue:
+			f.release()
+		default:
+			return
+		}
+	}
+}
+
+// push queues f, applying the slow consumer policy if the queue is full.
+// The outbox holds f until it is written or dropped.
+func (o *outbox) push(f *frame) error {
+	select {
+	case <-o.done:
+		return errOutboxClosed
+	default:
+	}
+	f.retain()
+	if err := o.enqueue(f); err != nil {
+		f.release()
+		return err
+	}
+	return nil
+}
+
+func (o *outbox) enqueue(f *frame) error {
+	select {
+	case o.queue <- f:
+		return nil
+	default:
+	}
+
+	switch o.policy {
+	case config.SlowConsumerDropClient:
+		if o.disconnect() {
+			outboxStats.Add("dropped_clients", 1)
+		}
+		return errSlowConsumer
+	case config.SlowConsumerBlock:
+		timer := time.NewTimer(o.blockTimeout)
+		defer timer.Stop()
+		select {
+		case o.queue <- f:
+			return nil
+		case <-o.done:
+			return errOutboxClosed
+		case <-timer.C:
+			o.dropped.Add(1)
+			outboxStats.Add("dropped_events", 1)
+			return errSlowConsumer
+		}
+	default:
+		for {
+			select {
+			case dropped := <-o.queue:
+				dropped.release()
+				o.dropped.Add(1)
+				outboxStats.Add("dropped_events", 1)
+			default:
+			}
+			select {
+			case o.queue <- f:
+				return nil
+			case <-o.done:
+				return errOutboxClosed
+			default:
+			}
+		}
+	}
+}
+
+// disconnect closes the outbox and drops its client in the background, so
+// that a broadcast does not wait for it. It reports whether the client was
+// still connected.
+func (o *outbox) disconnect() bool {
+	first := false
+	o.dropOnce.Do(func() {
+		first = true
+		o.close()
+		go o.drop()
+	})
+	return first
+}
+
+// close stops the writer; queued events are discarded.
+func (o *outbox) close() {
+	o.closeOnce.Do(func() {
+		close(o.done)
+	})
+}
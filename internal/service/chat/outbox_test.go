@@ -0,0 +1,178 @@
+package chatsrv
+
+import (
+	"chatsrv/internal/config"
+	msgdomain "chatsrv/internal/domain/msg"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// gatedOutbox returns a started outbox whose writes wait for gate, and a
+// channel that is closed when it drops its client
+func gatedOutbox(t *testing.T, cfg testOutboundConfig, gate chan struct{}, written chan<- msgdomain.Message) (*outbox, chan struct{}) {
+	dropped := make(chan struct{})
+	out := newOutbox("o1", "test", "bob", cfg, func(f *frame) error {
+		<-gate
+		written <- f.msg
+		return nil
+	}, func() {
+		close(dropped)
+	})
+	out.start()
+	t.Cleanup(out.close)
+	return out, dropped
+}
+
+// fillOutbox pushes events until the writer is stuck on one and the queue
+// is full
+func fillOutbox(t *testing.T, out *outbox) {
+	if err := out.push(newFrame(msgdomain.Message{ID: "stuck"})); err != nil {
+		t.Fatalf("push failed: %v", err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); len(out.queue) != 0; time.Sleep(time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("Writer never took the first event")
+		}
+	}
+	for i := 0; i < cap(out.queue); i++ {
+		if err := out.push(newFrame(msgdomain.Message{ID: "queued"})); err != nil {
+			t.Fatalf("push failed: %v", err)
+		}
+	}
+}
+
+// TestOutboxDropsOldest verifies a full queue makes room for new events by
+// dropping the oldest, and counts them
+func TestOutboxDropsOldest(t *testing.T) {
+	gate := make(chan struct{})
+	written := make(chan msgdomain.Message, 10)
+	out, _ := gatedOutbox(t, testOutboundConfig{queueSize: 2}, gate, written)
+	fillOutbox(t, out)
+
+	if err := out.push(newFrame(msgdomain.Message{ID: "newest"})); err != nil {
+		t.Fatalf("push failed: %v", err)
+	}
+	if dropped := out.dropped.Load(); dropped != 1 {
+		t.Errorf("Expected 1 dropped event, got %d", dropped)
+	}
+
+	close(gate)
+	var ids []string
+	for i := 0; i < 3; i++ {
+		ids = append(ids, (<-written).ID)
+	}
+	if ids[0] != "stuck" || ids[1] != "queued" || ids[2] != "newest" {
+		t.Errorf("Unexpected events written %v", ids)
+	}
+}
+
+// TestOutboxDropsClient verifies a full queue disconnects the client under
+// the drop_client policy
+func TestOutboxDropsClient(t *testing.T) {
+	gate := make(chan struct{})
+	defer close(gate)
+	out, dropped := gatedOutbox(t, testOutboundConfig{queueSize: 1, policy: config.SlowConsumerDropClient},
+		gate, make(chan msgdomain.Message, 10))
+	fillOutbox(t, out)
+
+	if err := out.push(newFrame(msgdomain.Message{ID: "overflow"})); !errors.Is(err, errSlowConsumer) {
+		t.Errorf("Expected errSlowConsumer, got %v", err)
+	}
+	select {
+	case <-dropped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Client was never dropped")
+	}
+	if err := out.push(newFrame(msgdomain.Message{ID: "late"})); !errors.Is(err, errOutboxClosed) {
+		t.Errorf("Expected errOutboxClosed, got %v", err)
+	}
+}
+
+// TestOutboxBlocks verifies the block policy waits for room, and gives up
+// on the event after the block timeout
+func TestOutboxBlocks(t *testing.T) {
+	gate := make(chan struct{})
+	written := make(chan msgdomain.Message, 10)
+	out, _ := gatedOutbox(t, testOutboundConfig{queueSize: 1, policy: config.SlowConsumerBlock, blockTimeout: 20 * time.Millisecond},
+		gate, written)
+	fillOutbox(t, out)
+
+	if err := out.push(newFrame(msgdomain.Message{ID: "late"})); !errors.Is(err, errSlowConsumer) {
+		t.Errorf("Expected errSlowConsumer, got %v", err)
+	}
+
+	out.blockTimeout = 5 * time.Second
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		close(gate)
+	}()
+	if err := out.push(newFrame(msgdomain.Message{ID: "waited"})); err != nil {
+		t.Errorf("Expected the push to wait for room, got %v", err)
+	}
+}
+
+// TestOutboxDropsClientOnWriteError verifies a failed write disconnects the
+// client
+func TestOutboxDropsClientOnWriteError(t *testing.T) {
+	dropped := make(chan struct{})
+	out := newOutbox("o1", "test", "bob", testOutboundConfig{}, func(*frame) error {
+		return errors.New("write timeout")
+	}, func() {
+		close(dropped)
+	})
+	out.start()
+
+	if err := out.push(newFrame(msgdomain.Message{ID: "m1"})); err != nil {
+		t.Fatalf("push failed: %v", err)
+	}
+	select {
+	case <-dropped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Client was never dropped")
+	}
+}
+
+// TestSlowStreamDoesNotStallRoom verifies a stream that stopped reading
+// does not hold up the other clients of its room
+func TestSlowStreamDoesNotStallRoom(t *testing.T) {
+	srv := newLimitTestService(t, testRateLimitConfig{})
+	ctx := context.Background()
+
+	stuck := make(chan struct{})
+	defer close(stuck)
+	unsubscribe, _, err := srv.Subscribe(ctx, "bob", []string{"c1"}, func(msgdomain.Frame) error {
+		<-stuck
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	defer unsubscribe()
+
+	stream := make(chan msgdomain.Message, 10)
+	unsubscribe, _, err = srv.Subscribe(ctx, "carol", []string{"c1"}, streamTo(stream))
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	defer unsubscribe()
+
+	for i := 0; i < 3; i++ {
+		sent, err := srv.SendMessage(ctx, "dave", "c1", msgdomain.SendMessageRequest{Content: "hello"})
+		if err != nil {
+			t.Fatalf("SendMessage failed: %v", err)
+		}
+		for {
+			select {
+			case msg := <-stream:
+				if msg.ID != sent.ID {
+					continue
+				}
+			case <-time.After(5 * time.Second):
+				t.Fatalf("Message %d never reached the fast stream", i)
+			}
+			break
+		}
+	}
+}
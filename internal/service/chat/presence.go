@@ -0,0 +1,146 @@
+package chatsrv
+
+import (
+	"context"
+	"expvar"
+	"sync/atomic"
+	"time"
+
+	chatdomain "chatsrv/internal/domain/chat"
+	"chatsrv/internal/presence"
+
+	"go.uber.org/zap"
+)
+
+// presenceTimeout bounds each call to the presence registry, so that a slow
+// backend does not hold up a connection or a heartbeat.
+const presenceTimeout = 5 * time.Second
+
+// presenceNodes holds the live nodes as of the last heartbeat, published
+// under /debug/vars as presence_nodes.
+var presenceNodes atomic.Pointer[[]presence.Node]
+
+func init() {
+	expvar.Publish("presence_nodes", expvar.Func(func() any {
+		if nodes := presenceNodes.Load(); nodes != nil {
+			return *nodes
+		}
+		return []presence.Node{}
+	}))
+}
+
+// Stop implements service.ChatService.
+func (s *chatService) Stop() {
+	select {
+	case <-s.stop:
+	default:
+		close(s.stop)
+	}
+	<-s.done
+	s.unsubscribeSessions()
+
+	ctx, cancel := context.WithTimeout(context.Background(), presenceTimeout)
+	defer cancel()
+	if err := s.presence.Leave(ctx, s.node); err != nil {
+		s.log.Error("Stop", zap.Any("node", s.node), zap.Error(err))
+	}
+}
+
+// heartbeatPresence renews the lease of the node in the presence registry
+// three times per lease until the service stops. Each heartbeat also
+// replaces what the registry holds for the node with the users connected
+// now, correcting any Connect or Disconnect that was lost, and takes the
+// live nodes for presence_nodes.
+func (s *chatService) heartbeatPresence() {
+	defer close(s.done)
+
+	lease := s.presenceCfg.Lease()
+	ticker := time.NewTicker(lease / 3)
+	defer ticker.Stop()
+
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), presenceTimeout)
+		if err := s.presence.Heartbeat(ctx, s.node, s.conns.counts(), lease); err != nil {
+			s.log.Error("heartbeatPresence", zap.Any("node", s.node), zap.Error(err))
+		}
+		if nodes, err := s.presence.Nodes(ctx); err != nil {
+			s.log.Error("heartbeatPresence", zap.Any("node", s.node), zap.Error(err))
+		} else {
+			presenceNodes.Store(&nodes)
+		}
+		cancel()
+
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// streamOpened counts an event stream or mailbox of userID as a
+// connection.
+func (s *chatService) streamOpened(userID string) {
+	if userID == "" {
+		return
+	}
+	s.conns.addStream(userID)
+	s.connected(userID)
+}
+
+// streamClosed uncounts an event stream or mailbox of userID.
+func (s *chatService) streamClosed(userID string) {
+	if userID == "" {
+		return
+	}
+	s.conns.removeStream(userID)
+	s.disconnected(userID)
+}
+
+// connected records a new connection of userID in the presence registry.
+func (s *chatService) connected(userID string) {
+	if userID == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), presenceTimeout)
+	defer cancel()
+	if err := s.presence.Connect(ctx, s.node, userID); err != nil {
+		s.log.Error("connected", zap.Any("user", userID), zap.Error(err))
+	}
+}
+
+// disconnected records in the presence registry that a connection of
+// userID closed.
+func (s *chatService) disconnected(userID string) {
+	if userID == "" {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), presenceTimeout)
+	defer cancel()
+	if err := s.presence.Disconnect(ctx, s.node, userID); err != nil {
+		s.log.Error("disconnected", zap.Any("user", userID), zap.Error(err))
+	}
+}
+
+// withPresence marks which of members are connected to any node. Presence
+// is best effort: if the registry cannot be reached, members are returned
+// as offline.
+func (s *chatService) withPresence(ctx context.Context, members []*chatdomain.Member) []*chatdomain.Member {
+	if len(members) == 0 {
+		return members
+	}
+	userIDs := make([]string, 0, len(members))
+	for _, m := range members {
+		userIDs = append(userIDs, m.UserID)
+	}
+	online, err := s.presence.Online(ctx, userIDs)
+	if err != nil {
+		s.log.Error("withPresence", zap.Error(err))
+		return members
+	}
+	for _, m := range members {
+		m.Connections = online[m.UserID]
+		m.Online = m.Connections > 0
+	}
+	return members
+}
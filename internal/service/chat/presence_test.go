@@ -0,0 +1,82 @@
+package chatsrv
+
+import (
+	"chatsrv/internal/broker"
+	msgdomain "chatsrv/internal/domain/msg"
+	"context"
+	"testing"
+	"time"
+)
+
+// expectConnections waits until srv lists alice, the only member of c1,
+// with want connections
+func expectConnections(t *testing.T, srv *chatService, want int) {
+	t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		members, err := srv.GetMembers(context.Background(), "alice", "c1")
+		if err != nil {
+			t.Fatalf("GetMembers failed: %v", err)
+		}
+		if len(members) != 1 {
+			t.Fatalf("Expected alice as the only member, got %+v", members)
+		}
+		alice := members[0]
+		if alice.Connections == want && alice.Online == (want > 0) {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected alice with %d connections, got %+v", want, alice)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// TestMembersOnlineAcrossNodes verifies members connected to another node
+// are reported online with their connections, until that node stops
+func TestMembersOnlineAcrossNodes(t *testing.T) {
+	node1, node2 := newTestNodes(t, broker.NewMemory())
+	url := newWSTestServer(t, node2)
+	expectConnections(t, node1, 0)
+
+	tab1 := dialWSTestServer(t, url)
+	dialWSTestServer(t, url)
+	expectConnections(t, node1, 2)
+
+	tab1.Close()
+	expectConnections(t, node1, 1)
+
+	node2.Stop()
+	expectConnections(t, node1, 0)
+}
+
+// TestMembersOnlineOverStreams verifies event streams and long-poll
+// mailboxes count as connections, and survive a heartbeat
+func TestMembersOnlineOverStreams(t *testing.T) {
+	node1, node2 := newTestNodes(t, broker.NewMemory())
+	defer node2.Stop()
+	ctx := context.Background()
+
+	unsubscribe, _, err := node2.Subscribe(ctx, "alice", []string{"c1"}, func(msgdomain.Frame) error { return nil })
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	expectConnections(t, node1, 1)
+
+	resp, err := node2.Poll(ctx, "alice", msgdomain.PollRequest{ChatIDs: []string{"c1"}, Timeout: time.Millisecond})
+	if err != nil {
+		t.Fatalf("Poll failed: %v", err)
+	}
+	expectConnections(t, node1, 2)
+
+	if got := node2.conns.counts()["alice"]; got != 2 {
+		t.Fatalf("Expected the heartbeat to report 2 connections of alice, got %d", got)
+	}
+
+	unsubscribe()
+	unsubscribe()
+	expectConnections(t, node1, 1)
+
+	node2.closeMailbox(resp.PollID)
+	expectConnections(t, node1, 0)
+}
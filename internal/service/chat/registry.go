@@ -0,0 +1,152 @@
+package chatsrv
+
+import (
+	"sync"
+
+	"golang.org/x/net/websocket"
+)
+
+// registry holds the live WebSocket connections by ID, with the
+// connections of each user and login session. The controller hands the
+// service a *websocket.Conn, so connections can be found by it too. It also
+// counts the event streams and long-poll mailboxes of each user, which keep
+// them online like connections do.
+type registry struct {
+	mu        sync.RWMutex
+	conns     map[string]*conn
+	byWS      map[*websocket.Conn]*conn
+	byUser    map[string]map[string]*conn
+	bySession map[string]map[string]*conn
+	streams   map[string]int
+}
+
+func newRegistry() *registry {
+	return &registry{
+		conns:     make(map[string]*conn),
+		byWS:      make(map[*websocket.Conn]*conn),
+		byUser:    make(map[string]map[string]*conn),
+		bySession: make(map[string]map[string]*conn),
+		streams:   make(map[string]int),
+	}
+}
+
+func (r *registry) add(c *conn) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.conns[c.id] = c
+	r.byWS[c.ws] = c
+	if c.user != "" {
+		addConn(r.byUser, c.user, c)
+	}
+	if c.sessionID != "" {
+		addConn(r.bySession, c.sessionID, c)
+	}
+}
+
+// remove drops the connection of ws and returns it, or nil if it was not
+// registered.
+func (r *registry) remove(ws *websocket.Conn) *conn {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	c, ok := r.byWS[ws]
+	if !ok {
+		return nil
+	}
+	delete(r.conns, c.id)
+	delete(r.byWS, ws)
+	removeConn(r.bySession, c.sessionID, c)
+	removeConn(r.byUser, c.user, c)
+	return c
+}
+
+// get returns the connection with id, or nil.
+func (r *registry) get(id string) *conn {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.conns[id]
+}
+
+// of returns the connection of ws, or nil if it is not registered.
+func (r *registry) of(ws *websocket.Conn) *conn {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.byWS[ws]
+}
+
+// ofUser returns the connections of userID.
+func (r *registry) ofUser(userID string) []*conn {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return connList(r.byUser[userID])
+}
+
+// ofSession returns the connections opened with the login session
+// sessionID.
+func (r *registry) ofSession(sessionID string) []*conn {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return connList(r.bySession[sessionID])
+}
+
+// addStream counts an event stream or mailbox of userID.
+func (r *registry) addStream(userID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.streams[userID]++
+}
+
+// removeStream uncounts an event stream or mailbox of userID.
+func (r *registry) removeStream(userID string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.streams[userID]--; r.streams[userID] <= 0 {
+		delete(r.streams, userID)
+	}
+}
+
+// counts returns the number of connections, streams and mailboxes of each
+// authenticated user.
+func (r *registry) counts() map[string]int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	counts := make(map[string]int, len(r.byUser)+len(r.streams))
+	for userID, conns := range r.byUser {
+		counts[userID] = len(conns)
+	}
+	for userID, n := range r.streams {
+		counts[userID] += n
+	}
+	return counts
+}
+
+func addConn(index map[string]map[string]*conn, key string, c *conn) {
+	conns, ok := index[key]
+	if !ok {
+		conns = make(map[string]*conn)
+		index[key] = conns
+	}
+	conns[c.id] = c
+}
+
+// removeConn drops c from index, and key along with its last connection.
+func removeConn(index map[string]map[string]*conn, key string, c *conn) {
+	conns, ok := index[key]
+	if !ok {
+		return
+	}
+	delete(conns, c.id)
+	if len(conns) == 0 {
+		delete(index, key)
+	}
+}
+
+func connList(conns map[string]*conn) []*conn {
+	list := make([]*conn, 0, len(conns))
+	for _, c := range conns {
+		list = append(list, c)
+	}
+	return list
+}
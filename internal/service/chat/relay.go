@@ -0,0 +1,193 @@
+package chatsrv
+
+import (
+	"chatsrv/internal/broker"
+	msgdomain "chatsrv/internal/domain/msg"
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// subscribeTimeout bounds how long a room waits for its broker
+	// subscription.
+	subscribeTimeout = 5 * time.Second
+	// fetchTimeout bounds reading back what a stand-in event points to.
+	fetchTimeout = 5 * time.Second
+	// relayQueueSize is how many events from other nodes a room queues
+	// before dropping them.
+	relayQueueSize = 1024
+)
+
+// roomEvent is what the nodes publish to each other about a room. Node is
+// the publisher, which has already delivered the event to its own clients.
+type roomEvent struct {
+	Node    string             `json:"node"`
+	Origin  string             `json:"origin,omitempty"`
+	Message *msgdomain.Message `json:"message,omitempty"`
+	// MessageID stands in for a stored message too large for the broker.
+	// Receivers read it back from the repository.
+	MessageID string `json:"message_id,omitempty"`
+}
+
+// relay publishes msg to the other nodes through the broker. A message too
+// large for the broker is replaced by its ID, and a chat event by the same
+// event without the chat, for the receivers to read back.
+func (c *chatService) relay(msg msgdomain.Message, origin string) {
+	event := roomEvent{Node: c.node, Origin: origin, Message: &msg}
+	err := c.publishEvent(msg.ChatID, event)
+	if errors.Is(err, broker.ErrPayloadTooLarge) {
+		if standIn, ok := standInFor(event); ok {
+			err = c.publishEvent(msg.ChatID, standIn)
+		}
+	}
+	if err != nil {
+		c.log.Error("relay",
+			zap.Any("msg", msg.ID),
+			zap.Any("chat", msg.ChatID),
+			zap.Error(err))
+	}
+}
+
+func (c *chatService) publishEvent(chatID string, event roomEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return c.broker.Publish(context.Background(), chatID, payload)
+}
+
+// standInFor returns a smaller event the receivers can rebuild event from.
+func standInFor(event roomEvent) (roomEvent, bool) {
+	msg := event.Message
+	switch {
+	case isStored(*msg):
+		event.Message = nil
+		event.MessageID = msg.ID
+		return event, true
+	case msg.Event != nil && msg.Event.Chat != nil:
+		slim, systemEvent := *msg, *msg.Event
+		systemEvent.Chat = nil
+		slim.Event = &systemEvent
+		event.Message = &slim
+		return event, true
+	}
+	return event, false
+}
+
+// isStored reports whether msg is kept in the message repository, unlike
+// system events and typing notifications.
+func isStored(msg msgdomain.Message) bool {
+	return msg.Action != string(msgdomain.ActionSystem) && msg.Action != string(msgdomain.ActionTyping)
+}
+
+// subscribeRoom hands the events other nodes publish to chatID to its live
+// room until the returned function is called. A room without a
+// subscription still gets the events of this node. The events are queued
+// for a goroutine of the room's own, since reading back a stand-in must not
+// hold up the broker, which serves every room.
+func (c *chatService) subscribeRoom(chatID string) func() {
+	ctx, cancel := context.WithTimeout(context.Background(), subscribeTimeout)
+	defer cancel()
+
+	events := make(chan []byte, relayQueueSize)
+	unsubscribe, err := c.broker.Subscribe(ctx, chatID, func(payload []byte) {
+		select {
+		case events <- payload:
+		default:
+			c.log.Error("subscribeRoom queue full",
+				zap.Any("chat", chatID))
+		}
+	})
+	if err != nil {
+		c.log.Error("subscribeRoom",
+			zap.Any("chat", chatID),
+			zap.Error(err))
+		return func() {}
+	}
+
+	stop := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case payload := <-events:
+				c.receive(chatID, payload)
+			case <-stop:
+				return
+			}
+		}
+	}()
+	return func() {
+		unsubscribe()
+		close(stop)
+	}
+}
+
+// receive delivers an event published by another node to the live room of
+// chatID, and applies what it means for the room on this node.
+func (c *chatService) receive(chatID string, payload []byte) {
+	var event roomEvent
+	if err := json.Unmarshal(payload, &event); err != nil {
+		c.log.Error("receive",
+			zap.Any("chat", chatID),
+			zap.Error(err))
+		return
+	}
+	if event.Node == c.node {
+		return
+	}
+	live := c.room(chatID)
+	if live == nil {
+		return
+	}
+
+	msg, err := c.rebuild(chatID, event)
+	if err != nil {
+		c.log.Error("receive",
+			zap.Any("chat", chatID),
+			zap.Any("msg", event.MessageID),
+			zap.Error(err))
+		return
+	}
+	if msg.Event != nil && msg.Event.Type == msgdomain.EventChatDeleted {
+		c.closeRoom(chatID, msg)
+		return
+	}
+	if msg.Event != nil && expels(msg.Event.Type) {
+		c.evict(msg)
+	}
+	live.broadcast(msg, event.Origin)
+	if msg.Event != nil && msg.Event.Type == msgdomain.EventChatUpdated && msg.Event.Chat != nil {
+		live.setInfo(msg.Event.Chat)
+	}
+}
+
+// rebuild returns the message of event, reading back what a stand-in left
+// out.
+func (c *chatService) rebuild(chatID string, event roomEvent) (msgdomain.Message, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), fetchTimeout)
+	defer cancel()
+
+	if event.Message == nil {
+		stored, err := c.msgRepo.GetMessage(ctx, event.MessageID)
+		if err != nil {
+			return msgdomain.Message{}, err
+		}
+		return *stored, nil
+	}
+
+	msg := *event.Message
+	if msg.Event != nil && msg.Event.Type == msgdomain.EventChatUpdated && msg.Event.Chat == nil {
+		chat, err := c.repo.GetChat(ctx, chatID)
+		if err != nil {
+			return msgdomain.Message{}, err
+		}
+		systemEvent := *msg.Event
+		systemEvent.Chat = chat
+		msg.Event = &systemEvent
+	}
+	return msg, nil
+}
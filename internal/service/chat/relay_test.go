@@ -0,0 +1,335 @@
+package chatsrv
+
+import (
+	"chatsrv/internal/broker"
+	chatdomain "chatsrv/internal/domain/chat"
+	msgdomain "chatsrv/internal/domain/msg"
+	"chatsrv/internal/presence"
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"golang.org/x/net/websocket"
+)
+
+// newTestNodes returns two nodes sharing b, a presence registry and a store with the public chat
+// c1 owned by alice
+func newTestNodes(t *testing.T, b broker.Broker) (*chatService, *chatService) {
+	store := newMemoryStore()
+	chat := &chatdomain.Chat{ID: "c1", Type: chatdomain.ChatTypeRoom, Visibility: chatdomain.VisibilityPublic}
+	if err := store.CreateChat(context.Background(), chat, "alice"); err != nil {
+		t.Fatalf("CreateChat failed: %v", err)
+	}
+	online := presence.NewMemory()
+	node := func() *chatService {
+		return NewChatService(store, store, store, store, store, store, store, nopWebhooks{}, b, online,
+			testChatConfig{}, testRateLimitConfig{}, testHeartbeatConfig{}, testOutboundConfig{}, testPresenceConfig{}, zap.NewNop()).(*chatService)
+	}
+	return node(), node()
+}
+
+// expectOnly waits for a message with content on stream and checks no copy
+// of it follows
+func expectOnly(t *testing.T, stream <-chan msgdomain.Message, content string) {
+	t.Helper()
+	for deadline := time.After(5 * time.Second); ; {
+		select {
+		case msg := <-stream:
+			if msg.Content != content {
+				continue
+			}
+		case <-deadline:
+			t.Fatalf("Never got %q", content)
+		}
+		break
+	}
+	select {
+	case msg := <-stream:
+		if msg.Content == content {
+			t.Errorf("Got %q twice", content)
+		}
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// testRelay verifies events reach the clients of the other node once, and
+// the clients of the publishing node once
+func testRelay(t *testing.T, b broker.Broker) {
+	node1, node2 := newTestNodes(t, b)
+	ctx := context.Background()
+
+	stream1 := make(chan msgdomain.Message, 10)
+	unsubscribe, _, err := node1.Subscribe(ctx, "bob", []string{"c1"}, streamTo(stream1))
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	defer unsubscribe()
+	stream2 := make(chan msgdomain.Message, 10)
+	unsubscribe, _, err = node2.Subscribe(ctx, "carol", []string{"c1"}, streamTo(stream2))
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	defer unsubscribe()
+
+	if _, err := node2.SendMessage(ctx, "dave", "c1", msgdomain.SendMessageRequest{Content: "from node2"}); err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+	expectOnly(t, stream1, "from node2")
+	expectOnly(t, stream2, "from node2")
+
+	if _, err := node1.SendMessage(ctx, "dave", "c1", msgdomain.SendMessageRequest{Content: "from node1"}); err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+	expectOnly(t, stream1, "from node1")
+	expectOnly(t, stream2, "from node1")
+
+	// The other node refreshes its room on a chat event.
+	topic := "news"
+	if _, err := node1.UpdateChat(ctx, "alice", "c1", chatdomain.UpdateChatRequest{Topic: &topic}); err != nil {
+		t.Fatalf("UpdateChat failed: %v", err)
+	}
+	for deadline := time.Now().Add(5 * time.Second); node2.room("c1").info().Topic != topic; time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("The other node never saw the chat update")
+		}
+	}
+}
+
+func TestRelayMemory(t *testing.T) {
+	testRelay(t, broker.NewMemory())
+}
+
+func TestRelayRedis(t *testing.T) {
+	client := redis.NewClient(&redis.Options{Addr: miniredis.RunT(t).Addr()})
+	defer client.Close()
+	b := broker.NewRedis(client, "test:")
+	defer b.Close()
+	testRelay(t, b)
+}
+
+// smallBroker refuses payloads larger than max, like NOTIFY does
+type smallBroker struct {
+	*broker.Memory
+	max int
+}
+
+func (b smallBroker) Publish(ctx context.Context, room string, payload []byte) error {
+	if len(payload) > b.max {
+		return broker.ErrPayloadTooLarge
+	}
+	return b.Memory.Publish(ctx, room, payload)
+}
+
+// TestRelayFallsBackForLargePayloads verifies messages and chat events too
+// large for the broker are read back by the other node
+func TestRelayFallsBackForLargePayloads(t *testing.T) {
+	node1, node2 := newTestNodes(t, smallBroker{Memory: broker.NewMemory(), max: 300})
+	ctx := context.Background()
+
+	stream := make(chan msgdomain.Message, 10)
+	unsubscribe, _, err := node2.Subscribe(ctx, "bob", []string{"c1"}, streamTo(stream))
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	defer unsubscribe()
+
+	long := strings.Repeat("long ", 100)
+	if _, err := node1.SendMessage(ctx, "dave", "c1", msgdomain.SendMessageRequest{Content: long}); err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+	expectOnly(t, stream, long)
+
+	if _, err := node1.UpdateChat(ctx, "alice", "c1", chatdomain.UpdateChatRequest{Description: &long}); err != nil {
+		t.Fatalf("UpdateChat failed: %v", err)
+	}
+	for deadline := time.After(5 * time.Second); ; {
+		select {
+		case msg := <-stream:
+			if msg.Event == nil || msg.Event.Type != msgdomain.EventChatUpdated {
+				continue
+			}
+			if msg.Event.Chat == nil || msg.Event.Chat.Description != long {
+				t.Errorf("Expected the updated chat to be read back, got %+v", msg.Event.Chat)
+			}
+			if node2.room("c1").info().Description != long {
+				t.Error("Expected the other node to refresh its room")
+			}
+		case <-deadline:
+			t.Fatal("The chat update never reached the other node")
+		}
+		break
+	}
+}
+
+// slowMessages is a memoryStore whose GetMessage waits for release
+type slowMessages struct {
+	*memoryStore
+	release chan struct{}
+}
+
+func (s slowMessages) GetMessage(ctx context.Context, messageID string) (*msgdomain.Message, error) {
+	<-s.release
+	return s.memoryStore.GetMessage(ctx, messageID)
+}
+
+// TestRelaySlowReadBackDoesNotStallBroker verifies a stand-in whose message
+// is slow to read back holds up neither the publisher nor the other rooms
+func TestRelaySlowReadBackDoesNotStallBroker(t *testing.T) {
+	store := newMemoryStore()
+	ctx := context.Background()
+	for _, chatID := range []string{"c1", "c2"} {
+		chat := &chatdomain.Chat{ID: chatID, Type: chatdomain.ChatTypeRoom, Visibility: chatdomain.VisibilityPublic}
+		if err := store.CreateChat(ctx, chat, "alice"); err != nil {
+			t.Fatalf("CreateChat failed: %v", err)
+		}
+	}
+	slow := slowMessages{memoryStore: store, release: make(chan struct{})}
+	b := smallBroker{Memory: broker.NewMemory(), max: 300}
+	online := presence.NewMemory()
+	node1 := NewChatService(store, store, store, store, store, store, store, nopWebhooks{}, b, online,
+		testChatConfig{}, testRateLimitConfig{}, testHeartbeatConfig{}, testOutboundConfig{}, testPresenceConfig{}, zap.NewNop()).(*chatService)
+	node2 := NewChatService(store, store, store, slow, store, store, store, nopWebhooks{}, b, online,
+		testChatConfig{}, testRateLimitConfig{}, testHeartbeatConfig{}, testOutboundConfig{}, testPresenceConfig{}, zap.NewNop()).(*chatService)
+
+	streams := make(map[string]chan msgdomain.Message)
+	for _, chatID := range []string{"c1", "c2"} {
+		streams[chatID] = make(chan msgdomain.Message, 10)
+		unsubscribe, _, err := node2.Subscribe(ctx, "bob", []string{chatID}, streamTo(streams[chatID]))
+		if err != nil {
+			t.Fatalf("Subscribe failed: %v", err)
+		}
+		defer unsubscribe()
+	}
+
+	long := strings.Repeat("long ", 100)
+	sent := make(chan error, 1)
+	go func() {
+		_, err := node1.SendMessage(ctx, "dave", "c1", msgdomain.SendMessageRequest{Content: long})
+		sent <- err
+	}()
+	select {
+	case err := <-sent:
+		if err != nil {
+			t.Fatalf("SendMessage failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		close(slow.release)
+		t.Fatal("The publisher waited for the other node to read the message back")
+	}
+
+	if _, err := node1.SendMessage(ctx, "dave", "c2", msgdomain.SendMessageRequest{Content: "short"}); err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+	expectOnly(t, streams["c2"], "short")
+
+	close(slow.release)
+	expectOnly(t, streams["c1"], long)
+}
+
+// expectEvent waits for a system event of eventType on stream
+func expectEvent(t *testing.T, stream <-chan msgdomain.Message, eventType msgdomain.SystemEventType) msgdomain.Message {
+	t.Helper()
+	for deadline := time.After(5 * time.Second); ; {
+		select {
+		case msg := <-stream:
+			if msg.Event != nil && msg.Event.Type == eventType {
+				return msg
+			}
+		case <-deadline:
+			t.Fatalf("Never got a %s event", eventType)
+		}
+	}
+}
+
+// TestKickEvictsOnOtherNodes verifies a kick takes effect on the node the
+// user is connected to, even when another node handles it
+func TestKickEvictsOnOtherNodes(t *testing.T) {
+	node1, node2 := newTestNodes(t, broker.NewMemory())
+	ctx := context.Background()
+
+	bob := make(chan msgdomain.Message, 10)
+	unsubscribe, _, err := node2.Subscribe(ctx, "bob", []string{"c1"}, streamTo(bob))
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	defer unsubscribe()
+	carol := make(chan msgdomain.Message, 10)
+	unsubscribe, _, err = node2.Subscribe(ctx, "carol", []string{"c1"}, streamTo(carol))
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	defer unsubscribe()
+
+	if err := node1.Kick(ctx, "alice", "c1", chatdomain.ModerationRequest{UserID: "bob"}); err != nil {
+		t.Fatalf("Kick failed: %v", err)
+	}
+	if msg := expectEvent(t, bob, msgdomain.EventMemberKicked); msg.Event.UserID != "bob" {
+		t.Errorf("Expected bob to be told they were kicked, got %+v", msg.Event)
+	}
+	expectEvent(t, carol, msgdomain.EventMemberKicked)
+	waitForClients(t, node2, "c1", 1)
+
+	if _, err := node1.SendMessage(ctx, "dave", "c1", msgdomain.SendMessageRequest{Content: "after"}); err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+	expectOnly(t, carol, "after")
+	select {
+	case msg := <-bob:
+		t.Errorf("Expected bob to get nothing once kicked, got %+v", msg)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+// TestRemoveMemberEvictsOnOtherNodes verifies a removed member stops
+// getting the chat's events on every node
+func TestRemoveMemberEvictsOnOtherNodes(t *testing.T) {
+	node1, node2 := newTestNodes(t, broker.NewMemory())
+	ctx := context.Background()
+
+	bob := make(chan msgdomain.Message, 10)
+	unsubscribe, _, err := node2.Subscribe(ctx, "bob", []string{"c1"}, streamTo(bob))
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	defer unsubscribe()
+	carol := make(chan msgdomain.Message, 10)
+	unsubscribe, _, err = node2.Subscribe(ctx, "carol", []string{"c1"}, streamTo(carol))
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	defer unsubscribe()
+
+	if err := node1.RemoveMember(ctx, "alice", "c1", "bob"); err != nil {
+		t.Fatalf("RemoveMember failed: %v", err)
+	}
+	if msg := expectEvent(t, bob, msgdomain.EventMemberRemoved); msg.Event.UserID != "bob" || msg.Event.ActorID != "alice" {
+		t.Errorf("Expected bob to be told alice removed them, got %+v", msg.Event)
+	}
+	expectEvent(t, carol, msgdomain.EventMemberRemoved)
+	waitForClients(t, node2, "c1", 1)
+}
+
+// TestCloseSessionOnOtherNodes verifies revoking a session closes its
+// connections on every node, not only the one that revoked it
+func TestCloseSessionOnOtherNodes(t *testing.T) {
+	node1, node2 := newTestNodes(t, broker.NewMemory())
+	ws := dialWSTestServer(t, newWSTestServer(t, node2))
+	for deadline := time.Now().Add(5 * time.Second); len(node2.conns.ofSession("s1")) == 0; time.Sleep(5 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("The connection was never registered")
+		}
+	}
+
+	node1.CloseSession("s1")
+
+	var msg msgdomain.Message
+	err := websocket.JSON.Receive(ws, &msg)
+	if err == nil || os.IsTimeout(err) {
+		t.Fatalf("Expected the connection to be closed, got %+v, %v", msg, err)
+	}
+}
@@ -0,0 +1,19 @@
+package chatsrv
+
+import (
+	"chatsrv/internal/auth"
+	chatdomain "chatsrv/internal/domain/chat"
+	userdomain "chatsrv/internal/domain/user"
+	"context"
+)
+
+// checkScope holds requests made with an API key to the chat and
+// permissions the key was issued for. chatID is empty for requests that are
+// not about a single chat, which only unscoped keys may make.
+func checkScope(ctx context.Context, chatID string, perm userdomain.Permission) error {
+	principal := auth.FromContext(ctx)
+	if principal == nil || principal.Allows(chatID, perm) {
+		return nil
+	}
+	return chatdomain.ErrForbidden
+}
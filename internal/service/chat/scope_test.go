@@ -0,0 +1,53 @@
+package chatsrv
+
+import (
+	"chatsrv/internal/auth"
+	chatdomain "chatsrv/internal/domain/chat"
+	userdomain "chatsrv/internal/domain/user"
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// TestAPIKeyScope verifies requests made with an API key stay within its
+// chat and permissions
+func TestAPIKeyScope(t *testing.T) {
+	store := newMemoryStore()
+	srv := newTestService(store)
+	ctx := context.Background()
+
+	for _, id := range []string{"c1", "c2"} {
+		chat := &chatdomain.Chat{ID: id, Name: id, Type: chatdomain.ChatTypeRoom, Visibility: chatdomain.VisibilityPublic}
+		if err := store.CreateChat(ctx, chat, "bot"); err != nil {
+			t.Fatalf("CreateChat failed: %v", err)
+		}
+	}
+
+	keyCtx := auth.NewContext(ctx, &userdomain.Principal{
+		UserID:      "bot",
+		APIKeyID:    "k1",
+		Bot:         true,
+		ChatID:      "c1",
+		Permissions: []userdomain.Permission{userdomain.PermissionRead},
+	})
+
+	if _, err := srv.GetMessages(keyCtx, "bot", "c1", time.Time{}, 0); err != nil {
+		t.Errorf("Expected key to read its chat, got %v", err)
+	}
+	if _, err := srv.GetMessages(keyCtx, "bot", "c2", time.Time{}, 0); !errors.Is(err, chatdomain.ErrForbidden) {
+		t.Errorf("Expected ErrForbidden reading another chat, got %v", err)
+	}
+	topic := "bots"
+	if _, err := srv.UpdateChat(keyCtx, "bot", "c1", chatdomain.UpdateChatRequest{Topic: &topic}); !errors.Is(err, chatdomain.ErrForbidden) {
+		t.Errorf("Expected ErrForbidden managing without permission, got %v", err)
+	}
+	if _, err := srv.CreateChat(keyCtx, "bot", chatdomain.CreateChatRequest{Name: "new"}); !errors.Is(err, chatdomain.ErrForbidden) {
+		t.Errorf("Expected ErrForbidden creating a chat with a scoped key, got %v", err)
+	}
+
+	// The owner can do all of that when logged in.
+	if _, err := srv.UpdateChat(ctx, "bot", "c1", chatdomain.UpdateChatRequest{Topic: &topic}); err != nil {
+		t.Errorf("Expected owner without key to manage, got %v", err)
+	}
+}
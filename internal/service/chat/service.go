@@ -1,11 +1,18 @@
 package chatsrv
 
 import (
+	"chatsrv/internal/broker"
+	"chatsrv/internal/config"
 	chatdomain "chatsrv/internal/domain/chat"
 	msgdomain "chatsrv/internal/domain/msg"
+	userdomain "chatsrv/internal/domain/user"
+	webhookdomain "chatsrv/internal/domain/webhook"
+	"chatsrv/internal/presence"
+	"chatsrv/internal/ratelimit"
 	"chatsrv/internal/repository"
 	"chatsrv/internal/service"
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 
@@ -16,63 +23,296 @@ import (
 
 var _ service.ChatService = (*chatService)(nil)
 
-func NewChatService(repo repository.ChatRepository, log *zap.Logger) service.ChatService {
+func NewChatService(
+	repo repository.ChatRepository,
+	memberRepo repository.MemberRepository,
+	modRepo repository.ModerationRepository,
+	msgRepo repository.MessageRepository,
+	inviteRepo repository.InviteRepository,
+	idemRepo repository.IdempotencyRepository,
+	userRepo repository.UserRepository,
+	webhooks service.WebhookService,
+	broker broker.Broker,
+	presence presence.Registry,
+	cfg config.ChatConfig,
+	limitCfg config.RateLimitConfig,
+	heartbeatCfg config.HeartbeatConfig,
+	outboundCfg config.OutboundConfig,
+	presenceCfg config.PresenceConfig,
+	log *zap.Logger,
+) service.ChatService {
 	s := &chatService{
-		chats:   make(map[string]*chat),
-		msgChan: make(chan msgdomain.Message, 100),
-		repo:    repo,
-		log:     log,
+		node:         uuid.New().String(),
+		chats:        make(map[string]*chat),
+		conns:        newRegistry(),
+		connLimits:   ratelimit.NewLimiter(),
+		userLimits:   ratelimit.NewLimiter(),
+		mailboxes:    make(map[string]*mailbox),
+		repo:         repo,
+		memberRepo:   memberRepo,
+		modRepo:      modRepo,
+		msgRepo:      msgRepo,
+		inviteRepo:   inviteRepo,
+		idemRepo:     idemRepo,
+		userRepo:     userRepo,
+		webhooks:     webhooks,
+		broker:       broker,
+		presence:     presence,
+		cfg:          cfg,
+		limitCfg:     limitCfg,
+		heartbeatCfg: heartbeatCfg,
+		outboundCfg:  outboundCfg,
+		presenceCfg:  presenceCfg,
+		log:          log,
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
 	}
-
-	go s.processMessage()
+	s.unsubscribeSessions = s.subscribeSessions()
+	go s.heartbeatPresence()
 
 	return s
 }
 
 type chatService struct {
+	// node identifies this server among those sharing the broker.
+	node string
+
 	mutex sync.RWMutex
 	chats map[string]*chat
 
-	msgChan chan msgdomain.Message
-	repo    repository.ChatRepository
-	log     *zap.Logger
+	// conns holds the live WebSocket connections.
+	conns *registry
+
+	// connLimits and userLimits hold the WebSocket action budgets.
+	connLimits *ratelimit.Limiter
+	userLimits *ratelimit.Limiter
+
+	// mailboxes holds the long-poll subscriptions by poll ID.
+	pollMutex sync.Mutex
+	mailboxes map[string]*mailbox
+
+	repo         repository.ChatRepository
+	memberRepo   repository.MemberRepository
+	modRepo      repository.ModerationRepository
+	msgRepo      repository.MessageRepository
+	inviteRepo   repository.InviteRepository
+	idemRepo     repository.IdempotencyRepository
+	userRepo     repository.UserRepository
+	webhooks     service.WebhookService
+	broker       broker.Broker
+	presence     presence.Registry
+	cfg          config.ChatConfig
+	limitCfg     config.RateLimitConfig
+	heartbeatCfg config.HeartbeatConfig
+	outboundCfg  config.OutboundConfig
+	presenceCfg  config.PresenceConfig
+	log          *zap.Logger
+
+	// unsubscribeSessions stops hearing of the sessions other nodes revoke.
+	unsubscribeSessions func()
+
+	// stop ends the presence heartbeat, which closes done.
+	stop chan struct{}
+	done chan struct{}
 }
 
 // CreateChat implements service.ChatService.
-func (s *chatService) CreateChat(ctx context.Context, name string) (*chatdomain.Chat, error) {
-	uuid := uuid.New().String()
+func (s *chatService) CreateChat(ctx context.Context, userID string, req chatdomain.CreateChatRequest) (*chatdomain.Chat, error) {
+	if err := checkScope(ctx, "", userdomain.PermissionManage); err != nil {
+		return nil, err
+	}
+	name, err := chatdomain.ValidateName(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	visibility := req.Visibility
+	if visibility == "" {
+		visibility = chatdomain.VisibilityPublic
+	}
+	if visibility != chatdomain.VisibilityPublic && visibility != chatdomain.VisibilityPrivate {
+		return nil, fmt.Errorf("%w: unknown visibility %q", chatdomain.ErrInvalidChat, visibility)
+	}
+	if visibility == chatdomain.VisibilityPrivate && userID == "" {
+		return nil, fmt.Errorf("%w: a private chat needs an owner", chatdomain.ErrInvalidChat)
+	}
+
+	chat := &chatdomain.Chat{
+		ID:         uuid.New().String(),
+		Name:       name,
+		Type:       chatdomain.ChatTypeRoom,
+		Visibility: visibility,
+	}
+	if s.cfg.UniqueNames() {
+		chat.NameKey = chatdomain.NameKey(name)
+	}
 
-	err := s.repo.CreateChat(ctx, uuid, name)
+	if req.IdempotencyKey == "" {
+		return s.createChat(ctx, userID, chat)
+	}
+
+	chatID, replayed, err := s.idempotent(ctx, "create_chat", userID, req.IdempotencyKey,
+		string(visibility)+"\x00"+name,
+		func() (string, error) {
+			if _, err := s.createChat(ctx, userID, chat); err != nil {
+				return "", err
+			}
+			return chat.ID, nil
+		})
+	if err != nil {
+		return nil, err
+	}
+	if replayed {
+		return s.repo.GetChat(ctx, chatID)
+	}
+
+	return chat, nil
+}
+
+func (s *chatService) createChat(ctx context.Context, userID string, chat *chatdomain.Chat) (*chatdomain.Chat, error) {
+	err := s.repo.CreateChat(ctx, chat, userID)
+	if errors.Is(err, chatdomain.ErrChatNameTaken) {
+		return nil, err
+	}
 	if err != nil {
 		s.log.Error("CreateChat",
-			zap.Any("msg", name),
+			zap.Any("msg", chat.Name),
 			zap.Error(err))
 		return nil, err
 	}
 
-	return nil, nil
+	s.webhooks.Dispatch(ctx, webhookdomain.EventChatCreated, chat.ID, chat)
+
+	return chat, nil
 }
 
 func (s *chatService) HandleDisconnect(ws *websocket.Conn, clientID string) {
-	s.mutex.Lock()
-	defer s.mutex.Unlock()
-
-	for _, ch := range s.chats {
-		if _, ok := ch.clients[clientID]; ok {
-			ch.removeClient(clientID)
-			s.log.Debug("Client removed from chat on disconnect",
-				zap.Any("Client", clientID),
-				zap.Any("Chat", ch.chatID))
+	c := s.forgetConn(ws)
+	if c == nil {
+		return
+	}
+
+	for _, chatID := range c.subscriptions() {
+		if !s.leave(context.Background(), chatID, c) {
+			continue
 		}
+		s.log.Debug("Client removed from chat on disconnect",
+			zap.Any("Client", clientID),
+			zap.Any("Connection", c.id),
+			zap.Any("Chat", chatID))
 	}
 }
 
-// GetChats implements service.ChatService.
-func (c *chatService) GetChats(ctx context.Context) ([]*chatdomain.Chat, error) {
-	return c.repo.GetChats(ctx)
+// CreateDirectChat implements service.ChatService.
+func (s *chatService) CreateDirectChat(ctx context.Context, userID string, otherID string) (*chatdomain.Chat, bool, error) {
+	if err := checkScope(ctx, "", userdomain.PermissionPost); err != nil {
+		return nil, false, err
+	}
+	if userID == "" || otherID == "" {
+		return nil, false, fmt.Errorf("%w: both participants are required", chatdomain.ErrInvalidDirectChat)
+	}
+	if userID == otherID {
+		return nil, false, fmt.Errorf("%w: cannot open a direct chat with yourself", chatdomain.ErrInvalidDirectChat)
+	}
+
+	chat, created, err := s.repo.GetOrCreateDirectChat(ctx, uuid.New().String(), userID, otherID)
+	if err != nil {
+		s.log.Error("CreateDirectChat",
+			zap.Any("user", userID),
+			zap.Any("other", otherID),
+			zap.Error(err))
+		return nil, false, err
+	}
+
+	if created {
+		s.webhooks.Dispatch(ctx, webhookdomain.EventChatCreated, chat.ID, chat)
+	}
+	s.setCounterparts(ctx, userID, chat)
+
+	return chat, created, nil
+}
+
+// setCounterparts fills the counterpart of the direct chats among chats as
+// seen by userID. Counterparts without an account are named by their ID.
+func (c *chatService) setCounterparts(ctx context.Context, userID string, chats ...*chatdomain.Chat) {
+	var ids []string
+	for _, chat := range chats {
+		if chat.IsDirect() {
+			chat.CounterpartID = chat.Counterpart(userID)
+			chat.CounterpartName = chat.CounterpartID
+			ids = append(ids, chat.CounterpartID)
+		}
+	}
+	if len(ids) == 0 {
+		return
+	}
+
+	users, err := c.userRepo.GetUsers(ctx, ids)
+	if err != nil {
+		c.log.Error("GetUsers",
+			zap.Any("users", ids),
+			zap.Error(err))
+		return
+	}
+	names := make(map[string]string, len(users))
+	for _, user := range users {
+		names[user.ID] = user.DisplayName
+	}
+	for _, chat := range chats {
+		if name, ok := names[chat.CounterpartID]; ok && chat.IsDirect() {
+			chat.CounterpartName = name
+		}
+	}
+}
+
+// PostMessage implements service.ChatService.
+func (c *chatService) PostMessage(ctx context.Context, msg msgdomain.Message) (*msgdomain.Message, error) {
+	chat, err := c.getChat(ctx, msg.ChatID)
+	if err != nil {
+		return nil, err
+	}
+	if chat.IsArchived() {
+		return nil, chatdomain.ErrChatArchived
+	}
+
+	msg.ID = uuid.New().String()
+
+	if err := c.msgRepo.CreateMessage(ctx, &msg); err != nil {
+		c.log.Error("PostMessage",
+			zap.Any("user", msg.SenderID),
+			zap.Any("chat", msg.ChatID),
+			zap.Error(err))
+		return nil, err
+	}
+
+	c.publish(msg, originOf(ctx))
+	return &msg, nil
+}
+
+// postAs posts msg after checking its sender may post in the chat.
+func (c *chatService) postAs(ctx context.Context, msg msgdomain.Message) (*msgdomain.Message, error) {
+	chat, err := c.getChat(ctx, msg.ChatID)
+	if err != nil {
+		return nil, err
+	}
+	if err := c.authorize(ctx, chat, msg.SenderID, userdomain.PermissionPost); err != nil {
+		return nil, err
+	}
+	if err := c.checkRestriction(ctx, msg.ChatID, msg.SenderID, chatdomain.RestrictionMute); err != nil {
+		return nil, err
+	}
+	return c.PostMessage(ctx, msg)
 }
 
 func (c *chatService) GetIncomeMessage(ws *websocket.Conn, msg msgdomain.Message) error {
+	if err := c.checkLimits(ws, msg); err != nil {
+		return err
+	}
+	// Clients name themselves through their account only.
+	msg.Username = ""
+	if conn := c.connState(ws); conn != nil {
+		msg.Username = conn.username
+	}
+
 	switch msg.Action {
 	case string(msgdomain.ActionJoinChat):
 		c.log.Debug("Handle Join Chat",
@@ -89,117 +329,222 @@ func (c *chatService) GetIncomeMessage(ws *websocket.Conn, msg msgdomain.Message
 		c.log.Debug("Handle Send Text",
 			zap.Any("User", msg.SenderID),
 			zap.Any("Chat", msg.ChatID))
-		c.msgChan <- msg
-		return nil
+		ctx := ws.Request().Context()
+		if conn := c.connState(ws); conn != nil {
+			ctx = withOrigin(ctx, conn.id)
+		}
+		_, err := c.postAs(ctx, msg)
+		return err
+	case string(msgdomain.ActionTyping):
+		return c.handleTyping(ws, msg)
+	case string(msgdomain.ActionPing):
+		return c.reply(ws, msgdomain.Message{
+			ID:     msg.ID,
+			Action: string(msgdomain.ActionPong),
+		})
+	case string(msgdomain.ActionKick), string(msgdomain.ActionBan), string(msgdomain.ActionUnban),
+		string(msgdomain.ActionMute), string(msgdomain.ActionUnmute):
+		c.log.Debug("Handle Moderation",
+			zap.Any("User", msg.SenderID),
+			zap.Any("Action", msg.Action),
+			zap.Any("Target", msg.TargetID),
+			zap.Any("Chat", msg.ChatID))
+		return c.handleModeration(ws, msg)
 	default:
 		// Unknown action
 		return nil
 	}
+}
 
+func (c *chatService) handleJoinChat(ws *websocket.Conn, msg msgdomain.Message) error {
+	conn := c.connState(ws)
+	if conn == nil {
+		return fmt.Errorf("unknown connection of user %s", msg.SenderID)
+	}
+	if err := c.join(ws.Request().Context(), msg.ChatID, conn); err != nil {
+		return err
+	}
+	conn.subscribe(msg.ChatID)
 	return nil
 }
 
-func (c *chatService) handleJoinChat(ws *websocket.Conn, msg msgdomain.Message) error {
-	c.mutex.RLock()
-	chat, ok := c.chats[msg.ChatID]
-	c.mutex.RUnlock()
-	if !ok {
-		storedChat, err := c.repo.GetChat(ws.Request().Context(), msg.ChatID)
-		if err != nil {
-			c.log.Debug("Join Chat",
-				zap.Any("msg", msg),
+// join adds cl to the live room of chatID, starting the room on first use.
+// The chat hears that the user joined only from their first client.
+func (c *chatService) join(ctx context.Context, chatID string, cl client) error {
+	userID := cl.userID()
+
+	meta, err := c.getChat(ctx, chatID)
+	if err != nil {
+		c.log.Debug("Join Chat",
+			zap.Any("chat", chatID),
+			zap.Error(err))
+		return err
+	}
+
+	if err := c.authorize(ctx, meta, userID, userdomain.PermissionRead); err != nil {
+		c.log.Debug("Join Chat not allowed",
+			zap.Any("user", userID),
+			zap.Any("chat", chatID))
+		return err
+	}
+	if !meta.IsPrivate() && userID != "" {
+		// Joining a public chat makes the user a member of it.
+		if err := c.memberRepo.AddMember(ctx, chatID, userID, chatdomain.RoleMember); err != nil {
+			c.log.Error("Join Chat add member",
+				zap.Any("user", userID),
+				zap.Any("chat", chatID),
 				zap.Error(err))
 			return err
 		}
-		chat = newChat(storedChat.ID)
-		c.mutex.Lock()
-		c.chats[msg.ChatID] = chat
-		c.mutex.Unlock()
 	}
 
-	chat.m.RLock()
-	_, ok = chat.clients[msg.SenderID]
-	chat.m.RUnlock()
-	if ok {
-		c.log.Error("Join Chat already in chat",
-			zap.Any("user", msg.SenderID),
-			zap.Any("chat", msg.ChatID))
-		return fmt.Errorf("user %s already in chat %s", msg.SenderID, msg.ChatID)
+	var firstOfUser bool
+	for {
+		firstOfUser, err = c.openRoom(meta).addClient(cl)
+		if errors.Is(err, errRoomClosed) {
+			// The room emptied or its chat was deleted in the meantime.
+			if meta, err = c.repo.GetChat(ctx, chatID); err != nil {
+				return err
+			}
+			continue
+		}
+		if errors.Is(err, errAlreadyJoined) {
+			c.log.Error("Join Chat already in chat",
+				zap.Any("user", userID),
+				zap.Any("client", cl.clientID()),
+				zap.Any("chat", chatID))
+			return fmt.Errorf("user %s already in chat %s", userID, chatID)
+		}
+		break
 	}
 
-	chat.addClient(NewClient(msg.SenderID, msg.ChatID, ws))
+	if !firstOfUser {
+		return nil
+	}
+	c.webhooks.Dispatch(ctx, webhookdomain.EventMemberJoined, chatID,
+		webhookdomain.MemberEvent{UserID: userID})
 	return nil
 }
 
-func (c *chatService) handleLeaveChat(ws *websocket.Conn, msg msgdomain.Message) error {
+// room returns the live room of chatID, or nil if nobody is connected to
+// it.
+func (c *chatService) room(chatID string) *chat {
 	c.mutex.RLock()
-	chat, ok := c.chats[msg.ChatID]
-	c.mutex.RUnlock()
+	defer c.mutex.RUnlock()
+	return c.chats[chatID]
+}
+
+// openRoom returns the live room of meta, starting it if needed.
+func (c *chatService) openRoom(meta *chatdomain.Chat) *chat {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	room, ok := c.chats[meta.ID]
 	if !ok {
+		room = newChat(meta, c.releaseRoom, c.subscribeRoom, c.log)
+		c.chats[meta.ID] = room
+	}
+	return room
+}
+
+// releaseRoom forgets room once its last client has left.
+func (c *chatService) releaseRoom(room *chat) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.chats[room.chatID] == room {
+		delete(c.chats, room.chatID)
+	}
+}
+
+// handleTyping relays a typing notification to the room without storing it.
+func (c *chatService) handleTyping(ws *websocket.Conn, msg msgdomain.Message) error {
+	chat, err := c.getChat(ws.Request().Context(), msg.ChatID)
+	if err != nil {
+		return err
+	}
+	if err := c.authorize(ws.Request().Context(), chat, msg.SenderID, userdomain.PermissionPost); err != nil {
+		return err
+	}
+	if err := c.checkRestriction(ws.Request().Context(), msg.ChatID, msg.SenderID, chatdomain.RestrictionMute); err != nil {
+		return err
+	}
+
+	c.publish(msgdomain.Message{
+		Action:   string(msgdomain.ActionTyping),
+		SenderID: msg.SenderID,
+		ChatID:   msg.ChatID,
+		Username: msg.Username,
+		Bot:      msg.Bot,
+	}, "")
+	return nil
+}
+
+func (c *chatService) handleLeaveChat(ws *websocket.Conn, msg msgdomain.Message) error {
+	chat := c.room(msg.ChatID)
+	if chat == nil {
 		c.log.Error("Leave Chat with unknown chatID",
 			zap.Any("user", msg.SenderID),
 			zap.Any("chat", msg.ChatID))
 		return fmt.Errorf("unknown chatID %s", msg.ChatID)
 	}
 
-	chat.m.RLock()
-	client, ok := chat.clients[msg.SenderID]
-	chat.m.RUnlock()
-	if !ok {
+	conn := c.connState(ws)
+	if conn == nil || !conn.subscribed(msg.ChatID) {
 		c.log.Error("Leave Chat user not found in chat",
 			zap.Any("user", msg.SenderID),
 			zap.Any("chat", msg.ChatID))
 		return fmt.Errorf("user %s not found in chat %s", msg.SenderID, msg.ChatID)
 	}
 
-	chat.m.Lock()
-	delete(chat.clients, client.id)
-	chat.m.Unlock()
-	if len(chat.clients) == 0 {
-		c.mutex.Lock()
-		delete(c.chats, msg.ChatID)
-		c.mutex.Unlock()
-	}
+	c.leave(ws.Request().Context(), msg.ChatID, conn)
 	return nil
 }
 
-func (c *chatService) processMessage() error {
-	for {
-		select {
-		case msg := <-c.msgChan:
-			c.mutex.RLock()
-			chat, ok := c.chats[msg.ChatID]
-			c.mutex.RUnlock()
-			if !ok {
-				// Handle error: chat not found
-
-				continue
-			}
+// leave removes cl from the live room of chatID. The room hears that the
+// user left once their last client is gone. It reports false if cl was not
+// in the room.
+func (c *chatService) leave(ctx context.Context, chatID string, cl client) bool {
+	if conn, ok := cl.(*conn); ok {
+		conn.unsubscribe(chatID)
+	}
+	chat := c.room(chatID)
+	if chat == nil {
+		return false
+	}
+	removed, lastOfUser := chat.removeClient(cl)
+	if !removed || !lastOfUser {
+		return removed
+	}
 
-			chat.m.RLock()
-			clients := make([]*client, 0, len(chat.clients))
-			for _, c := range chat.clients {
-				if c.id == msg.SenderID {
-					continue
-				}
-				clients = append(clients, c)
-			}
-			chat.m.RUnlock()
-
-			// Broadcast the message to all clients in the chat
-			for _, client := range clients {
-				err := client.sendMessage(msg)
-				if err != nil {
-					c.log.Error("processMessage",
-						zap.Any("msg", msg),
-						zap.Any("client", client.id),
-						zap.Any("chat", msg.ChatID),
-						zap.Error(err))
-					continue
-				}
-			}
-		default:
-			// Process the message (e.g., broadcast to other clients)
-		}
+	userID := cl.userID()
+	c.broadcastEvent(chatID, msgdomain.SystemEvent{Type: msgdomain.EventMemberLeft, UserID: userID})
+	c.webhooks.Dispatch(ctx, webhookdomain.EventMemberLeft, chatID,
+		webhookdomain.MemberEvent{UserID: userID})
+	return true
+}
+
+// publish hands msg to the live room of its chat, if anyone is connected,
+// to the other nodes, and to the webhooks unless it is a system event or a
+// typing notification. origin is the client msg came from, which does not
+// get it back.
+func (c *chatService) publish(msg msgdomain.Message, origin string) {
+	if chat := c.room(msg.ChatID); chat != nil {
+		chat.broadcast(msg, origin)
 	}
+	c.relay(msg, origin)
+	if isStored(msg) {
+		c.webhooks.Dispatch(context.Background(), webhookdomain.EventMessageCreated, msg.ChatID, msg)
+	}
+}
+
+type originKey struct{}
+
+// withOrigin records in ctx the client a message is posted from.
+func withOrigin(ctx context.Context, clientID string) context.Context {
+	return context.WithValue(ctx, originKey{}, clientID)
+}
+
+// originOf returns the client recorded by withOrigin, or "".
+func originOf(ctx context.Context) string {
+	origin, _ := ctx.Value(originKey{}).(string)
+	return origin
 }
@@ -0,0 +1,244 @@
+package chatsrv
+
+import (
+	"chatsrv/internal/auth"
+	msgdomain "chatsrv/internal/domain/msg"
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// mailboxSize is how many events a long-poll mailbox keeps between
+	// polls before it drops the oldest.
+	mailboxSize = 100
+	// mailboxTTL is how long a mailbox outlives its last poll.
+	mailboxTTL = msgdomain.MaxPollTimeout + 30*time.Second
+)
+
+// SendMessage implements service.ChatService.
+func (s *chatService) SendMessage(ctx context.Context, userID string, chatID string, req msgdomain.SendMessageRequest) (*msgdomain.Message, error) {
+	if err := req.Validate(); err != nil {
+		return nil, err
+	}
+	if err := s.checkUserLimit(userID, limitMessage, string(msgdomain.ActionSendText)); err != nil {
+		return nil, err
+	}
+
+	msg := msgdomain.Message{
+		Action:      string(msgdomain.ActionSendText),
+		Content:     req.Content,
+		Attachments: req.Attachments,
+		SenderID:    userID,
+		ChatID:      chatID,
+	}
+	if principal := auth.FromContext(ctx); principal != nil {
+		msg.Bot = principal.Bot
+	}
+	if account := s.account(ctx, userID); account != nil {
+		msg.Username = account.Username
+	}
+	return s.postAs(ctx, msg)
+}
+
+// Subscribe implements service.ChatService. Events reach send through an
+// outbox, like those of a WebSocket connection.
+func (s *chatService) Subscribe(ctx context.Context, userID string, chatIDs []string, send func(msgdomain.Frame) error) (func(), <-chan struct{}, error) {
+	write := func(f *frame) error {
+		data, err := f.bytes()
+		if err != nil {
+			return err
+		}
+		return send(msgdomain.Frame{ID: f.msg.ID, Action: f.msg.Action, Data: data})
+	}
+	dropped := make(chan struct{})
+	id := uuid.New().String()
+	out := newOutbox(id, "stream", userID, s.outboundCfg, write, func() {
+		close(dropped)
+	})
+	cl := &funcClient{id: id, user: userID, deliver: out.push}
+
+	out.start()
+	joined, err := s.joinAll(ctx, chatIDs, cl)
+	if err != nil {
+		out.close()
+		return nil, nil, err
+	}
+	s.streamOpened(userID)
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			out.close()
+			s.leaveAll(context.Background(), joined, cl)
+			s.streamClosed(userID)
+		})
+	}, dropped, nil
+}
+
+// joinAll joins cl to every chat of chatIDs, or to none of them.
+func (s *chatService) joinAll(ctx context.Context, chatIDs []string, cl client) ([]string, error) {
+	chatIDs = slices.Compact(slices.Sorted(slices.Values(chatIDs)))
+	if len(chatIDs) == 0 || chatIDs[0] == "" {
+		return nil, msgdomain.ErrNoChats
+	}
+
+	joined := make([]string, 0, len(chatIDs))
+	for _, chatID := range chatIDs {
+		err := s.checkUserLimit(cl.userID(), limitJoin, string(msgdomain.ActionJoinChat))
+		if err == nil {
+			err = s.join(ctx, chatID, cl)
+		}
+		if err != nil {
+			s.leaveAll(ctx, joined, cl)
+			return nil, err
+		}
+		joined = append(joined, chatID)
+	}
+	return joined, nil
+}
+
+func (s *chatService) leaveAll(ctx context.Context, chatIDs []string, cl client) {
+	for _, chatID := range chatIDs {
+		s.leave(ctx, chatID, cl)
+	}
+}
+
+// mailbox is a long-poll client. It queues the events of its rooms until
+// the next poll takes them.
+type mailbox struct {
+	id      string
+	user    string
+	chatIDs []string
+	expiry  *time.Timer
+
+	mu      sync.Mutex
+	events  []msgdomain.Message
+	dropped int
+	// ready holds a token while events are queued.
+	ready chan struct{}
+}
+
+func (m *mailbox) clientID() string {
+	return m.id
+}
+
+func (m *mailbox) userID() string {
+	return m.user
+}
+
+func (m *mailbox) send(f *frame) error {
+	m.mu.Lock()
+	if len(m.events) >= mailboxSize {
+		m.events = m.events[1:]
+		m.dropped++
+	}
+	m.events = append(m.events, f.msg)
+	m.mu.Unlock()
+
+	select {
+	case m.ready <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// drain takes the queued events and the count of dropped ones.
+func (m *mailbox) drain() ([]msgdomain.Message, int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	select {
+	case <-m.ready:
+	default:
+	}
+	events, dropped := m.events, m.dropped
+	m.events, m.dropped = nil, 0
+	if events == nil {
+		events = []msgdomain.Message{}
+	}
+	return events, dropped
+}
+
+// Poll implements service.ChatService.
+func (s *chatService) Poll(ctx context.Context, userID string, req msgdomain.PollRequest) (*msgdomain.PollResponse, error) {
+	m, err := s.mailboxFor(ctx, userID, req)
+	if err != nil {
+		return nil, err
+	}
+
+	timeout := req.Timeout
+	if timeout <= 0 {
+		timeout = msgdomain.DefaultPollTimeout
+	}
+	timeout = min(timeout, msgdomain.MaxPollTimeout)
+
+	events, dropped := m.drain()
+	if len(events) == 0 && dropped == 0 {
+		timer := time.NewTimer(timeout)
+		select {
+		case <-m.ready:
+		case <-timer.C:
+		case <-ctx.Done():
+		}
+		timer.Stop()
+		if err := ctx.Err(); err != nil {
+			// Keep the events for the next poll of a client that gave up.
+			return nil, err
+		}
+		events, dropped = m.drain()
+	}
+	m.expiry.Reset(mailboxTTL)
+
+	return &msgdomain.PollResponse{PollID: m.id, Events: events, Dropped: dropped}, nil
+}
+
+// mailboxFor returns the mailbox req.PollID of userID, or opens a new one
+// subscribed to req.ChatIDs.
+func (s *chatService) mailboxFor(ctx context.Context, userID string, req msgdomain.PollRequest) (*mailbox, error) {
+	if req.PollID != "" {
+		s.pollMutex.Lock()
+		m, ok := s.mailboxes[req.PollID]
+		s.pollMutex.Unlock()
+		if !ok || m.user != userID {
+			return nil, msgdomain.ErrPollNotFound
+		}
+		m.expiry.Reset(mailboxTTL)
+		return m, nil
+	}
+
+	m := &mailbox{
+		id:    uuid.New().String(),
+		user:  userID,
+		ready: make(chan struct{}, 1),
+	}
+	joined, err := s.joinAll(ctx, req.ChatIDs, m)
+	if err != nil {
+		return nil, err
+	}
+	m.chatIDs = joined
+	s.streamOpened(userID)
+
+	m.expiry = time.AfterFunc(mailboxTTL, func() { s.closeMailbox(m.id) })
+	s.pollMutex.Lock()
+	s.mailboxes[m.id] = m
+	s.pollMutex.Unlock()
+
+	return m, nil
+}
+
+// closeMailbox drops a mailbox that has not been polled for mailboxTTL.
+func (s *chatService) closeMailbox(pollID string) {
+	s.pollMutex.Lock()
+	m, ok := s.mailboxes[pollID]
+	delete(s.mailboxes, pollID)
+	s.pollMutex.Unlock()
+
+	if ok {
+		s.leaveAll(context.Background(), m.chatIDs, m)
+		s.streamClosed(m.user)
+	}
+}
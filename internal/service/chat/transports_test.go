@@ -0,0 +1,130 @@
+package chatsrv
+
+import (
+	msgdomain "chatsrv/internal/domain/msg"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// waitForClients waits until the live room of chatID has n clients
+func waitForClients(t *testing.T, srv *chatService, chatID string, n int) {
+	for deadline := time.Now().Add(5 * time.Second); ; time.Sleep(5 * time.Millisecond) {
+		if live := srv.room(chatID); live != nil && live.count() == n {
+			return
+		}
+		if time.Now().After(deadline) {
+			t.Fatalf("Room %s never reached %d clients", chatID, n)
+		}
+	}
+}
+
+// streamTo returns a Subscribe callback that decodes the frames into stream
+func streamTo(stream chan<- msgdomain.Message) func(msgdomain.Frame) error {
+	return func(frame msgdomain.Frame) error {
+		var msg msgdomain.Message
+		if err := json.Unmarshal(frame.Data, &msg); err != nil {
+			return err
+		}
+		stream <- msg
+		return nil
+	}
+}
+
+// TestTransportsShareRoomFanOut verifies a message posted over HTTP reaches
+// WebSocket, event stream and long-poll clients of the room alike
+func TestTransportsShareRoomFanOut(t *testing.T) {
+	srv := newLimitTestService(t, testRateLimitConfig{})
+	ctx := context.Background()
+
+	ws := dialWSTestServer(t, newWSTestServer(t, srv))
+	if err := websocket.JSON.Send(ws, msgdomain.Message{Action: string(msgdomain.ActionJoinChat), ChatID: "c1"}); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+
+	stream := make(chan msgdomain.Message, 10)
+	unsubscribe, _, err := srv.Subscribe(ctx, "bob", []string{"c1"}, streamTo(stream))
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	defer unsubscribe()
+
+	first, err := srv.Poll(ctx, "carol", msgdomain.PollRequest{ChatIDs: []string{"c1"}, Timeout: time.Millisecond})
+	if err != nil {
+		t.Fatalf("Poll failed: %v", err)
+	}
+	if first.PollID == "" || len(first.Events) != 0 {
+		t.Fatalf("Expected a new empty mailbox, got %+v", first)
+	}
+	waitForClients(t, srv, "c1", 3)
+
+	sent, err := srv.SendMessage(ctx, "dave", "c1", msgdomain.SendMessageRequest{Content: "hello"})
+	if err != nil {
+		t.Fatalf("SendMessage failed: %v", err)
+	}
+
+	var frame msgdomain.Message
+	if err := websocket.JSON.Receive(ws, &frame); err != nil || frame.ID != sent.ID {
+		t.Errorf("Expected the WebSocket client to get %s, got %+v, %v", sent.ID, frame, err)
+	}
+	select {
+	case msg := <-stream:
+		if msg.ID != sent.ID || msg.Content != "hello" {
+			t.Errorf("Expected the stream to get %s, got %+v", sent.ID, msg)
+		}
+	case <-time.After(5 * time.Second):
+		t.Error("Stream got nothing")
+	}
+	polled, err := srv.Poll(ctx, "carol", msgdomain.PollRequest{PollID: first.PollID, Timeout: 5 * time.Second})
+	if err != nil {
+		t.Fatalf("Poll failed: %v", err)
+	}
+	if len(polled.Events) != 1 || polled.Events[0].ID != sent.ID {
+		t.Errorf("Expected the mailbox to hold %s, got %+v", sent.ID, polled.Events)
+	}
+
+	unsubscribe()
+	waitForClients(t, srv, "c1", 2)
+}
+
+// TestPollRejects verifies polls need chats, and a mailbox can only be
+// polled by its owner
+func TestPollRejects(t *testing.T) {
+	srv := newLimitTestService(t, testRateLimitConfig{})
+	ctx := context.Background()
+
+	if _, err := srv.Poll(ctx, "bob", msgdomain.PollRequest{}); !errors.Is(err, msgdomain.ErrNoChats) {
+		t.Errorf("Expected ErrNoChats, got %v", err)
+	}
+	resp, err := srv.Poll(ctx, "bob", msgdomain.PollRequest{ChatIDs: []string{"c1"}, Timeout: time.Millisecond})
+	if err != nil {
+		t.Fatalf("Poll failed: %v", err)
+	}
+	if _, err := srv.Poll(ctx, "eve", msgdomain.PollRequest{PollID: resp.PollID}); !errors.Is(err, msgdomain.ErrPollNotFound) {
+		t.Errorf("Expected ErrPollNotFound for another user, got %v", err)
+	}
+
+	srv.closeMailbox(resp.PollID)
+	if _, err := srv.Poll(ctx, "bob", msgdomain.PollRequest{PollID: resp.PollID}); !errors.Is(err, msgdomain.ErrPollNotFound) {
+		t.Errorf("Expected ErrPollNotFound for an expired mailbox, got %v", err)
+	}
+}
+
+// TestSendMessageValidates verifies empty and oversized messages are
+// rejected before they are stored
+func TestSendMessageValidates(t *testing.T) {
+	srv := newLimitTestService(t, testRateLimitConfig{})
+
+	for _, req := range []msgdomain.SendMessageRequest{
+		{Content: "  "},
+		{Content: string(make([]byte, msgdomain.MaxContentLength+1))},
+	} {
+		if _, err := srv.SendMessage(context.Background(), "bob", "c1", req); !errors.Is(err, msgdomain.ErrInvalidMessage) {
+			t.Errorf("Expected ErrInvalidMessage, got %v", err)
+		}
+	}
+}
@@ -2,15 +2,138 @@ package service
 
 import (
 	chatdomain "chatsrv/internal/domain/chat"
+	invitedomain "chatsrv/internal/domain/invite"
 	msgdomain "chatsrv/internal/domain/msg"
+	userdomain "chatsrv/internal/domain/user"
+	webhookdomain "chatsrv/internal/domain/webhook"
 	"context"
+	"time"
 
 	"golang.org/x/net/websocket"
 )
 
 type ChatService interface {
 	GetIncomeMessage(ws *websocket.Conn, msg msgdomain.Message) error
-	GetChats(ctx context.Context) ([]*chatdomain.Chat, error)
+	// ListChats returns a page of the chats visible to req.UserID.
+	ListChats(ctx context.Context, req chatdomain.ListChatsRequest) (*chatdomain.ChatPage, error)
+	// MarkRead marks chatID as read by userID up to now.
+	MarkRead(ctx context.Context, userID string, chatID string) error
+	// HandleConnect registers a new connection before it is read from. A
+	// connection that negotiated protocol is sent the welcome frame.
+	HandleConnect(ws *websocket.Conn, protocol string) error
 	HandleDisconnect(ws *websocket.Conn, clientID string)
-	CreateChat(ctx context.Context, name string) (*chatdomain.Chat, error)
+	// Stop takes the node out of the presence registry on shutdown.
+	Stop()
+	SessionCloser
+	// CreateChat creates a chat owned by userID.
+	CreateChat(ctx context.Context, userID string, req chatdomain.CreateChatRequest) (*chatdomain.Chat, error)
+	// CreateDirectChat returns the direct chat between userID and otherID,
+	// creating it on first use. The bool reports whether it was created.
+	CreateDirectChat(ctx context.Context, userID string, otherID string) (*chatdomain.Chat, bool, error)
+	GetChat(ctx context.Context, userID string, chatID string) (*chatdomain.Chat, error)
+	// UpdateChat changes the name, topic or description of a room.
+	UpdateChat(ctx context.Context, userID string, chatID string, req chatdomain.UpdateChatRequest) (*chatdomain.Chat, error)
+	// SetArchived archives a room, making it read-only, or unarchives it.
+	SetArchived(ctx context.Context, userID string, chatID string, archived bool) (*chatdomain.Chat, error)
+	// DeleteChat soft-deletes a room and disconnects its clients.
+	DeleteChat(ctx context.Context, userID string, chatID string) error
+	// PostMessage persists msg and queues it for broadcast, the same way a
+	// send_text frame from a socket is handled.
+	PostMessage(ctx context.Context, msg msgdomain.Message) (*msgdomain.Message, error)
+	// SendMessage posts a message from userID the way a send_text frame
+	// does, for clients that cannot send over WebSocket.
+	SendMessage(ctx context.Context, userID string, chatID string, req msgdomain.SendMessageRequest) (*msgdomain.Message, error)
+	// Subscribe joins userID to chatIDs on behalf of a stream that receives
+	// the events of those rooms through send, one at a time. The returned
+	// func leaves them; the channel is closed if the service drops a stream
+	// that cannot keep up.
+	Subscribe(ctx context.Context, userID string, chatIDs []string, send func(msgdomain.Frame) error) (func(), <-chan struct{}, error)
+	// Poll waits up to req.Timeout for the events of a long-poll mailbox,
+	// creating it on the first poll.
+	Poll(ctx context.Context, userID string, req msgdomain.PollRequest) (*msgdomain.PollResponse, error)
+	GetMessages(ctx context.Context, userID string, chatID string, before time.Time, limit int) ([]*msgdomain.Message, error)
+
+	GetMembers(ctx context.Context, userID string, chatID string) ([]*chatdomain.Member, error)
+	AddMember(ctx context.Context, userID string, chatID string, req chatdomain.AddMemberRequest) (*chatdomain.Member, error)
+	RemoveMember(ctx context.Context, userID string, chatID string, memberID string) error
+	UpdateMemberRole(ctx context.Context, userID string, chatID string, memberID string, req chatdomain.UpdateMemberRequest) (*chatdomain.Member, error)
+
+	// Kick disconnects req.UserID from the live chat without touching its
+	// membership.
+	Kick(ctx context.Context, userID string, chatID string, req chatdomain.ModerationRequest) error
+	// Ban kicks req.UserID and keeps it out of the chat until req.Until.
+	Ban(ctx context.Context, userID string, chatID string, req chatdomain.ModerationRequest) (*chatdomain.Restriction, error)
+	Unban(ctx context.Context, userID string, chatID string, targetID string) error
+	// Mute rejects messages from req.UserID until req.Until.
+	Mute(ctx context.Context, userID string, chatID string, req chatdomain.ModerationRequest) (*chatdomain.Restriction, error)
+	Unmute(ctx context.Context, userID string, chatID string, targetID string) error
+
+	CreateInvite(ctx context.Context, userID string, chatID string, req invitedomain.CreateInviteRequest) (*invitedomain.Invite, error)
+	GetInvites(ctx context.Context, userID string, chatID string) ([]*invitedomain.Invite, error)
+	RevokeInvite(ctx context.Context, userID string, chatID string, inviteID string) error
+	// AcceptInvite adds userID to the invite's chat and audits the acceptance.
+	AcceptInvite(ctx context.Context, userID string, token string, meta invitedomain.AcceptMeta) (*chatdomain.Member, error)
+}
+
+// SessionCloser closes the live connections opened with a login session.
+type SessionCloser interface {
+	CloseSession(sessionID string)
+}
+
+type AuthService interface {
+	// Register creates an account and logs it in.
+	Register(ctx context.Context, req userdomain.RegisterRequest, meta userdomain.ClientMeta) (*userdomain.AuthToken, error)
+	Login(ctx context.Context, req userdomain.LoginRequest, meta userdomain.ClientMeta) (*userdomain.AuthToken, error)
+	// Refresh exchanges a refresh token for a new access and refresh token.
+	// A refresh token that was already used revokes its session and returns
+	// userdomain.ErrRefreshTokenReused.
+	Refresh(ctx context.Context, refreshToken string, meta userdomain.ClientMeta) (*userdomain.AuthToken, error)
+	// Logout revokes the session of principal.
+	Logout(ctx context.Context, principal *userdomain.Principal) error
+	// Authenticate resolves an access token or API key. It returns
+	// userdomain.ErrUnauthenticated for tokens that are unknown, expired or
+	// revoked.
+	Authenticate(ctx context.Context, token string) (*userdomain.Principal, error)
+	// GetSessions lists the active sessions of principal's user, marking
+	// principal's own.
+	GetSessions(ctx context.Context, principal *userdomain.Principal) ([]*userdomain.Session, error)
+	// RevokeSession revokes one of the sessions of principal's user and
+	// closes its connections.
+	RevokeSession(ctx context.Context, principal *userdomain.Principal, sessionID string) error
+	GetUser(ctx context.Context, userID string) (*userdomain.User, error)
+
+	// OIDCLoginURL starts an OpenID Connect login and returns the URL of the
+	// provider to send the user to.
+	OIDCLoginURL(ctx context.Context) (string, error)
+	// OIDCCallback finishes the login started with state, provisioning the
+	// user on its first login.
+	OIDCCallback(ctx context.Context, code string, state string, meta userdomain.ClientMeta) (*userdomain.AuthToken, error)
+
+	// CreateServiceAccount creates a bot user that can only authenticate
+	// with API keys.
+	CreateServiceAccount(ctx context.Context, req userdomain.CreateServiceAccountRequest) (*userdomain.User, error)
+	// CreateAPIKey issues a key for the service account userID. The key is
+	// only returned here; it is stored hashed.
+	CreateAPIKey(ctx context.Context, userID string, req userdomain.CreateAPIKeyRequest) (*userdomain.APIKey, error)
+	GetAPIKeys(ctx context.Context, userID string) ([]*userdomain.APIKey, error)
+	RevokeAPIKey(ctx context.Context, keyID string) error
+}
+
+type WebhookService interface {
+	// Dispatch queues event for every webhook subscribed to it. It never
+	// blocks on the database or the receivers: deliveries are created and
+	// sent in the background, and events are dropped if too many wait.
+	Dispatch(ctx context.Context, eventType webhookdomain.EventType, chatID string, data any)
+	CreateWebhook(ctx context.Context, req webhookdomain.CreateWebhookRequest) (*webhookdomain.Webhook, error)
+	GetWebhooks(ctx context.Context) ([]*webhookdomain.Webhook, error)
+	DeleteWebhook(ctx context.Context, webhookID string) error
+	GetDeliveries(ctx context.Context, webhookID string, limit int) ([]*webhookdomain.Delivery, error)
+	Stop()
+}
+
+type IncomingWebhookService interface {
+	CreateIncomingWebhook(ctx context.Context, chatID string, req webhookdomain.CreateIncomingWebhookRequest) (*webhookdomain.IncomingWebhook, error)
+	GetIncomingWebhooks(ctx context.Context, chatID string) ([]*webhookdomain.IncomingWebhook, error)
+	DeleteIncomingWebhook(ctx context.Context, hookID string) error
+	Post(ctx context.Context, token string, msg webhookdomain.IncomingMessage) (*msgdomain.Message, error)
 }
@@ -0,0 +1,160 @@
+package webhooksrv
+
+import (
+	"chatsrv/internal/config"
+	msgdomain "chatsrv/internal/domain/msg"
+	webhookdomain "chatsrv/internal/domain/webhook"
+	"chatsrv/internal/ratelimit"
+	"chatsrv/internal/repository"
+	"chatsrv/internal/service"
+	"chatsrv/internal/token"
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+var _ service.IncomingWebhookService = (*incomingWebhookService)(nil)
+
+const (
+	maxIncomingText     = 4000
+	maxIncomingUsername = 64
+	maxAttachments      = 10
+
+	// IncomingSenderPrefix marks messages injected through an incoming webhook.
+	IncomingSenderPrefix = "hook:"
+)
+
+func NewIncomingWebhookService(
+	repo repository.IncomingWebhookRepository,
+	chatRepo repository.ChatRepository,
+	chatSrv service.ChatService,
+	cfg config.IncomingWebhookConfig,
+	log *zap.Logger,
+) service.IncomingWebhookService {
+	return &incomingWebhookService{
+		repo:     repo,
+		chatRepo: chatRepo,
+		chatSrv:  chatSrv,
+		cfg:      cfg,
+		limiter:  ratelimit.NewLimiter(),
+		log:      log,
+	}
+}
+
+type incomingWebhookService struct {
+	repo     repository.IncomingWebhookRepository
+	chatRepo repository.ChatRepository
+	chatSrv  service.ChatService
+	cfg      config.IncomingWebhookConfig
+	limiter  *ratelimit.Limiter
+	log      *zap.Logger
+}
+
+// CreateIncomingWebhook implements service.IncomingWebhookService.
+func (s *incomingWebhookService) CreateIncomingWebhook(ctx context.Context, chatID string, req webhookdomain.CreateIncomingWebhookRequest) (*webhookdomain.IncomingWebhook, error) {
+	if _, err := s.chatRepo.GetChat(ctx, chatID); err != nil {
+		return nil, err
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" || utf8.RuneCountInString(name) > maxIncomingUsername {
+		return nil, fmt.Errorf("%w: name must be 1-%d characters", webhookdomain.ErrInvalidWebhook, maxIncomingUsername)
+	}
+	if req.RatePerMinute < 0 || req.Burst < 0 {
+		return nil, fmt.Errorf("%w: rate limits must not be negative", webhookdomain.ErrInvalidWebhook)
+	}
+
+	tok, err := token.New()
+	if err != nil {
+		return nil, err
+	}
+
+	hook := &webhookdomain.IncomingWebhook{
+		ID:            uuid.New().String(),
+		ChatID:        chatID,
+		Name:          name,
+		TokenHash:     token.Hash(tok),
+		RatePerMinute: req.RatePerMinute,
+		Burst:         req.Burst,
+	}
+	if hook.RatePerMinute == 0 {
+		hook.RatePerMinute = s.cfg.RatePerMinute()
+	}
+	if hook.Burst == 0 {
+		hook.Burst = s.cfg.Burst()
+	}
+
+	if err := s.repo.CreateIncomingWebhook(ctx, hook); err != nil {
+		s.log.Error("CreateIncomingWebhook",
+			zap.Any("chat", chatID),
+			zap.Error(err))
+		return nil, err
+	}
+	hook.Token = tok
+
+	return hook, nil
+}
+
+// GetIncomingWebhooks implements service.IncomingWebhookService.
+func (s *incomingWebhookService) GetIncomingWebhooks(ctx context.Context, chatID string) ([]*webhookdomain.IncomingWebhook, error) {
+	return s.repo.GetIncomingWebhooks(ctx, chatID)
+}
+
+// DeleteIncomingWebhook implements service.IncomingWebhookService.
+func (s *incomingWebhookService) DeleteIncomingWebhook(ctx context.Context, hookID string) error {
+	if err := s.repo.DeleteIncomingWebhook(ctx, hookID); err != nil {
+		return err
+	}
+	s.limiter.Forget(hookID)
+	return nil
+}
+
+// Post implements service.IncomingWebhookService.
+func (s *incomingWebhookService) Post(ctx context.Context, tok string, in webhookdomain.IncomingMessage) (*msgdomain.Message, error) {
+	hook, err := s.repo.GetIncomingWebhookByTokenHash(ctx, token.Hash(tok))
+	if err != nil {
+		return nil, err
+	}
+
+	if ok, wait := s.limiter.Allow(hook.ID, float64(hook.RatePerMinute)/60, hook.Burst); !ok {
+		return nil, &webhookdomain.RateLimitError{RetryAfter: wait}
+	}
+
+	if err := validateIncoming(in); err != nil {
+		return nil, err
+	}
+
+	username := strings.TrimSpace(in.Username)
+	if username == "" {
+		username = hook.Name
+	}
+
+	return s.chatSrv.PostMessage(ctx, msgdomain.Message{
+		Action:      string(msgdomain.ActionSendText),
+		Content:     in.Text,
+		SenderID:    IncomingSenderPrefix + hook.ID,
+		ChatID:      hook.ChatID,
+		Username:    username,
+		Attachments: in.Attachments,
+	})
+}
+
+func validateIncoming(in webhookdomain.IncomingMessage) error {
+	if strings.TrimSpace(in.Text) == "" && len(in.Attachments) == 0 {
+		return fmt.Errorf("%w: text or attachments are required", webhookdomain.ErrInvalidIncomingMessage)
+	}
+	if utf8.RuneCountInString(in.Text) > maxIncomingText {
+		return fmt.Errorf("%w: text exceeds %d characters", webhookdomain.ErrInvalidIncomingMessage, maxIncomingText)
+	}
+	if utf8.RuneCountInString(in.Username) > maxIncomingUsername {
+		return fmt.Errorf("%w: username exceeds %d characters", webhookdomain.ErrInvalidIncomingMessage, maxIncomingUsername)
+	}
+	if len(in.Attachments) > maxAttachments {
+		return fmt.Errorf("%w: at most %d attachments are allowed", webhookdomain.ErrInvalidIncomingMessage, maxAttachments)
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package webhooksrv
+
+import (
+	chatdomain "chatsrv/internal/domain/chat"
+	msgdomain "chatsrv/internal/domain/msg"
+	webhookdomain "chatsrv/internal/domain/webhook"
+	"chatsrv/internal/service"
+	"context"
+	"errors"
+	"testing"
+
+	"go.uber.org/zap"
+)
+
+type incomingConfig struct{}
+
+func (incomingConfig) RatePerMinute() int { return 60 }
+func (incomingConfig) Burst() int         { return 2 }
+
+type memoryIncomingRepository struct {
+	hooks map[string]*webhookdomain.IncomingWebhook
+}
+
+func (r *memoryIncomingRepository) CreateIncomingWebhook(_ context.Context, h *webhookdomain.IncomingWebhook) error {
+	cp := *h
+	r.hooks[h.TokenHash] = &cp
+	return nil
+}
+
+func (r *memoryIncomingRepository) GetIncomingWebhooks(context.Context, string) ([]*webhookdomain.IncomingWebhook, error) {
+	return nil, nil
+}
+
+func (r *memoryIncomingRepository) GetIncomingWebhookByTokenHash(_ context.Context, hash string) (*webhookdomain.IncomingWebhook, error) {
+	h, ok := r.hooks[hash]
+	if !ok {
+		return nil, webhookdomain.ErrIncomingWebhookNotFound
+	}
+	return h, nil
+}
+
+func (r *memoryIncomingRepository) DeleteIncomingWebhook(context.Context, string) error {
+	return nil
+}
+
+type stubChatRepository struct{}
+
+func (stubChatRepository) ListChats(context.Context, chatdomain.ListChatsRequest) ([]*chatdomain.ChatSummary, error) {
+	return nil, nil
+}
+func (stubChatRepository) GetChat(_ context.Context, id string) (*chatdomain.Chat, error) {
+	return &chatdomain.Chat{ID: id}, nil
+}
+func (stubChatRepository) CreateChat(context.Context, *chatdomain.Chat, string) error {
+	return nil
+}
+func (stubChatRepository) GetOrCreateDirectChat(context.Context, string, string, string) (*chatdomain.Chat, bool, error) {
+	return nil, false, nil
+}
+func (stubChatRepository) UpdateChat(context.Context, *chatdomain.Chat) error {
+	return nil
+}
+func (stubChatRepository) SetArchived(context.Context, string, bool) (*chatdomain.Chat, error) {
+	return nil, nil
+}
+func (stubChatRepository) DeleteChat(context.Context, string) error {
+	return nil
+}
+
+// recordingChatService captures messages posted through the pipeline
+type recordingChatService struct {
+	service.ChatService
+	posted []msgdomain.Message
+}
+
+func (s *recordingChatService) PostMessage(_ context.Context, msg msgdomain.Message) (*msgdomain.Message, error) {
+	s.posted = append(s.posted, msg)
+	return &msg, nil
+}
+
+// TestIncomingPostInjectsMessage verifies a hook message reaches the chat
+// pipeline and that each token is rate limited on its own
+func TestIncomingPostInjectsMessage(t *testing.T) {
+	chatSrv := &recordingChatService{}
+	srv := NewIncomingWebhookService(
+		&memoryIncomingRepository{hooks: map[string]*webhookdomain.IncomingWebhook{}},
+		stubChatRepository{},
+		chatSrv,
+		incomingConfig{},
+		zap.NewNop(),
+	)
+	ctx := context.Background()
+
+	ci, err := srv.CreateIncomingWebhook(ctx, "chat-1", webhookdomain.CreateIncomingWebhookRequest{Name: "CI"})
+	if err != nil {
+		t.Fatalf("CreateIncomingWebhook failed: %v", err)
+	}
+	alerts, err := srv.CreateIncomingWebhook(ctx, "chat-1", webhookdomain.CreateIncomingWebhookRequest{Name: "alerts"})
+	if err != nil {
+		t.Fatalf("CreateIncomingWebhook failed: %v", err)
+	}
+	if ci.Token == "" || ci.Token == alerts.Token {
+		t.Fatal("Expected distinct non-empty tokens")
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := srv.Post(ctx, ci.Token, webhookdomain.IncomingMessage{Text: "build ok"}); err != nil {
+			t.Fatalf("Post %d failed: %v", i, err)
+		}
+	}
+
+	var rateErr *webhookdomain.RateLimitError
+	if _, err := srv.Post(ctx, ci.Token, webhookdomain.IncomingMessage{Text: "build ok"}); !errors.As(err, &rateErr) {
+		t.Fatalf("Expected rate limit error, got %v", err)
+	}
+	if _, err := srv.Post(ctx, alerts.Token, webhookdomain.IncomingMessage{Text: "disk full", Username: "pager"}); err != nil {
+		t.Fatalf("Other token should not be limited: %v", err)
+	}
+
+	if len(chatSrv.posted) != 3 {
+		t.Fatalf("Expected 3 posted messages, got %d", len(chatSrv.posted))
+	}
+	last := chatSrv.posted[2]
+	if last.ChatID != "chat-1" || last.Username != "pager" || last.SenderID != IncomingSenderPrefix+alerts.ID {
+		t.Errorf("Unexpected message: %+v", last)
+	}
+	if chatSrv.posted[0].Username != "CI" {
+		t.Errorf("Expected hook name as default username, got %q", chatSrv.posted[0].Username)
+	}
+}
+
+// TestIncomingPostRejectsUnknownToken verifies unknown tokens are not found
+func TestIncomingPostRejectsUnknownToken(t *testing.T) {
+	srv := NewIncomingWebhookService(
+		&memoryIncomingRepository{hooks: map[string]*webhookdomain.IncomingWebhook{}},
+		stubChatRepository{},
+		&recordingChatService{},
+		incomingConfig{},
+		zap.NewNop(),
+	)
+
+	_, err := srv.Post(context.Background(), "nope", webhookdomain.IncomingMessage{Text: "hi"})
+	if !errors.Is(err, webhookdomain.ErrIncomingWebhookNotFound) {
+		t.Errorf("Expected not found, got %v", err)
+	}
+}
@@ -0,0 +1,371 @@
+package webhooksrv
+
+import (
+	"bytes"
+	"chatsrv/internal/config"
+	webhookdomain "chatsrv/internal/domain/webhook"
+	"chatsrv/internal/repository"
+	"chatsrv/internal/service"
+	"chatsrv/internal/token"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+var _ service.WebhookService = (*webhookService)(nil)
+
+const (
+	claimBatchSize    = 50
+	maxResponseError  = 512
+	defaultDeliveries = 50
+	// eventQueueSize bounds the events waiting to be queued for their
+	// webhooks; Dispatch drops events beyond it.
+	eventQueueSize = 1024
+)
+
+func NewWebhookService(repo repository.WebhookRepository, cfg config.WebhookConfig, log *zap.Logger) service.WebhookService {
+	s := &webhookService{
+		repo:   repo,
+		cfg:    cfg,
+		log:    log,
+		client: &http.Client{Timeout: cfg.Timeout()},
+		events: make(chan webhookdomain.Event, eventQueueSize),
+		wake:   make(chan struct{}, 1),
+		stop:   make(chan struct{}),
+		queued: make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	go s.queueEvents()
+	go s.run()
+
+	return s
+}
+
+type webhookService struct {
+	repo   repository.WebhookRepository
+	cfg    config.WebhookConfig
+	log    *zap.Logger
+	client *http.Client
+
+	// events holds the dispatched events until queueEvents creates their
+	// deliveries, which closes queued once stopped.
+	events chan webhookdomain.Event
+	queued chan struct{}
+
+	wake chan struct{}
+	stop chan struct{}
+	done chan struct{}
+}
+
+// CreateWebhook implements service.WebhookService.
+func (s *webhookService) CreateWebhook(ctx context.Context, req webhookdomain.CreateWebhookRequest) (*webhookdomain.Webhook, error) {
+	u, err := url.Parse(req.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, fmt.Errorf("%w: url must be an absolute http(s) URL", webhookdomain.ErrInvalidWebhook)
+	}
+	if len(req.Events) == 0 {
+		return nil, fmt.Errorf("%w: at least one event is required", webhookdomain.ErrInvalidWebhook)
+	}
+	for _, e := range req.Events {
+		if !e.Valid() {
+			return nil, fmt.Errorf("%w: unknown event %q", webhookdomain.ErrInvalidWebhook, e)
+		}
+	}
+
+	secret := req.Secret
+	if secret == "" {
+		secret, err = token.New()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	webhook := &webhookdomain.Webhook{
+		ID:     uuid.New().String(),
+		ChatID: req.ChatID,
+		URL:    u.String(),
+		Secret: secret,
+		Events: req.Events,
+	}
+	if err := s.repo.CreateWebhook(ctx, webhook); err != nil {
+		s.log.Error("CreateWebhook",
+			zap.Any("req", req.URL),
+			zap.Error(err))
+		return nil, err
+	}
+
+	return webhook, nil
+}
+
+// GetWebhooks implements service.WebhookService.
+func (s *webhookService) GetWebhooks(ctx context.Context) ([]*webhookdomain.Webhook, error) {
+	webhooks, err := s.repo.GetWebhooks(ctx)
+	if err != nil {
+		return nil, err
+	}
+	// The secret is only disclosed once, on creation.
+	for _, w := range webhooks {
+		w.Secret = ""
+	}
+	return webhooks, nil
+}
+
+// DeleteWebhook implements service.WebhookService.
+func (s *webhookService) DeleteWebhook(ctx context.Context, webhookID string) error {
+	return s.repo.DeleteWebhook(ctx, webhookID)
+}
+
+// GetDeliveries implements service.WebhookService.
+func (s *webhookService) GetDeliveries(ctx context.Context, webhookID string, limit int) ([]*webhookdomain.Delivery, error) {
+	if _, err := s.repo.GetWebhook(ctx, webhookID); err != nil {
+		return nil, err
+	}
+	if limit <= 0 {
+		limit = defaultDeliveries
+	}
+	return s.repo.GetDeliveries(ctx, webhookID, limit)
+}
+
+// Dispatch implements service.WebhookService.
+func (s *webhookService) Dispatch(_ context.Context, eventType webhookdomain.EventType, chatID string, data any) {
+	event := webhookdomain.Event{
+		ID:         uuid.New().String(),
+		Type:       eventType,
+		ChatID:     chatID,
+		OccurredAt: time.Now().UTC(),
+	}
+	// The payload is encoded now, as the caller may change data once
+	// Dispatch returns.
+	payload, err := json.Marshal(data)
+	if err != nil {
+		s.log.Error("Dispatch marshal event",
+			zap.Any("event", eventType),
+			zap.Error(err))
+		return
+	}
+	event.Data = json.RawMessage(payload)
+
+	select {
+	case s.events <- event:
+	default:
+		s.log.Error("Dispatch queue full",
+			zap.Any("event", eventType),
+			zap.Any("chat", chatID))
+	}
+}
+
+// queueEvents creates the deliveries of the dispatched events until the
+// service stops, then of those still waiting.
+func (s *webhookService) queueEvents() {
+	defer close(s.queued)
+
+	for {
+		select {
+		case event := <-s.events:
+			s.queue(event)
+		case <-s.stop:
+			for {
+				select {
+				case event := <-s.events:
+					s.queue(event)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// queue creates a pending delivery of event for every webhook subscribed
+// to it and wakes the worker.
+func (s *webhookService) queue(event webhookdomain.Event) {
+	ctx := context.Background()
+
+	webhooks, err := s.repo.GetWebhooksForEvent(ctx, event.ChatID, event.Type)
+	if err != nil {
+		s.log.Error("queue get webhooks",
+			zap.Any("event", event.Type),
+			zap.Any("chat", event.ChatID),
+			zap.Error(err))
+		return
+	}
+	if len(webhooks) == 0 {
+		return
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		s.log.Error("queue marshal event",
+			zap.Any("event", event.Type),
+			zap.Error(err))
+		return
+	}
+
+	for _, w := range webhooks {
+		if !w.Matches(event) {
+			continue
+		}
+		delivery := &webhookdomain.Delivery{
+			ID:            uuid.New().String(),
+			WebhookID:     w.ID,
+			EventID:       event.ID,
+			EventType:     event.Type,
+			Payload:       payload,
+			Status:        webhookdomain.DeliveryPending,
+			NextAttemptAt: event.OccurredAt,
+		}
+		if err := s.repo.CreateDelivery(ctx, delivery); err != nil {
+			s.log.Error("queue create delivery",
+				zap.Any("webhook", w.ID),
+				zap.Any("event", event.Type),
+				zap.Error(err))
+		}
+	}
+
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Stop implements service.WebhookService.
+func (s *webhookService) Stop() {
+	select {
+	case <-s.stop:
+	default:
+		close(s.stop)
+	}
+	<-s.queued
+	<-s.done
+}
+
+func (s *webhookService) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.cfg.PollInterval())
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-s.stop:
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+		s.deliverDue()
+	}
+}
+
+func (s *webhookService) deliverDue() {
+	ctx := context.Background()
+
+	deliveries, err := s.repo.ClaimDueDeliveries(ctx, claimBatchSize, 2*s.cfg.Timeout())
+	if err != nil {
+		s.log.Error("deliverDue claim", zap.Error(err))
+		return
+	}
+
+	// The batch is delivered concurrently, so that each delivery ends
+	// within the client timeout and well inside its lease, however many
+	// were claimed. One after another, the last ones would outlive their
+	// lease and be claimed again by another replica.
+	var wg sync.WaitGroup
+	for _, d := range deliveries {
+		wg.Go(func() {
+			s.deliver(ctx, d)
+		})
+	}
+	wg.Wait()
+}
+
+func (s *webhookService) deliver(ctx context.Context, d *webhookdomain.Delivery) {
+	webhook, err := s.repo.GetWebhook(ctx, d.WebhookID)
+	if err != nil {
+		s.log.Error("deliver get webhook",
+			zap.Any("delivery", d.ID),
+			zap.Any("webhook", d.WebhookID),
+			zap.Error(err))
+		return
+	}
+
+	d.Attempts++
+	code, err := s.post(ctx, webhook, d)
+	d.ResponseCode = code
+	now := time.Now().UTC()
+
+	switch {
+	case err == nil:
+		d.Status = webhookdomain.DeliveryDelivered
+		d.LastError = ""
+		d.DeliveredAt = &now
+	case d.Attempts >= s.cfg.MaxAttempts():
+		d.Status = webhookdomain.DeliveryDead
+		d.LastError = err.Error()
+		if err := s.repo.CreateDeadLetter(ctx, d); err != nil {
+			s.log.Error("deliver dead letter",
+				zap.Any("delivery", d.ID),
+				zap.Error(err))
+		}
+	default:
+		d.LastError = err.Error()
+		d.NextAttemptAt = now.Add(s.backoff(d.Attempts))
+	}
+
+	if err := s.repo.UpdateDelivery(ctx, d); err != nil {
+		s.log.Error("deliver update delivery",
+			zap.Any("delivery", d.ID),
+			zap.Error(err))
+	}
+}
+
+func (s *webhookService) post(ctx context.Context, webhook *webhookdomain.Webhook, d *webhookdomain.Delivery) (int, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.URL, bytes.NewReader(d.Payload))
+	if err != nil {
+		return 0, err
+	}
+
+	ts := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderEvent, string(d.EventType))
+	req.Header.Set(HeaderDelivery, d.ID)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(ts, 10))
+	req.Header.Set(HeaderSignature, Sign(webhook.Secret, ts, d.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponseError))
+		return resp.StatusCode, errors.New("receiver responded " + resp.Status + ": " + string(body))
+	}
+	io.Copy(io.Discard, resp.Body)
+
+	return resp.StatusCode, nil
+}
+
+// backoff doubles the base delay for every failed attempt, capped at the
+// configured maximum.
+func (s *webhookService) backoff(attempts int) time.Duration {
+	delay := s.cfg.BaseBackoff()
+	for i := 1; i < attempts; i++ {
+		delay *= 2
+		if delay >= s.cfg.MaxBackoff() {
+			return s.cfg.MaxBackoff()
+		}
+	}
+	return delay
+}
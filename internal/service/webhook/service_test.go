@@ -0,0 +1,390 @@
+package webhooksrv
+
+import (
+	webhookdomain "chatsrv/internal/domain/webhook"
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type testConfig struct {
+	maxAttempts int
+}
+
+func (c testConfig) MaxAttempts() int            { return c.maxAttempts }
+func (c testConfig) BaseBackoff() time.Duration  { return time.Millisecond }
+func (c testConfig) MaxBackoff() time.Duration   { return 5 * time.Millisecond }
+func (c testConfig) PollInterval() time.Duration { return 5 * time.Millisecond }
+func (c testConfig) Timeout() time.Duration      { return time.Second }
+
+// memoryRepository is an in-memory repository.WebhookRepository
+type memoryRepository struct {
+	mu          sync.Mutex
+	webhooks    map[string]*webhookdomain.Webhook
+	deliveries  map[string]*webhookdomain.Delivery
+	deadLetters []*webhookdomain.Delivery
+}
+
+func newMemoryRepository() *memoryRepository {
+	return &memoryRepository{
+		webhooks:   make(map[string]*webhookdomain.Webhook),
+		deliveries: make(map[string]*webhookdomain.Delivery),
+	}
+}
+
+func (r *memoryRepository) CreateWebhook(_ context.Context, w *webhookdomain.Webhook) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	w.CreatedAt = time.Now()
+	cp := *w
+	r.webhooks[w.ID] = &cp
+	return nil
+}
+
+func (r *memoryRepository) GetWebhooks(context.Context) ([]*webhookdomain.Webhook, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []*webhookdomain.Webhook
+	for _, w := range r.webhooks {
+		cp := *w
+		out = append(out, &cp)
+	}
+	return out, nil
+}
+
+func (r *memoryRepository) GetWebhook(_ context.Context, id string) (*webhookdomain.Webhook, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	w, ok := r.webhooks[id]
+	if !ok {
+		return nil, webhookdomain.ErrWebhookNotFound
+	}
+	cp := *w
+	return &cp, nil
+}
+
+func (r *memoryRepository) GetWebhooksForEvent(_ context.Context, chatID string, event webhookdomain.EventType) ([]*webhookdomain.Webhook, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []*webhookdomain.Webhook
+	for _, w := range r.webhooks {
+		if w.Matches(webhookdomain.Event{Type: event, ChatID: chatID}) {
+			cp := *w
+			out = append(out, &cp)
+		}
+	}
+	return out, nil
+}
+
+func (r *memoryRepository) DeleteWebhook(_ context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.webhooks, id)
+	return nil
+}
+
+func (r *memoryRepository) CreateDelivery(_ context.Context, d *webhookdomain.Delivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cp := *d
+	r.deliveries[d.ID] = &cp
+	return nil
+}
+
+func (r *memoryRepository) ClaimDueDeliveries(_ context.Context, limit int, lease time.Duration) ([]*webhookdomain.Delivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	now := time.Now()
+	var out []*webhookdomain.Delivery
+	for _, d := range r.deliveries {
+		if len(out) == limit {
+			break
+		}
+		if d.Status == webhookdomain.DeliveryPending && !d.NextAttemptAt.After(now) {
+			d.NextAttemptAt = now.Add(lease)
+			cp := *d
+			out = append(out, &cp)
+		}
+	}
+	return out, nil
+}
+
+func (r *memoryRepository) UpdateDelivery(_ context.Context, d *webhookdomain.Delivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cp := *d
+	r.deliveries[d.ID] = &cp
+	return nil
+}
+
+func (r *memoryRepository) GetDeliveries(_ context.Context, webhookID string, _ int) ([]*webhookdomain.Delivery, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []*webhookdomain.Delivery
+	for _, d := range r.deliveries {
+		if d.WebhookID == webhookID {
+			cp := *d
+			out = append(out, &cp)
+		}
+	}
+	return out, nil
+}
+
+func (r *memoryRepository) CreateDeadLetter(_ context.Context, d *webhookdomain.Delivery) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	cp := *d
+	r.deadLetters = append(r.deadLetters, &cp)
+	return nil
+}
+
+func (r *memoryRepository) deadLetterCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.deadLetters)
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	t.Fatal("condition not met before deadline")
+}
+
+// TestDispatchDeliversSignedPayload verifies the receiver gets a POST whose
+// signature header validates against the webhook secret
+func TestDispatchDeliversSignedPayload(t *testing.T) {
+	received := make(chan bool, 1)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		ts, _ := strconv.ParseInt(r.Header.Get(HeaderTimestamp), 10, 64)
+		received <- Verify("s3cret", ts, body, r.Header.Get(HeaderSignature)) &&
+			r.Header.Get(HeaderEvent) == string(webhookdomain.EventMessageCreated)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer receiver.Close()
+
+	repo := newMemoryRepository()
+	srv := NewWebhookService(repo, testConfig{maxAttempts: 3}, zap.NewNop())
+	defer srv.Stop()
+
+	webhook, err := srv.CreateWebhook(context.Background(), webhookdomain.CreateWebhookRequest{
+		URL:    receiver.URL,
+		Secret: "s3cret",
+		Events: []webhookdomain.EventType{webhookdomain.EventMessageCreated},
+	})
+	if err != nil {
+		t.Fatalf("CreateWebhook failed: %v", err)
+	}
+
+	srv.Dispatch(context.Background(), webhookdomain.EventMessageCreated, "chat-1", map[string]string{"content": "hi"})
+
+	select {
+	case ok := <-received:
+		if !ok {
+			t.Fatal("Receiver got an invalid signature or event header")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Receiver was not called")
+	}
+
+	waitFor(t, func() bool {
+		deliveries, _ := srv.GetDeliveries(context.Background(), webhook.ID, 0)
+		return len(deliveries) == 1 && deliveries[0].Status == webhookdomain.DeliveryDelivered
+	})
+}
+
+// TestDispatchSkipsUnsubscribedEvents verifies event filters are honoured
+func TestDispatchSkipsUnsubscribedEvents(t *testing.T) {
+	repo := newMemoryRepository()
+	srv := NewWebhookService(repo, testConfig{maxAttempts: 3}, zap.NewNop())
+	defer srv.Stop()
+
+	webhook, err := srv.CreateWebhook(context.Background(), webhookdomain.CreateWebhookRequest{
+		ChatID: "chat-1",
+		URL:    "http://127.0.0.1:1",
+		Events: []webhookdomain.EventType{webhookdomain.EventMemberJoined},
+	})
+	if err != nil {
+		t.Fatalf("CreateWebhook failed: %v", err)
+	}
+
+	srv.Dispatch(context.Background(), webhookdomain.EventMessageCreated, "chat-1", nil)
+	srv.Dispatch(context.Background(), webhookdomain.EventMemberJoined, "chat-2", nil)
+	srv.Stop()
+
+	deliveries, _ := srv.GetDeliveries(context.Background(), webhook.ID, 0)
+	if len(deliveries) != 0 {
+		t.Errorf("Expected no deliveries, got %d", len(deliveries))
+	}
+}
+
+// TestDispatchDoesNotWaitForRepository verifies events are queued in the
+// background, so a slow database does not hold up the caller
+func TestDispatchDoesNotWaitForRepository(t *testing.T) {
+	repo := newMemoryRepository()
+	srv := NewWebhookService(repo, testConfig{maxAttempts: 3}, zap.NewNop())
+	defer srv.Stop()
+
+	webhook, err := srv.CreateWebhook(context.Background(), webhookdomain.CreateWebhookRequest{
+		URL:    "http://127.0.0.1:1",
+		Events: []webhookdomain.EventType{webhookdomain.EventMessageCreated},
+	})
+	if err != nil {
+		t.Fatalf("CreateWebhook failed: %v", err)
+	}
+
+	repo.mu.Lock()
+	dispatched := make(chan struct{})
+	go func() {
+		srv.Dispatch(context.Background(), webhookdomain.EventMessageCreated, "chat-1", nil)
+		close(dispatched)
+	}()
+	select {
+	case <-dispatched:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Dispatch waited for the repository")
+	}
+	repo.mu.Unlock()
+
+	waitFor(t, func() bool {
+		deliveries, _ := srv.GetDeliveries(context.Background(), webhook.ID, 0)
+		return len(deliveries) == 1
+	})
+}
+
+// TestDispatchRetriesThenDeadLetters verifies failing receivers are retried
+// with backoff and end up in the dead-letter table
+func TestDispatchRetriesThenDeadLetters(t *testing.T) {
+	var (
+		mu    sync.Mutex
+		calls int
+	)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		http.Error(w, "boom", http.StatusInternalServerError)
+	}))
+	defer receiver.Close()
+
+	repo := newMemoryRepository()
+	srv := NewWebhookService(repo, testConfig{maxAttempts: 3}, zap.NewNop())
+	defer srv.Stop()
+
+	webhook, err := srv.CreateWebhook(context.Background(), webhookdomain.CreateWebhookRequest{
+		URL:    receiver.URL,
+		Events: []webhookdomain.EventType{webhookdomain.EventChatCreated},
+	})
+	if err != nil {
+		t.Fatalf("CreateWebhook failed: %v", err)
+	}
+
+	srv.Dispatch(context.Background(), webhookdomain.EventChatCreated, "chat-1", nil)
+
+	waitFor(t, func() bool { return repo.deadLetterCount() == 1 })
+
+	deliveries, _ := srv.GetDeliveries(context.Background(), webhook.ID, 0)
+	if len(deliveries) != 1 {
+		t.Fatalf("Expected 1 delivery, got %d", len(deliveries))
+	}
+	if deliveries[0].Status != webhookdomain.DeliveryDead || deliveries[0].Attempts != 3 {
+		t.Errorf("Expected dead delivery after 3 attempts, got %s after %d", deliveries[0].Status, deliveries[0].Attempts)
+	}
+	if deliveries[0].ResponseCode != http.StatusInternalServerError {
+		t.Errorf("Expected response code 500, got %d", deliveries[0].ResponseCode)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if calls != 3 {
+		t.Errorf("Expected 3 calls to receiver, got %d", calls)
+	}
+}
+
+// TestDeliverDueSendsBatchConcurrently verifies a claimed batch is
+// delivered at once rather than one after another, so that no delivery
+// waits past its lease for the ones before it
+func TestDeliverDueSendsBatchConcurrently(t *testing.T) {
+	const batch = 3
+	var (
+		mu      sync.Mutex
+		arrived int
+	)
+	all := make(chan struct{})
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		if arrived++; arrived == batch {
+			close(all)
+		}
+		mu.Unlock()
+
+		select {
+		case <-all:
+			w.WriteHeader(http.StatusNoContent)
+		case <-r.Context().Done():
+		}
+	}))
+	defer receiver.Close()
+
+	repo := newMemoryRepository()
+	webhook := &webhookdomain.Webhook{ID: "w1", URL: receiver.URL, Events: []webhookdomain.EventType{webhookdomain.EventChatCreated}}
+	repo.CreateWebhook(context.Background(), webhook)
+	for i := range batch {
+		repo.CreateDelivery(context.Background(), &webhookdomain.Delivery{
+			ID:        strconv.Itoa(i),
+			WebhookID: webhook.ID,
+			EventType: webhookdomain.EventChatCreated,
+			Status:    webhookdomain.DeliveryPending,
+		})
+	}
+
+	srv := NewWebhookService(repo, testConfig{maxAttempts: 3}, zap.NewNop())
+	defer srv.Stop()
+
+	waitFor(t, func() bool {
+		deliveries, _ := repo.GetDeliveries(context.Background(), webhook.ID, 0)
+		for _, d := range deliveries {
+			if d.Status != webhookdomain.DeliveryDelivered {
+				return false
+			}
+		}
+		return true
+	})
+
+	deliveries, _ := repo.GetDeliveries(context.Background(), webhook.ID, 0)
+	for _, d := range deliveries {
+		if d.Attempts != 1 {
+			t.Errorf("Expected delivery %s on the first attempt, got %d", d.ID, d.Attempts)
+		}
+	}
+}
+
+// TestCreateWebhookValidation verifies bad URLs and unknown events are rejected
+func TestCreateWebhookValidation(t *testing.T) {
+	srv := NewWebhookService(newMemoryRepository(), testConfig{maxAttempts: 1}, zap.NewNop())
+	defer srv.Stop()
+
+	cases := []webhookdomain.CreateWebhookRequest{
+		{URL: "ftp://example.com", Events: []webhookdomain.EventType{webhookdomain.EventChatCreated}},
+		{URL: "http://example.com"},
+		{URL: "http://example.com", Events: []webhookdomain.EventType{"chat.exploded"}},
+	}
+	for _, req := range cases {
+		if _, err := srv.CreateWebhook(context.Background(), req); err == nil {
+			t.Errorf("Expected validation error for %+v", req)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package webhooksrv
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+)
+
+const (
+	HeaderEvent     = "X-IChat-Event"
+	HeaderDelivery  = "X-IChat-Delivery"
+	HeaderTimestamp = "X-IChat-Timestamp"
+	HeaderSignature = "X-IChat-Signature"
+
+	signaturePrefix = "sha256="
+)
+
+// Sign returns the value of the signature header for body sent at timestamp.
+// The MAC covers "<timestamp>.<body>" so a captured request cannot be
+// replayed with a different timestamp.
+func Sign(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return signaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify reports whether signature is a valid Sign result for the inputs.
+func Verify(secret string, timestamp int64, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, timestamp, body)), []byte(signature))
+}
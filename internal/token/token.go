@@ -0,0 +1,26 @@
+package token
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+)
+
+const size = 32
+
+// New returns an unguessable URL-safe token with 256 bits of entropy.
+func New() (string, error) {
+	buf := make([]byte, size)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(buf), nil
+}
+
+// Hash returns the hex SHA-256 of token. Tokens are high-entropy so a plain
+// digest is enough to keep them useless if the table leaks.
+func Hash(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
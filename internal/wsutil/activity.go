@@ -0,0 +1,88 @@
+package wsutil
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Activity records when a connection last received anything. Pongs count
+// too, which is the point: the websocket package consumes them silently.
+type Activity struct {
+	last atomic.Int64
+}
+
+func (a *Activity) touch() {
+	a.last.Store(time.Now().UnixNano())
+}
+
+// Last returns when the connection last received data.
+func (a *Activity) Last() time.Time {
+	return time.Unix(0, a.last.Load())
+}
+
+type activityKey struct{}
+
+// ActivityFromContext returns the Activity of the connection upgraded by
+// a request passed through TrackActivity, or nil.
+func ActivityFromContext(ctx context.Context) *Activity {
+	activity, _ := ctx.Value(activityKey{}).(*Activity)
+	return activity
+}
+
+// TrackActivity wraps a WebSocket handler so that the connections it
+// upgrades record their reads in an Activity stored in the request context.
+func TrackActivity(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		activity := &Activity{}
+		activity.touch()
+		ctx := context.WithValue(r.Context(), activityKey{}, activity)
+		next.ServeHTTP(&activityWriter{ResponseWriter: w, activity: activity}, r.WithContext(ctx))
+	})
+}
+
+type activityWriter struct {
+	http.ResponseWriter
+	activity *Activity
+}
+
+// Hijack hands out the connection wrapped so that reads touch the Activity.
+// Bytes the server had already buffered are read first.
+func (w *activityWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("wsutil: response writer cannot be hijacked")
+	}
+	conn, rw, err := hijacker.Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tracked := &activityConn{Conn: conn, activity: w.activity}
+	buffered, err := rw.Reader.Peek(rw.Reader.Buffered())
+	if err != nil {
+		conn.Close()
+		return nil, nil, err
+	}
+	reader := io.MultiReader(bytes.NewReader(bytes.Clone(buffered)), tracked)
+	return tracked, bufio.NewReadWriter(bufio.NewReader(reader), rw.Writer), nil
+}
+
+type activityConn struct {
+	net.Conn
+	activity *Activity
+}
+
+func (c *activityConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 {
+		c.activity.touch()
+	}
+	return n, err
+}
@@ -0,0 +1,77 @@
+// Package wsutil fills gaps in golang.org/x/net/websocket.
+package wsutil
+
+import (
+	"encoding/binary"
+	"errors"
+	"os"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// Close status codes from RFC 6455 and the IANA registry.
+const (
+	CloseNormal          = 1000
+	CloseGoingAway       = 1001
+	CloseProtocolError   = 1002
+	ClosePolicyViolation = 1008
+	CloseTryAgainLater   = 1013
+)
+
+// maxCloseReason is what fits in a control frame after the status code.
+const maxCloseReason = 123
+
+type closeFrame struct {
+	code   int
+	reason string
+}
+
+// closeCodec sends a closeFrame as a close control frame. Going through a
+// Codec serializes it with the other writes on the connection.
+var closeCodec = websocket.Codec{
+	Marshal: func(v any) ([]byte, byte, error) {
+		frame := v.(closeFrame)
+		reason := frame.reason
+		if len(reason) > maxCloseReason {
+			reason = reason[:maxCloseReason]
+		}
+		data := make([]byte, 2, 2+len(reason))
+		binary.BigEndian.PutUint16(data, uint16(frame.code))
+		return append(data, reason...), websocket.CloseFrame, nil
+	},
+}
+
+// closeTimeout bounds how long Close waits to send its frame to a peer
+// that may be gone.
+const closeTimeout = time.Second
+
+// Close sends a close frame with code and reason and closes ws. The
+// websocket package only ever sends 1000 without a reason.
+func Close(ws *websocket.Conn, code int, reason string) error {
+	ws.SetWriteDeadline(time.Now().Add(closeTimeout))
+	err := closeCodec.Send(ws, closeFrame{code: code, reason: reason})
+	if cerr := closeConn(ws); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// closeConn closes the connection under ws without sending anything.
+// ws.Close would first send a close frame of its own, a second one after
+// Close's, so a write deadline in the past fails that frame, along with
+// any write stuck on the peer, before a byte of it goes out.
+func closeConn(ws *websocket.Conn) error {
+	ws.SetWriteDeadline(time.Unix(1, 0))
+	if err := ws.Close(); !errors.Is(err, os.ErrDeadlineExceeded) {
+		return err
+	}
+	return nil
+}
+
+// pingCodec sends its value as the payload of a ping control frame.
+var pingCodec = websocket.Codec{
+	Marshal: func(v any) ([]byte, byte, error) {
+		return v.([]byte), websocket.PingFrame, nil
+	},
+}
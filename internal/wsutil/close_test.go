@@ -0,0 +1,61 @@
+package wsutil
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// TestCloseSendsOneCloseFrame verifies the peer only gets the close frame
+// carrying the code, not the websocket package's own 1000 after it
+func TestCloseSendsOneCloseFrame(t *testing.T) {
+	server := httptest.NewServer(websocket.Handler(func(ws *websocket.Conn) {
+		Close(ws, ClosePolicyViolation, "bye")
+	}))
+	defer server.Close()
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(server.URL, "http://"))
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	io.WriteString(conn, "GET / HTTP/1.1\r\n"+
+		"Host: "+conn.RemoteAddr().String()+"\r\n"+
+		"Upgrade: websocket\r\n"+
+		"Connection: Upgrade\r\n"+
+		"Origin: http://localhost\r\n"+
+		"Sec-WebSocket-Key: dGhlIHNhbXBsZSBub25jZQ==\r\n"+
+		"Sec-WebSocket-Version: 13\r\n\r\n")
+
+	// The server closes the connection once done, so everything it sent is
+	// read, frames included.
+	data, err := io.ReadAll(conn)
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	_, frames, ok := bytes.Cut(data, []byte("\r\n\r\n"))
+	if !ok {
+		t.Fatalf("No handshake response in %q", data)
+	}
+
+	var codes []int
+	for len(frames) >= 2 {
+		opcode, length := frames[0]&0x0f, int(frames[1]&0x7f)
+		payload := frames[2 : 2+length]
+		if opcode == websocket.CloseFrame {
+			codes = append(codes, int(binary.BigEndian.Uint16(payload)))
+		}
+		frames = frames[2+length:]
+	}
+	if len(codes) != 1 || codes[0] != ClosePolicyViolation {
+		t.Errorf("Expected a single close frame with %d, got %v", ClosePolicyViolation, codes)
+	}
+}
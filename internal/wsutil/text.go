@@ -0,0 +1,17 @@
+package wsutil
+
+import "golang.org/x/net/websocket"
+
+// textCodec sends its value, already encoded, as a text frame.
+var textCodec = websocket.Codec{
+	Marshal: func(v any) ([]byte, byte, error) {
+		return v.([]byte), websocket.TextFrame, nil
+	},
+}
+
+// SendText sends data as a text frame. Unlike websocket.Message, it takes
+// the bytes as they are, so the same encoded frame can be written to many
+// connections without copying it.
+func SendText(ws *websocket.Conn, data []byte) error {
+	return textCodec.Send(ws, data)
+}
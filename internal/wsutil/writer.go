@@ -0,0 +1,59 @@
+package wsutil
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// Writer serializes the writes to a connection along with their deadlines.
+// The websocket package serializes the frames themselves, but a connection
+// has a single write deadline, which a writer could otherwise clear while
+// another one is blocked on a stalled peer.
+type Writer struct {
+	mu sync.Mutex
+	ws *websocket.Conn
+}
+
+func NewWriter(ws *websocket.Conn) *Writer {
+	return &Writer{ws: ws}
+}
+
+// SendText sends data as a text frame, giving up after timeout unless it is
+// zero.
+func (w *Writer) SendText(data []byte, timeout time.Duration) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if timeout > 0 {
+		w.ws.SetWriteDeadline(time.Now().Add(timeout))
+		defer w.ws.SetWriteDeadline(time.Time{})
+	}
+	return SendText(w.ws, data)
+}
+
+// Ping sends a ping frame, which the peer answers with a pong, giving up
+// after timeout. While another write is in progress it sends nothing and
+// reports false: the peer is being written to already.
+func (w *Writer) Ping(timeout time.Duration) (bool, error) {
+	if !w.mu.TryLock() {
+		return false, nil
+	}
+	defer w.mu.Unlock()
+
+	w.ws.SetWriteDeadline(time.Now().Add(timeout))
+	defer w.ws.SetWriteDeadline(time.Time{})
+	return true, pingCodec.Send(w.ws, []byte{})
+}
+
+// Close sends a close frame with code and reason and closes the connection.
+// While another write is in progress, possibly stuck on the peer, it closes
+// the connection without the frame, which fails that write.
+func (w *Writer) Close(code int, reason string) error {
+	if !w.mu.TryLock() {
+		return closeConn(w.ws)
+	}
+	defer w.mu.Unlock()
+	return Close(w.ws, code, reason)
+}
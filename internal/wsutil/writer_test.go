@@ -0,0 +1,52 @@
+package wsutil
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/net/websocket"
+)
+
+// TestWriterPingKeepsWriteDeadline verifies pings sent while a write is
+// stuck on a peer that does not read leave its deadline in place
+func TestWriterPingKeepsWriteDeadline(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	server := httptest.NewServer(websocket.Handler(func(ws *websocket.Conn) {
+		<-release
+	}))
+	defer server.Close()
+
+	ws, err := websocket.Dial("ws"+strings.TrimPrefix(server.URL, "http"), "", "http://localhost")
+	if err != nil {
+		t.Fatalf("Dial failed: %v", err)
+	}
+	defer ws.Close()
+	w := NewWriter(ws)
+
+	sent := make(chan error, 1)
+	go func() {
+		sent <- w.SendText(make([]byte, 16<<20), 200*time.Millisecond)
+	}()
+	go func() {
+		for {
+			select {
+			case <-release:
+				return
+			case <-time.After(10 * time.Millisecond):
+				w.Ping(time.Hour)
+			}
+		}
+	}()
+
+	select {
+	case err := <-sent:
+		if err == nil {
+			t.Fatal("Expected the write to time out")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("The write outlived its deadline")
+	}
+}
@@ -0,0 +1,49 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS webhooks (
+    id SERIAL PRIMARY KEY,
+    uuid VARCHAR(36) NOT NULL UNIQUE,
+    chat_id VARCHAR(36) REFERENCES chats(uuid) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    secret VARCHAR(128) NOT NULL,
+    events TEXT[] NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS webhooks_chat_id_idx ON webhooks (chat_id);
+
+CREATE TABLE IF NOT EXISTS webhook_deliveries (
+    id SERIAL PRIMARY KEY,
+    uuid VARCHAR(36) NOT NULL UNIQUE,
+    webhook_id VARCHAR(36) NOT NULL REFERENCES webhooks(uuid) ON DELETE CASCADE,
+    event_id VARCHAR(36) NOT NULL,
+    event_type VARCHAR(64) NOT NULL,
+    payload JSONB NOT NULL,
+    status VARCHAR(16) NOT NULL DEFAULT 'pending',
+    attempts INTEGER NOT NULL DEFAULT 0,
+    response_code INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    next_attempt_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    delivered_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook_id_idx ON webhook_deliveries (webhook_id, created_at DESC);
+
+CREATE TABLE IF NOT EXISTS webhook_dead_letters (
+    id SERIAL PRIMARY KEY,
+    delivery_id VARCHAR(36) NOT NULL REFERENCES webhook_deliveries(uuid) ON DELETE CASCADE,
+    webhook_id VARCHAR(36) NOT NULL REFERENCES webhooks(uuid) ON DELETE CASCADE,
+    payload JSONB NOT NULL,
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS webhook_dead_letters;
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhooks;
+-- +goose StatementEnd
@@ -0,0 +1,34 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS messages (
+    id SERIAL PRIMARY KEY,
+    uuid VARCHAR(36) NOT NULL UNIQUE,
+    chat_id VARCHAR(36) NOT NULL REFERENCES chats(uuid) ON DELETE CASCADE,
+    sender_id VARCHAR(255) NOT NULL,
+    username VARCHAR(255) NOT NULL DEFAULT '',
+    content TEXT NOT NULL,
+    attachments JSONB NOT NULL DEFAULT '[]',
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS messages_chat_id_created_at_idx ON messages (chat_id, created_at DESC);
+
+CREATE TABLE IF NOT EXISTS incoming_webhooks (
+    id SERIAL PRIMARY KEY,
+    uuid VARCHAR(36) NOT NULL UNIQUE,
+    chat_id VARCHAR(36) NOT NULL REFERENCES chats(uuid) ON DELETE CASCADE,
+    name VARCHAR(255) NOT NULL,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    rate_per_minute INTEGER NOT NULL,
+    burst INTEGER NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS incoming_webhooks_chat_id_idx ON incoming_webhooks (chat_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS incoming_webhooks;
+DROP TABLE IF EXISTS messages;
+-- +goose StatementEnd
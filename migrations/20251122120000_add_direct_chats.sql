@@ -0,0 +1,19 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE chats ADD COLUMN IF NOT EXISTS type VARCHAR(16) NOT NULL DEFAULT 'room';
+-- Direct chats store their participants ordered so the pair is unordered.
+ALTER TABLE chats ADD COLUMN IF NOT EXISTS dm_user_a VARCHAR(255);
+ALTER TABLE chats ADD COLUMN IF NOT EXISTS dm_user_b VARCHAR(255);
+
+CREATE UNIQUE INDEX IF NOT EXISTS chats_dm_pair_idx ON chats (dm_user_a, dm_user_b) WHERE type = 'dm';
+CREATE INDEX IF NOT EXISTS chats_dm_user_b_idx ON chats (dm_user_b) WHERE type = 'dm';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS chats_dm_user_b_idx;
+DROP INDEX IF EXISTS chats_dm_pair_idx;
+ALTER TABLE chats DROP COLUMN IF EXISTS dm_user_b;
+ALTER TABLE chats DROP COLUMN IF EXISTS dm_user_a;
+ALTER TABLE chats DROP COLUMN IF EXISTS type;
+-- +goose StatementEnd
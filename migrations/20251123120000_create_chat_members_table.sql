@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE chats ADD COLUMN IF NOT EXISTS visibility VARCHAR(16) NOT NULL DEFAULT 'public';
+
+CREATE TABLE IF NOT EXISTS chat_members (
+    chat_id VARCHAR(36) NOT NULL REFERENCES chats(uuid) ON DELETE CASCADE,
+    user_id VARCHAR(255) NOT NULL,
+    role VARCHAR(16) NOT NULL DEFAULT 'member',
+    joined_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (chat_id, user_id)
+);
+
+CREATE INDEX IF NOT EXISTS chat_members_user_id_idx ON chat_members (user_id);
+
+-- Direct chats become private chats whose members are the two participants.
+UPDATE chats SET visibility = 'private' WHERE type = 'dm';
+INSERT INTO chat_members (chat_id, user_id)
+SELECT uuid, dm_user_a FROM chats WHERE type = 'dm'
+UNION
+SELECT uuid, dm_user_b FROM chats WHERE type = 'dm'
+ON CONFLICT DO NOTHING;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS chat_members;
+ALTER TABLE chats DROP COLUMN IF EXISTS visibility;
+-- +goose StatementEnd
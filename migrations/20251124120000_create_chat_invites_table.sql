@@ -0,0 +1,37 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS chat_invites (
+    id SERIAL PRIMARY KEY,
+    uuid VARCHAR(36) NOT NULL UNIQUE,
+    chat_id VARCHAR(36) NOT NULL REFERENCES chats(uuid) ON DELETE CASCADE,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    created_by VARCHAR(255) NOT NULL,
+    role VARCHAR(16) NOT NULL DEFAULT 'member',
+    max_uses INTEGER NOT NULL DEFAULT 0,
+    uses INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP WITH TIME ZONE,
+    revoked_at TIMESTAMP WITH TIME ZONE,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS chat_invites_chat_id_idx ON chat_invites (chat_id);
+
+CREATE TABLE IF NOT EXISTS chat_invite_acceptances (
+    id SERIAL PRIMARY KEY,
+    invite_id VARCHAR(36) NOT NULL REFERENCES chat_invites(uuid) ON DELETE CASCADE,
+    chat_id VARCHAR(36) NOT NULL REFERENCES chats(uuid) ON DELETE CASCADE,
+    user_id VARCHAR(255) NOT NULL,
+    result VARCHAR(32) NOT NULL,
+    ip VARCHAR(64) NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    accepted_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS chat_invite_acceptances_invite_id_idx ON chat_invite_acceptances (invite_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS chat_invite_acceptances;
+DROP TABLE IF EXISTS chat_invites;
+-- +goose StatementEnd
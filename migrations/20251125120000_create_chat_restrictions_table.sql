@@ -0,0 +1,18 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE IF NOT EXISTS chat_restrictions (
+    chat_id VARCHAR(36) NOT NULL REFERENCES chats(uuid) ON DELETE CASCADE,
+    user_id VARCHAR(255) NOT NULL,
+    kind VARCHAR(16) NOT NULL,
+    reason TEXT NOT NULL DEFAULT '',
+    until TIMESTAMP WITH TIME ZONE,
+    created_by VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP WITH TIME ZONE DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (chat_id, user_id, kind)
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS chat_restrictions;
+-- +goose StatementEnd
@@ -0,0 +1,16 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE chats ADD COLUMN IF NOT EXISTS topic VARCHAR(255) NOT NULL DEFAULT '';
+ALTER TABLE chats ADD COLUMN IF NOT EXISTS description TEXT NOT NULL DEFAULT '';
+ALTER TABLE chats ADD COLUMN IF NOT EXISTS archived_at TIMESTAMP WITH TIME ZONE;
+-- Deleted chats are kept, with their history, until they are purged.
+ALTER TABLE chats ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE chats DROP COLUMN IF EXISTS deleted_at;
+ALTER TABLE chats DROP COLUMN IF EXISTS archived_at;
+ALTER TABLE chats DROP COLUMN IF EXISTS description;
+ALTER TABLE chats DROP COLUMN IF EXISTS topic;
+-- +goose StatementEnd
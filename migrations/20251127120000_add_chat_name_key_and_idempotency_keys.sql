@@ -0,0 +1,26 @@
+-- +goose Up
+-- +goose StatementBegin
+-- name_key is lower(name) when room names must be unique and NULL otherwise,
+-- so the constraint can be switched on without touching existing rooms.
+ALTER TABLE chats ADD COLUMN IF NOT EXISTS name_key VARCHAR(255);
+CREATE UNIQUE INDEX IF NOT EXISTS chats_name_key_idx ON chats (name_key) WHERE deleted_at IS NULL;
+
+CREATE TABLE IF NOT EXISTS idempotency_keys (
+    scope VARCHAR(64) NOT NULL,
+    user_id VARCHAR(255) NOT NULL,
+    key VARCHAR(255) NOT NULL,
+    request_hash VARCHAR(64) NOT NULL,
+    resource_id VARCHAR(36),
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (scope, user_id, key)
+);
+
+CREATE INDEX IF NOT EXISTS idempotency_keys_created_at_idx ON idempotency_keys (created_at);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS idempotency_keys;
+DROP INDEX IF EXISTS chats_name_key_idx;
+ALTER TABLE chats DROP COLUMN IF EXISTS name_key;
+-- +goose StatementEnd
@@ -0,0 +1,24 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE chats ADD COLUMN IF NOT EXISTS last_activity_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP;
+UPDATE chats SET last_activity_at = COALESCE(
+    (SELECT max(m.created_at) FROM messages m WHERE m.chat_id = chats.uuid),
+    chats.created_at,
+    chats.last_activity_at
+);
+
+CREATE INDEX IF NOT EXISTS chats_name_idx ON chats (lower(name), uuid) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS chats_created_at_idx ON chats (created_at DESC, uuid DESC) WHERE deleted_at IS NULL;
+CREATE INDEX IF NOT EXISTS chats_last_activity_at_idx ON chats (last_activity_at DESC, uuid DESC) WHERE deleted_at IS NULL;
+
+ALTER TABLE chat_members ADD COLUMN IF NOT EXISTS last_read_at TIMESTAMP WITH TIME ZONE;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE chat_members DROP COLUMN IF EXISTS last_read_at;
+DROP INDEX IF EXISTS chats_last_activity_at_idx;
+DROP INDEX IF EXISTS chats_created_at_idx;
+DROP INDEX IF EXISTS chats_name_idx;
+ALTER TABLE chats DROP COLUMN IF EXISTS last_activity_at;
+-- +goose StatementEnd
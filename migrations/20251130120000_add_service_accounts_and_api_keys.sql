@@ -0,0 +1,28 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users ADD COLUMN IF NOT EXISTS bot BOOLEAN NOT NULL DEFAULT false;
+ALTER TABLE messages ADD COLUMN IF NOT EXISTS bot BOOLEAN NOT NULL DEFAULT false;
+
+-- permissions is a comma-separated list of read, post and manage. A NULL
+-- chat_id lets the key act in every chat.
+CREATE TABLE IF NOT EXISTS api_keys (
+    id VARCHAR(36) PRIMARY KEY,
+    user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    name VARCHAR(64) NOT NULL,
+    chat_id VARCHAR(36) REFERENCES chats(uuid) ON DELETE CASCADE,
+    permissions VARCHAR(64) NOT NULL,
+    key_hash VARCHAR(64) NOT NULL UNIQUE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    revoked_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS api_keys_user_id_idx ON api_keys (user_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS api_keys;
+ALTER TABLE messages DROP COLUMN IF EXISTS bot;
+ALTER TABLE users DROP COLUMN IF EXISTS bot;
+-- +goose StatementEnd
@@ -0,0 +1,34 @@
+-- +goose Up
+-- +goose StatementBegin
+-- token_hash now holds the short-lived access token; expires_at is pushed
+-- forward on every refresh.
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS access_expires_at TIMESTAMP WITH TIME ZONE;
+UPDATE sessions SET access_expires_at = expires_at WHERE access_expires_at IS NULL;
+ALTER TABLE sessions ALTER COLUMN access_expires_at SET NOT NULL;
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS device_name VARCHAR(64) NOT NULL DEFAULT '';
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS ip VARCHAR(45) NOT NULL DEFAULT '';
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS user_agent TEXT NOT NULL DEFAULT '';
+ALTER TABLE sessions ADD COLUMN IF NOT EXISTS last_seen_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP;
+
+-- Used tokens are kept so that presenting one again can be told apart from
+-- presenting an unknown one.
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    id VARCHAR(36) PRIMARY KEY,
+    session_id VARCHAR(36) NOT NULL REFERENCES sessions(id) ON DELETE CASCADE,
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    used_at TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS refresh_tokens_session_id_idx ON refresh_tokens (session_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS refresh_tokens;
+ALTER TABLE sessions DROP COLUMN IF EXISTS last_seen_at;
+ALTER TABLE sessions DROP COLUMN IF EXISTS user_agent;
+ALTER TABLE sessions DROP COLUMN IF EXISTS ip;
+ALTER TABLE sessions DROP COLUMN IF EXISTS device_name;
+ALTER TABLE sessions DROP COLUMN IF EXISTS access_expires_at;
+-- +goose StatementEnd
@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE users ADD COLUMN IF NOT EXISTS role VARCHAR(16) NOT NULL DEFAULT 'user';
+
+-- A user can be linked to one account per OpenID Connect issuer.
+CREATE TABLE IF NOT EXISTS user_identities (
+    issuer VARCHAR(255) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    user_id VARCHAR(36) NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    created_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (issuer, subject)
+);
+
+CREATE INDEX IF NOT EXISTS user_identities_user_id_idx ON user_identities (user_id);
+
+-- Logins in progress. Rows are deleted when the provider calls back.
+CREATE TABLE IF NOT EXISTS oidc_login_states (
+    state VARCHAR(64) PRIMARY KEY,
+    nonce VARCHAR(64) NOT NULL,
+    verifier VARCHAR(128) NOT NULL,
+    expires_at TIMESTAMP WITH TIME ZONE NOT NULL
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS oidc_login_states;
+DROP TABLE IF EXISTS user_identities;
+ALTER TABLE users DROP COLUMN IF EXISTS role;
+-- +goose StatementEnd